@@ -13,8 +13,11 @@ import (
 	"strings"
 
 	"github.com/rvben/ru/internal/cache"
+	"github.com/rvben/ru/internal/config"
+	"github.com/rvben/ru/internal/packagemanager/pypi"
 	"github.com/rvben/ru/internal/update"
 	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // version is set during build
@@ -135,6 +138,15 @@ func printHelp(globalFlags *flag.FlagSet, updateFlags *flag.FlagSet) {
 	fmt.Println("  ru update -verify             Update with verification")
 	fmt.Println("  ru update -verbose            Update with verbose logging")
 	fmt.Println("  ru update -no-cache           Update without using cache")
+	fmt.Println("  ru update -latest             Update npm ranges beyond their major version")
+	fmt.Println("  ru update -align-workspaces   Use one version of each dependency in a workspace")
+	fmt.Println("  ru update -range-strategy widen  Extend version ranges to admit the latest version")
+	fmt.Println("  ru update -level minor        Only apply minor and patch updates")
+	fmt.Println("  ru update -allow-downgrade    Move pre-releases and yanked pins back to the latest release")
+	fmt.Println("  ru update -python 3.9         Only use releases that support Python 3.9")
+	fmt.Println("  ru update -require-wheels manylinux_2_17_x86_64,win_amd64")
+	fmt.Println("                                Only use releases with wheels for these platforms")
+	fmt.Println("  ru update -no-pypi-fallback   Only look Python packages up on the configured indexes")
 	fmt.Println("  ru update -verbose -verify    Combine multiple flags")
 	fmt.Println("  ru clean-cache -verbose       Use global flags with other commands")
 }
@@ -170,6 +182,15 @@ func main() {
 	updateFlags := flag.NewFlagSet("update", flag.ExitOnError)
 	verifyFlag := updateFlags.Bool("verify", false, "Verify dependency compatibility (slower)")
 	dryRunFlag := updateFlags.Bool("dry-run", false, "Show what would be updated without making changes")
+	latestFlag := updateFlags.Bool("latest", false, "Allow npm updates outside the declared range (e.g. ^1.4.0 -> ^2.0.0)")
+	alignWorkspacesFlag := updateFlags.Bool("align-workspaces", false, "Keep each npm dependency at one version across workspace packages")
+	rangeStrategyFlag := updateFlags.String("range-strategy", "", "How to update version ranges: pin, bump, widen or replace")
+	allowDowngradeFlag := updateFlags.Bool("allow-downgrade", false, "Allow moving dependencies to a lower version than the current one")
+	pythonFlag := updateFlags.String("python", "", "Python version the projects run on (default from requires-python or .python-version)")
+	requireWheelsFlag := updateFlags.String("require-wheels", "", "Comma-separated platform tags every Python release must ship wheels for, e.g. manylinux_2_17_x86_64,win_amd64")
+	indexStrategyFlag := updateFlags.String("index-strategy", "", "How Python packages are looked up across indexes: first-index, unsafe-first-match or unsafe-best-match (default from tool.uv.index-strategy, else first-index)")
+	noPyPIFallbackFlag := updateFlags.Bool("no-pypi-fallback", false, "Don't look Python packages up on PyPI when custom indexes don't have them (default on when the project's indexes replace PyPI; -no-pypi-fallback=false turns the fallback back on)")
+	levelFlag := updateFlags.String("level", "", "Highest kind of update to apply: patch, minor or major (default from "+config.FileName+", else major)")
 	// Add the global flags to the update command as well
 	updateVerboseFlag := updateFlags.Bool("verbose", false, "Enable verbose logging")
 	updateNoCacheFlag := updateFlags.Bool("no-cache", false, "Disable caching")
@@ -217,6 +238,74 @@ func main() {
 			updater.SetDryRun(true)
 		}
 
+		// Allow npm ranges to move to a new major version
+		if *latestFlag {
+			updater.SetLatest(true)
+		}
+		if *alignWorkspacesFlag {
+			updater.SetAlignWorkspaces(true)
+		}
+		if *allowDowngradeFlag {
+			updater.SetAllowDowngrade(true)
+		}
+		if *pythonFlag != "" {
+			target, err := pypi.ParsePythonVersion(*pythonFlag, "-python")
+			if err != nil {
+				utils.Error("%v", err)
+				os.Exit(2)
+			}
+			updater.SetPython(target)
+		}
+		if *requireWheelsFlag != "" {
+			var platforms []string
+			for _, platform := range strings.Split(*requireWheelsFlag, ",") {
+				platform = strings.TrimSpace(platform)
+				if platform == "" {
+					continue
+				}
+				if err := pypi.ValidatePlatformTag(platform); err != nil {
+					utils.Error("%v", err)
+					os.Exit(2)
+				}
+				platforms = append(platforms, platform)
+			}
+			updater.SetRequireWheels(platforms)
+		}
+		if *indexStrategyFlag != "" {
+			strategy, err := pypi.ParseIndexStrategy(*indexStrategyFlag)
+			if err != nil {
+				utils.Error("%v", err)
+				os.Exit(2)
+			}
+			updater.SetIndexStrategy(strategy)
+		}
+		// Projects whose indexes replace PyPI don't fall back to it unless
+		// the flag is given either way
+		updateFlags.Visit(func(f *flag.Flag) {
+			if f.Name == "no-pypi-fallback" {
+				updater.SetPyPIFallback(!*noPyPIFallbackFlag)
+			}
+		})
+		if *rangeStrategyFlag != "" {
+			strategy, err := versioning.ParseRangeStrategy(*rangeStrategyFlag)
+			if err != nil {
+				utils.Error("%v", err)
+				os.Exit(2)
+			}
+			updater.SetRangeStrategy(strategy)
+		}
+
+		// Update levels come from the configuration file of each path;
+		// -level overrides its default
+		if *levelFlag != "" {
+			level, err := versioning.ParseLevel(*levelFlag)
+			if err != nil {
+				utils.Error("%v", err)
+				os.Exit(2)
+			}
+			updater.SetLevel(level)
+		}
+
 		// Run the updater
 		if err := updater.Run(); err != nil {
 			utils.Error("Update failed: %v", err)
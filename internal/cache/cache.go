@@ -7,8 +7,6 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
-
-	"github.com/rvben/ru/internal/utils"
 )
 
 const cacheDirName = ".cache/ru"
@@ -138,8 +136,5 @@ func Clean() error {
 		}
 	}
 
-	// Also clear the version cache
-	utils.ClearVersionCache()
-
 	return nil
 }
@@ -0,0 +1,112 @@
+// Package config reads the project configuration of ru from a .ru.toml
+// file, e.g.
+//
+//	level = "minor"
+//
+//	[packages.django]
+//	level = "patch"
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// FileName is the name of the configuration file
+const FileName = ".ru.toml"
+
+// Config is the configuration of a project.
+type Config struct {
+	// Level caps the updates of every package: "patch", "minor" or "major"
+	Level string `toml:"level"`
+	// Packages holds the settings of single packages, keyed by name
+	Packages map[string]Package `toml:"packages"`
+
+	level    *versioning.Level
+	packages map[string]versioning.Level
+}
+
+// Package is the configuration of a single package.
+type Package struct {
+	// Level caps the updates of the package, overriding the project level
+	Level string `toml:"level"`
+}
+
+// Load reads the configuration file in dir. A missing file is an empty
+// configuration.
+func Load(dir string) (*Config, error) {
+	path := filepath.Join(dir, FileName)
+	c := &Config{}
+	if _, err := toml.DecodeFile(path, c); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// validate parses the levels of the configuration
+func (c *Config) validate() error {
+	if c.Level != "" {
+		level, err := versioning.ParseLevel(c.Level)
+		if err != nil {
+			return err
+		}
+		c.level = &level
+	}
+	c.packages = make(map[string]versioning.Level, len(c.Packages))
+	for name, pkg := range c.Packages {
+		if pkg.Level == "" {
+			continue
+		}
+		level, err := versioning.ParseLevel(pkg.Level)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", name, err)
+		}
+		c.packages[normalizeName(name)] = level
+	}
+	return nil
+}
+
+// SetLevel caps the updates of every package without a level of its own,
+// replacing the level of the file.
+func (c *Config) SetLevel(level versioning.Level) {
+	c.Level = level.String()
+	c.level = &level
+}
+
+// LevelFor returns the update level of a package: its own level if it has
+// one, else that of the project. It reports false if updates of the
+// package are not capped. A nil configuration caps nothing.
+func (c *Config) LevelFor(name string) (versioning.Level, bool) {
+	if c == nil {
+		return 0, false
+	}
+	if level, ok := c.packages[normalizeName(name)]; ok {
+		return level, true
+	}
+	if c.level != nil {
+		return *c.level, true
+	}
+	return 0, false
+}
+
+// nameSeparatorRegex matches the separators Python package names treat as equal
+var nameSeparatorRegex = regexp.MustCompile(`[-_.]+`)
+
+// normalizeName returns the name packages are matched by: lowercase, with
+// runs of "-", "_" and "." collapsed to "-", as PyPI compares names
+func normalizeName(name string) string {
+	return nameSeparatorRegex.ReplaceAllString(strings.ToLower(name), "-")
+}
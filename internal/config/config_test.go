@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rvben/ru/internal/versioning"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", FileName, err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeConfig(t, `level = "minor"
+
+[packages.Django]
+level = "patch"
+
+[packages."@types/node"]
+level = "major"
+`)
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		expected versioning.Level
+	}{
+		{"django", versioning.Patch},
+		{"DJANGO", versioning.Patch},
+		{"@types/node", versioning.Major},
+		{"requests", versioning.Minor},
+	}
+	for _, tt := range tests {
+		level, ok := c.LevelFor(tt.name)
+		if !ok || level != tt.expected {
+			t.Errorf("LevelFor(%q) = %s, %v, want %s", tt.name, level, ok, tt.expected)
+		}
+	}
+
+	c.SetLevel(versioning.Patch)
+	if level, _ := c.LevelFor("requests"); level != versioning.Patch {
+		t.Errorf("expected SetLevel to replace the project level, got %s", level)
+	}
+	if level, _ := c.LevelFor("@types/node"); level != versioning.Major {
+		t.Errorf("expected package levels to win over SetLevel, got %s", level)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	c, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := c.LevelFor("requests"); ok {
+		t.Error("expected no level without a configuration file")
+	}
+
+	var nilConfig *Config
+	if _, ok := nilConfig.LevelFor("requests"); ok {
+		t.Error("expected a nil configuration to cap nothing")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, content := range []string{
+		`level = "breaking"`,
+		"[packages.django]\nlevel = \"minor-ish\"\n",
+		`level = `,
+	} {
+		if _, err := Load(writeConfig(t, content)); err == nil {
+			t.Errorf("expected Load to fail for %q", content)
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"sort"
 	"strings"
 
-	semv "github.com/Masterminds/semver/v3"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // Node represents a package in the dependency graph
@@ -21,12 +21,15 @@ type Node struct {
 // Graph represents the dependency graph
 type Graph struct {
 	Nodes map[string]*Node
+	// scheme is the version scheme of the packages and their constraints
+	scheme versioning.Scheme
 }
 
-// New creates a new dependency graph
-func New() *Graph {
+// New creates a new dependency graph for packages versioned by scheme
+func New(scheme versioning.Scheme) *Graph {
 	return &Graph{
-		Nodes: make(map[string]*Node),
+		Nodes:  make(map[string]*Node),
+		scheme: scheme,
 	}
 }
 
@@ -138,7 +141,7 @@ func (g *Graph) ValidateUpdate(name, newVersion string) error {
 		return fmt.Errorf("package %s not found in graph", name)
 	}
 
-	v, err := semv.NewVersion(newVersion)
+	v, err := g.scheme.Parse(newVersion)
 	if err != nil {
 		return fmt.Errorf("invalid version %s: %w", newVersion, err)
 	}
@@ -150,38 +153,24 @@ func (g *Graph) ValidateUpdate(name, newVersion string) error {
 			continue
 		}
 
-		// Handle different constraint formats
-		var c *semv.Constraints
-		if strings.HasPrefix(constraint, "~=") {
-			// Handle PEP 440 compatible release operator
-			baseVersion := strings.TrimPrefix(constraint, "~=")
-			parts := strings.Split(baseVersion, ".")
-			if len(parts) >= 2 {
-				// ~=2.2 means >=2.2,<3.0
-				c, err = semv.NewConstraint(fmt.Sprintf(">=%s,<%d.0", baseVersion, semv.MustParse(parts[0]).Major()+1))
-			} else {
-				return fmt.Errorf("invalid compatible release constraint: %s", constraint)
-			}
-		} else if strings.HasPrefix(constraint, "==") {
+		if current, ok := strings.CutPrefix(constraint, "=="); ok && !strings.ContainsAny(current, ",*") {
 			// For exact version constraints, we want to allow updates to newer versions
-			currentVersion := strings.TrimPrefix(constraint, "==")
-			cv, err := semv.NewVersion(currentVersion)
+			cv, err := g.scheme.Parse(current)
 			if err != nil {
 				return fmt.Errorf("invalid version in constraint %s: %w", constraint, err)
 			}
 			// Allow the update if the new version is greater
-			if !v.GreaterThan(cv) {
-				return fmt.Errorf("new version %s is not greater than current version %s", newVersion, currentVersion)
+			if g.scheme.Compare(v, cv) <= 0 {
+				return fmt.Errorf("new version %s is not greater than current version %s", newVersion, current)
 			}
 			continue
-		} else {
-			c, err = semv.NewConstraint(constraint)
 		}
+
+		ok, err := g.scheme.Satisfies(v, constraint)
 		if err != nil {
 			return fmt.Errorf("invalid constraint %s: %w", constraint, err)
 		}
-
-		if !c.Check(v) {
+		if !ok {
 			return fmt.Errorf("version %s violates constraint %s", newVersion, constraint)
 		}
 	}
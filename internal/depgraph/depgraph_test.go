@@ -3,6 +3,8 @@ package depgraph
 import (
 	"reflect"
 	"testing"
+
+	"github.com/rvben/ru/internal/versioning"
 )
 
 func TestDetectCycles(t *testing.T) {
@@ -46,7 +48,7 @@ func TestDetectCycles(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := New()
+			g := New(versioning.PEP440)
 			for from, deps := range tt.dependencies {
 				for to, constraint := range deps {
 					if err := g.AddDependency(from, to, constraint); err != nil {
@@ -66,13 +68,15 @@ func TestDetectCycles(t *testing.T) {
 func TestValidateUpdate(t *testing.T) {
 	tests := []struct {
 		name          string
+		scheme        versioning.Scheme
 		dependencies  map[string]map[string]string
 		updatePackage string
 		newVersion    string
 		expectError   bool
 	}{
 		{
-			name: "valid update",
+			name:   "valid update",
+			scheme: versioning.PEP440,
 			dependencies: map[string]map[string]string{
 				"A": {"B": ">=1.0.0"},
 				"C": {"B": ">=1.0.0,<3.0.0"},
@@ -82,7 +86,8 @@ func TestValidateUpdate(t *testing.T) {
 			expectError:   false,
 		},
 		{
-			name: "invalid update - violates constraint",
+			name:   "invalid update - violates constraint",
+			scheme: versioning.PEP440,
 			dependencies: map[string]map[string]string{
 				"A": {"B": ">=1.0.0,<2.0.0"},
 				"C": {"B": ">=1.0.0,<3.0.0"},
@@ -92,7 +97,8 @@ func TestValidateUpdate(t *testing.T) {
 			expectError:   true,
 		},
 		{
-			name: "compatible release operator",
+			name:   "compatible release operator",
+			scheme: versioning.PEP440,
 			dependencies: map[string]map[string]string{
 				"A": {"B": "~=2.2"},
 			},
@@ -101,7 +107,8 @@ func TestValidateUpdate(t *testing.T) {
 			expectError:   false,
 		},
 		{
-			name: "compatible release violation",
+			name:   "compatible release violation",
+			scheme: versioning.PEP440,
 			dependencies: map[string]map[string]string{
 				"A": {"B": "~=2.2"},
 			},
@@ -109,11 +116,73 @@ func TestValidateUpdate(t *testing.T) {
 			newVersion:    "3.0.0",
 			expectError:   true,
 		},
+		{
+			name:   "npm caret and union ranges",
+			scheme: versioning.NodeSemver,
+			dependencies: map[string]map[string]string{
+				"A": {"B": "^17.0.0 || ^18.0.0"},
+				"C": {"B": "16 - 18"},
+			},
+			updatePackage: "B",
+			newVersion:    "18.2.0",
+			expectError:   false,
+		},
+		{
+			name:   "npm range excludes prereleases",
+			scheme: versioning.NodeSemver,
+			dependencies: map[string]map[string]string{
+				"A": {"B": "^1.2.0"},
+			},
+			updatePackage: "B",
+			newVersion:    "1.3.0-rc.1",
+			expectError:   true,
+		},
+		{
+			name:   "compatible release on three components",
+			scheme: versioning.PEP440,
+			dependencies: map[string]map[string]string{
+				"A": {"B": "~=1.4.2"},
+			},
+			updatePackage: "B",
+			newVersion:    "1.5.0",
+			expectError:   true,
+		},
+		{
+			name:   "excluded version",
+			scheme: versioning.PEP440,
+			dependencies: map[string]map[string]string{
+				"A": {"B": ">=1.0,!=1.5.*"},
+			},
+			updatePackage: "B",
+			newVersion:    "1.5.2",
+			expectError:   true,
+		},
+		{
+			name:   "prefix match with epoch and post release",
+			scheme: versioning.PEP440,
+			dependencies: map[string]map[string]string{
+				"A": {"B": "==1!2.*"},
+				"C": {"B": ">1!2.0"},
+			},
+			updatePackage: "B",
+			newVersion:    "1!2.1.post1",
+			expectError:   false,
+		},
+		{
+			name:   "python range excludes prereleases",
+			scheme: versioning.PEP440,
+			dependencies: map[string]map[string]string{
+				"A": {"B": ">=1.0,<3.0"},
+			},
+			updatePackage: "B",
+			newVersion:    "2.0rc1",
+			expectError:   true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := New()
+			g := New(tt.scheme)
 			for from, deps := range tt.dependencies {
 				for to, constraint := range deps {
 					if err := g.AddDependency(from, to, constraint); err != nil {
@@ -159,7 +228,7 @@ func TestGetUpdateOrder(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := New()
+			g := New(versioning.PEP440)
 			for from, deps := range tt.dependencies {
 				for to, constraint := range deps {
 					if err := g.AddDependency(from, to, constraint); err != nil {
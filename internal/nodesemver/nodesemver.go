@@ -0,0 +1,657 @@
+// Package nodesemver implements npm's semantic versioning rules: version
+// parsing and precedence, and range matching with the same semantics as the
+// node-semver package (||, hyphen ranges, x-ranges, ~, ^ and the prerelease
+// rules), so ranges in package.json mean exactly what npm takes them to mean.
+package nodesemver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version as understood by npm.
+type Version struct {
+	Major, Minor, Patch uint64
+	// Prerelease holds the dot-separated prerelease identifiers, e.g. ["beta", "2"]
+	Prerelease []string
+	// Build holds the build metadata identifiers, which never affect precedence
+	Build []string
+}
+
+// identifier matches a single prerelease identifier
+const identifier = `(?:0|[1-9]\d*|\d*[a-zA-Z-][a-zA-Z0-9-]*)`
+
+var versionRegex = regexp.MustCompile(`^[v=\s]*(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-(` + identifier + `(?:\.` + identifier + `)*))?` +
+	`(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
+
+// partialRegex matches the possibly incomplete versions used inside ranges,
+// e.g. "1", "1.2", "1.x", "*" or "1.2.3-beta"
+var partialRegex = regexp.MustCompile(`^[v=\s]*(\d+|[xX*])(?:\.(\d+|[xX*])(?:\.(\d+|[xX*])` +
+	`(?:-?(` + identifier + `(?:\.` + identifier + `)*))?` +
+	`(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?)?)?$`)
+
+// Parse parses a version such as "1.2.3", "v1.2.3" or "1.2.3-beta.1+build".
+func Parse(s string) (*Version, error) {
+	m := versionRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, fmt.Errorf("invalid version: %q", s)
+	}
+	v := &Version{}
+	var err error
+	if v.Major, err = strconv.ParseUint(m[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid major version in %q: %w", s, err)
+	}
+	if v.Minor, err = strconv.ParseUint(m[2], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid minor version in %q: %w", s, err)
+	}
+	if v.Patch, err = strconv.ParseUint(m[3], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid patch version in %q: %w", s, err)
+	}
+	if m[4] != "" {
+		v.Prerelease = strings.Split(m[4], ".")
+	}
+	if m[5] != "" {
+		v.Build = strings.Split(m[5], ".")
+	}
+	return v, nil
+}
+
+// coerceRegex finds the first version-like sequence in a string
+var coerceRegex = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// Coerce is npm's semver.coerce: it turns the first version-like part of s
+// into a version, filling in missing parts with zero, so "v2" becomes 2.0.0
+// and "2.31.0.post1" becomes 2.31.0. Prerelease and build data are dropped.
+func Coerce(s string) (*Version, error) {
+	m := coerceRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid version: %q", s)
+	}
+	var nums [3]uint64
+	for i, p := range m[1:] {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		nums[i] = n
+	}
+	return &Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// MustParse is like Parse but panics on invalid input. It is meant for
+// constants and tests.
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the canonical form of the version, without build metadata.
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	return s
+}
+
+// IsPrerelease reports whether the version has prerelease identifiers.
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or 1 depending on whether v precedes, equals or
+// follows other. Build metadata is ignored.
+func (v *Version) Compare(other *Version) int {
+	if c := compareUint(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// sameTuple reports whether both versions share major, minor and patch
+func (v *Version) sameTuple(other *Version) bool {
+	return v.Major == other.Major && v.Minor == other.Minor && v.Patch == other.Patch
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares prerelease identifiers. A version without
+// prerelease has higher precedence than one with.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+// compareIdentifier compares prerelease identifiers: numeric identifiers
+// compare numerically and sort before alphanumeric ones
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// comparator is a single primitive comparison such as ">=1.2.3". A nil
+// version matches any version.
+type comparator struct {
+	op      string
+	version *Version
+}
+
+func (c comparator) test(v *Version) bool {
+	if c.version == nil {
+		return true
+	}
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "", "=":
+		return cmp == 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func (c comparator) String() string {
+	if c.version == nil {
+		return ""
+	}
+	return c.op + c.version.String()
+}
+
+// Range is a parsed npm version range: a union of comparator sets.
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+var (
+	orRegex = regexp.MustCompile(`\s*\|\|\s*`)
+	// hyphenRegex matches "A - B" hyphen ranges
+	hyphenRegex = regexp.MustCompile(`^\s*(\S+)\s+-\s+(\S+)\s*$`)
+	// operatorSpaceRegex removes spaces between an operator and its version, "> 1.2" -> ">1.2"
+	operatorSpaceRegex = regexp.MustCompile(`(~>?|\^|[<>]=?|=)\s+`)
+	// operatorRegex splits a comparator into operator and partial version
+	operatorRegex = regexp.MustCompile(`^(~>?|\^|[<>]=?|=)?(.*)$`)
+)
+
+// ParseRange parses a range such as "^1.2.3", ">=1.0.0 <2.0.0 || 3.x" or
+// "1.2 - 2". An empty range matches every release.
+func ParseRange(s string) (*Range, error) {
+	r := &Range{raw: s}
+	for _, part := range orRegex.Split(strings.TrimSpace(s), -1) {
+		set, err := parseComparatorSet(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// MustParseRange is like ParseRange but panics on invalid input.
+func MustParseRange(s string) *Range {
+	r, err := ParseRange(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String returns the range as it was written.
+func (r *Range) String() string {
+	return r.raw
+}
+
+// Desugared returns the range expanded into primitive comparators, the way
+// node-semver prints it, e.g. "^1.2.3" -> ">=1.2.3 <2.0.0-0".
+func (r *Range) Desugared() string {
+	sets := make([]string, 0, len(r.sets))
+	for _, set := range r.sets {
+		parts := make([]string, 0, len(set))
+		for _, c := range set {
+			if s := c.String(); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "*")
+		}
+		sets = append(sets, strings.Join(parts, " "))
+	}
+	return strings.Join(sets, " || ")
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	s = strings.TrimSpace(s)
+	if m := hyphenRegex.FindStringSubmatch(s); m != nil {
+		return hyphenRange(m[1], m[2])
+	}
+
+	s = operatorSpaceRegex.ReplaceAllString(s, "$1")
+	var set []comparator
+	for _, token := range strings.Fields(s) {
+		comps, err := desugar(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, comps...)
+	}
+	if len(set) == 0 {
+		set = append(set, comparator{})
+	}
+	return set, nil
+}
+
+// partial is a possibly incomplete version; missing or wildcard parts are ""
+type partial struct {
+	major, minor, patch string
+	prerelease          string
+}
+
+func parsePartial(s string) (partial, error) {
+	m := partialRegex.FindStringSubmatch(s)
+	if m == nil {
+		return partial{}, fmt.Errorf("invalid version %q", s)
+	}
+	p := partial{major: m[1], minor: m[2], patch: m[3], prerelease: m[4]}
+	for _, f := range []*string{&p.major, &p.minor, &p.patch} {
+		if isX(*f) {
+			*f = ""
+		}
+	}
+	return p, nil
+}
+
+func isX(s string) bool {
+	return s == "" || s == "x" || s == "X" || s == "*"
+}
+
+// num converts a numeric partial component, treating wildcards as zero
+func num(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+// version builds a full version from numeric parts and an optional prerelease
+func version(major, minor, patch uint64, prerelease string) *Version {
+	v := &Version{Major: major, Minor: minor, Patch: patch}
+	if prerelease != "" {
+		v.Prerelease = strings.Split(prerelease, ".")
+	}
+	return v
+}
+
+// upper returns the exclusive upper bound "<M.m.p-0", which also keeps out
+// prereleases of that version
+func upper(major, minor, patch uint64) comparator {
+	return comparator{op: "<", version: version(major, minor, patch, "0")}
+}
+
+func desugar(token string) ([]comparator, error) {
+	m := operatorRegex.FindStringSubmatch(token)
+	op, rest := m[1], m[2]
+	p, err := parsePartial(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "~", "~>":
+		return tilde(p), nil
+	case "^":
+		return caret(p), nil
+	}
+	return xRange(op, p), nil
+}
+
+// tilde desugars "~1.2.3" to ">=1.2.3 <1.3.0-0": patch-level changes if a
+// minor version is given, minor-level changes otherwise
+func tilde(p partial) []comparator {
+	M, m, pt := num(p.major), num(p.minor), num(p.patch)
+	switch {
+	case p.major == "":
+		return []comparator{{}}
+	case p.minor == "":
+		return []comparator{{op: ">=", version: version(M, 0, 0, "")}, upper(M+1, 0, 0)}
+	case p.patch == "":
+		return []comparator{{op: ">=", version: version(M, m, 0, "")}, upper(M, m+1, 0)}
+	}
+	return []comparator{{op: ">=", version: version(M, m, pt, p.prerelease)}, upper(M, m+1, 0)}
+}
+
+// caret desugars "^1.2.3" to ">=1.2.3 <2.0.0-0": changes that do not modify
+// the left-most non-zero component
+func caret(p partial) []comparator {
+	M, m, pt := num(p.major), num(p.minor), num(p.patch)
+	switch {
+	case p.major == "":
+		return []comparator{{}}
+	case p.minor == "":
+		return []comparator{{op: ">=", version: version(M, 0, 0, "")}, upper(M+1, 0, 0)}
+	case p.patch == "":
+		if M == 0 {
+			return []comparator{{op: ">=", version: version(M, m, 0, "")}, upper(M, m+1, 0)}
+		}
+		return []comparator{{op: ">=", version: version(M, m, 0, "")}, upper(M+1, 0, 0)}
+	}
+
+	lower := comparator{op: ">=", version: version(M, m, pt, p.prerelease)}
+	switch {
+	case M == 0 && m == 0:
+		return []comparator{lower, upper(M, m, pt+1)}
+	case M == 0:
+		return []comparator{lower, upper(M, m+1, 0)}
+	}
+	return []comparator{lower, upper(M+1, 0, 0)}
+}
+
+// xRange desugars comparators with wildcard or missing components, e.g.
+// "1.x" -> ">=1.0.0 <2.0.0-0" and ">1.2" -> ">=1.3.0"
+func xRange(op string, p partial) []comparator {
+	M, m, pt := num(p.major), num(p.minor), num(p.patch)
+	xM := p.major == ""
+	xm := xM || p.minor == ""
+	xp := xm || p.patch == ""
+
+	if op == "=" && xp {
+		op = ""
+	}
+
+	switch {
+	case xM:
+		if op == ">" || op == "<" {
+			// Nothing is allowed
+			return []comparator{{op: "<", version: version(0, 0, 0, "0")}}
+		}
+		return []comparator{{}}
+	case op != "" && xp:
+		if xm {
+			m = 0
+		}
+		pt = 0
+		switch op {
+		case ">":
+			// ">1" -> ">=2.0.0", ">1.2" -> ">=1.3.0"
+			op = ">="
+			if xm {
+				M++
+				m = 0
+			} else {
+				m++
+			}
+		case "<=":
+			// "<=1" -> "<2.0.0-0", "<=1.2" -> "<1.3.0-0"
+			op = "<"
+			if xm {
+				M++
+			} else {
+				m++
+			}
+		}
+		if op == "<" {
+			return []comparator{upper(M, m, pt)}
+		}
+		return []comparator{{op: op, version: version(M, m, pt, "")}}
+	case xm:
+		return []comparator{{op: ">=", version: version(M, 0, 0, "")}, upper(M+1, 0, 0)}
+	case xp:
+		return []comparator{{op: ">=", version: version(M, m, 0, "")}, upper(M, m+1, 0)}
+	}
+	return []comparator{{op: op, version: version(M, m, pt, p.prerelease)}}
+}
+
+// hyphenRange desugars "1.2.3 - 2.3.4" to ">=1.2.3 <=2.3.4". Missing parts
+// of the lower bound are zero; the upper bound accepts everything matching
+// its given parts, so "1.2 - 2.3" means ">=1.2.0 <2.4.0-0".
+func hyphenRange(from, to string) ([]comparator, error) {
+	f, err := parsePartial(from)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parsePartial(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var set []comparator
+	switch {
+	case f.major == "":
+	case f.minor == "":
+		set = append(set, comparator{op: ">=", version: version(num(f.major), 0, 0, "")})
+	case f.patch == "":
+		set = append(set, comparator{op: ">=", version: version(num(f.major), num(f.minor), 0, "")})
+	default:
+		set = append(set, comparator{op: ">=", version: version(num(f.major), num(f.minor), num(f.patch), f.prerelease)})
+	}
+
+	switch {
+	case t.major == "":
+	case t.minor == "":
+		set = append(set, upper(num(t.major)+1, 0, 0))
+	case t.patch == "":
+		set = append(set, upper(num(t.major), num(t.minor)+1, 0))
+	default:
+		set = append(set, comparator{op: "<=", version: version(num(t.major), num(t.minor), num(t.patch), t.prerelease)})
+	}
+
+	if len(set) == 0 {
+		set = append(set, comparator{})
+	}
+	return set, nil
+}
+
+// Test reports whether v satisfies the range. Following npm, a prerelease
+// version only satisfies a comparator set if one of its comparators names a
+// prerelease of the same major.minor.patch, so "^1.2.3-beta.1" accepts
+// "1.2.3-beta.2" but not "1.2.4-beta.1".
+func (r *Range) Test(v *Version) bool {
+	for _, set := range r.sets {
+		if testSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func testSet(set []comparator, v *Version) bool {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+	if !v.IsPrerelease() {
+		return true
+	}
+	for _, c := range set {
+		if c.version != nil && c.version.IsPrerelease() && c.version.sameTuple(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSatisfying returns the highest of versions that satisfies the range,
+// or "" if none does. Unparsable versions are ignored.
+func (r *Range) MaxSatisfying(versions []string) string {
+	var best *Version
+	bestRaw := ""
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil || !r.Test(v) {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, bestRaw = v, raw
+		}
+	}
+	return bestRaw
+}
+
+// MinVersion returns the lowest version that can possibly satisfy the range,
+// or nil if the range can never be satisfied.
+func (r *Range) MinVersion() *Version {
+	for _, candidate := range []*Version{version(0, 0, 0, ""), version(0, 0, 0, "0")} {
+		if r.Test(candidate) {
+			return candidate
+		}
+	}
+
+	var min *Version
+	for _, set := range r.sets {
+		var setMin *Version
+		for _, c := range set {
+			if c.version == nil {
+				continue
+			}
+			var candidate *Version
+			switch c.op {
+			case ">":
+				next := *c.version
+				if next.IsPrerelease() {
+					next.Prerelease = append(append([]string{}, next.Prerelease...), "0")
+				} else {
+					next.Patch++
+				}
+				candidate = &next
+			case "", "=", ">=":
+				candidate = c.version
+			default:
+				continue
+			}
+			if setMin == nil || candidate.Compare(setMin) > 0 {
+				setMin = candidate
+			}
+		}
+		if setMin != nil && testSet(set, setMin) && (min == nil || setMin.Compare(min) < 0) {
+			min = setMin
+		}
+	}
+	return min
+}
+
+// Intersects reports whether some version could satisfy both ranges, e.g.
+// ">=18" and "^18.17.0 || >=20" intersect while "18.x" and ">=20" do not.
+// Like node-semver it compares the comparators pairwise, so prerelease
+// restrictions are not taken into account.
+func (r *Range) Intersects(other *Range) bool {
+	for _, a := range r.sets {
+		if !setIntersects(a, a) {
+			continue
+		}
+		for _, b := range other.sets {
+			if setIntersects(b, b) && setIntersects(a, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setIntersects reports whether every comparator of a intersects every
+// comparator of b
+func setIntersects(a, b []comparator) bool {
+	for _, ca := range a {
+		for _, cb := range b {
+			if !ca.intersects(cb) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// intersects reports whether some version satisfies both comparators
+func (c comparator) intersects(o comparator) bool {
+	if c.version == nil || o.version == nil {
+		return true
+	}
+	if c.op == "" || c.op == "=" {
+		return o.test(c.version)
+	}
+	if o.op == "" || o.op == "=" {
+		return c.test(o.version)
+	}
+
+	up, otherUp := c.op[0] == '>', o.op[0] == '>'
+	if up == otherUp {
+		// Both are lower bounds or both are upper bounds
+		return true
+	}
+	cmp := c.version.Compare(o.version)
+	if cmp == 0 {
+		return strings.HasSuffix(c.op, "=") && strings.HasSuffix(o.op, "=")
+	}
+	// A lower bound below an upper bound leaves room in between
+	return (up && cmp < 0) || (!up && cmp > 0)
+}
+
+// Satisfies reports whether version satisfies rng.
+func Satisfies(version, rng string) (bool, error) {
+	v, err := Parse(version)
+	if err != nil {
+		return false, err
+	}
+	r, err := ParseRange(rng)
+	if err != nil {
+		return false, err
+	}
+	return r.Test(v), nil
+}
+
+// MaxSatisfying returns the highest of versions that satisfies rng.
+func MaxSatisfying(versions []string, rng string) (string, error) {
+	r, err := ParseRange(rng)
+	if err != nil {
+		return "", err
+	}
+	return r.MaxSatisfying(versions), nil
+}
@@ -0,0 +1,396 @@
+package nodesemver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{"=1.2.3", "1.2.3", true},
+		{"1.2.3-beta.1", "1.2.3-beta.1", true},
+		{"1.2.3+build.5", "1.2.3", true},
+		{"1.2.3-rc.1+build", "1.2.3-rc.1", true},
+		{"1.2", "", false},
+		{"01.2.3", "", false},
+		{"1.2.3.4", "", false},
+		{"latest", "", false},
+	}
+
+	for _, tt := range tests {
+		v, err := Parse(tt.input)
+		if (err == nil) != tt.valid {
+			t.Errorf("Parse(%q) error = %v, want valid=%v", tt.input, err, tt.valid)
+			continue
+		}
+		if err == nil && v.String() != tt.expected {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, v, tt.expected)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Each version is lower than the next one
+	ordered := []string{
+		"0.0.0",
+		"0.0.1-0",
+		"0.0.1",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, b := MustParse(ordered[i]), MustParse(ordered[i+1])
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("expected %s < %s", a, b)
+		}
+	}
+	if MustParse("1.0.0+a").Compare(MustParse("1.0.0+b")) != 0 {
+		t.Error("build metadata must not affect precedence")
+	}
+}
+
+func TestRangeIncludes(t *testing.T) {
+	tests := []struct {
+		rng     string
+		version string
+	}{
+		{"1.0.0 - 2.0.0", "1.2.3"},
+		{"^1.2.3+build", "1.2.3"},
+		{"^1.2.3+build", "1.3.0"},
+		{"1.2.3-pre+asdf - 2.4.3-pre+asdf", "1.2.3"},
+		{"1.2.3-pre+asdf - 2.4.3-pre+asdf", "1.2.3-pre.2"},
+		{"1.2.3-pre+asdf - 2.4.3-pre+asdf", "2.4.3-alpha"},
+		{"1.2.3+asdf - 2.4.3+asdf", "1.2.3"},
+		{"1.0.0", "1.0.0"},
+		{">=*", "0.2.4"},
+		{"", "1.0.0"},
+		{"*", "1.2.3"},
+		{">=1.0.0", "1.0.0"},
+		{">=1.0.0", "1.0.1"},
+		{">1.0.0", "1.1.0"},
+		{"<=2.0.0", "2.0.0"},
+		{"<=2.0.0", "0.2.9"},
+		{"<2.0.0", "1.9999.9999"},
+		{">= 1.0.0", "1.0.0"},
+		{">=  1.0.0", "1.0.1"},
+		{"> 1.0.0", "1.0.1"},
+		{"<=   2.0.0", "2.0.0"},
+		{"0.1.20 || 1.2.4", "1.2.4"},
+		{">=0.2.3 || <0.0.1", "0.0.0"},
+		{">=0.2.3 || <0.0.1", "0.2.3"},
+		{"||", "1.3.4"},
+		{"2.x.x", "2.1.3"},
+		{"1.2.x", "1.2.3"},
+		{"1.2.x || 2.x", "2.1.3"},
+		{"x", "1.2.3"},
+		{"2.*.*", "2.1.3"},
+		{"1.2.*", "1.2.3"},
+		{"2", "2.1.2"},
+		{"2.3", "2.3.1"},
+		{"~0.0.1", "0.0.1"},
+		{"~0.0.1", "0.0.2"},
+		{"~x", "0.0.9"},
+		{"~2", "2.0.9"},
+		{"~2.4", "2.4.0"},
+		{"~2.4", "2.4.5"},
+		{"~>3.2.1", "3.2.2"},
+		{"~1", "1.2.3"},
+		{"~>1", "1.2.3"},
+		{"~> 1", "1.2.3"},
+		{"~1.0", "1.0.2"},
+		{"~ 1.0", "1.0.2"},
+		{"~ 1.0.3", "1.0.12"},
+		{">=1", "1.0.0"},
+		{">= 1", "1.0.0"},
+		{"<1.2", "1.1.1"},
+		{"< 1.2", "1.1.1"},
+		{"~v0.5.4-pre", "0.5.5"},
+		{"~v0.5.4-pre", "0.5.4"},
+		{"=0.7.x", "0.7.2"},
+		{"<=0.7.x", "0.7.2"},
+		{">=0.7.x", "0.7.2"},
+		{"<=0.7.x", "0.6.2"},
+		{"~1.2.1 >=1.2.3", "1.2.3"},
+		{"~1.2.1 =1.2.3", "1.2.3"},
+		{"~1.2.1 1.2.3", "1.2.3"},
+		{">=1.2.1 1.2.3", "1.2.3"},
+		{"1.2.3 >=1.2.1", "1.2.3"},
+		{">=1.2.3 >=1.2.1", "1.2.3"},
+		{">=1.2.1 >=1.2.3", "1.2.3"},
+		{">=1.2", "1.2.8"},
+		{"^1.2.3", "1.8.1"},
+		{"^0.1.2", "0.1.2"},
+		{"^0.1", "0.1.2"},
+		{"^0.0.1", "0.0.1"},
+		{"^1.2", "1.4.2"},
+		{"^1.2 ^1", "1.4.2"},
+		{"^1.2.3-alpha", "1.2.3-pre"},
+		{"^1.2.0-alpha", "1.2.0-pre"},
+		{"^0.0.1-alpha", "0.0.1-beta"},
+		{"^0.0.1-alpha", "0.0.1"},
+		{"^0.1.1-alpha", "0.1.1-beta"},
+		{"^x", "1.2.3"},
+		{"x - 1.0.0", "0.9.7"},
+		{"x - 1.x", "0.9.7"},
+		{"1.0.0 - x", "1.9.7"},
+		{"1.x - x", "1.9.7"},
+		{"<=7.x", "7.9.9"},
+	}
+
+	for _, tt := range tests {
+		ok, err := Satisfies(tt.version, tt.rng)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q) error: %v", tt.version, tt.rng, err)
+			continue
+		}
+		if !ok {
+			t.Errorf("expected %s to satisfy %q", tt.version, tt.rng)
+		}
+	}
+}
+
+func TestRangeExcludes(t *testing.T) {
+	tests := []struct {
+		rng     string
+		version string
+	}{
+		{"1.0.0 - 2.0.0", "2.2.3"},
+		{"1.2.3+asdf - 2.4.3+asdf", "1.2.3-pre.2"},
+		{"1.2.3+asdf - 2.4.3+asdf", "2.4.3-alpha"},
+		{"^1.2.3+build", "2.0.0"},
+		{"^1.2.3+build", "1.2.0"},
+		{"^1.2.3", "1.2.3-pre"},
+		{"^1.2", "1.2.0-pre"},
+		{">1.2", "1.3.0-beta"},
+		{"<=1.2.3", "1.2.3-beta"},
+		{"^1.2.3", "1.2.3-beta"},
+		{"=0.7.x", "0.7.0-asdf"},
+		{">=0.7.x", "0.7.0-asdf"},
+		{"1.0.0", "1.0.1"},
+		{">=1.0.0", "0.0.0"},
+		{">=1.0.0", "0.0.1"},
+		{">=1.0.0", "0.1.0"},
+		{">1.0.0", "0.0.1"},
+		{">1.0.0", "1.0.0"},
+		{"<=2.0.0", "3.0.0"},
+		{"<=2.0.0", "2.9999.9999"},
+		{"<2.0.0", "2.0.0"},
+		{"0.1.20 || 1.2.4", "1.2.3"},
+		{">=0.2.3 || <0.0.1", "0.0.3"},
+		{">=0.2.3 || <0.0.1", "0.2.2"},
+		{"2.x.x", "1.1.3"},
+		{"2.x.x", "3.1.3"},
+		{"1.2.x", "1.3.3"},
+		{"1.2.x || 2.x", "3.1.3"},
+		{"1.2.x || 2.x", "1.1.3"},
+		{"2.*.*", "1.1.3"},
+		{"2", "1.1.2"},
+		{"2.3", "2.4.1"},
+		{"~0.0.1", "0.1.0-alpha"},
+		{"~0.0.1", "0.1.0"},
+		{"~2.4", "2.5.0"},
+		{"~2.4", "2.3.9"},
+		{"~>3.2.1", "3.3.2"},
+		{"~>3.2.1", "3.2.0"},
+		{"~1", "0.2.3"},
+		{"~>1", "2.2.3"},
+		{"~1.0", "1.1.0"},
+		{"<1", "1.0.0"},
+		{">=1.2", "1.1.1"},
+		{"~v0.5.4-beta", "0.5.4-alpha"},
+		{"=0.7.x", "0.8.2"},
+		{">=0.7.x", "0.6.2"},
+		{"<0.7.x", "0.7.2"},
+		{"<1.2.3", "1.2.3-beta"},
+		{"=1.2.3", "1.2.3-beta"},
+		{">1.2", "1.2.8"},
+		{"^0.0.1", "0.0.2"},
+		{"^1.2.3", "2.0.0-alpha"},
+		{"^1.2.3", "1.2.2"},
+		{"^1.2", "1.1.9"},
+		{"*", "1.2.3-foo"},
+		{"^1.0.0", "2.0.0-rc1"},
+		{"1 - 2", "2.0.0-pre"},
+		{"1 - 2", "1.0.0-pre"},
+		{"1.1.x", "1.0.0-a"},
+		{"1.1.x", "1.1.0-a"},
+		{"1.1.x", "1.2.0-a"},
+		{"1.x", "1.0.0-a"},
+		{"1.x", "2.0.0-a"},
+		{">=1.0.0 <1.1.0", "1.1.0-pre"},
+		{">1", "1.2.3"},
+		{"<x", "0.0.0"},
+	}
+
+	for _, tt := range tests {
+		ok, err := Satisfies(tt.version, tt.rng)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q) error: %v", tt.version, tt.rng, err)
+			continue
+		}
+		if ok {
+			t.Errorf("expected %s not to satisfy %q", tt.version, tt.rng)
+		}
+	}
+}
+
+func TestDesugared(t *testing.T) {
+	tests := map[string]string{
+		"^1.2.3":          ">=1.2.3 <2.0.0-0",
+		"^0.2.3":          ">=0.2.3 <0.3.0-0",
+		"^0.0.3":          ">=0.0.3 <0.0.4-0",
+		"^1.2.x":          ">=1.2.0 <2.0.0-0",
+		"^0.0.x":          ">=0.0.0 <0.1.0-0",
+		"^0.x":            ">=0.0.0 <1.0.0-0",
+		"~1.2.3":          ">=1.2.3 <1.3.0-0",
+		"~1.2":            ">=1.2.0 <1.3.0-0",
+		"~1":              ">=1.0.0 <2.0.0-0",
+		"~1.2.3-beta.2":   ">=1.2.3-beta.2 <1.3.0-0",
+		"1.2.3 - 2.3.4":   ">=1.2.3 <=2.3.4",
+		"1.2 - 2.3.4":     ">=1.2.0 <=2.3.4",
+		"1.2.3 - 2.3":     ">=1.2.3 <2.4.0-0",
+		"1.2.3 - 2":       ">=1.2.3 <3.0.0-0",
+		"*":               "*",
+		"1.x":             ">=1.0.0 <2.0.0-0",
+		">1.2":            ">=1.3.0",
+		"<=1.2":           "<1.3.0-0",
+		">= 1.0.0 < 2":    ">=1.0.0 <2.0.0-0",
+		"1.0.0 || ^2.0.0": "1.0.0 || >=2.0.0 <3.0.0-0",
+	}
+	for input, want := range tests {
+		r, err := ParseRange(input)
+		if err != nil {
+			t.Errorf("ParseRange(%q) error: %v", input, err)
+			continue
+		}
+		if got := r.Desugared(); got != want {
+			t.Errorf("ParseRange(%q).Desugared() = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, input := range []string{"latest", "^1.2.3.4", "git+https://github.com/a/b", ">=a"} {
+		if _, err := ParseRange(input); err == nil {
+			t.Errorf("expected ParseRange(%q) to fail", input)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.2.3", "1.2.4", "1.3.0", "1.4.0-beta.1", "2.0.0", "2.1.0-rc.1", "not-a-version"}
+	tests := []struct {
+		rng      string
+		expected string
+	}{
+		{"^1.2.3", "1.3.0"},
+		{"~1.2.3", "1.2.4"},
+		{"^2.0.0", "2.0.0"},
+		{"^2.1.0-rc.0", "2.1.0-rc.1"},
+		{"^1.4.0-beta.0", "1.4.0-beta.1"},
+		{"*", "2.0.0"},
+		{"^3.0.0", ""},
+		{"1.2.3 || ^2", "2.0.0"},
+	}
+	for _, tt := range tests {
+		got, err := MaxSatisfying(versions, tt.rng)
+		if err != nil {
+			t.Errorf("MaxSatisfying(%q) error: %v", tt.rng, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("MaxSatisfying(%q) = %q, want %q", tt.rng, got, tt.expected)
+		}
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	tests := map[string]string{
+		"*":                "0.0.0",
+		"^1.2.3":           "1.2.3",
+		"~1.2":             "1.2.0",
+		">1.2.3":           "1.2.4",
+		">1.2.3-beta":      "1.2.3-beta.0",
+		"<2.0.0":           "0.0.0",
+		">=1.0.0 <2.0.0":   "1.0.0",
+		"^2.0.0 || ^1.0.0": "1.0.0",
+		">4.0.0 <4.0.0":    "",
+	}
+	for input, want := range tests {
+		min := MustParseRange(input).MinVersion()
+		got := ""
+		if min != nil {
+			got = min.String()
+		}
+		if got != want {
+			t.Errorf("MinVersion(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"18", ">=18", true},
+		{"18", ">=20", false},
+		{"18.x", "^18.17.0 || >=20", true},
+		{"16", "^18.17.0 || >=20", false},
+		{"18.17.0", ">=18.12", true},
+		{"18.10.0", ">=18.12", false},
+		{"<=1.0.0", ">=1.0.0", true},
+		{"<1.0.0", ">=1.0.0", false},
+		{">=1.0.0 <2.0.0", ">1.5.0 <3.0.0", true},
+		{"*", ">=20", true},
+		{">4.0.0 <4.0.0", "*", false},
+	}
+	for _, tt := range tests {
+		a, b := MustParseRange(tt.a), MustParseRange(tt.b)
+		if got := a.Intersects(b); got != tt.expected {
+			t.Errorf("Intersects(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+		}
+		if got := b.Intersects(a); got != tt.expected {
+			t.Errorf("Intersects(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.expected)
+		}
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v2", "2.0.0"},
+		{"2.2", "2.2.0"},
+		{"2.31.0.post1", "2.31.0"},
+		{"1.2.3-beta.1", "1.2.3"},
+		{"version 010.2", "10.2.0"},
+	}
+	for _, tt := range tests {
+		v, err := Coerce(tt.input)
+		if err != nil {
+			t.Errorf("Coerce(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if v.String() != tt.expected {
+			t.Errorf("Coerce(%q) = %s, want %s", tt.input, v, tt.expected)
+		}
+	}
+	if _, err := Coerce("latest"); err == nil {
+		t.Error("expected error for input without a version")
+	}
+}
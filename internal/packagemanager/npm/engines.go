@@ -0,0 +1,120 @@
+package npm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rvben/ru/internal/nodesemver"
+	"github.com/rvben/ru/internal/utils"
+)
+
+// nodeVersionFiles are the files version managers read the Node.js version
+// of a project from: .nvmrc (nvm) and .node-version (nodenv, fnm, n)
+var nodeVersionFiles = []string{".nvmrc", ".node-version"}
+
+// NodeTarget is the Node.js version a project runs on. Releases whose
+// engines.node does not allow it are not considered for updates.
+type NodeTarget struct {
+	// Range is the declared version or range, e.g. ">=18" or "18.17"
+	Range *nodesemver.Range
+	// Source is where it was declared, e.g. "engines.node" or ".nvmrc"
+	Source string
+	// declared is set for engines.node, which promises support for every
+	// version in the range, down to the lowest one
+	declared bool
+}
+
+// NodeTargetFromEngines returns the target declared by the engines.node
+// range of a package.json.
+func NodeTargetFromEngines(rng string) (*NodeTarget, error) {
+	r, err := nodesemver.ParseRange(rng)
+	if err != nil {
+		return nil, fmt.Errorf("invalid engines.node: %w", err)
+	}
+	return &NodeTarget{Range: r, Source: "engines.node", declared: true}, nil
+}
+
+// FindNodeVersionFile looks for a .nvmrc or .node-version file in dir and
+// its parents, the way nvm does, and returns the version it names. It
+// returns nil if there is none or if it names an alias such as "lts/*",
+// which cannot be resolved without a Node.js installation.
+func FindNodeVersionFile(dir string) (*NodeTarget, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for current := dir; ; {
+		for _, name := range nodeVersionFiles {
+			path := filepath.Join(current, name)
+			version, err := readNodeVersionFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", path, err)
+			}
+			// "18" and "v18.17" stand for the newest release of that line
+			r, err := nodesemver.ParseRange(strings.TrimPrefix(version, "v"))
+			if err != nil || version == "" || strings.ContainsAny(version, "/*") {
+				utils.Debug("npm", "Ignoring Node.js version %q in %s", version, path)
+				return nil, nil
+			}
+			return &NodeTarget{Range: r, Source: name}, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return nil, nil
+		}
+		current = parent
+	}
+}
+
+// readNodeVersionFile returns the first non-comment line of a version file
+func readNodeVersionFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// Supports reports whether a release can be used on the target's Node.js
+// version. A range from engines.node must be supported down to its lowest
+// version, so ">=18" rejects a release requiring ">=20". A version from a
+// version file only needs to overlap, so "18" accepts a release requiring
+// ">=18.12". Releases without a valid engines.node are always accepted.
+func (t *NodeTarget) Supports(info *VersionInfo) bool {
+	if t == nil || info == nil {
+		return true
+	}
+	engines, ok := info.Engines["node"]
+	if !ok {
+		return true
+	}
+	required, err := nodesemver.ParseRange(engines)
+	if err != nil {
+		return true
+	}
+	if t.declared {
+		min := t.Range.MinVersion()
+		return min == nil || required.Test(min)
+	}
+	return required.Intersects(t.Range)
+}
+
+// String describes the target for output, e.g. "node 18 (.nvmrc)"
+func (t *NodeTarget) String() string {
+	return fmt.Sprintf("node %s (%s)", t.Range, t.Source)
+}
@@ -0,0 +1,73 @@
+package npm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rvben/ru/internal/nodesemver"
+)
+
+func TestFindNodeVersionFile(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "packages", "app")
+	if err := os.MkdirAll(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(root, ".nvmrc"), "# pinned for CI\nv18.17\n")
+	target, err := FindNodeVersionFile(pkg)
+	if err != nil {
+		t.Fatalf("FindNodeVersionFile failed: %v", err)
+	}
+	if target == nil || target.Range.String() != "18.17" || target.Source != ".nvmrc" {
+		t.Fatalf("expected 18.17 from the parent .nvmrc, got %v", target)
+	}
+
+	writeFile(t, filepath.Join(pkg, ".node-version"), "20.11.1\n")
+	if target, err = FindNodeVersionFile(pkg); err != nil || target == nil || target.Range.String() != "20.11.1" {
+		t.Fatalf("expected the closest version file to win, got %v, %v", target, err)
+	}
+
+	writeFile(t, filepath.Join(pkg, ".node-version"), "lts/*\n")
+	if target, err = FindNodeVersionFile(pkg); err != nil || target != nil {
+		t.Errorf("expected aliases to be ignored, got %v, %v", target, err)
+	}
+}
+
+func TestNodeTargetSupports(t *testing.T) {
+	engines, err := NodeTargetFromEngines(">=18")
+	if err != nil {
+		t.Fatalf("NodeTargetFromEngines failed: %v", err)
+	}
+	nvmrc := &NodeTarget{Range: nodesemver.MustParseRange("18"), Source: ".nvmrc"}
+
+	tests := []struct {
+		engines     string
+		fromEngines bool
+		fromNvmrc   bool
+	}{
+		{"", true, true},
+		{">=14", true, true},
+		{">=20", false, false},
+		{"^18.17.0 || >=20", false, true},
+		{"not a range ~~", true, true},
+	}
+	for _, tt := range tests {
+		info := &VersionInfo{Version: "1.0.0"}
+		if tt.engines != "" {
+			info.Engines = map[string]string{"node": tt.engines}
+		}
+		if got := engines.Supports(info); got != tt.fromEngines {
+			t.Errorf("engines.node >=18 supports %q = %v, want %v", tt.engines, got, tt.fromEngines)
+		}
+		if got := nvmrc.Supports(info); got != tt.fromNvmrc {
+			t.Errorf(".nvmrc 18 supports %q = %v, want %v", tt.engines, got, tt.fromNvmrc)
+		}
+	}
+
+	if _, err := NodeTargetFromEngines("~~"); err == nil || !strings.Contains(err.Error(), "engines.node") {
+		t.Errorf("expected an error for an invalid engines.node, got %v", err)
+	}
+}
@@ -1,14 +1,14 @@
 package npm
 
 import (
-	"bufio"
-	"compress/gzip"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/rvben/ru/internal/nodesemver"
 	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 const (
@@ -16,91 +16,127 @@ const (
 )
 
 type NPM struct {
+	// registryURL overrides the registry for unscoped packages when set
 	registryURL string
+	config      *Config
 	client      *utils.OptimizerHTTPClient
 }
 
 func New() *NPM {
 	return &NPM{
-		registryURL: "https://registry.npmjs.org",
-		client:      utils.NewHTTPClient(),
+		config: NewConfig(),
+		client: utils.NewHTTPClient(),
 	}
 }
 
-func (n *NPM) GetLatestVersion(packageName string) (string, error) {
-	url := fmt.Sprintf("%s/%s/latest", n.registryURL, packageName)
+// WithConfig returns a client that uses the registries and credentials of
+// cfg. It shares the HTTP client, and with it the connection pool and
+// circuit breaker, with n.
+func (n *NPM) WithConfig(cfg *Config) *NPM {
+	return &NPM{
+		registryURL: n.registryURL,
+		config:      cfg,
+		client:      n.client,
+	}
+}
 
-	// Use optimized HTTP client with retry and circuit breaker
-	resp, err := n.client.GetWithRetry(url, map[string]string{
-		"Accept":          "application/json",
-		"Accept-Encoding": "gzip, deflate", // Explicitly request compression
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch latest version for package %s: %w", packageName, err)
+// registryFor returns the registry a package is fetched from
+func (n *NPM) registryFor(packageName string) string {
+	registry := n.config.RegistryFor(packageName)
+	if n.registryURL != "" && registry == n.config.Registry {
+		registry = normalizeRegistry(n.registryURL)
 	}
-	defer resp.Body.Close()
+	return registry
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch latest version for package %s: status %s", packageName, resp.Status)
+// packageURL returns the registry URL of a package document. Scoped names
+// keep their "@" but have the slash encoded, as npm does: "@scope%2fname".
+func (n *NPM) packageURL(packageName string) string {
+	escaped := url.PathEscape(packageName)
+	if strings.HasPrefix(packageName, "@") {
+		escaped = "@" + strings.Replace(url.PathEscape(packageName[1:]), "%2F", "%2f", 1)
 	}
+	return n.registryFor(packageName) + escaped
+}
 
-	// Handle compressed response if needed
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		utils.VerboseLog("NPM response is gzip encoded, decompressing")
-		gzipReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("error creating gzip reader: %w", err)
-		}
-		defer gzipReader.Close()
-		reader = gzipReader
+// get requests a package document with the credentials configured for its registry
+func (n *NPM) get(packageName, accept string) (*http.Response, error) {
+	headers := map[string]string{
+		"Accept":          accept,
+		"Accept-Encoding": "gzip, deflate", // Explicitly request compression
 	}
 
-	// Use optimized JSON extraction instead of the standard decoder
-	version, err := extractVersionFromNPMJSON(reader)
+	registry := n.registryFor(packageName)
+	if cred := n.config.CredentialsFor(registry); cred != nil {
+		headers["Authorization"] = cred.header()
+	} else if n.config.requiresAuth(registry) {
+		return nil, fmt.Errorf("always-auth is set but no credentials are configured for %s", registry)
+	}
+
+	// Use optimized HTTP client with retry and circuit breaker
+	return n.client.GetWithRetry(n.packageURL(packageName), headers)
+}
+
+// GetLatestVersion returns the version the "latest" dist-tag of a package
+// points to. If that version is deprecated, it returns the highest older
+// release that is not (see Packument.Latest).
+func (n *NPM) GetLatestVersion(packageName string) (string, error) {
+	p, err := n.GetPackument(packageName)
 	if err != nil {
-		return "", fmt.Errorf("error processing JSON for package %s: %w", packageName, err)
+		return "", err
+	}
+	tagged := p.DistTags["latest"]
+	if tagged == "" {
+		return "", fmt.Errorf("package %s has no latest dist-tag", packageName)
+	}
+	latest := p.Latest()
+	if latest == "" {
+		return "", fmt.Errorf("every release of %s up to %s is deprecated", packageName, tagged)
 	}
+	if latest != tagged {
+		utils.Debug("npm", "Latest version %s of %s is deprecated, using %s", tagged, packageName, latest)
+	}
+	return latest, nil
+}
 
-	return version, nil
+// GetVersions returns every version of a package published to the registry.
+func (n *NPM) GetVersions(packageName string) ([]string, error) {
+	p, err := n.GetPackument(packageName)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(p.Versions))
+	for v := range p.Versions {
+		versions = append(versions, v)
+	}
+	return versions, nil
 }
 
-// extractVersionFromNPMJSON efficiently extracts only the version field from NPM JSON response
-func extractVersionFromNPMJSON(r io.Reader) (string, error) {
-	// Create a buffered reader for efficiency
-	br := bufio.NewReaderSize(r, bufferSize)
-
-	dec := json.NewDecoder(br)
-
-	for {
-		t, err := dec.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", err
-		}
-
-		// Look for the version field
-		if key, ok := t.(string); ok && key == "version" {
-			// Next token should be the version value
-			t, err := dec.Token()
-			if err != nil {
-				return "", err
-			}
-
-			// Extract the version value
-			if versionVal, ok := t.(string); ok {
-				return versionVal, nil
-			}
-
-			return "", fmt.Errorf("expected string value for version, got %T", t)
-		}
+// GetLatestVersionFrom returns the latest version of a package for a
+// project on version current, following its prerelease channel if current
+// is a prerelease (see Packument.LatestFrom).
+func (n *NPM) GetLatestVersionFrom(packageName, current string) (string, error) {
+	if v, err := nodesemver.Parse(current); err != nil || !v.IsPrerelease() {
+		return n.GetLatestVersion(packageName)
+	}
+	p, err := n.GetPackument(packageName)
+	if err != nil {
+		return "", err
 	}
+	return p.LatestFrom(current), nil
+}
 
-	return "", fmt.Errorf("version field not found in NPM response")
+// GetHighestVersion returns the highest release of a package that is newer
+// than current without crossing level, or "" if there is none.
+func (n *NPM) GetHighestVersion(packageName, current string, level versioning.Level) (string, error) {
+	p, err := n.GetPackument(packageName)
+	if err != nil {
+		return "", err
+	}
+	return p.HighestWithin(current, level)
 }
 
+// SetCustomIndexURL overrides the registry for unscoped packages.
 func (n *NPM) SetCustomIndexURL(url string) {
 	n.registryURL = url
 }
@@ -12,14 +12,19 @@ import (
 
 // TestGetLatestVersionGzipped tests the handling of gzip-compressed NPM responses
 func TestGetLatestVersionGzipped(t *testing.T) {
-	// Create sample NPM response JSON
+	// Create sample NPM packument JSON
 	jsonResponse := `{
 		"name": "example-package",
-		"version": "2.0.0",
-		"description": "A test package",
-		"dependencies": {
-			"dependency1": "^1.0.0",
-			"dependency2": "^2.0.0"
+		"dist-tags": {"latest": "2.0.0"},
+		"versions": {
+			"2.0.0": {
+				"name": "example-package",
+				"version": "2.0.0",
+				"dependencies": {
+					"dependency1": "^1.0.0",
+					"dependency2": "^2.0.0"
+				}
+			}
 		}
 	}`
 
@@ -138,10 +143,11 @@ func TestNPMMixedEncodingResponses(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create sample NPM response JSON
+			// Create sample NPM packument JSON
 			jsonResponse := `{
 				"name": "example-package",
-				"version": "1.0.0"
+				"dist-tags": {"latest": "1.0.0"},
+				"versions": {"1.0.0": {"name": "example-package", "version": "1.0.0"}}
 			}`
 
 			// Create a mock HTTP server
@@ -2,22 +2,27 @@ package npm
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strings"
 	"testing"
 )
 
 func TestGetLatestVersion(t *testing.T) {
-	expectedVersion := "1.2.3"
-	mockResponse := struct {
-		Version string `json:"version"`
-	}{Version: expectedVersion}
-	responseData, _ := json.Marshal(mockResponse)
-
+	var paths []string
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(responseData)
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/vnd.npm.install-v1+json")
+		w.Write([]byte(`{
+			"name": "example-package",
+			"dist-tags": {"latest": "1.2.3", "next": "2.0.0-rc.1"},
+			"versions": {
+				"1.2.3": {"name": "example-package", "version": "1.2.3"},
+				"2.0.0-rc.1": {"name": "example-package", "version": "2.0.0-rc.1"}
+			}
+		}`))
 	}))
 	defer ts.Close()
 
@@ -29,179 +34,168 @@ func TestGetLatestVersion(t *testing.T) {
 		t.Fatalf("GetLatestVersion failed: %v", err)
 	}
 
-	if version != expectedVersion {
-		t.Errorf("Expected version %s, got %s", expectedVersion, version)
+	if version != "1.2.3" {
+		t.Errorf("Expected version 1.2.3, got %s", version)
+	}
+	if len(paths) != 1 || paths[0] != "/example-package" {
+		t.Errorf("expected a single request for /example-package, got %v", paths)
 	}
 }
 
-func BenchmarkStandardNPMJSONProcessing(b *testing.B) {
-	// Create a sample NPM JSON response
-	jsonResponse := `{
-		"name": "sample-package",
-		"version": "2.1.0",
-		"description": "A sample package for testing",
-		"main": "index.js",
-		"scripts": {
-			"test": "echo \"Error: no test specified\" && exit 1"
+func TestGetLatestVersionDeprecated(t *testing.T) {
+	tests := []struct {
+		name      string
+		packument string
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name: "older release",
+			packument: `{
+				"name": "example-package",
+				"dist-tags": {"latest": "1.1.0"},
+				"versions": {
+					"1.0.0": {"name": "example-package", "version": "1.0.0"},
+					"1.0.1": {"name": "example-package", "version": "1.0.1", "deprecated": "broken"},
+					"1.1.0": {"name": "example-package", "version": "1.1.0", "deprecated": "security issue"}
+				}
+			}`,
+			expected: "1.0.0",
 		},
-		"keywords": [
-			"test",
-			"sample"
-		],
-		"author": "Test Author",
-		"license": "MIT",
-		"dependencies": {
-			"lodash": "^4.17.21",
-			"express": "^4.17.1",
-			"react": "^17.0.2"
-		}
-	}`
-
-	b.ResetTimer()
-	b.ReportAllocs()
-
-	for i := 0; i < b.N; i++ {
-		// Create a new reader for each iteration
-		reader := strings.NewReader(jsonResponse)
-
-		// Use the old method
-		var data struct {
-			Version string `json:"version"`
-		}
-		err := json.NewDecoder(reader).Decode(&data)
-		if err != nil {
-			b.Fatal(err)
-		}
+		{
+			name: "every release deprecated",
+			packument: `{
+				"name": "example-package",
+				"dist-tags": {"latest": "1.1.0"},
+				"versions": {
+					"1.0.0": {"name": "example-package", "version": "1.0.0", "deprecated": "broken"},
+					"1.1.0": {"name": "example-package", "version": "1.1.0", "deprecated": "security issue"}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name:      "no latest tag",
+			packument: `{"name": "example-package", "dist-tags": {}, "versions": {}}`,
+			wantErr:   true,
+		},
+	}
 
-		// Verify version
-		if data.Version != "2.1.0" {
-			b.Fatalf("Expected version 2.1.0, got %s", data.Version)
-		}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.packument))
+			}))
+			defer ts.Close()
+
+			npm := New()
+			npm.SetCustomIndexURL(ts.URL)
+
+			version, err := npm.GetLatestVersion("example-package")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetLatestVersion failed: %v", err)
+			}
+			if version != tt.expected {
+				t.Errorf("Expected the newest version that is not deprecated, %s, got %s", tt.expected, version)
+			}
+		})
 	}
 }
 
-func BenchmarkOptimizedNPMJSONProcessing(b *testing.B) {
-	// Create a sample NPM JSON response
-	jsonResponse := `{
-		"name": "sample-package",
-		"version": "2.1.0",
-		"description": "A sample package for testing",
-		"main": "index.js",
-		"scripts": {
-			"test": "echo \"Error: no test specified\" && exit 1"
-		},
-		"keywords": [
-			"test",
-			"sample"
-		],
-		"author": "Test Author",
-		"license": "MIT",
-		"dependencies": {
-			"lodash": "^4.17.21",
-			"express": "^4.17.1",
-			"react": "^17.0.2"
-		}
-	}`
-
-	b.ResetTimer()
-	b.ReportAllocs()
-
-	for i := 0; i < b.N; i++ {
-		// Create a new reader for each iteration
-		reader := strings.NewReader(jsonResponse)
+func TestGetVersions(t *testing.T) {
+	var accept, path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/vnd.npm.install-v1+json")
+		w.Write([]byte(`{
+			"name": "example-package",
+			"dist-tags": {"latest": "1.1.0"},
+			"versions": {
+				"1.0.0": {"name": "example-package", "version": "1.0.0", "dependencies": {"a": "^1.0.0"}},
+				"1.1.0": {"name": "example-package", "version": "1.1.0"},
+				"2.0.0-beta.1": {"name": "example-package", "version": "2.0.0-beta.1"}
+			}
+		}`))
+	}))
+	defer ts.Close()
 
-		// Use the optimized method
-		version, err := extractVersionFromNPMJSON(reader)
-		if err != nil {
-			b.Fatal(err)
-		}
+	npm := New()
+	npm.SetCustomIndexURL(ts.URL)
 
-		// Verify version
-		if version != "2.1.0" {
-			b.Fatalf("Expected version 2.1.0, got %s", version)
-		}
+	versions, err := npm.GetVersions("example-package")
+	if err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	sort.Strings(versions)
+	if got := strings.Join(versions, ","); got != "1.0.0,1.1.0,2.0.0-beta.1" {
+		t.Errorf("unexpected versions: %s", got)
+	}
+	if path != "/example-package" {
+		t.Errorf("expected request for /example-package, got %s", path)
+	}
+	if !strings.Contains(accept, "application/vnd.npm.install-v1+json") {
+		t.Errorf("expected abbreviated metadata to be requested, got Accept: %s", accept)
 	}
 }
 
-func BenchmarkLargeNPMJSONProcessingComparison(b *testing.B) {
-	// Create a larger JSON response
-	var jsonBuilder strings.Builder
-	jsonBuilder.WriteString(`{
-		"name": "large-package",
-		"version": "3.5.1",
-		"description": "A large package for benchmark testing",
-		"main": "index.js",
-		"scripts": {
-			"test": "jest",
-			"start": "node index.js",
-			"build": "webpack"
-		},`)
-
-	// Add a large number of keywords
-	jsonBuilder.WriteString(`"keywords": [`)
-	for i := 0; i < 100; i++ {
+// largePackument returns a packument with many versions, each carrying the
+// dependencies, dist and scripts fields that make real packuments large
+func largePackument() string {
+	var builder strings.Builder
+	builder.WriteString(`{"name": "large-package", "dist-tags": {"latest": "9.9.9", "next": "10.0.0-rc.1"}, "versions": {`)
+	var times []string
+	for i := 0; i < 1000; i++ {
+		version := fmt.Sprintf("%d.%d.%d", i/100, i/10%10, i%10)
 		if i > 0 {
-			jsonBuilder.WriteString(",")
+			builder.WriteString(",")
 		}
-		jsonBuilder.WriteString(`"keyword-`)
-		jsonBuilder.WriteString(strings.Repeat(string(rune('a'+i%26)), 20))
-		jsonBuilder.WriteString(`"`)
-	}
-	jsonBuilder.WriteString(`],`)
-
-	// Add a large dependencies object
-	jsonBuilder.WriteString(`"dependencies": {`)
-	for i := 0; i < 100; i++ {
-		if i > 0 {
-			jsonBuilder.WriteString(",")
+		fmt.Fprintf(&builder, `%q: {"name": "large-package", "version": %q, "engines": {"node": ">=18"},`, version, version)
+		builder.WriteString(`"scripts": {"test": "jest", "build": "tsc -p ."}, "dependencies": {`)
+		for j := 0; j < 20; j++ {
+			if j > 0 {
+				builder.WriteString(",")
+			}
+			fmt.Fprintf(&builder, `"dependency-%d": "^%d.0.0"`, j, j)
 		}
-		jsonBuilder.WriteString(`"package-`)
-		jsonBuilder.WriteString(strings.Repeat(string(rune('a'+i%26)), 10))
-		jsonBuilder.WriteString(`": "^`)
-		jsonBuilder.WriteString(string(rune('0' + i/10)))
-		jsonBuilder.WriteString(".")
-		jsonBuilder.WriteString(string(rune('0' + i%10)))
-		jsonBuilder.WriteString(".0\"")
+		fmt.Fprintf(&builder, `}, "dist": {"shasum": "%040d", "tarball": "https://registry.npmjs.org/large-package/-/large-package-%s.tgz"}}`, i, version)
+		times = append(times, fmt.Sprintf(`%q: "2024-01-02T03:04:05.000Z"`, version))
 	}
-	jsonBuilder.WriteString(`}}`)
+	builder.WriteString(`}, "time": {`)
+	builder.WriteString(strings.Join(times, ","))
+	builder.WriteString(`}}`)
+	return builder.String()
+}
 
-	largeJSON := jsonBuilder.String()
+func BenchmarkDecodePackument(b *testing.B) {
+	packument := largePackument()
 
 	b.Run("Standard", func(b *testing.B) {
-		b.ResetTimer()
 		b.ReportAllocs()
-
 		for i := 0; i < b.N; i++ {
-			reader := strings.NewReader(largeJSON)
-
-			var data struct {
-				Version string `json:"version"`
-			}
-			err := json.NewDecoder(reader).Decode(&data)
-			if err != nil {
+			var data map[string]interface{}
+			if err := json.NewDecoder(strings.NewReader(packument)).Decode(&data); err != nil {
 				b.Fatal(err)
 			}
-
-			if data.Version != "3.5.1" {
-				b.Fatalf("Expected version 3.5.1, got %s", data.Version)
-			}
 		}
 	})
 
-	b.Run("Optimized", func(b *testing.B) {
-		b.ResetTimer()
+	b.Run("Streaming", func(b *testing.B) {
 		b.ReportAllocs()
-
 		for i := 0; i < b.N; i++ {
-			reader := strings.NewReader(largeJSON)
-
-			version, err := extractVersionFromNPMJSON(reader)
+			p, err := decodePackument(strings.NewReader(packument))
 			if err != nil {
 				b.Fatal(err)
 			}
-
-			if version != "3.5.1" {
-				b.Fatalf("Expected version 3.5.1, got %s", version)
+			if len(p.Versions) != 1000 || p.DistTags["latest"] != "9.9.9" {
+				b.Fatalf("unexpected packument: %d versions, latest %s", len(p.Versions), p.DistTags["latest"])
 			}
 		}
 	})
@@ -0,0 +1,343 @@
+package npm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/rvben/ru/internal/utils"
+)
+
+// DefaultRegistry is the public npm registry
+const DefaultRegistry = "https://registry.npmjs.org/"
+
+// Config is the registry configuration read from .npmrc files and the
+// environment.
+type Config struct {
+	// Registry is the registry for unscoped packages, with a trailing slash
+	Registry string
+	// ScopeRegistries maps scopes such as "@acme" to their registry
+	ScopeRegistries map[string]string
+	// Credentials maps registry "nerf darts" such as "//npm.acme.com/" to
+	// the credentials used for them
+	Credentials map[string]*Credentials
+	// AlwaysAuth requires credentials for every registry request
+	AlwaysAuth bool
+}
+
+// Credentials authenticate requests to a registry.
+type Credentials struct {
+	// Token is sent as a bearer token
+	Token string
+	// Auth is the base64 encoded "user:password" of _auth
+	Auth string
+	// Username and Password are the decoded username/_password pair
+	Username string
+	Password string
+	// AlwaysAuth is the per-registry always-auth setting
+	AlwaysAuth bool
+}
+
+// header returns the Authorization header value for the credentials
+func (c *Credentials) header() string {
+	switch {
+	case c.Token != "":
+		return "Bearer " + c.Token
+	case c.Auth != "":
+		return "Basic " + c.Auth
+	case c.Username != "" && c.Password != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+	}
+	return ""
+}
+
+// NewConfig returns the built-in configuration: the public registry and
+// no credentials.
+func NewConfig() *Config {
+	return &Config{
+		Registry:        DefaultRegistry,
+		ScopeRegistries: make(map[string]string),
+		Credentials:     make(map[string]*Credentials),
+	}
+}
+
+// LoadConfig reads the configuration that applies to the project in dir,
+// using npm's precedence: environment, then the project .npmrc, then the
+// user .npmrc, then the global npmrc.
+func LoadConfig(dir string) (*Config, error) {
+	cfg := NewConfig()
+
+	// Apply the files from lowest to highest precedence
+	for _, path := range []string{globalConfigPath(), userConfigPath(), projectConfigPath(dir)} {
+		if path == "" {
+			continue
+		}
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range []string{"NPM_CONFIG_REGISTRY", "npm_config_registry"} {
+		if registry := os.Getenv(name); registry != "" {
+			cfg.Registry = normalizeRegistry(registry)
+			break
+		}
+	}
+	for _, name := range []string{"NPM_CONFIG_ALWAYS_AUTH", "npm_config_always_auth"} {
+		if value := os.Getenv(name); value != "" {
+			cfg.AlwaysAuth = value == "true"
+			break
+		}
+	}
+	return cfg, nil
+}
+
+// projectConfigPath returns the .npmrc of the project in dir. Packages of a
+// workspace share the .npmrc of the workspace root, so parent directories
+// that contain a package.json are searched as well.
+func projectConfigPath(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for current := dir; ; {
+		path := filepath.Join(current, ".npmrc")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		if _, err := os.Stat(filepath.Join(parent, "package.json")); err != nil {
+			return ""
+		}
+		current = parent
+	}
+}
+
+// userConfigPath returns the user .npmrc, honoring NPM_CONFIG_USERCONFIG
+func userConfigPath() string {
+	if path := configEnv("USERCONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".npmrc")
+}
+
+// globalConfigPath returns the global npmrc, which lives in npm's prefix:
+// $PREFIX/etc/npmrc, where the prefix defaults to the node installation.
+func globalConfigPath() string {
+	if path := configEnv("GLOBALCONFIG"); path != "" {
+		return path
+	}
+	prefix := configEnv("PREFIX")
+	if prefix == "" {
+		node, err := exec.LookPath("node")
+		if err != nil {
+			return ""
+		}
+		if node, err = filepath.EvalSymlinks(node); err != nil {
+			return ""
+		}
+		// node lives in $PREFIX/bin, except on Windows
+		prefix = filepath.Dir(node)
+		if runtime.GOOS != "windows" {
+			prefix = filepath.Dir(prefix)
+		}
+	}
+	return filepath.Join(prefix, "etc", "npmrc")
+}
+
+// configEnv returns an npm_config_* environment variable, which npm reads
+// case-insensitively
+func configEnv(key string) string {
+	if value := os.Getenv("NPM_CONFIG_" + key); value != "" {
+		return value
+	}
+	return os.Getenv("npm_config_" + strings.ToLower(key))
+}
+
+// loadFile applies the settings of an npmrc file. A missing file is not an error.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	defer f.Close()
+
+	utils.Debug("npm", "Reading npm configuration from %s", path)
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value, missing := expandNpmrcEnv(unquote(strings.TrimSpace(value)))
+		for _, name := range missing {
+			utils.Warning("%s:%d: environment variable %s is not set", path, lineNumber, name)
+		}
+		c.set(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// set applies a single key=value setting
+func (c *Config) set(key, value string) {
+	// Registry-specific settings: //host/path/:key
+	if strings.HasPrefix(key, "//") {
+		if i := strings.LastIndex(key, ":"); i > 0 {
+			c.setCredential(key[:i], key[i+1:], value)
+		}
+		return
+	}
+
+	switch {
+	case key == "registry":
+		c.Registry = normalizeRegistry(value)
+	case strings.HasPrefix(key, "@") && strings.HasSuffix(key, ":registry"):
+		c.ScopeRegistries[strings.TrimSuffix(key, ":registry")] = normalizeRegistry(value)
+	case key == "always-auth":
+		c.AlwaysAuth = value == "true"
+	case key == "_authToken", key == "_auth", key == "username", key == "_password":
+		// Legacy settings without a registry apply to the default registry
+		c.setCredential(nerfDart(c.Registry), key, value)
+	}
+}
+
+// setCredential applies an authentication setting for a registry
+func (c *Config) setCredential(nerf, key, value string) {
+	nerf = strings.TrimSuffix(nerf, "/") + "/"
+	cred := c.Credentials[nerf]
+	if cred == nil {
+		cred = &Credentials{}
+		c.Credentials[nerf] = cred
+	}
+	switch key {
+	case "_authToken":
+		cred.Token = value
+	case "_auth":
+		cred.Auth = value
+	case "username":
+		cred.Username = value
+	case "_password":
+		// _password is stored base64 encoded
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+			cred.Password = string(decoded)
+		} else {
+			cred.Password = value
+		}
+	case "always-auth":
+		cred.AlwaysAuth = value == "true"
+	}
+}
+
+// RegistryFor returns the registry a package is fetched from.
+func (c *Config) RegistryFor(packageName string) string {
+	if strings.HasPrefix(packageName, "@") {
+		scope, _, _ := strings.Cut(packageName, "/")
+		if registry, ok := c.ScopeRegistries[scope]; ok {
+			return registry
+		}
+	}
+	return c.Registry
+}
+
+// CredentialsFor returns the credentials for a registry URL, or nil. Like
+// npm it tries the full registry path first and then each parent path, so
+// "//host/" credentials also cover "https://host/repo/npm/".
+func (c *Config) CredentialsFor(registry string) *Credentials {
+	nerf := nerfDart(registry)
+	for nerf != "" {
+		if cred, ok := c.Credentials[nerf]; ok && cred.header() != "" {
+			return cred
+		}
+		trimmed := strings.TrimSuffix(nerf, "/")
+		i := strings.LastIndex(trimmed, "/")
+		if i < 2 {
+			break
+		}
+		nerf = trimmed[:i+1]
+	}
+	return nil
+}
+
+// requiresAuth reports whether requests to registry must be authenticated
+func (c *Config) requiresAuth(registry string) bool {
+	if c.AlwaysAuth {
+		return true
+	}
+	cred, ok := c.Credentials[nerfDart(registry)]
+	return ok && cred.AlwaysAuth
+}
+
+// nerfDart strips the scheme, query and credentials from a registry URL,
+// e.g. "https://npm.acme.com:8443/repo/npm" -> "//npm.acme.com:8443/repo/npm/"
+func nerfDart(registry string) string {
+	u, err := url.Parse(registry)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return "//" + u.Host + strings.TrimSuffix(u.Path, "/") + "/"
+}
+
+// normalizeRegistry adds the trailing slash npm uses for registry URLs
+func normalizeRegistry(registry string) string {
+	return strings.TrimSuffix(strings.TrimSpace(registry), "/") + "/"
+}
+
+// unquote removes the quotes around an ini value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// npmrcEnvRegex matches ${VAR} and ${VAR?} references
+var npmrcEnvRegex = regexp.MustCompile(`(\\*)\$\{([^${}?]+)(\?)?\}`)
+
+// expandNpmrcEnv replaces environment variable references the way npm does.
+// References to unset variables are left as written and returned in missing,
+// unless they end with "?", which makes them expand to an empty string. A
+// backslash escapes a reference.
+func expandNpmrcEnv(value string) (expanded string, missing []string) {
+	expanded = npmrcEnvRegex.ReplaceAllStringFunc(value, func(match string) string {
+		m := npmrcEnvRegex.FindStringSubmatch(match)
+		escapes, name, optional := m[1], m[2], m[3] != ""
+		reference := strings.TrimPrefix(match, escapes)
+		if len(escapes)%2 == 1 {
+			return escapes[:len(escapes)/2] + reference
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok && !optional {
+			missing = append(missing, name)
+			v = reference
+		}
+		return escapes[:len(escapes)/2] + v
+	})
+	return expanded, missing
+}
@@ -0,0 +1,206 @@
+package npm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateConfig points the user and global npmrc at files in a temporary
+// directory so the developer's own configuration does not leak into tests
+func isolateConfig(t *testing.T) (userrc, globalrc string) {
+	dir := t.TempDir()
+	userrc = filepath.Join(dir, "userrc")
+	globalrc = filepath.Join(dir, "globalrc")
+	t.Setenv("NPM_CONFIG_USERCONFIG", userrc)
+	t.Setenv("NPM_CONFIG_GLOBALCONFIG", globalrc)
+	t.Setenv("NPM_CONFIG_REGISTRY", "")
+	t.Setenv("npm_config_registry", "")
+	t.Setenv("NPM_CONFIG_ALWAYS_AUTH", "")
+	t.Setenv("npm_config_always_auth", "")
+	return userrc, globalrc
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	userrc, globalrc := isolateConfig(t)
+	project := t.TempDir()
+
+	writeFile(t, globalrc, "registry=https://global.example.com\n@acme:registry=https://global.example.com/acme\n@other:registry=https://other.example.com/\n")
+	writeFile(t, userrc, "registry=https://user.example.com/\n@acme:registry=https://user.example.com/acme/\n//user.example.com/:_authToken=user-token\n")
+	writeFile(t, filepath.Join(project, ".npmrc"), "; project settings\n@acme:registry = \"https://npm.acme.com/repo/npm\"\n")
+
+	cfg, err := LoadConfig(project)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Registry != "https://user.example.com/" {
+		t.Errorf("expected user registry to override global, got %s", cfg.Registry)
+	}
+	if got := cfg.RegistryFor("@acme/widgets"); got != "https://npm.acme.com/repo/npm/" {
+		t.Errorf("expected project scope registry, got %s", got)
+	}
+	if got := cfg.RegistryFor("@other/pkg"); got != "https://other.example.com/" {
+		t.Errorf("expected global scope registry, got %s", got)
+	}
+	if got := cfg.RegistryFor("lodash"); got != "https://user.example.com/" {
+		t.Errorf("expected default registry for unscoped package, got %s", got)
+	}
+
+	// The environment overrides every file
+	t.Setenv("NPM_CONFIG_REGISTRY", "https://env.example.com")
+	if cfg, err = LoadConfig(project); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Registry != "https://env.example.com/" {
+		t.Errorf("expected environment registry, got %s", cfg.Registry)
+	}
+}
+
+func TestLoadConfigWorkspaceRoot(t *testing.T) {
+	isolateConfig(t)
+	root := t.TempDir()
+	pkg := filepath.Join(root, "packages", "app")
+	if err := os.MkdirAll(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, ".npmrc"), "registry=https://root.example.com/\n")
+	writeFile(t, filepath.Join(root, "package.json"), "{}")
+	writeFile(t, filepath.Join(root, "packages", "package.json"), "{}")
+	writeFile(t, filepath.Join(pkg, "package.json"), "{}")
+
+	cfg, err := LoadConfig(pkg)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Registry != "https://root.example.com/" {
+		t.Errorf("expected workspace root .npmrc to apply, got %s", cfg.Registry)
+	}
+}
+
+func TestCredentialsFor(t *testing.T) {
+	t.Setenv("NPM_TOKEN", "s3cret")
+	cfg := NewConfig()
+	for _, line := range []string{
+		"//npm.acme.com/:_authToken=${NPM_TOKEN}",
+		"//npm.acme.com/private/npm/:_authToken=private-token",
+		"//basic.example.com/:_auth=" + base64.StdEncoding.EncodeToString([]byte("bob:pw")),
+		"//userpass.example.com:8443/:username=alice",
+		"//userpass.example.com:8443/:_password=" + base64.StdEncoding.EncodeToString([]byte("hunter2")),
+	} {
+		key, value, _ := strings.Cut(line, "=")
+		value, _ = expandNpmrcEnv(value)
+		cfg.set(key, value)
+	}
+
+	tests := []struct {
+		registry string
+		expected string
+	}{
+		{"https://npm.acme.com/", "Bearer s3cret"},
+		{"https://npm.acme.com/repo/npm/", "Bearer s3cret"},
+		{"https://npm.acme.com/private/npm/", "Bearer private-token"},
+		{"https://basic.example.com/", "Basic " + base64.StdEncoding.EncodeToString([]byte("bob:pw"))},
+		{"https://userpass.example.com:8443/", "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:hunter2"))},
+		{"https://userpass.example.com/", ""},
+		{"https://registry.npmjs.org/", ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if cred := cfg.CredentialsFor(tt.registry); cred != nil {
+			got = cred.header()
+		}
+		if got != tt.expected {
+			t.Errorf("CredentialsFor(%s) = %q, want %q", tt.registry, got, tt.expected)
+		}
+	}
+}
+
+func TestExpandNpmrcEnv(t *testing.T) {
+	t.Setenv("NPM_TOKEN", "abc")
+	os.Unsetenv("RU_UNSET_TOKEN")
+
+	tests := []struct {
+		input    string
+		expected string
+		missing  string
+	}{
+		{"${NPM_TOKEN}", "abc", ""},
+		{"prefix-${NPM_TOKEN}-suffix", "prefix-abc-suffix", ""},
+		{"${RU_UNSET_TOKEN}", "${RU_UNSET_TOKEN}", "RU_UNSET_TOKEN"},
+		{"${RU_UNSET_TOKEN?}", "", ""},
+		{`\${NPM_TOKEN}`, "${NPM_TOKEN}", ""},
+		{"$NPM_TOKEN", "$NPM_TOKEN", ""},
+	}
+	for _, tt := range tests {
+		got, missing := expandNpmrcEnv(tt.input)
+		if got != tt.expected || strings.Join(missing, ",") != tt.missing {
+			t.Errorf("expandNpmrcEnv(%q) = %q, %v; want %q, %q", tt.input, got, missing, tt.expected, tt.missing)
+		}
+	}
+}
+
+func TestScopedRegistryWithAuth(t *testing.T) {
+	var rawPath, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawPath = r.URL.EscapedPath()
+		auth = r.Header.Get("Authorization")
+		if auth != "Bearer acme-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name": "@acme/widgets", "dist-tags": {"latest": "2.1.0"}, "versions": {"2.1.0": {"version": "2.1.0"}}}`)
+	}))
+	defer server.Close()
+
+	userrc, _ := isolateConfig(t)
+	t.Setenv("ACME_TOKEN", "acme-token")
+	host := strings.TrimPrefix(server.URL, "http:")
+	writeFile(t, userrc, "@acme:registry="+server.URL+"/npm/\n"+host+"/npm/:_authToken=${ACME_TOKEN}\n")
+
+	cfg, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	client := New().WithConfig(cfg)
+
+	version, err := client.GetLatestVersion("@acme/widgets")
+	if err != nil {
+		t.Fatalf("GetLatestVersion failed: %v", err)
+	}
+	if version != "2.1.0" {
+		t.Errorf("expected 2.1.0, got %s", version)
+	}
+	if rawPath != "/npm/@acme%2fwidgets" {
+		t.Errorf("expected scoped name to be encoded, got %s", rawPath)
+	}
+	if auth != "Bearer acme-token" {
+		t.Errorf("expected bearer token, got %q", auth)
+	}
+
+	// Credentials are only sent to the registry they belong to
+	if cred := cfg.CredentialsFor(cfg.RegistryFor("lodash")); cred != nil {
+		t.Errorf("expected no credentials for the public registry, got %+v", cred)
+	}
+}
+
+func TestAlwaysAuthWithoutCredentials(t *testing.T) {
+	cfg := NewConfig()
+	cfg.set("always-auth", "true")
+	client := New().WithConfig(cfg)
+	if _, err := client.GetLatestVersion("lodash"); err == nil || !strings.Contains(err.Error(), "always-auth") {
+		t.Errorf("expected always-auth error, got %v", err)
+	}
+}
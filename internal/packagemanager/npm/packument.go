@@ -0,0 +1,395 @@
+package npm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rvben/ru/internal/nodesemver"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+const (
+	// abbreviatedAccept asks for the abbreviated "corgi" metadata document,
+	// which only carries what an installer needs and is much smaller
+	abbreviatedAccept = "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8"
+	// fullAccept asks for the full packument, which also has publish times
+	fullAccept = "application/json"
+)
+
+// Packument is the registry metadata of a package: every published version
+// together with its dist-tags.
+type Packument struct {
+	Name string
+	// DistTags maps tags such as "latest" or "next" to versions
+	DistTags map[string]string
+	// Versions holds the metadata of every published version
+	Versions map[string]*VersionInfo
+	// Time maps versions to their publish time. The abbreviated document
+	// does not contain it; use GetFullPackument when it is needed.
+	Time map[string]time.Time
+	// Modified is the last time the package was changed
+	Modified time.Time
+}
+
+// VersionInfo is the part of a version's manifest ru cares about.
+type VersionInfo struct {
+	Version string
+	// Deprecated is the deprecation message, empty if the version is not deprecated
+	Deprecated string
+	// Engines maps engine names such as "node" to the ranges they must satisfy
+	Engines map[string]string
+	// PeerDependencies maps package names to the ranges the version expects
+	PeerDependencies map[string]string
+}
+
+// Latest returns the version the "latest" dist-tag points to. If that
+// version is deprecated, it returns the highest older release that is not,
+// or "" if there is none.
+func (p *Packument) Latest() string {
+	latest := p.DistTags["latest"]
+	info, ok := p.Versions[latest]
+	if !ok || info == nil || info.Deprecated == "" {
+		return latest
+	}
+	tagged, err := nodesemver.Parse(latest)
+	if err != nil {
+		return ""
+	}
+	versions := p.SortedVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := nodesemver.MustParse(versions[i])
+		if v.Compare(tagged) < 0 && !v.IsPrerelease() && p.Versions[versions[i]].Deprecated == "" {
+			return versions[i]
+		}
+	}
+	return ""
+}
+
+// LatestFrom returns the version a project on version current should move
+// to. That is the "latest" dist-tag, unless current is a prerelease: then
+// it is the newest prerelease or the latest release, whichever is higher.
+// Deprecated prereleases are skipped.
+func (p *Packument) LatestFrom(current string) string {
+	latest := p.Latest()
+	cur, err := nodesemver.Parse(current)
+	if err != nil || !cur.IsPrerelease() {
+		return latest
+	}
+	best, _ := nodesemver.Parse(latest)
+	for raw, info := range p.Versions {
+		v, err := nodesemver.Parse(raw)
+		if err != nil || !v.IsPrerelease() || (info != nil && info.Deprecated != "") {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			latest, best = raw, v
+		}
+	}
+	return latest
+}
+
+// SortedVersions returns all published versions that are valid semver,
+// lowest first.
+func (p *Packument) SortedVersions() []string {
+	type parsed struct {
+		raw string
+		v   *nodesemver.Version
+	}
+	list := make([]parsed, 0, len(p.Versions))
+	for raw := range p.Versions {
+		if v, err := nodesemver.Parse(raw); err == nil {
+			list = append(list, parsed{raw, v})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].v.Compare(list[j].v) < 0 })
+
+	versions := make([]string, len(list))
+	for i, p := range list {
+		versions[i] = p.raw
+	}
+	return versions
+}
+
+// HighestWithin returns the highest version newer than current that does
+// not cross level, skipping deprecated versions, or "" if there is none.
+func (p *Packument) HighestWithin(current string, level versioning.Level) (string, error) {
+	versions := make([]string, 0, len(p.Versions))
+	for version, info := range p.Versions {
+		if info == nil || info.Deprecated == "" {
+			versions = append(versions, version)
+		}
+	}
+	return versioning.Highest(versioning.NodeSemver, versions, current, level)
+}
+
+// Wanted returns the version npm would install for the range spec, or "" if
+// none satisfies it or spec is not a valid range. Like npm it prefers the
+// "latest" dist-tag when that is in range and avoids deprecated versions
+// unless nothing else matches.
+func (p *Packument) Wanted(spec string) string {
+	return p.WantedMatching(spec, nil)
+}
+
+// WantedMatching is like Wanted but only considers versions accepted by
+// accept, e.g. those supporting the project's Node.js version. A nil accept
+// accepts every version.
+func (p *Packument) WantedMatching(spec string, accept func(*VersionInfo) bool) string {
+	rng, err := nodesemver.ParseRange(spec)
+	if err != nil {
+		return ""
+	}
+	ok := func(version string) bool {
+		return accept == nil || accept(p.Versions[version])
+	}
+	if latest := p.Latest(); latest != "" && ok(latest) {
+		if info, found := p.Versions[latest]; found && info.Deprecated == "" {
+			if v, err := nodesemver.Parse(latest); err == nil && rng.Test(v) {
+				return latest
+			}
+		}
+	}
+
+	var deprecated string
+	versions := p.SortedVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := nodesemver.MustParse(versions[i])
+		if !rng.Test(v) || !ok(versions[i]) {
+			continue
+		}
+		if p.Versions[versions[i]].Deprecated == "" {
+			return versions[i]
+		}
+		if deprecated == "" {
+			deprecated = versions[i]
+		}
+	}
+	return deprecated
+}
+
+// GetPackument fetches the abbreviated metadata document of a package.
+func (n *NPM) GetPackument(packageName string) (*Packument, error) {
+	return n.fetchPackument(packageName, abbreviatedAccept)
+}
+
+// GetFullPackument fetches the full metadata document of a package, which
+// is larger but includes publish times.
+func (n *NPM) GetFullPackument(packageName string) (*Packument, error) {
+	return n.fetchPackument(packageName, fullAccept)
+}
+
+func (n *NPM) fetchPackument(packageName, accept string) (*Packument, error) {
+	resp, err := n.get(packageName, accept)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata for package %s: %w", packageName, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch metadata for package %s: status %s", packageName, resp.Status)
+	}
+
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		utils.VerboseLog("NPM response is gzip encoded, decompressing")
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error creating gzip reader: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	p, err := decodePackument(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error processing JSON for package %s: %w", packageName, err)
+	}
+	return p, nil
+}
+
+// decodePackument reads a packument with a streaming decoder. Only the
+// top-level fields ru uses are decoded; everything else, including the bulk
+// of each version manifest (dependencies, dist, scripts), is skipped.
+func decodePackument(r io.Reader) (*Packument, error) {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, bufferSize))
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	p := &Packument{
+		DistTags: make(map[string]string),
+		Versions: make(map[string]*VersionInfo),
+		Time:     make(map[string]time.Time),
+	}
+	for dec.More() {
+		key, err := stringToken(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "name":
+			if err := dec.Decode(&p.Name); err != nil {
+				return nil, fmt.Errorf("invalid name: %w", err)
+			}
+		case "dist-tags":
+			if err := dec.Decode(&p.DistTags); err != nil {
+				return nil, fmt.Errorf("invalid dist-tags: %w", err)
+			}
+		case "modified":
+			var modified string
+			if err := dec.Decode(&modified); err != nil {
+				return nil, fmt.Errorf("invalid modified time: %w", err)
+			}
+			p.Modified, _ = time.Parse(time.RFC3339, modified)
+		case "time":
+			// Besides versions, "time" has "created" and "modified" entries
+			var times map[string]string
+			if err := dec.Decode(&times); err != nil {
+				return nil, fmt.Errorf("invalid time: %w", err)
+			}
+			for k, v := range times {
+				if t, err := time.Parse(time.RFC3339, v); err == nil {
+					p.Time[k] = t
+				}
+			}
+			if t, ok := p.Time["modified"]; ok && p.Modified.IsZero() {
+				p.Modified = t
+			}
+		case "versions":
+			if err := decodeVersions(dec, p.Versions); err != nil {
+				return nil, err
+			}
+		default:
+			if err := skipValue(dec); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// decodeVersions reads the "versions" object one manifest at a time
+func decodeVersions(dec *json.Decoder, versions map[string]*VersionInfo) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return fmt.Errorf("invalid versions: %w", err)
+	}
+	for dec.More() {
+		version, err := stringToken(dec)
+		if err != nil {
+			return err
+		}
+
+		// Decoding into a struct skips unknown fields without allocating them
+		var manifest struct {
+			Deprecated       json.RawMessage   `json:"deprecated"`
+			Engines          json.RawMessage   `json:"engines"`
+			PeerDependencies map[string]string `json:"peerDependencies"`
+		}
+		if err := dec.Decode(&manifest); err != nil {
+			return fmt.Errorf("invalid manifest for version %s: %w", version, err)
+		}
+		versions[version] = &VersionInfo{
+			Version:          version,
+			Deprecated:       decodeDeprecated(manifest.Deprecated),
+			Engines:          decodeEngines(manifest.Engines),
+			PeerDependencies: manifest.PeerDependencies,
+		}
+	}
+	_, err := dec.Token() // closing }
+	return err
+}
+
+// decodeDeprecated returns the deprecation message. Some old manifests use
+// a boolean instead of a message.
+func decodeDeprecated(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil {
+		return message
+	}
+	var deprecated bool
+	if err := json.Unmarshal(raw, &deprecated); err == nil && deprecated {
+		return "deprecated"
+	}
+	return ""
+}
+
+// decodeEngines returns the engine ranges. Very old manifests list them as
+// an array of strings such as ["node >=0.6"].
+func decodeEngines(raw json.RawMessage) map[string]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var engines map[string]string
+	if err := json.Unmarshal(raw, &engines); err == nil {
+		return engines
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil
+	}
+	engines = make(map[string]string)
+	for _, entry := range list {
+		name, rng, _ := strings.Cut(strings.TrimSpace(entry), " ")
+		if name != "" {
+			engines[name] = strings.TrimSpace(rng)
+		}
+	}
+	return engines
+}
+
+// expectDelim reads the next token and checks that it is the given delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %q, got %v", delim, t)
+	}
+	return nil
+}
+
+// stringToken reads an object key
+func stringToken(dec *json.Decoder) (string, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := t.(string)
+	if !ok {
+		return "", fmt.Errorf("expected object key, got %v", t)
+	}
+	return key, nil
+}
+
+// skipValue discards the next value, however deeply nested
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package npm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rvben/ru/internal/nodesemver"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+const examplePackument = `{
+	"name": "example-package",
+	"modified": "2024-05-01T10:00:00.000Z",
+	"dist-tags": {"latest": "1.2.0", "next": "2.0.0-beta.1"},
+	"versions": {
+		"0.9.0": {"name": "example-package", "version": "0.9.0", "engines": ["node >=0.6"], "deprecated": true},
+		"1.0.0": {
+			"name": "example-package",
+			"version": "1.0.0",
+			"dependencies": {"a": "^1.0.0"},
+			"dist": {"tarball": "https://example.com/a.tgz", "signatures": [{"keyid": "x", "sig": "y"}]},
+			"engines": {"node": ">=14"},
+			"peerDependencies": {"react": "^17.0.0"}
+		},
+		"1.1.0": {"name": "example-package", "version": "1.1.0", "deprecated": "security issue, use 1.2.0"},
+		"1.2.0": {"name": "example-package", "version": "1.2.0", "engines": {"node": ">=16"}, "peerDependencies": {"react": "^17.0.0 || ^18.0.0"}},
+		"2.0.0-beta.1": {"name": "example-package", "version": "2.0.0-beta.1"}
+	},
+	"_rev": "12-abc"
+}`
+
+func TestGetPackument(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/vnd.npm.install-v1+json")
+		w.Write([]byte(examplePackument))
+	}))
+	defer ts.Close()
+
+	npm := New()
+	npm.SetCustomIndexURL(ts.URL)
+
+	p, err := npm.GetPackument("example-package")
+	if err != nil {
+		t.Fatalf("GetPackument failed: %v", err)
+	}
+	if !strings.HasPrefix(accept, "application/vnd.npm.install-v1+json") {
+		t.Errorf("expected abbreviated metadata to be requested, got Accept: %s", accept)
+	}
+
+	if p.Name != "example-package" || p.Latest() != "1.2.0" || p.DistTags["next"] != "2.0.0-beta.1" {
+		t.Errorf("unexpected name or dist-tags: %s %v", p.Name, p.DistTags)
+	}
+	if p.Modified.Year() != 2024 {
+		t.Errorf("expected modified time to be parsed, got %v", p.Modified)
+	}
+	if got := strings.Join(p.SortedVersions(), ","); got != "0.9.0,1.0.0,1.1.0,1.2.0,2.0.0-beta.1" {
+		t.Errorf("unexpected versions: %s", got)
+	}
+
+	v := p.Versions["1.0.0"]
+	if v.Engines["node"] != ">=14" || v.PeerDependencies["react"] != "^17.0.0" || v.Deprecated != "" {
+		t.Errorf("unexpected metadata for 1.0.0: %+v", v)
+	}
+	if got := p.Versions["1.1.0"].Deprecated; got != "security issue, use 1.2.0" {
+		t.Errorf("unexpected deprecation message: %q", got)
+	}
+	old := p.Versions["0.9.0"]
+	if old.Deprecated == "" || old.Engines["node"] != ">=0.6" {
+		t.Errorf("expected legacy deprecated flag and engines array to be understood: %+v", old)
+	}
+}
+
+func TestGetFullPackumentTimes(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"name": "example-package",
+			"dist-tags": {"latest": "1.0.0"},
+			"versions": {"1.0.0": {"version": "1.0.0"}},
+			"time": {"created": "2020-01-01T00:00:00.000Z", "modified": "2023-02-03T00:00:00.000Z", "1.0.0": "2021-06-07T08:09:10.000Z"}
+		}`))
+	}))
+	defer ts.Close()
+
+	npm := New()
+	npm.SetCustomIndexURL(ts.URL)
+
+	p, err := npm.GetFullPackument("example-package")
+	if err != nil {
+		t.Fatalf("GetFullPackument failed: %v", err)
+	}
+	if accept != "application/json" {
+		t.Errorf("expected full metadata to be requested, got Accept: %s", accept)
+	}
+	if published := p.Time["1.0.0"]; published.Year() != 2021 || published.Month() != 6 {
+		t.Errorf("unexpected publish time: %v", published)
+	}
+	if p.Modified.Year() != 2023 {
+		t.Errorf("expected modified time from time map, got %v", p.Modified)
+	}
+}
+
+func TestDecodePackumentInvalid(t *testing.T) {
+	for _, input := range []string{``, `[]`, `{"versions": []}`, `{"versions": {"1.0.0": "x"}}`} {
+		if _, err := decodePackument(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestPackumentWanted(t *testing.T) {
+	p, err := decodePackument(strings.NewReader(examplePackument))
+	if err != nil {
+		t.Fatalf("decodePackument failed: %v", err)
+	}
+
+	tests := []struct {
+		rng      string
+		expected string
+	}{
+		{"^1.0.0", "1.2.0"},        // latest tag is in range
+		{"~1.1.0", "1.1.0"},        // only a deprecated version matches
+		{"1.0.0 - 1.1.0", "1.0.0"}, // deprecated 1.1.0 is avoided
+		{"^2.0.0", ""},             // prereleases need an explicit range
+		{"^2.0.0-beta.0", "2.0.0-beta.1"},
+		{"^3.0.0", ""},
+		{"not a range", ""},
+	}
+	for _, tt := range tests {
+		if got := p.Wanted(tt.rng); got != tt.expected {
+			t.Errorf("Wanted(%q) = %q, want %q", tt.rng, got, tt.expected)
+		}
+	}
+}
+
+func TestPackumentHighestWithin(t *testing.T) {
+	p, err := decodePackument(strings.NewReader(examplePackument))
+	if err != nil {
+		t.Fatalf("decodePackument failed: %v", err)
+	}
+
+	tests := []struct {
+		current  string
+		level    versioning.Level
+		expected string
+	}{
+		{"1.0.0", versioning.Patch, ""},
+		{"1.0.0", versioning.Minor, "1.2.0"}, // deprecated 1.1.0 is skipped
+		{"0.9.0", versioning.Minor, ""},
+		{"1.0.0", versioning.Major, "1.2.0"}, // prereleases need a prerelease
+		{"2.0.0-alpha.1", versioning.Patch, "2.0.0-beta.1"},
+	}
+	for _, tt := range tests {
+		got, err := p.HighestWithin(tt.current, tt.level)
+		if err != nil {
+			t.Fatalf("HighestWithin(%s, %s) failed: %v", tt.current, tt.level, err)
+		}
+		if got != tt.expected {
+			t.Errorf("HighestWithin(%s, %s) = %q, want %q", tt.current, tt.level, got, tt.expected)
+		}
+	}
+}
+
+func TestPackumentLatestFrom(t *testing.T) {
+	p, err := decodePackument(strings.NewReader(examplePackument))
+	if err != nil {
+		t.Fatalf("decodePackument failed: %v", err)
+	}
+
+	tests := []struct {
+		current  string
+		expected string
+	}{
+		{"1.0.0", "1.2.0"},
+		{"", "1.2.0"},
+		{"2.0.0-alpha.1", "2.0.0-beta.1"},
+		{"1.2.0-rc.1", "2.0.0-beta.1"}, // the newest prerelease is higher than latest
+	}
+	for _, tt := range tests {
+		if got := p.LatestFrom(tt.current); got != tt.expected {
+			t.Errorf("LatestFrom(%q) = %q, want %q", tt.current, got, tt.expected)
+		}
+	}
+
+	// A prerelease moves to a release that overtook the prereleases
+	p.DistTags["latest"] = "2.0.0"
+	p.Versions["2.0.0"] = &VersionInfo{Version: "2.0.0"}
+	if got := p.LatestFrom("2.0.0-alpha.1"); got != "2.0.0" {
+		t.Errorf("LatestFrom(2.0.0-alpha.1) = %q, want 2.0.0", got)
+	}
+}
+
+func TestPackumentLatestSkipsDeprecated(t *testing.T) {
+	p, err := decodePackument(strings.NewReader(examplePackument))
+	if err != nil {
+		t.Fatalf("decodePackument failed: %v", err)
+	}
+
+	tests := []struct {
+		latest   string
+		expected string
+	}{
+		{"1.2.0", "1.2.0"},
+		{"1.1.0", "1.0.0"}, // deprecated 1.1.0 gives way to the release before it
+		{"0.9.0", ""},      // nothing older is left
+	}
+	for _, tt := range tests {
+		p.DistTags["latest"] = tt.latest
+		if got := p.Latest(); got != tt.expected {
+			t.Errorf("Latest() with latest tag %s = %q, want %q", tt.latest, got, tt.expected)
+		}
+	}
+}
+
+func TestPackumentWantedMatching(t *testing.T) {
+	p, err := decodePackument(strings.NewReader(examplePackument))
+	if err != nil {
+		t.Fatalf("decodePackument failed: %v", err)
+	}
+
+	node14 := &NodeTarget{Range: nodesemver.MustParseRange("14.21.3"), Source: ".nvmrc"}
+	if got := p.WantedMatching("^1.0.0", node14.Supports); got != "1.0.0" {
+		t.Errorf("expected 1.0.0 for node 14, got %q", got)
+	}
+	if got := p.WantedMatching("^1.0.0", nil); got != "1.2.0" {
+		t.Errorf("expected a nil filter to accept everything, got %q", got)
+	}
+}
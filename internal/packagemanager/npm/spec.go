@@ -0,0 +1,172 @@
+package npm
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rvben/ru/internal/nodesemver"
+)
+
+// SpecType is the kind of a dependency spec in package.json, following the
+// types of npm-package-arg.
+type SpecType int
+
+const (
+	// SpecRange is a semver version or range such as "1.2.3", "^1.2.0" or "*"
+	SpecRange SpecType = iota
+	// SpecTag is a dist-tag such as "latest" or "next"
+	SpecTag
+	// SpecAlias installs another package under this name: "npm:real@^1.0.0"
+	SpecAlias
+	// SpecGit is a git repository, including hosted shortcuts like "github:user/repo"
+	SpecGit
+	// SpecRemote is a tarball URL
+	SpecRemote
+	// SpecFile is a local tarball or directory: "file:../lib" or "./lib"
+	SpecFile
+	// SpecLink is a symlinked local directory: "link:../lib" or "portal:../lib"
+	SpecLink
+	// SpecWorkspace is a workspace package: "workspace:*" or "workspace:^1.0.0"
+	SpecWorkspace
+	// SpecInvalid is anything else
+	SpecInvalid
+)
+
+// String returns a short description used when reporting skipped specs
+func (t SpecType) String() string {
+	switch t {
+	case SpecRange:
+		return "range"
+	case SpecTag:
+		return "dist-tag"
+	case SpecAlias:
+		return "alias"
+	case SpecGit:
+		return "git dependency"
+	case SpecRemote:
+		return "tarball URL"
+	case SpecFile:
+		return "local path"
+	case SpecLink:
+		return "link"
+	case SpecWorkspace:
+		return "workspace dependency"
+	default:
+		return "invalid spec"
+	}
+}
+
+// Spec is a classified dependency spec.
+type Spec struct {
+	Type SpecType
+	// Raw is the spec as written
+	Raw string
+	// Name is the package the spec resolves against: the real package for
+	// aliases, empty otherwise
+	Name string
+	// Range is the semver range to resolve: the spec itself for ranges and
+	// the part after "@" for aliases ("*" if the alias has none)
+	Range string
+	// Tag is the dist-tag for SpecTag, and for aliases pointing at a tag
+	Tag string
+}
+
+// Registry reports whether the spec is resolved against the registry.
+func (s Spec) Registry() bool {
+	return s.Type == SpecRange || s.Type == SpecTag || s.Type == SpecAlias
+}
+
+// WithRange returns the spec with its range replaced, keeping alias syntax:
+// "npm:real@^1.0.0" with "^1.2.0" becomes "npm:real@^1.2.0".
+func (s Spec) WithRange(rng string) string {
+	if s.Type == SpecAlias {
+		return "npm:" + s.Name + "@" + rng
+	}
+	return rng
+}
+
+var (
+	// gitHostRegex matches hosted git shortcuts and git URLs
+	gitHostRegex = regexp.MustCompile(`^(?:git\+[a-z]+:|git:|git@|github:|gitlab:|bitbucket:|gist:)`)
+	// shorthandRegex matches the "user/repo" GitHub shorthand, optionally with a #ref
+	shorthandRegex = regexp.MustCompile(`^[^@/\s:#.][^/\s:#]*/[^/\s:#]+(?:#.*)?$`)
+	// tagRegex matches a dist-tag name
+	tagRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+)
+
+// ParseSpec classifies a dependency spec from package.json.
+func ParseSpec(raw string) Spec {
+	spec := strings.TrimSpace(raw)
+	lower := strings.ToLower(spec)
+	s := Spec{Raw: raw}
+
+	switch {
+	case strings.HasPrefix(lower, "npm:"):
+		return parseAlias(raw, spec[len("npm:"):])
+	case strings.HasPrefix(lower, "workspace:"):
+		s.Type = SpecWorkspace
+	case strings.HasPrefix(lower, "link:"), strings.HasPrefix(lower, "portal:"):
+		s.Type = SpecLink
+	case strings.HasPrefix(lower, "file:"), isLocalPath(spec):
+		s.Type = SpecFile
+	case gitHostRegex.MatchString(lower):
+		s.Type = SpecGit
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		s.Type = SpecRemote
+		if strings.HasSuffix(strings.SplitN(lower, "#", 2)[0], ".git") {
+			s.Type = SpecGit
+		}
+	case strings.Contains(spec, "://"):
+		s.Type = SpecInvalid
+	case shorthandRegex.MatchString(spec):
+		s.Type = SpecGit
+	default:
+		if _, err := nodesemver.ParseRange(spec); err == nil {
+			s.Type = SpecRange
+			s.Range = spec
+		} else if tagRegex.MatchString(spec) {
+			s.Type = SpecTag
+			s.Tag = spec
+		} else {
+			s.Type = SpecInvalid
+		}
+	}
+	return s
+}
+
+// parseAlias parses the part of an alias after "npm:", e.g. "@scope/real@^1.0.0"
+func parseAlias(raw, target string) Spec {
+	name, rng := target, ""
+	if i := strings.LastIndex(target, "@"); i > 0 {
+		name, rng = target[:i], target[i+1:]
+	}
+	if name == "" || strings.ContainsAny(name, " :") {
+		return Spec{Type: SpecInvalid, Raw: raw}
+	}
+
+	s := Spec{Type: SpecAlias, Raw: raw, Name: name, Range: rng}
+	if rng == "" {
+		s.Range = "*"
+		return s
+	}
+	// The target of an alias must itself be a registry spec
+	switch inner := ParseSpec(rng); inner.Type {
+	case SpecRange:
+	case SpecTag:
+		s.Range = ""
+		s.Tag = inner.Tag
+	default:
+		return Spec{Type: SpecInvalid, Raw: raw}
+	}
+	return s
+}
+
+// isLocalPath reports whether a spec is a relative or absolute path
+func isLocalPath(spec string) bool {
+	for _, prefix := range []string{"./", "../", "/", "~/", ".\\", "..\\"} {
+		if strings.HasPrefix(spec, prefix) {
+			return true
+		}
+	}
+	return spec == "." || spec == ".." || (len(spec) > 2 && spec[1] == ':' && (spec[2] == '\\' || spec[2] == '/'))
+}
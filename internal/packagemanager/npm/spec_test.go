@@ -0,0 +1,58 @@
+package npm
+
+import "testing"
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		raw  string
+		typ  SpecType
+		name string
+		rng  string
+		tag  string
+	}{
+		{"1.2.3", SpecRange, "", "1.2.3", ""},
+		{"^1.2.0", SpecRange, "", "^1.2.0", ""},
+		{">=1.0.0 <2.0.0 || 3.x", SpecRange, "", ">=1.0.0 <2.0.0 || 3.x", ""},
+		{"*", SpecRange, "", "*", ""},
+		{"", SpecRange, "", "", ""},
+		{"latest", SpecTag, "", "", "latest"},
+		{"next", SpecTag, "", "", "next"},
+		{"npm:string-width@^4.2.0", SpecAlias, "string-width", "^4.2.0", ""},
+		{"npm:@scope/real@~1.0.0", SpecAlias, "@scope/real", "~1.0.0", ""},
+		{"npm:real", SpecAlias, "real", "*", ""},
+		{"npm:real@beta", SpecAlias, "real", "", "beta"},
+		{"npm:real@file:../x", SpecInvalid, "", "", ""},
+		{"workspace:*", SpecWorkspace, "", "", ""},
+		{"workspace:^1.0.0", SpecWorkspace, "", "", ""},
+		{"file:../lib", SpecFile, "", "", ""},
+		{"../lib", SpecFile, "", "", ""},
+		{"./vendor/pkg.tgz", SpecFile, "", "", ""},
+		{"link:../lib", SpecLink, "", "", ""},
+		{"portal:../lib", SpecLink, "", "", ""},
+		{"github:user/repo", SpecGit, "", "", ""},
+		{"user/repo#v1.0.0", SpecGit, "", "", ""},
+		{"git+ssh://git@github.com/user/repo.git#semver:^1.0.0", SpecGit, "", "", ""},
+		{"git@github.com:user/repo.git", SpecGit, "", "", ""},
+		{"https://github.com/user/repo.git", SpecGit, "", "", ""},
+		{"https://example.com/pkg-1.0.0.tgz", SpecRemote, "", "", ""},
+		{"ftp://example.com/pkg.tgz", SpecInvalid, "", "", ""},
+		{"not a range", SpecInvalid, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		s := ParseSpec(tt.raw)
+		if s.Type != tt.typ || s.Name != tt.name || s.Range != tt.rng || s.Tag != tt.tag {
+			t.Errorf("ParseSpec(%q) = %s %q %q %q; want %s %q %q %q",
+				tt.raw, s.Type, s.Name, s.Range, s.Tag, tt.typ, tt.name, tt.rng, tt.tag)
+		}
+	}
+}
+
+func TestSpecWithRange(t *testing.T) {
+	if got := ParseSpec("npm:@scope/real@^1.0.0").WithRange("^1.4.0"); got != "npm:@scope/real@^1.4.0" {
+		t.Errorf("unexpected alias spec: %s", got)
+	}
+	if got := ParseSpec("^1.0.0").WithRange("^1.4.0"); got != "^1.4.0" {
+		t.Errorf("unexpected range spec: %s", got)
+	}
+}
@@ -0,0 +1,313 @@
+// Package packagejson implements format-preserving editing of package.json files.
+//
+// Instead of decoding into a map and marshaling it back, the document keeps
+// the original bytes together with the offsets of every value. Edits replace
+// single string literals in place, so key order, indentation, line endings
+// and the final newline are left exactly as they were.
+package packagejson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Kind is the JSON type of a value
+type Kind int
+
+const (
+	// KindObject is a JSON object
+	KindObject Kind = iota
+	// KindArray is a JSON array
+	KindArray
+	// KindString is a JSON string
+	KindString
+	// KindNumber is a JSON number
+	KindNumber
+	// KindBool is true or false
+	KindBool
+	// KindNull is null
+	KindNull
+)
+
+// bom is the UTF-8 byte order mark some editors put in front of package.json
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// Value is a JSON value together with its location in the source.
+type Value struct {
+	Kind Kind
+	// Start and End are the byte offsets of the value in the document
+	Start, End int
+	// Str is the decoded value of a string
+	Str string
+	// Members are the members of an object, in source order
+	Members []*Member
+	// Elements are the elements of an array
+	Elements []*Value
+}
+
+// Member is a key/value pair of an object.
+type Member struct {
+	Key   string
+	Value *Value
+}
+
+// Get returns the value of the last member named key, like JSON.parse does
+// for duplicate keys. It returns nil if v is not an object or has no such key.
+func (v *Value) Get(key string) *Value {
+	if v == nil || v.Kind != KindObject {
+		return nil
+	}
+	for i := len(v.Members) - 1; i >= 0; i-- {
+		if v.Members[i].Key == key {
+			return v.Members[i].Value
+		}
+	}
+	return nil
+}
+
+// Document is an editable package.json file.
+type Document struct {
+	data []byte
+	root *Value
+}
+
+// Parse parses the content of a package.json file.
+func Parse(data []byte) (*Document, error) {
+	body := bytes.TrimPrefix(data, bom)
+	if !json.Valid(body) {
+		// Let encoding/json produce a descriptive error
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid JSON")
+	}
+
+	p := &parser{data: data, pos: len(data) - len(body)}
+	root, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{data: data, root: root}, nil
+}
+
+// ParseFile reads and parses the package.json file at path.
+func ParseFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Bytes returns the current content of the document.
+func (d *Document) Bytes() []byte {
+	return d.data
+}
+
+// Root returns the top-level value of the document.
+func (d *Document) Root() *Value {
+	return d.root
+}
+
+// Lookup returns the value at the given key path, or nil if it does not exist.
+func (d *Document) Lookup(path ...string) *Value {
+	v := d.root
+	for _, key := range path {
+		if v = v.Get(key); v == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+// StringMembers returns the members of the object at path whose values are
+// strings, in source order. Typical use is iterating over "dependencies".
+func (d *Document) StringMembers(path ...string) []*Member {
+	obj := d.Lookup(path...)
+	if obj == nil || obj.Kind != KindObject {
+		return nil
+	}
+	var members []*Member
+	for _, m := range obj.Members {
+		if m.Value.Kind == KindString {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// SetString replaces the string value at path with s. Only the bytes of the
+// string literal change. It returns an error if the value does not exist or
+// is not a string.
+func (d *Document) SetString(s string, path ...string) error {
+	v := d.Lookup(path...)
+	if v == nil {
+		return fmt.Errorf("%s: not found", strings.Join(path, "."))
+	}
+	if v.Kind != KindString {
+		return fmt.Errorf("%s: not a string", strings.Join(path, "."))
+	}
+	if v.Str == s {
+		return nil
+	}
+
+	literal := encodeString(s)
+	var buf bytes.Buffer
+	buf.Grow(len(d.data) + len(literal))
+	buf.Write(d.data[:v.Start])
+	buf.Write(literal)
+	buf.Write(d.data[v.End:])
+
+	doc, err := Parse(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	*d = *doc
+	return nil
+}
+
+// encodeString encodes s as a JSON string literal without escaping HTML
+// characters, matching what npm writes.
+func encodeString(s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// parser is a small recursive descent parser recording value offsets. The
+// input has already been validated, so it only needs to track positions.
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) value() (*Value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("unexpected end of JSON input")
+	}
+
+	start := p.pos
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		s, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Kind: KindString, Start: start, End: p.pos, Str: s}, nil
+	case c == 't' || c == 'f':
+		p.literal()
+		return &Value{Kind: KindBool, Start: start, End: p.pos}, nil
+	case c == 'n':
+		p.literal()
+		return &Value{Kind: KindNull, Start: start, End: p.pos}, nil
+	default:
+		p.literal()
+		return &Value{Kind: KindNumber, Start: start, End: p.pos}, nil
+	}
+}
+
+// literal skips over a number, true, false or null
+func (p *parser) literal() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) str() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal(p.data[start:p.pos], &s); err != nil {
+				return "", err
+			}
+			return s, nil
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func (p *parser) object() (*Value, error) {
+	v := &Value{Kind: KindObject, Start: p.pos}
+	p.pos++ // {
+	for {
+		p.skipSpace()
+		if p.data[p.pos] == '}' {
+			p.pos++
+			v.End = p.pos
+			return v, nil
+		}
+		if p.data[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+
+		key, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		p.pos++ // :
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.Members = append(v.Members, &Member{Key: key, Value: val})
+	}
+}
+
+func (p *parser) array() (*Value, error) {
+	v := &Value{Kind: KindArray, Start: p.pos}
+	p.pos++ // [
+	for {
+		p.skipSpace()
+		if p.data[p.pos] == ']' {
+			p.pos++
+			v.End = p.pos
+			return v, nil
+		}
+		if p.data[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+
+		elem, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.Elements = append(v.Elements, elem)
+	}
+}
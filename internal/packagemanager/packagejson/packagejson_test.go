@@ -0,0 +1,141 @@
+package packagejson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetStringPreservesFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		path     []string
+		value    string
+		expected string
+	}{
+		{
+			name:     "two spaces with trailing newline",
+			input:    "{\n  \"name\": \"app\",\n  \"dependencies\": {\n    \"react\": \"^17.0.0\"\n  }\n}\n",
+			path:     []string{"dependencies", "react"},
+			value:    "^18.2.0",
+			expected: "{\n  \"name\": \"app\",\n  \"dependencies\": {\n    \"react\": \"^18.2.0\"\n  }\n}\n",
+		},
+		{
+			name:     "tabs",
+			input:    "{\n\t\"dependencies\": {\n\t\t\"react\": \"^17.0.0\"\n\t}\n}\n",
+			path:     []string{"dependencies", "react"},
+			value:    "^18.2.0",
+			expected: "{\n\t\"dependencies\": {\n\t\t\"react\": \"^18.2.0\"\n\t}\n}\n",
+		},
+		{
+			name:     "four spaces, CRLF, no final newline",
+			input:    "{\r\n    \"dependencies\": {\r\n        \"react\": \"^17.0.0\"\r\n    }\r\n}",
+			path:     []string{"dependencies", "react"},
+			value:    "^18.2.0",
+			expected: "{\r\n    \"dependencies\": {\r\n        \"react\": \"^18.2.0\"\r\n    }\r\n}",
+		},
+		{
+			name:     "key order is kept",
+			input:    `{"version":"1.0.0","name":"z","dependencies":{"zod":"3.0.0","axios":"1.0.0"}}`,
+			path:     []string{"dependencies", "axios"},
+			value:    "1.6.0",
+			expected: `{"version":"1.0.0","name":"z","dependencies":{"zod":"3.0.0","axios":"1.6.0"}}`,
+		},
+		{
+			name:     "escapes elsewhere are untouched",
+			input:    `{"description":"a & b \/ c","dependencies":{"x":">=1.0.0 <2.0.0"}}`,
+			path:     []string{"dependencies", "x"},
+			value:    ">=1.5.0 <2.0.0",
+			expected: `{"description":"a & b \/ c","dependencies":{"x":">=1.5.0 <2.0.0"}}`,
+		},
+		{
+			name:     "byte order mark",
+			input:    "\xEF\xBB\xBF{\"dependencies\": {\"x\": \"1.0.0\"}}\n",
+			path:     []string{"dependencies", "x"},
+			value:    "2.0.0",
+			expected: "\xEF\xBB\xBF{\"dependencies\": {\"x\": \"2.0.0\"}}\n",
+		},
+		{
+			name:     "duplicate keys edit the effective value",
+			input:    `{"dependencies":{"x":"1.0.0","x":"1.1.0"}}`,
+			path:     []string{"dependencies", "x"},
+			value:    "2.0.0",
+			expected: `{"dependencies":{"x":"1.0.0","x":"2.0.0"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := string(doc.Bytes()); got != tt.input {
+				t.Fatalf("unmodified document changed\nwant: %q\ngot:  %q", tt.input, got)
+			}
+			if err := doc.SetString(tt.value, tt.path...); err != nil {
+				t.Fatalf("SetString failed: %v", err)
+			}
+			if got := string(doc.Bytes()); got != tt.expected {
+				t.Errorf("unexpected output\nwant: %q\ngot:  %q", tt.expected, got)
+			}
+			if v := doc.Lookup(tt.path...); v == nil || v.Str != tt.value {
+				t.Errorf("expected lookup to return %q after edit, got %+v", tt.value, v)
+			}
+		})
+	}
+}
+
+func TestSetStringErrors(t *testing.T) {
+	doc, err := Parse([]byte(`{"dependencies":{"x":"1.0.0"},"private":true}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := doc.SetString("1.0.0", "dependencies", "missing"); err == nil {
+		t.Error("expected error for missing key")
+	}
+	if err := doc.SetString("false", "private"); err == nil || !strings.Contains(err.Error(), "not a string") {
+		t.Errorf("expected not a string error, got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{``, `{`, `{"a": }`, `{"a": "b",}`} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestStringMembers(t *testing.T) {
+	doc, err := Parse([]byte(`{
+  "dependencies": {
+    "b": "^1.0.0",
+    "a": "~2.0.0",
+    "nested": {"c": "1.0.0"},
+    "n": 1
+  },
+  "list": ["x", "y"]
+}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	members := doc.StringMembers("dependencies")
+	var keys []string
+	for _, m := range members {
+		keys = append(keys, m.Key+"="+m.Value.Str)
+	}
+	if got := strings.Join(keys, ","); got != "b=^1.0.0,a=~2.0.0" {
+		t.Errorf("unexpected members: %s", got)
+	}
+	if doc.StringMembers("list") != nil || doc.StringMembers("missing") != nil {
+		t.Error("expected no members for non-objects")
+	}
+	if v := doc.Lookup("dependencies", "nested", "c"); v == nil || v.Str != "1.0.0" {
+		t.Errorf("expected nested lookup to succeed, got %+v", v)
+	}
+	if v := doc.Lookup("list"); v == nil || len(v.Elements) != 2 {
+		t.Errorf("expected array with 2 elements, got %+v", v)
+	}
+}
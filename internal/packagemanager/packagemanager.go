@@ -1,7 +1,27 @@
 package packagemanager
 
+import "github.com/rvben/ru/internal/versioning"
+
 // PackageManager defines the interface for package managers
 type PackageManager interface {
 	GetLatestVersion(packageName string) (string, error)
 	SetCustomIndexURL() error
 }
+
+// LevelLookup is implemented by package managers that can find the highest
+// version of a package within an update level of the current one, e.g. the
+// newest 1.4.x for 1.4.2 at the patch level
+type LevelLookup interface {
+	// GetHighestVersion returns the highest release of packageName that is
+	// newer than current without crossing level, or "" if there is none
+	GetHighestVersion(packageName, current string, level versioning.Level) (string, error)
+}
+
+// ChannelLookup is implemented by package managers that follow the release
+// channel of the current version: a project on a prerelease moves to the
+// newest prerelease or the latest release, whichever is higher
+type ChannelLookup interface {
+	// GetLatestVersionFrom returns the latest version of packageName for a
+	// project on version current
+	GetLatestVersionFrom(packageName, current string) (string, error)
+}
@@ -0,0 +1,464 @@
+package pypi
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Index is a package index from the project configuration, modelled on the
+// [[tool.uv.index]] tables of uv
+type Index struct {
+	Name string
+	URL  string
+	// Default marks an index that replaces PyPI
+	Default bool
+	// Explicit indexes are only used for the packages pinned to them
+	Explicit bool
+	// Supplemental indexes are only looked up after the primary index, which
+	// is PyPI unless an index replaces it
+	Supplemental bool
+	// Authenticate is the credential policy of uv: "auto", "always" or
+	// "never"; empty means "auto"
+	Authenticate string
+}
+
+// envNameRegex matches the characters an index name loses in the names of
+// environment variables
+var envNameRegex = regexp.MustCompile(`[^A-Z0-9]`)
+
+// credentialURL returns the URL of the index with the credentials uv and
+// Poetry take from the environment for a named index:
+// UV_INDEX_{NAME}_USERNAME and UV_INDEX_{NAME}_PASSWORD, or
+// POETRY_HTTP_BASIC_{NAME}_USERNAME and _PASSWORD. Credentials in the URL
+// itself take precedence, and an index that never authenticates gets none.
+func (i Index) credentialURL() string {
+	u, err := url.Parse(i.URL)
+	if err != nil {
+		return i.URL
+	}
+	if i.Authenticate == "never" {
+		u.User = nil
+		return u.String()
+	}
+	if u.User != nil || i.Name == "" {
+		return i.URL
+	}
+
+	name := envNameRegex.ReplaceAllString(strings.ToUpper(i.Name), "_")
+	for _, prefix := range []string{"UV_INDEX_", "POETRY_HTTP_BASIC_"} {
+		username := os.Getenv(prefix + name + "_USERNAME")
+		password, hasPassword := os.LookupEnv(prefix + name + "_PASSWORD")
+		if username == "" && !hasPassword {
+			continue
+		}
+		if hasPassword {
+			u.User = url.UserPassword(username, password)
+		} else {
+			u.User = url.User(username)
+		}
+		return u.String()
+	}
+	return i.URL
+}
+
+// redactURL returns an index URL with its password masked, for logging
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
+
+// IndexStrategy is how a package is looked up across indexes, after the
+// index-strategy setting of uv
+type IndexStrategy string
+
+const (
+	// FirstIndex uses the first index that has the package
+	FirstIndex IndexStrategy = "first-index"
+	// UnsafeFirstMatch prefers the releases of the first index that has the
+	// package; later indexes only add versions older than all of those of
+	// the indexes before them
+	UnsafeFirstMatch IndexStrategy = "unsafe-first-match"
+	// UnsafeBestMatch uses the releases of every index that has the
+	// package, taking a version listed by several from the first of them
+	UnsafeBestMatch IndexStrategy = "unsafe-best-match"
+)
+
+// ParseIndexStrategy parses an index strategy as uv spells it
+func ParseIndexStrategy(s string) (IndexStrategy, error) {
+	switch strategy := IndexStrategy(s); strategy {
+	case FirstIndex, UnsafeFirstMatch, UnsafeBestMatch:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown index strategy %q (use first-index, unsafe-first-match or unsafe-best-match)", s)
+}
+
+// IndexSet is the set of indexes a project resolves packages from, in the
+// order they are configured. Primary and Extras give the lookup order.
+type IndexSet struct {
+	Indexes []Index
+	// Sources maps the normalized names of packages pinned to an index to
+	// the name of that index
+	Sources map[string]string
+	// Strategy is the index strategy the project asks for, or "" if it
+	// doesn't say
+	Strategy IndexStrategy
+	// PrimaryLast is set when the primary index is looked up after the extra
+	// indexes, as uv does with its default index
+	PrimaryLast bool
+}
+
+// Index returns the index with the given name, or nil if there is none
+func (s IndexSet) Index(name string) *Index {
+	for i := range s.Indexes {
+		if s.Indexes[i].Name != "" && strings.EqualFold(s.Indexes[i].Name, name) {
+			return &s.Indexes[i]
+		}
+	}
+	return nil
+}
+
+// Pinned returns the index a package is pinned to, or nil if it is looked
+// up on the primary and extra indexes
+func (s IndexSet) Pinned(packageName string) *Index {
+	name, ok := s.Sources[normalizeName(packageName)]
+	if !ok {
+		return nil
+	}
+	return s.Index(name)
+}
+
+// pin pins the packages of deps whose value names an index under key, e.g.
+// mylib = { index = "internal" }. section names deps in errors.
+func (s *IndexSet) pin(deps map[string]interface{}, key, section string) error {
+	for packageName, value := range deps {
+		name := pinnedIndex(value, key)
+		if name == "" {
+			continue
+		}
+		if s.Index(name) == nil {
+			return fmt.Errorf("%s.%s uses unknown index %q", section, packageName, name)
+		}
+		if s.Sources == nil {
+			s.Sources = make(map[string]string)
+		}
+		s.Sources[normalizeName(packageName)] = name
+	}
+	return nil
+}
+
+// pinnedIndex returns the index a dependency table names under key. A list
+// of tables, e.g. one per platform, only pins the package when they all
+// name the same index.
+func pinnedIndex(value interface{}, key string) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		name, _ := v[key].(string)
+		return name
+	case []interface{}:
+		var name string
+		for i, entry := range v {
+			entryName := pinnedIndex(entry, key)
+			if entryName == "" || (i > 0 && entryName != name) {
+				return ""
+			}
+			name = entryName
+		}
+		return name
+	}
+	return ""
+}
+
+// nameSeparatorRegex matches the separators Python package names treat as equal
+var nameSeparatorRegex = regexp.MustCompile(`[-_.]+`)
+
+// normalizeName returns the name packages are matched by: lowercase, with
+// runs of "-", "_" and "." collapsed to "-", as PyPI compares names
+func normalizeName(name string) string {
+	return nameSeparatorRegex.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// Primary returns the index that takes the place of PyPI: the default
+// index, or else the first index that is neither explicit nor supplemental.
+// It returns nil when packages come from PyPI. It is looked up first unless
+// PrimaryLast is set.
+func (s IndexSet) Primary() *Index {
+	for i := range s.Indexes {
+		if s.Indexes[i].Default {
+			return &s.Indexes[i]
+		}
+	}
+	for i := range s.Indexes {
+		if !s.Indexes[i].Explicit && !s.Indexes[i].Supplemental {
+			return &s.Indexes[i]
+		}
+	}
+	return nil
+}
+
+// ReplacesPyPI reports whether an index of the set takes the place of PyPI,
+// so that packages are not looked up there
+func (s IndexSet) ReplacesPyPI() bool {
+	for _, index := range s.Indexes {
+		if index.Default && !index.Explicit {
+			return true
+		}
+	}
+	return false
+}
+
+// Extras returns the indexes looked up after the primary one, in order
+func (s IndexSet) Extras() []Index {
+	primary := s.Primary()
+	var extras []Index
+	for i := range s.Indexes {
+		if &s.Indexes[i] != primary && !s.Indexes[i].Explicit {
+			extras = append(extras, s.Indexes[i])
+		}
+	}
+	return extras
+}
+
+// Explicit returns the indexes that are only used for the packages pinned
+// to them
+func (s IndexSet) Explicit() []Index {
+	var explicit []Index
+	for _, index := range s.Indexes {
+		if index.Explicit {
+			explicit = append(explicit, index)
+		}
+	}
+	return explicit
+}
+
+// uvIndex is a [[tool.uv.index]] table
+type uvIndex struct {
+	Name         string `toml:"name"`
+	URL          string `toml:"url"`
+	Default      bool   `toml:"default"`
+	Explicit     bool   `toml:"explicit"`
+	Authenticate string `toml:"authenticate"`
+}
+
+// poetrySource is a [[tool.poetry.source]] table
+type poetrySource struct {
+	Name     string `toml:"name"`
+	URL      string `toml:"url"`
+	Priority string `toml:"priority"`
+}
+
+// poetryPriorities ranks the priorities of Poetry sources in lookup order.
+// "default" and "secondary" are deprecated but still accepted by Poetry.
+var poetryPriorities = map[string]int{
+	"default":      0,
+	"primary":      1,
+	"secondary":    2,
+	"supplemental": 3,
+	"explicit":     4,
+}
+
+// pyProjectIndexes holds the parts of pyproject.toml that configure indexes
+type pyProjectIndexes struct {
+	Tool struct {
+		UV struct {
+			Index         []uvIndex              `toml:"index"`
+			IndexStrategy string                 `toml:"index-strategy"`
+			Sources       map[string]interface{} `toml:"sources"`
+		} `toml:"uv"`
+		Poetry struct {
+			Source          toml.Primitive         `toml:"source"`
+			Dependencies    map[string]interface{} `toml:"dependencies"`
+			DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+			Group           map[string]struct {
+				Dependencies map[string]interface{} `toml:"dependencies"`
+			} `toml:"group"`
+		} `toml:"poetry"`
+		Pip struct {
+			IndexURL      string      `toml:"index-url"`
+			ExtraIndexURL interface{} `toml:"extra-index-url"`
+		} `toml:"pip"`
+	} `toml:"tool"`
+}
+
+// ParsePyProjectIndexes returns the indexes configured in pyproject.toml
+// and the packages pinned to them with [tool.uv.sources] or the source of
+// Poetry dependencies. uv indexes take precedence over Poetry sources, which
+// take precedence over [tool.pip]; only the first of them that is present
+// is used.
+func ParsePyProjectIndexes(content []byte) (IndexSet, error) {
+	var cfg pyProjectIndexes
+	md, err := toml.Decode(strings.TrimPrefix(string(content), "\ufeff"), &cfg)
+	if err != nil {
+		return IndexSet{}, fmt.Errorf("failed to parse TOML: %w", err)
+	}
+
+	set, err := pyProjectIndexSet(&cfg, md)
+	if err != nil {
+		return IndexSet{}, err
+	}
+	if cfg.Tool.UV.IndexStrategy != "" {
+		set.Strategy, err = ParseIndexStrategy(cfg.Tool.UV.IndexStrategy)
+		if err != nil {
+			return IndexSet{}, fmt.Errorf("invalid tool.uv.index-strategy: %w", err)
+		}
+	}
+	return set, nil
+}
+
+// pyProjectIndexSet returns the indexes of the first kind of index
+// configuration present in cfg, with the packages pinned to them
+func pyProjectIndexSet(cfg *pyProjectIndexes, md toml.MetaData) (IndexSet, error) {
+	if len(cfg.Tool.UV.Index) > 0 {
+		set, err := uvIndexSet(cfg.Tool.UV.Index)
+		if err != nil {
+			return IndexSet{}, err
+		}
+		return set, set.pin(cfg.Tool.UV.Sources, "index", "tool.uv.sources")
+	}
+
+	if md.IsDefined("tool", "poetry", "source") {
+		// Poetry only documents an array of tables, but a single table is
+		// common enough in the wild to accept it as well
+		var sources []poetrySource
+		if err := md.PrimitiveDecode(cfg.Tool.Poetry.Source, &sources); err != nil {
+			var source poetrySource
+			if err := md.PrimitiveDecode(cfg.Tool.Poetry.Source, &source); err != nil {
+				return IndexSet{}, fmt.Errorf("invalid tool.poetry.source: %w", err)
+			}
+			sources = []poetrySource{source}
+		}
+		if len(sources) > 0 {
+			set, err := poetryIndexSet(sources)
+			if err != nil {
+				return IndexSet{}, err
+			}
+			poetry := cfg.Tool.Poetry
+			if err := set.pin(poetry.Dependencies, "source", "tool.poetry.dependencies"); err != nil {
+				return IndexSet{}, err
+			}
+			if err := set.pin(poetry.DevDependencies, "source", "tool.poetry.dev-dependencies"); err != nil {
+				return IndexSet{}, err
+			}
+			for name, group := range poetry.Group {
+				if err := set.pin(group.Dependencies, "source", "tool.poetry.group."+name+".dependencies"); err != nil {
+					return IndexSet{}, err
+				}
+			}
+			return set, nil
+		}
+	}
+
+	return pipIndexSet(cfg.Tool.Pip.IndexURL, cfg.Tool.Pip.ExtraIndexURL)
+}
+
+// uvIndexSet converts [[tool.uv.index]] tables, keeping their order. uv
+// looks the default index up after all others.
+func uvIndexSet(indexes []uvIndex) (IndexSet, error) {
+	var set IndexSet
+	for _, index := range indexes {
+		if index.URL == "" {
+			return IndexSet{}, fmt.Errorf("tool.uv.index %q has no url", index.Name)
+		}
+		switch index.Authenticate {
+		case "", "auto", "always", "never":
+		default:
+			return IndexSet{}, fmt.Errorf("tool.uv.index %q has invalid authenticate value %q", index.Name, index.Authenticate)
+		}
+		set.Indexes = append(set.Indexes, Index{
+			Name:         index.Name,
+			URL:          index.URL,
+			Default:      index.Default,
+			Explicit:     index.Explicit,
+			Authenticate: index.Authenticate,
+		})
+		set.PrimaryLast = set.PrimaryLast || index.Default
+	}
+	return set, nil
+}
+
+// poetryIndexSet converts [[tool.poetry.source]] tables, ordering
+// them the way Poetry looks them up: the default source, primary sources,
+// secondary sources and then supplemental sources. Sources without a
+// priority are primary. Default and primary sources replace PyPI; without
+// them PyPI stays the primary index and secondary and supplemental sources
+// are only looked up after it.
+func poetryIndexSet(sources []poetrySource) (IndexSet, error) {
+	ranks := make([]int, len(sources))
+	for i, source := range sources {
+		if source.Priority == "" {
+			source.Priority = "primary"
+		}
+		rank, ok := poetryPriorities[source.Priority]
+		if !ok {
+			return IndexSet{}, fmt.Errorf("tool.poetry.source %q has invalid priority %q", source.Name, source.Priority)
+		}
+		ranks[i] = rank
+	}
+
+	order := make([]int, len(sources))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return ranks[order[a]] < ranks[order[b]] })
+
+	var set IndexSet
+	for _, i := range order {
+		source := sources[i]
+		url := source.URL
+		if url == "" {
+			// A source named PyPI without a URL refers to PyPI itself
+			if !strings.EqualFold(source.Name, "pypi") {
+				return IndexSet{}, fmt.Errorf("tool.poetry.source %q has no url", source.Name)
+			}
+			url = "https://pypi.org/simple"
+		}
+		set.Indexes = append(set.Indexes, Index{
+			Name:         source.Name,
+			URL:          url,
+			Default:      ranks[i] <= poetryPriorities["primary"],
+			Explicit:     ranks[i] == poetryPriorities["explicit"],
+			Supplemental: ranks[i] == poetryPriorities["secondary"] || ranks[i] == poetryPriorities["supplemental"],
+		})
+	}
+	return set, nil
+}
+
+// pipIndexSet converts [tool.pip], whose extra-index-url is either a list
+// or a space-separated string. Extra indexes are ignored without an
+// index-url.
+func pipIndexSet(indexURL string, extraIndexURL interface{}) (IndexSet, error) {
+	var set IndexSet
+	if indexURL == "" {
+		return set, nil
+	}
+	set.Indexes = append(set.Indexes, Index{URL: indexURL, Default: true})
+
+	var extras []string
+	switch v := extraIndexURL.(type) {
+	case nil:
+	case string:
+		extras = strings.Fields(v)
+	case []interface{}:
+		for _, url := range v {
+			s, ok := url.(string)
+			if !ok {
+				return IndexSet{}, fmt.Errorf("invalid tool.pip.extra-index-url entry %v", url)
+			}
+			extras = append(extras, s)
+		}
+	default:
+		return IndexSet{}, fmt.Errorf("invalid tool.pip.extra-index-url %v", v)
+	}
+	for _, url := range extras {
+		set.Indexes = append(set.Indexes, Index{URL: url})
+	}
+	return set, nil
+}
@@ -0,0 +1,640 @@
+package pypi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rvben/ru/internal/packagemanager/requirements"
+)
+
+func TestParsePyProjectIndexes(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		primary  string
+		extras   []string
+		explicit []string
+		// primaryLast is set when the primary index is looked up after
+		// the extras
+		primaryLast bool
+	}{
+		{
+			name: "uv indexes in any key order with comments and explicit indexes",
+			content: `[[tool.uv.index]]
+# CPU wheels only
+url = "https://download.pytorch.org/whl/cpu"
+explicit = true
+name = "pytorch"
+
+[[tool.uv.index]]
+url = "https://extra.example.com/simple"  # searched first
+name = "extra"
+authenticate = "always"
+
+[[tool.uv.index]]
+default = true
+name = "internal"
+url = "https://internal.example.com/simple"
+`,
+			primary:     "https://internal.example.com/simple",
+			extras:      []string{"https://extra.example.com/simple"},
+			explicit:    []string{"https://download.pytorch.org/whl/cpu"},
+			primaryLast: true,
+		},
+		{
+			name: "uv indexes as inline tables",
+			content: `[tool.uv]
+index = [
+  { name = "pytorch", url = "https://download.pytorch.org/whl/cpu", explicit = true },
+  { name = "extra", url = "https://extra.example.com/simple" },
+]
+`,
+			primary:  "https://extra.example.com/simple",
+			explicit: []string{"https://download.pytorch.org/whl/cpu"},
+		},
+		{
+			name: "only explicit uv indexes keep PyPI",
+			content: `[[tool.uv.index]]
+name = "pytorch"
+url = "https://download.pytorch.org/whl/cpu"
+explicit = true
+`,
+			explicit: []string{"https://download.pytorch.org/whl/cpu"},
+		},
+		{
+			name: "Poetry priorities",
+			content: `[[tool.poetry.source]]
+name = "supplemental"
+url = "https://supplemental.example.com/simple"
+priority = "supplemental"
+
+[[tool.poetry.source]]
+name = "secondary"
+url = "https://secondary.example.com/simple"
+priority = "secondary"
+
+[[tool.poetry.source]]
+priority = "explicit"
+name = "explicit"
+url = "https://explicit.example.com/simple"
+
+[[tool.poetry.source]]
+name = "primary"
+url = "https://primary.example.com/simple"
+
+[[tool.poetry.source]]
+name = "default"
+url = "https://default.example.com/simple"
+priority = "default"
+`,
+			primary: "https://default.example.com/simple",
+			extras: []string{
+				"https://primary.example.com/simple",
+				"https://secondary.example.com/simple",
+				"https://supplemental.example.com/simple",
+			},
+			explicit: []string{"https://explicit.example.com/simple"},
+		},
+		{
+			name: "Poetry source as a single table",
+			content: `[tool.poetry.source]
+name = "custom"
+url = "https://poetry-source.example.com"
+`,
+			primary: "https://poetry-source.example.com",
+		},
+		{
+			name: "Poetry PyPI source without a URL",
+			content: `[[tool.poetry.source]]
+name = "PyPI"
+priority = "primary"
+
+[[tool.poetry.source]]
+name = "private"
+url = "https://private.example.com/simple"
+priority = "supplemental"
+`,
+			primary: "https://pypi.org/simple",
+			extras:  []string{"https://private.example.com/simple"},
+		},
+		{
+			name: "Poetry supplemental sources keep PyPI primary",
+			content: `[[tool.poetry.source]]
+name = "private"
+url = "https://private.example.com/simple"
+priority = "supplemental"
+
+[[tool.poetry.source]]
+name = "legacy"
+url = "https://legacy.example.com/simple"
+priority = "secondary"
+`,
+			extras: []string{"https://legacy.example.com/simple", "https://private.example.com/simple"},
+		},
+		{
+			name: "pip with a list of extra indexes",
+			content: `[tool.pip]
+extra-index-url = ["https://extra1.example.com/simple", "https://extra2.example.com/simple"]
+index-url = "https://custom-index.example.com/simple"
+`,
+			primary: "https://custom-index.example.com/simple",
+			extras:  []string{"https://extra1.example.com/simple", "https://extra2.example.com/simple"},
+		},
+		{
+			name: "uv takes precedence over Poetry and pip",
+			content: `[[tool.poetry.source]]
+name = "poetry"
+url = "https://poetry.example.com/simple"
+
+[tool.pip]
+index-url = "https://pip.example.com/simple"
+
+[[tool.uv.index]]
+name = "uv"
+url = "https://uv.example.com/simple"
+`,
+			primary: "https://uv.example.com/simple",
+		},
+		{
+			name: "no index configuration",
+			content: `[project]
+name = "example"
+`,
+		},
+	}
+
+	urls := func(indexes []Index) []string {
+		var result []string
+		for _, index := range indexes {
+			result = append(result, index.URL)
+		}
+		return result
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParsePyProjectIndexes([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParsePyProjectIndexes failed: %v", err)
+			}
+			var primary string
+			if index := set.Primary(); index != nil {
+				primary = index.URL
+			}
+			if primary != tt.primary {
+				t.Errorf("primary = %q, want %q", primary, tt.primary)
+			}
+			if got := urls(set.Extras()); !reflect.DeepEqual(got, tt.extras) {
+				t.Errorf("extras = %v, want %v", got, tt.extras)
+			}
+			if got := urls(set.Explicit()); !reflect.DeepEqual(got, tt.explicit) {
+				t.Errorf("explicit = %v, want %v", got, tt.explicit)
+			}
+			if set.PrimaryLast != tt.primaryLast {
+				t.Errorf("PrimaryLast = %v, want %v", set.PrimaryLast, tt.primaryLast)
+			}
+		})
+	}
+}
+
+func TestParsePyProjectIndexesUVModel(t *testing.T) {
+	set, err := ParsePyProjectIndexes([]byte(`[[tool.uv.index]]
+name = "private"
+url = "https://private.example.com/simple"
+authenticate = "always"
+explicit = true
+`))
+	if err != nil {
+		t.Fatalf("ParsePyProjectIndexes failed: %v", err)
+	}
+	expected := []Index{{
+		Name:         "private",
+		URL:          "https://private.example.com/simple",
+		Explicit:     true,
+		Authenticate: "always",
+	}}
+	if !reflect.DeepEqual(set.Indexes, expected) {
+		t.Errorf("indexes = %+v, want %+v", set.Indexes, expected)
+	}
+}
+
+func TestParsePyProjectIndexesSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		sources  map[string]string
+		strategy IndexStrategy
+	}{
+		{
+			name: "uv sources",
+			content: `[tool.uv]
+index-strategy = "unsafe-best-match"
+
+[tool.uv.sources]
+My_Lib = { index = "internal" }
+torch = [
+  { index = "pytorch", marker = "sys_platform == 'linux'" },
+  { index = "pytorch", marker = "sys_platform == 'win32'" },
+]
+split = [
+  { index = "pytorch", marker = "sys_platform == 'linux'" },
+  { index = "internal", marker = "sys_platform == 'win32'" },
+]
+local = { path = "../local" }
+
+[[tool.uv.index]]
+name = "internal"
+url = "https://internal.example.com/simple"
+
+[[tool.uv.index]]
+name = "pytorch"
+url = "https://download.pytorch.org/whl/cpu"
+explicit = true
+`,
+			sources:  map[string]string{"my-lib": "internal", "torch": "pytorch"},
+			strategy: UnsafeBestMatch,
+		},
+		{
+			name: "Poetry dependency sources",
+			content: `[tool.poetry.dependencies]
+python = "^3.9"
+mylib = { version = "^1", source = "internal" }
+flask = "^2.0"
+
+[tool.poetry.group.dev.dependencies]
+"internal.tools" = { version = "^2", source = "internal" }
+
+[[tool.poetry.source]]
+name = "internal"
+url = "https://internal.example.com/simple"
+priority = "explicit"
+`,
+			sources: map[string]string{"mylib": "internal", "internal-tools": "internal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParsePyProjectIndexes([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParsePyProjectIndexes failed: %v", err)
+			}
+			if !reflect.DeepEqual(set.Sources, tt.sources) {
+				t.Errorf("sources = %v, want %v", set.Sources, tt.sources)
+			}
+			if set.Strategy != tt.strategy {
+				t.Errorf("strategy = %q, want %q", set.Strategy, tt.strategy)
+			}
+			for name, index := range tt.sources {
+				if pinned := set.Pinned(name); pinned == nil || pinned.Name != index {
+					t.Errorf("Pinned(%s) = %+v, want index %s", name, pinned, index)
+				}
+			}
+			if set.Pinned("flask") != nil {
+				t.Errorf("expected flask not to be pinned")
+			}
+		})
+	}
+}
+
+func TestParsePyProjectIndexesInvalid(t *testing.T) {
+	for _, content := range []string{
+		"[[tool.uv.index]]\nname = \"no-url\"\n",
+		"[[tool.uv.index]]\nurl = \"https://example.com\"\nauthenticate = \"sometimes\"\n",
+		"[[tool.poetry.source]]\nname = \"x\"\nurl = \"https://example.com\"\npriority = \"first\"\n",
+		"[[tool.poetry.source]]\nname = \"x\"\n",
+		"[tool.pip]\nindex-url = \"https://example.com\"\nextra-index-url = 1\n",
+		"[tool.uv\n",
+		"[tool.uv]\nindex-strategy = \"best\"\n",
+		"[tool.uv.sources]\nmylib = { index = \"missing\" }\n[[tool.uv.index]]\nname = \"x\"\nurl = \"https://example.com\"\n",
+		"[tool.poetry.dependencies]\nmylib = { version = \"^1\", source = \"missing\" }\n[[tool.poetry.source]]\nname = \"x\"\nurl = \"https://example.com\"\n",
+	} {
+		if _, err := ParsePyProjectIndexes([]byte(content)); err == nil {
+			t.Errorf("expected an error for %q", content)
+		}
+	}
+}
+
+func TestSetIndexURLFromPyProjectTOMLSkipsExplicitIndexes(t *testing.T) {
+	p := New(true)
+	p.SetIndexURLFromPyProjectTOML([]byte(`[[tool.uv.index]]
+name = "pytorch"
+url = "https://download.pytorch.org/whl/cpu"
+explicit = true
+`))
+	if p.pypiURL != "https://pypi.org/pypi" || len(p.GetExtraIndexURLs()) != 0 {
+		t.Errorf("explicit index was used for all packages: %s %v", p.pypiURL, p.GetExtraIndexURLs())
+	}
+}
+
+func TestIndexSetFromRequirementsIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"requirements.txt": "--index-url=https://first.example.com/simple\n-r base.txt\n-e .\nflask==2.0.0\n",
+		"base.txt":         "--index-url https://second.example.com/simple\n--extra-index-url=https://extra.example.com/simple\nrequests==2.25.0\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	doc, err := requirements.Load(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// The included file is read after the --index-url of its parent, so its
+	// own --index-url wins
+	expected := []Index{
+		{URL: "https://second.example.com/simple", Default: true},
+		{URL: "https://extra.example.com/simple"},
+	}
+	if set := IndexSetFromRequirements(doc); !reflect.DeepEqual(set.Indexes, expected) {
+		t.Errorf("indexes = %+v, want %+v", set.Indexes, expected)
+	}
+}
+
+func TestSetIndexURLFromPyProjectTOMLKeepsPyPIForSupplementalSources(t *testing.T) {
+	p := New(true)
+	p.SetIndexURLFromPyProjectTOML([]byte(`[[tool.poetry.source]]
+name = "private"
+url = "https://private.example.com/simple"
+priority = "supplemental"
+`))
+	if p.isCustomIndexURL || p.pypiURL != defaultIndexURL {
+		t.Errorf("supplemental source replaced PyPI: %s", p.pypiURL)
+	}
+	if extras := p.GetExtraIndexURLs(); !reflect.DeepEqual(extras, []string{"https://private.example.com/pypi"}) {
+		t.Errorf("extras = %v, want the supplemental source", extras)
+	}
+}
+
+func TestPyPIFallbackFollowsProject(t *testing.T) {
+	primary := `[[tool.poetry.source]]
+name = "private"
+url = "https://private.example.com/simple"
+priority = "primary"
+`
+	supplemental := `[[tool.poetry.source]]
+name = "private"
+url = "https://private.example.com/simple"
+priority = "supplemental"
+`
+	tests := []struct {
+		name    string
+		content string
+		// flag is the -no-pypi-fallback setting: "", "on" or "off"
+		flag     string
+		expected bool
+	}{
+		{"no indexes", "", "", true},
+		{"Poetry primary source", primary, "", false},
+		{"Poetry supplemental source", supplemental, "", true},
+		{"uv index", "[[tool.uv.index]]\nname = \"extra\"\nurl = \"https://extra.example.com/simple\"\n", "", true},
+		{"uv default index", "[[tool.uv.index]]\nname = \"main\"\nurl = \"https://main.example.com/simple\"\ndefault = true\n", "", false},
+		{"pip index-url", "[tool.pip]\nindex-url = \"https://custom.example.com/simple\"\n", "", false},
+		{"enabled by the user", primary, "on", true},
+		{"disabled by the user", supplemental, "off", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(false)
+			if tt.flag != "" {
+				p.SetPyPIFallback(tt.flag == "on")
+			}
+			p.SetIndexURLFromPyProjectTOML([]byte(tt.content))
+			if got := p.usesPyPIFallback(); got != tt.expected {
+				t.Errorf("usesPyPIFallback() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// indexServer serves the Simple API of an index (PEP 691) with a wheel for
+// each of the given versions of a package and records the packages it is
+// asked for
+func indexServer(t *testing.T, packages map[string][]string, requests *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := normalizeName(strings.Trim(strings.TrimPrefix(r.URL.Path, "/simple/"), "/"))
+		*requests = append(*requests, name)
+		versions, ok := packages[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var files []string
+		for _, v := range versions {
+			filename := fmt.Sprintf("%s-%s-py3-none-any.whl", name, v)
+			files = append(files, fmt.Sprintf(`{"filename": %q, "url": "/files/%s", "hashes": {}}`, filename, filename))
+		}
+		w.Header().Set("Content-Type", simpleJSONType)
+		io.WriteString(w, fmt.Sprintf(`{"meta": {"api-version": "1.1"}, "name": %q, "files": [%s]}`, name, strings.Join(files, ", ")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestIndexRouting(t *testing.T) {
+	var primaryRequests, internalRequests []string
+	primary := indexServer(t, map[string][]string{
+		"flask":  {"2.0.0", "3.0.0"},
+		"mylib":  {"99.0.0"},
+		"shared": {"1.0.0", "2.0.0"},
+	}, &primaryRequests)
+	internal := indexServer(t, map[string][]string{
+		"mylib":  {"1.0.0", "1.1.0"},
+		"shared": {"0.5.0", "1.0.0", "3.0.0"},
+	}, &internalRequests)
+
+	content := fmt.Sprintf(`[[tool.uv.index]]
+name = "main"
+url = "%s/simple"
+default = true
+
+[[tool.uv.index]]
+name = "internal"
+url = "%s/simple"
+explicit = true
+
+[tool.uv.sources]
+mylib = { index = "internal" }
+`, primary.URL, internal.URL)
+
+	p := New(true)
+	p.SetPyPIFallback(false)
+	p.SetIndexURLFromPyProjectTOML([]byte(content))
+
+	// A pinned package only comes from its index, even if another has a
+	// higher version
+	versions, err := p.GetVersions("MyLib")
+	if err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	sort.Strings(versions)
+	if !reflect.DeepEqual(versions, []string{"1.0.0", "1.1.0"}) {
+		t.Errorf("mylib versions = %v, want those of the internal index", versions)
+	}
+
+	// Other packages never reach the explicit index
+	if _, err := p.GetVersions("flask"); err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	if _, err := p.GetVersions("shared"); err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	if !reflect.DeepEqual(internalRequests, []string{"mylib"}) {
+		t.Errorf("internal index was asked for %v, want only mylib", internalRequests)
+	}
+
+	// Without the PyPI fallback, a package the indexes don't have is an error
+	if _, err := p.GetVersions("missing"); err == nil {
+		t.Errorf("expected an error for a package missing from every index")
+	}
+}
+
+func TestDefaultIndexLookedUpLast(t *testing.T) {
+	var mainRequests, extraRequests []string
+	main := indexServer(t, map[string][]string{"shared": {"1.0.0"}, "flask": {"3.0.0"}}, &mainRequests)
+	extra := indexServer(t, map[string][]string{"shared": {"2.0.0"}}, &extraRequests)
+
+	content := fmt.Sprintf(`[[tool.uv.index]]
+name = "main"
+url = "%s/simple"
+default = true
+
+[[tool.uv.index]]
+name = "extra"
+url = "%s/simple"
+`, main.URL, extra.URL)
+
+	p := New(true)
+	p.SetPyPIFallback(false)
+	p.SetIndexURLFromPyProjectTOML([]byte(content))
+
+	// The first index that has a package wins, and the default index is
+	// asked after the others
+	versions, err := p.GetVersions("shared")
+	if err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	if !reflect.DeepEqual(versions, []string{"2.0.0"}) {
+		t.Errorf("shared versions = %v, want those of the extra index", versions)
+	}
+	versions, err = p.GetVersions("flask")
+	if err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	if !reflect.DeepEqual(versions, []string{"3.0.0"}) {
+		t.Errorf("flask versions = %v, want those of the default index", versions)
+	}
+	if !reflect.DeepEqual(mainRequests, []string{"flask"}) {
+		t.Errorf("default index was asked for %v, want only flask", mainRequests)
+	}
+}
+
+func TestIndexStrategies(t *testing.T) {
+	var requests []string
+	first := indexServer(t, map[string][]string{"shared": {"1.0.0", "2.0.0"}}, &requests)
+	second := indexServer(t, map[string][]string{"shared": {"0.5.0", "1.0.0", "3.0.0"}}, &requests)
+
+	tests := []struct {
+		strategy IndexStrategy
+		expected []string
+	}{
+		{FirstIndex, []string{"1.0.0", "2.0.0"}},
+		{UnsafeFirstMatch, []string{"0.5.0", "1.0.0", "2.0.0"}},
+		{UnsafeBestMatch, []string{"0.5.0", "1.0.0", "2.0.0", "3.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			p := New(true)
+			p.SetPyPIFallback(false)
+			p.SetIndexStrategy(tt.strategy)
+			p.SetDirectIndexURL(first.URL + "/simple")
+			p.AddExtraDirectIndexURL(second.URL + "/simple")
+
+			versions, err := p.GetVersions("shared")
+			if err != nil {
+				t.Fatalf("GetVersions failed: %v", err)
+			}
+			sort.Strings(versions)
+			if !reflect.DeepEqual(versions, tt.expected) {
+				t.Errorf("versions = %v, want %v", versions, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithIndexes(t *testing.T) {
+	var requests []string
+	first := indexServer(t, map[string][]string{"shared": {"1.0.0"}}, &requests)
+	second := indexServer(t, map[string][]string{"shared": {"2.0.0"}}, &requests)
+
+	base := New(false)
+	base.SetPyPIFallback(false)
+	clients := map[string]*PyPI{
+		"1.0.0": base.WithIndexes(IndexSet{Indexes: []Index{{URL: first.URL + "/simple", Default: true}}}),
+		"2.0.0": base.WithIndexes(IndexSet{Indexes: []Index{{URL: second.URL + "/simple", Default: true}}}),
+	}
+	for i := 0; i < 2; i++ {
+		for expected, client := range clients {
+			versions, err := client.GetVersions("shared")
+			if err != nil {
+				t.Fatalf("GetVersions failed: %v", err)
+			}
+			if !reflect.DeepEqual(versions, []string{expected}) {
+				t.Errorf("versions = %v, want [%s]", versions, expected)
+			}
+		}
+	}
+	// Each index was asked once; the second round came from the cache
+	if len(requests) != 2 {
+		t.Errorf("expected 2 requests, got %v", requests)
+	}
+	if base.isCustomIndexURL || base.pypiURL != defaultIndexURL {
+		t.Errorf("WithIndexes changed the base client: %s", base.pypiURL)
+	}
+}
+
+func TestIndexCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "ci" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"info": {"name": "mylib"}, "releases": {"1.0.0": [{}]}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("UV_INDEX_PRIVATE_REPO_USERNAME", "ci")
+	t.Setenv("UV_INDEX_PRIVATE_REPO_PASSWORD", "s3cret")
+
+	index := Index{Name: "private-repo", URL: server.URL + "/simple"}
+	if got := redactURL(index.credentialURL()); !strings.Contains(got, "ci:xxxxx@") {
+		t.Errorf("expected redacted credentials in %q", got)
+	}
+
+	p := New(false).WithIndexes(IndexSet{Indexes: []Index{index}})
+	versions, err := p.GetVersions("mylib")
+	if err != nil {
+		t.Fatalf("GetVersions failed: %v", err)
+	}
+	if !reflect.DeepEqual(versions, []string{"1.0.0"}) {
+		t.Errorf("versions = %v, want [1.0.0]", versions)
+	}
+
+	index.Authenticate = "never"
+	if got := index.credentialURL(); got != server.URL+"/simple" {
+		t.Errorf("expected no credentials for an index that never authenticates, got %q", got)
+	}
+}
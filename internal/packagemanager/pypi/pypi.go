@@ -6,13 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
-	"sort"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -20,28 +21,91 @@ import (
 	"golang.org/x/net/html"
 
 	"github.com/rvben/ru/internal/cache"
+	"github.com/rvben/ru/internal/packagemanager/requirements"
+	"github.com/rvben/ru/internal/pep440"
 	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // Buffer size for JSON token reader
 const bufferSize = 4096
 
-// PyPI represents a PyPI package manager
+// defaultIndexURL is the JSON API of PyPI itself
+const defaultIndexURL = "https://pypi.org/pypi"
+
+// Release is a version of a package listed by an index.
+type Release struct {
+	Version string
+	// RequiresPython is the Python versions the release supports, e.g.
+	// ">=3.9", or "" if it does not say
+	RequiresPython string
+	// Files are the names of the release's distribution files, as far as
+	// the index lists them
+	Files []string
+	// Yanked releases (PEP 592) have all of their files yanked, with the
+	// index's reason in YankedReason if it gives one
+	Yanked       bool
+	YankedReason string
+	// UploadTime is when the first file of the release was uploaded, or
+	// zero if the index does not say
+	UploadTime time.Time
+}
+
+// releaseVersions returns the versions of releases
+func releaseVersions(releases []Release) []string {
+	versions := make([]string, len(releases))
+	for i, r := range releases {
+		versions[i] = r.Version
+	}
+	return versions
+}
+
+// sharedState is what a PyPI client shares with the clients derived from it
+// with WithIndexes
+type sharedState struct {
+	// releaseCache holds the result of looking a package up on an index, by
+	// index URL and normalized package name
+	releaseCache          map[releaseKey]indexResult
+	cacheMutex            sync.Mutex
+	unreachableHosts      map[string]time.Time
+	unreachableHostsMutex sync.RWMutex
+}
+
+// releaseKey identifies a package on an index
+type releaseKey struct {
+	indexURL string
+	name     string
+}
+
+// indexResult is the outcome of looking a package up on an index
+type indexResult struct {
+	releases []Release
+	err      error
+}
+
+// PyPI represents a PyPI package manager. Its index configuration is set up
+// before lookups start; a project with indexes of its own uses a client
+// derived with WithIndexes.
 type PyPI struct {
+	*sharedState
+
 	packageManagerType        string
 	pypiURL                   string
 	extraIndexURLs            []string
 	isCustomIndexURL          bool
 	isCodeArtifact            bool
-	versionCache              map[string]string
-	cacheMutex                sync.Mutex
+	primaryLast               bool
+	pinnedIndexURLs           map[string]string
+	simpleURLs                map[string]string
+	indexStrategy             IndexStrategy
+	projectIndexStrategy      IndexStrategy
+	pypiFallback              *bool
+	projectReplacesPyPI       bool
 	cache                     *cache.Cache
 	noCache                   bool
 	client                    *utils.OptimizerHTTPClient
 	potentialPipConfLocations []string
 	verbose                   bool
-	unreachableHosts          map[string]time.Time
-	unreachableHostsMutex     sync.RWMutex
 	hostRetryInterval         time.Duration
 }
 
@@ -49,19 +113,21 @@ type PyPI struct {
 func New(verbose bool) *PyPI {
 	pypi := &PyPI{
 		packageManagerType: "pypi",
-		pypiURL:            "https://pypi.org/pypi",
+		pypiURL:            defaultIndexURL,
 		extraIndexURLs:     []string{},
 		isCustomIndexURL:   false,
 		verbose:            verbose,
 		noCache:            false,
-		versionCache:       make(map[string]string),
 		client:             utils.NewHTTPClient(),
 		potentialPipConfLocations: []string{
 			filepath.Join(os.Getenv("HOME"), ".config", "pip", "pip.conf"),
 			filepath.Join(os.Getenv("HOME"), ".pip", "pip.conf"),
 			"/etc/pip.conf",
 		},
-		unreachableHosts:  make(map[string]time.Time),
+		sharedState: &sharedState{
+			releaseCache:     make(map[releaseKey]indexResult),
+			unreachableHosts: make(map[string]time.Time),
+		},
 		hostRetryInterval: 30 * time.Minute, // Only retry unreachable hosts after 30 minutes
 	}
 
@@ -217,223 +283,155 @@ func (p *PyPI) getExtraIndexURLsFromEnv() []string {
 	return extraURLs
 }
 
-// addExtraIndexURL adds an extra index URL to the list
-func (p *PyPI) addExtraIndexURL(url string) {
+// indexAPIURL returns the base URL of the JSON API of an index: /simple is
+// replaced by /pypi, the /+simple of devpi is dropped and /pypi is appended
+// to other URLs, except for CodeArtifact. Indexes are identified by this URL
+// but looked up on the Simple API at the URL they were configured with.
+func indexAPIURL(url string) string {
 	// Clean up the URL - trim trailing slashes
 	url = strings.TrimRight(url, "/")
 
+	if base, ok := strings.CutSuffix(url, "/+simple"); ok {
+		return base
+	}
 	// Handle URLs with /simple suffix - convert to /pypi to match test expectations
 	if strings.HasSuffix(url, "/simple") {
-		url = strings.TrimSuffix(url, "/simple") + "/pypi"
-	} else if strings.Contains(url, "amazonaws.com") {
-		// For CodeArtifact URLs, don't append /pypi
-		// Do nothing, keep the URL as is
-	} else {
-		// Otherwise, ensure the URL ends with /pypi
-		if !strings.HasSuffix(url, "/pypi") {
-			url = url + "/pypi"
-		}
+		return strings.TrimSuffix(url, "/simple") + "/pypi"
+	}
+	// For CodeArtifact URLs, don't append /pypi
+	if strings.Contains(url, "amazonaws.com") || strings.HasSuffix(url, "/pypi") {
+		return url
+	}
+	return url + "/pypi"
+}
+
+// configureIndex returns the base URL of the JSON API of the index at url
+// and remembers url as its Simple API root
+func (p *PyPI) configureIndex(url string) string {
+	apiURL := indexAPIURL(url)
+	if p.simpleURLs == nil {
+		p.simpleURLs = make(map[string]string)
 	}
+	p.simpleURLs[apiURL] = strings.TrimRight(url, "/")
+	return apiURL
+}
+
+// addExtraIndexURL adds an extra index URL to the list
+func (p *PyPI) addExtraIndexURL(url string) {
+	url = p.configureIndex(url)
 
 	// Check if the URL is already in the list
 	for _, existingURL := range p.extraIndexURLs {
 		if existingURL == url {
-			utils.VerboseLog(p.verbose, fmt.Sprintf("Extra index URL already exists, skipping: %s", url))
+			utils.VerboseLog(p.verbose, fmt.Sprintf("Extra index URL already exists, skipping: %s", redactURL(url)))
 			return
 		}
 	}
 
 	p.extraIndexURLs = append(p.extraIndexURLs, url)
-	utils.VerboseLog(p.verbose, fmt.Sprintf("Added extra PyPI index URL: %s", url))
+	utils.VerboseLog(p.verbose, fmt.Sprintf("Added extra PyPI index URL: %s", redactURL(url)))
 }
 
-// SetIndexURLFromRequirements sets the PyPI index URL from a requirements file content.
-func (p *PyPI) SetIndexURLFromRequirements(content string) {
-	var primaryIndexURL string
-	var extraIndexURLs []string
-
-	// Create a scanner to read the content line by line
-	scanner := bufio.NewScanner(strings.NewReader(content))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
+// IndexSetFromRequirements returns the indexes a requirements file and the
+// files it includes set with --index-url (or -i) and --extra-index-url. As in
+// pip, the last --index-url of the tree wins.
+func IndexSetFromRequirements(doc *requirements.Document) IndexSet {
+	var set IndexSet
+	for _, line := range doc.Lines() {
+		if line.Kind != requirements.KindOption || line.Value == "" {
 			continue
 		}
-
-		// Check for --index-url or -i flags
-		if strings.HasPrefix(line, "--index-url") || strings.HasPrefix(line, "-i ") {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				url := strings.TrimSpace(parts[1])
-				primaryIndexURL = url
-				utils.VerboseLog(p.verbose, fmt.Sprintf("Found primary index URL in requirements file: %s", url))
-			}
-		} else if strings.HasPrefix(line, "--extra-index-url") || strings.HasPrefix(line, "-e ") {
-			// Check for --extra-index-url or -e flags
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				url := strings.TrimSpace(parts[1])
-				extraIndexURLs = append(extraIndexURLs, url)
-				utils.VerboseLog(p.verbose, fmt.Sprintf("Found extra index URL in requirements file: %s", url))
-			}
+		url := requirements.ExpandEnv(line.Value)
+		switch line.Option {
+		case "--index-url":
+			// A later --index-url replaces an earlier one
+			set.Indexes = slices.DeleteFunc(set.Indexes, func(index Index) bool { return index.Default })
+			set.Indexes = append(set.Indexes, Index{URL: url, Default: true})
+			utils.Debug("pypi", "Found primary index URL in requirements file: %s", redactURL(url))
+		case "--extra-index-url":
+			set.Indexes = append(set.Indexes, Index{URL: url})
+			utils.Debug("pypi", "Found extra index URL in requirements file: %s", redactURL(url))
 		}
 	}
+	return set
+}
 
-	// Set the primary index URL if found
-	if primaryIndexURL != "" {
-		p.setIndexURL(primaryIndexURL)
+// SetIndexURLFromPyProjectTOML sets the PyPI index URLs from the index
+// configuration in pyproject.toml content. Explicit indexes are left out as
+// they only serve the packages pinned to them.
+func (p *PyPI) SetIndexURLFromPyProjectTOML(content []byte) {
+	set, err := ParsePyProjectIndexes(content)
+	if err != nil {
+		utils.Warning("Ignoring the index configuration in pyproject.toml: %v", err)
+		return
 	}
-
-	// Add extra index URLs if found
-	for _, url := range extraIndexURLs {
-		p.addExtraIndexURL(url)
+	for _, index := range set.Explicit() {
+		utils.VerboseLog(p.verbose, fmt.Sprintf("Skipping explicit index %q in pyproject.toml: %s", index.Name, redactURL(index.URL)))
 	}
+	p.UseIndexes(set)
 }
 
-// SetIndexURLFromPyProjectTOML sets the PyPI index URL from pyproject.toml content.
-func (p *PyPI) SetIndexURLFromPyProjectTOML(content []byte) {
-	contentStr := string(content)
-	var primaryIndexURL string
-	var extraIndexURLs []string
-
-	// Try to find UV indices first (highest precedence for pyproject.toml)
-
-	// 1. Look for UV indices with default = true
-	uvSourceRegex := regexp.MustCompile(`\[\[tool\.uv\.index\]\][\s\n]+name\s*=\s*["']([^"']+)["'][\s\n]+(?:.*[\s\n]+)*?url\s*=\s*["']([^"']+)["'][\s\n]+(?:.*[\s\n]+)*?default\s*=\s*true`)
-	uvMatches := uvSourceRegex.FindAllStringSubmatch(contentStr, -1)
-	if len(uvMatches) > 0 {
-		for _, match := range uvMatches {
-			if len(match) >= 3 {
-				// match[1] is the name, match[2] is the URL
-				url := match[2]
-				primaryIndexURL = url
-				utils.VerboseLog(p.verbose, fmt.Sprintf("Found default UV index in pyproject.toml: %s", url))
-				break
-			}
+// UseIndexes looks packages up on the indexes of set: its primary index
+// replaces the current one, its extra indexes are added and the packages it
+// pins are only looked up on their index. Clients shared between projects
+// use WithIndexes instead.
+func (p *PyPI) UseIndexes(set IndexSet) {
+	if primary := set.Primary(); primary != nil {
+		p.setIndexURL(primary.credentialURL())
+		p.primaryLast = set.PrimaryLast
+	}
+	for _, index := range set.Extras() {
+		p.addExtraIndexURL(index.credentialURL())
+	}
+	for name := range set.Sources {
+		index := set.Pinned(name)
+		if p.pinnedIndexURLs == nil {
+			p.pinnedIndexURLs = make(map[string]string)
 		}
+		p.pinnedIndexURLs[name] = p.configureIndex(index.credentialURL())
+		utils.VerboseLog(p.verbose, fmt.Sprintf("Pinned %s to index %q: %s", name, index.Name, redactURL(index.URL)))
 	}
-
-	// 2. Look for all UV indices to find non-default ones
-	allUVSourceRegex := regexp.MustCompile(`\[\[tool\.uv\.index\]\][\s\n]+name\s*=\s*["']([^"']+)["'][\s\n]+(?:.*[\s\n]+)*?url\s*=\s*["']([^"']+)["']`)
-	allUVMatches := allUVSourceRegex.FindAllStringSubmatch(contentStr, -1)
-
-	// Process all UV indices and add the ones that aren't already the primary as extras
-	for _, match := range allUVMatches {
-		if len(match) >= 3 {
-			url := match[2]
-			// Skip the one we already set as primary
-			if url == primaryIndexURL {
-				continue
-			}
-
-			// Add as an extra index URL
-			extraIndexURLs = append(extraIndexURLs, url)
-			utils.VerboseLog(p.verbose, fmt.Sprintf("Found non-default UV index in pyproject.toml: %s", url))
-		}
+	if set.Strategy != "" {
+		p.projectIndexStrategy = set.Strategy
 	}
-
-	// 3. If no primary UV index was found but there are UV indices, use the first one as primary
-	if primaryIndexURL == "" && len(allUVMatches) > 0 && len(allUVMatches[0]) >= 3 {
-		primaryIndexURL = allUVMatches[0][2]
-		utils.VerboseLog(p.verbose, fmt.Sprintf("No default UV index found, using first UV index as primary: %s", primaryIndexURL))
-
-		// Remove this URL from extras if it's there
-		for i, url := range extraIndexURLs {
-			if url == primaryIndexURL {
-				// Remove this URL from the slice
-				extraIndexURLs = append(extraIndexURLs[:i], extraIndexURLs[i+1:]...)
-				break
-			}
-		}
+	if set.ReplacesPyPI() {
+		p.projectReplacesPyPI = true
 	}
+}
 
-	// If UV indices are not found, try Poetry sources next
-	if primaryIndexURL == "" {
-		// 4. Look for primary Poetry source with priority = "primary" or "default"
-		primarySourceRegex := regexp.MustCompile(`\[\[tool\.poetry\.source\]\][\s\n]+(?:.*?name\s*=\s*["']([^"']+)["'])?[\s\n]+(?:.*?url\s*=\s*["']([^"']+)["'])?[\s\n]+(?:.*?priority\s*=\s*["'](primary|default)["'])?`)
-		primaryMatches := primarySourceRegex.FindAllStringSubmatch(contentStr, -1)
-		for _, match := range primaryMatches {
-			if len(match) >= 4 && match[3] != "" { // Check that we have a priority field
-				name := match[1]
-				url := match[2]
-
-				// Only set if we have a valid URL
-				if url != "" {
-					primaryIndexURL = url
-					utils.VerboseLog(p.verbose, fmt.Sprintf("Found primary Poetry source '%s' in pyproject.toml with URL: %s", name, url))
-					break
-				}
-			}
-		}
-
-		// 5. Look for supplemental Poetry sources
-		supplementalSourceRegex := regexp.MustCompile(`\[\[tool\.poetry\.source\]\][\s\n]+(?:.*?name\s*=\s*["']([^"']+)["'])?[\s\n]+(?:.*?url\s*=\s*["']([^"']+)["'])?[\s\n]+(?:.*?priority\s*=\s*["'](supplemental|secondary)["'])?`)
-		supplementalMatches := supplementalSourceRegex.FindAllStringSubmatch(contentStr, -1)
-		for _, match := range supplementalMatches {
-			if len(match) >= 4 && match[3] != "" { // Check that we have a priority field
-				name := match[1]
-				url := match[2]
-
-				// Only add if we have a valid URL
-				if url != "" {
-					extraIndexURLs = append(extraIndexURLs, url)
-					utils.VerboseLog(p.verbose, fmt.Sprintf("Found supplemental Poetry source '%s' in pyproject.toml with URL: %s", name, url))
-				}
-			}
-		}
-
-		// 6. If no primary source was found, try a regular Poetry source without priority
-		if primaryIndexURL == "" {
-			regularSourceRegex := regexp.MustCompile(`\[\[tool\.poetry\.source\]\][\s\n]+(?:.*[\s\n]+)*?name\s*=\s*["']([^"']+)["'][\s\n]+(?:.*[\s\n]+)*?url\s*=\s*["']([^"']+)["']`)
-			regularMatches := regularSourceRegex.FindAllStringSubmatch(contentStr, -1)
-			if len(regularMatches) > 0 {
-				for _, match := range regularMatches {
-					if len(match) >= 3 {
-						// match[1] is the name, match[2] is the URL
-						url := match[2]
-						primaryIndexURL = url
-						utils.VerboseLog(p.verbose, fmt.Sprintf("Found regular Poetry source in pyproject.toml: %s", url))
-						break
-					}
-				}
-			}
-		}
-	}
+// WithIndexes returns a client that also uses the indexes of set, as
+// UseIndexes does, and leaves p unchanged. It shares the HTTP client, the
+// releases looked up so far and the unreachable hosts with p, so clients
+// for projects with different indexes can be used side by side.
+func (p *PyPI) WithIndexes(set IndexSet) *PyPI {
+	c := *p
+	c.extraIndexURLs = slices.Clone(p.extraIndexURLs)
+	c.pinnedIndexURLs = maps.Clone(p.pinnedIndexURLs)
+	c.simpleURLs = maps.Clone(p.simpleURLs)
+	c.UseIndexes(set)
+	return &c
+}
 
-	// 7. If no Poetry or UV sources found, check for pip configuration
-	if primaryIndexURL == "" {
-		pipIndexRegex := regexp.MustCompile(`\[tool\.pip\](?:\s|\n)+index-url\s*=\s*["']([^"']+)["']`)
-		pipMatches := pipIndexRegex.FindStringSubmatch(contentStr)
-		if len(pipMatches) > 1 {
-			url := pipMatches[1]
-			primaryIndexURL = url
-			utils.VerboseLog(p.verbose, fmt.Sprintf("Found pip index-url in pyproject.toml: %s", url))
-
-			// Also check for extra-index-url in the pip section
-			pipExtraIndexRegex := regexp.MustCompile(`\[tool\.pip\](?:\s|\n)+(?:.*\n)*?extra-index-url\s*=\s*["']([^"']+)["']`)
-			pipExtraMatches := pipExtraIndexRegex.FindStringSubmatch(contentStr)
-			if len(pipExtraMatches) > 1 {
-				extraURLsStr := pipExtraMatches[1]
-				// Split by space if multiple URLs are provided
-				for _, url := range strings.Fields(extraURLsStr) {
-					extraIndexURLs = append(extraIndexURLs, url)
-					utils.VerboseLog(p.verbose, fmt.Sprintf("Found pip extra-index-url in pyproject.toml: %s", url))
-				}
-			}
-		}
-	}
+// SetIndexStrategy sets how packages are looked up across indexes,
+// overriding the strategy of the project
+func (p *PyPI) SetIndexStrategy(strategy IndexStrategy) {
+	p.indexStrategy = strategy
+}
 
-	// Set the primary index URL if found
-	if primaryIndexURL != "" {
-		p.setIndexURL(primaryIndexURL)
-	}
+// SetPyPIFallback sets whether packages a custom index and its extra
+// indexes don't have are looked up on PyPI, overriding the project. By
+// default they are, unless the indexes of the project replace PyPI.
+func (p *PyPI) SetPyPIFallback(enabled bool) {
+	p.pypiFallback = &enabled
+}
 
-	// Add extra index URLs if found
-	for _, url := range extraIndexURLs {
-		p.addExtraIndexURL(url)
+// usesPyPIFallback reports whether packages the custom indexes don't have
+// are looked up on PyPI
+func (p *PyPI) usesPyPIFallback() bool {
+	if p.pypiFallback != nil {
+		return *p.pypiFallback
 	}
+	return !p.projectReplacesPyPI
 }
 
 // SetDirectIndexURL allows setting the index URL directly from code
@@ -455,161 +453,289 @@ func (p *PyPI) GetExtraIndexURLs() []string {
 	return p.extraIndexURLs
 }
 
-// ClearExtraIndexURLs clears all extra index URLs
-func (p *PyPI) ClearExtraIndexURLs() {
-	p.extraIndexURLs = make([]string, 0)
-}
-
 // GetLatestVersion returns the latest version of a package from PyPI
 func (p *PyPI) GetLatestVersion(packageName string) (string, error) {
-	// Check if using cached version
+	versions, err := p.GetVersions(packageName)
+	if err != nil {
+		return "", err
+	}
+	version, err := p.selectLatestStableVersion(versions, "")
+	if err != nil {
+		return "", fmt.Errorf("error selecting latest version for %s: %w", packageName, err)
+	}
+	return version, nil
+}
+
+// GetLatestVersionFrom returns the latest version of a package for a
+// project on version current. Pre-releases are only considered when current
+// is one; otherwise this is GetLatestVersion.
+func (p *PyPI) GetLatestVersionFrom(packageName, current string) (string, error) {
+	if cur, err := pep440.Parse(current); err != nil || !cur.IsPrerelease() {
+		return p.GetLatestVersion(packageName)
+	}
+	versions, err := p.GetVersions(packageName)
+	if err != nil {
+		return "", err
+	}
+	version, err := p.selectLatestStableVersion(versions, current)
+	if err != nil {
+		return "", fmt.Errorf("error selecting latest version for %s: %w", packageName, err)
+	}
+	return version, nil
+}
+
+// GetHighestVersion returns the highest release of a package that is newer
+// than current without crossing level, or "" if there is none.
+func (p *PyPI) GetHighestVersion(packageName, current string, level versioning.Level) (string, error) {
+	versions, err := p.GetVersions(packageName)
+	if err != nil {
+		return "", err
+	}
+	return versioning.Highest(versioning.PEP440, versions, current, level)
+}
+
+// GetVersions returns the versions of a package listed by the first index
+// that has it: the custom index, its extra indexes, then PyPI itself.
+// Yanked releases (PEP 592) are left out, so they are never updated to.
+func (p *PyPI) GetVersions(packageName string) ([]string, error) {
+	releases, err := p.GetReleases(packageName)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, r := range releases {
+		if r.Yanked {
+			utils.Debug("pypi", "Skipping yanked release %s %s", utils.FormatPackageName(packageName), r.Version)
+			continue
+		}
+		versions = append(versions, r.Version)
+	}
+	return versions, nil
+}
+
+// GetReleases returns every release of a package listed by the first index
+// that has it, yanked ones included, together with its metadata.
+func (p *PyPI) GetReleases(packageName string) ([]Release, error) {
+	return p.findReleases(packageName)
+}
+
+// indexReleases returns the releases of a package listed by the index at
+// baseURL. Results are cached by index and package, so projects using
+// different indexes never see each other's releases.
+func (p *PyPI) indexReleases(packageName, baseURL string) ([]Release, error) {
+	key := releaseKey{indexURL: baseURL, name: normalizeName(packageName)}
 	if !p.noCache {
 		p.cacheMutex.Lock()
-		cachedVersion, ok := p.versionCache[packageName]
+		result, ok := p.releaseCache[key]
 		p.cacheMutex.Unlock()
 		if ok {
-			utils.Info("cache", "Using cached version for %s: %s", utils.FormatPackageName(packageName), utils.FormatVersion(cachedVersion))
-			return cachedVersion, nil
+			return result.releases, result.err
 		}
 	}
 
-	var version string
-	var err error
+	releases, err := p.fetchReleases(packageName, baseURL)
+	if !p.noCache {
+		p.cacheMutex.Lock()
+		p.releaseCache[key] = indexResult{releases: releases, err: err}
+		p.cacheMutex.Unlock()
+	}
+	return releases, err
+}
 
-	// Try with custom index URL first if set
+// findReleases fetches the releases of a package. A package pinned to an
+// index is only looked up there. Other packages are looked up on the custom
+// index, its extra indexes and then PyPI, unless the fallback to PyPI is
+// disabled, combining them according to the index strategy.
+func (p *PyPI) findReleases(packageName string) ([]Release, error) {
+	if url, ok := p.pinnedIndexURLs[normalizeName(packageName)]; ok {
+		releases, err := p.indexReleases(packageName, url)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s from its pinned index %s: %w", packageName, redactURL(url), err)
+		}
+		return releases, nil
+	}
+
+	urls := []string{defaultIndexURL}
 	if p.isCustomIndexURL {
-		version, err = p.getLatestVersionFromHTML(packageName, p.pypiURL)
-
-		// If package not found in primary index and we have extra index URLs, try them
-		if err != nil && len(p.extraIndexURLs) > 0 {
-			var extraErr error
-			for _, extraURL := range p.extraIndexURLs {
-				// Skip known unreachable hosts
-				if p.IsHostUnreachable(extraURL) {
-					utils.Debug("pypi", "Skipping unreachable extra index: %s", utils.FormatURL(extraURL))
-					continue
-				}
+		urls[0] = p.pypiURL
+	}
+	for _, url := range p.extraIndexURLs {
+		if !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	if p.primaryLast {
+		// The default index of uv comes after the other indexes
+		urls = append(urls[1:], urls[0])
+	}
+	if p.usesPyPIFallback() && !slices.Contains(urls, defaultIndexURL) {
+		urls = append(urls, defaultIndexURL)
+	}
 
-				utils.Info("pypi", "Package %s not found in primary index, trying extra index: %s",
-					utils.FormatPackageName(packageName), utils.FormatURL(extraURL))
-				version, extraErr = p.getLatestVersionFromHTML(packageName, extraURL)
-				if extraErr == nil {
-					// Found in one of the extra indexes
-					err = nil
-					break
-				}
+	strategy := p.indexStrategy
+	if strategy == "" {
+		strategy = p.projectIndexStrategy
+	}
 
-				// Check if this host seems to be unreachable
-				if strings.Contains(extraErr.Error(), "no such host") ||
-					strings.Contains(extraErr.Error(), "connection refused") ||
-					strings.Contains(extraErr.Error(), "host is down") {
-					p.MarkHostUnreachable(extraURL)
-				}
-			}
+	var found [][]Release
+	var err error
+	for i, url := range urls {
+		// Skip known unreachable hosts
+		if i > 0 && p.IsHostUnreachable(url) {
+			utils.Debug("pypi", "Skipping unreachable index: %s", utils.FormatURL(redactURL(url)))
+			continue
+		}
+		if i > 0 && len(found) == 0 {
+			utils.Info("pypi", "Package %s not found in %s, trying index: %s",
+				utils.FormatPackageName(packageName), utils.FormatURL(redactURL(urls[i-1])), utils.FormatURL(redactURL(url)))
 		}
 
-		// If found in any index, cache and return the result
-		if err == nil {
-			if !p.noCache {
-				p.cacheMutex.Lock()
-				p.versionCache[packageName] = version
-				p.cacheMutex.Unlock()
+		releases, fetchErr := p.indexReleases(packageName, url)
+		if fetchErr != nil {
+			utils.Debug("pypi", "Error fetching %s from %s: %v", utils.FormatPackageName(packageName), utils.FormatURL(redactURL(url)), fetchErr)
+			err = fetchErr
+			// Check if this host seems to be unreachable
+			if strings.Contains(fetchErr.Error(), "no such host") ||
+				strings.Contains(fetchErr.Error(), "connection refused") ||
+				strings.Contains(fetchErr.Error(), "host is down") {
+				p.MarkHostUnreachable(url)
 			}
-			return version, nil
+			continue
 		}
+		if strategy != UnsafeFirstMatch && strategy != UnsafeBestMatch {
+			return releases, nil
+		}
+		found = append(found, releases)
+	}
 
-		// If still have error, fall back to default PyPI
-		utils.Debug("pypi", "Error fetching %s from custom index: %v", utils.FormatPackageName(packageName), err)
+	if len(found) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no reachable index")
+		}
+		return nil, fmt.Errorf("error fetching %s: %v", packageName, err)
 	}
+	return mergeReleases(found, strategy), nil
+}
 
-	// Use default PyPI URL if custom URL didn't work or wasn't provided
-	version, err = p.getLatestVersionFromHTML(packageName, "https://pypi.org/pypi")
-	if err != nil {
-		return "", fmt.Errorf("error fetching %s: %v", packageName, err)
+// mergeReleases combines the releases of a package from several indexes,
+// given in lookup order. A version listed by several indexes is taken from
+// the first; with UnsafeFirstMatch an index only adds versions older than
+// every release of the indexes before it.
+func mergeReleases(found [][]Release, strategy IndexStrategy) []Release {
+	var merged []Release
+	seen := make(map[string]bool)
+	var oldest *pep440.Version
+	for _, releases := range found {
+		var indexOldest *pep440.Version
+		for _, r := range releases {
+			key := r.Version
+			v, err := pep440.Parse(r.Version)
+			if err == nil {
+				key = v.String()
+			}
+			if seen[key] {
+				continue
+			}
+			if strategy == UnsafeFirstMatch && oldest != nil && (err != nil || v.Compare(oldest) >= 0) {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, r)
+			if err == nil && (indexOldest == nil || v.Compare(indexOldest) < 0) {
+				indexOldest = v
+			}
+		}
+		if indexOldest != nil && (oldest == nil || indexOldest.Compare(oldest) < 0) {
+			oldest = indexOldest
+		}
 	}
+	return merged
+}
 
-	// Cache the version
-	if !p.noCache {
-		p.cacheMutex.Lock()
-		p.versionCache[packageName] = version
-		p.cacheMutex.Unlock()
+// fetchReleases returns the releases of a package listed by the index at
+// baseURL. PyPI is asked through its JSON API; other indexes through the
+// Simple API, falling back to the JSON API that some of them mirror.
+func (p *PyPI) fetchReleases(packageName string, baseURL string) ([]Release, error) {
+	if baseURL == defaultIndexURL {
+		return p.fetchJSONReleases(packageName, baseURL)
 	}
+	releases, err := p.fetchSimpleReleases(packageName, baseURL)
+	if err == nil || isUnreachableError(err) {
+		return releases, err
+	}
+	utils.Debug("pypi", "Simple API failed for %s, trying the JSON API: %v", utils.FormatPackageName(packageName), err)
+	releases, jsonErr := p.fetchJSONReleases(packageName, baseURL)
+	if jsonErr != nil {
+		return nil, err
+	}
+	return releases, nil
+}
 
-	return version, nil
+// isUnreachableError reports whether a request failed because the host of
+// an index could not be reached
+func isUnreachableError(err error) bool {
+	return strings.Contains(err.Error(), "circuit breaker open for host") ||
+		strings.Contains(err.Error(), "no such host") ||
+		strings.Contains(err.Error(), "connection refused")
 }
 
-func (p *PyPI) getLatestVersionFromHTML(packageName string, baseURL string) (string, error) {
-	// Construct URL - use JSON API by default
+// responseReader returns the body of a response, decompressed if needed
+func responseReader(resp *http.Response) (io.Reader, error) {
+	utils.Debug("http", "Response content encoding: %s", resp.Header.Get("Content-Encoding"))
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return resp.Body, nil
+	}
+	utils.Info("http", "Response is gzip encoded, decompressing")
+	gzipReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating gzip reader: %w", err)
+	}
+	return gzipReader, nil
+}
+
+// fetchJSONReleases returns the releases of a package from the JSON API of
+// the index at baseURL
+func (p *PyPI) fetchJSONReleases(packageName string, baseURL string) ([]Release, error) {
 	url := fmt.Sprintf("%s/%s/json", baseURL, packageName)
-	utils.Debug("http", "Trying URL (JSON format): %s", utils.FormatURL(url))
+	utils.Debug("http", "Trying URL (JSON format): %s", utils.FormatURL(redactURL(url)))
 
-	// Make request
 	resp, err := p.client.GetWithRetry(url, map[string]string{
 		"Accept":          "application/json",
 		"Accept-Encoding": "gzip, deflate", // Request compression support
 	})
-
 	if err != nil {
-		// Check if this seems to be a host unreachable error
-		if strings.Contains(err.Error(), "circuit breaker open for host") ||
-			strings.Contains(err.Error(), "no such host") ||
-			strings.Contains(err.Error(), "connection refused") {
+		if isUnreachableError(err) {
 			p.MarkHostUnreachable(baseURL)
 		}
-
-		// If the JSON API fails, try the HTML endpoint as fallback
-		url = fmt.Sprintf("%s/%s/", baseURL, packageName)
-		utils.Debug("http", "JSON API failed, trying URL (HTML format): %s", utils.FormatURL(url))
-		resp, err = p.client.GetWithRetry(url, map[string]string{
-			"Accept":          "text/html",
-			"Accept-Encoding": "gzip, deflate",
-		})
-		if err != nil {
-			// Also check this second attempt for host unreachable errors
-			if strings.Contains(err.Error(), "circuit breaker open for host") ||
-				strings.Contains(err.Error(), "no such host") ||
-				strings.Contains(err.Error(), "connection refused") {
-				p.MarkHostUnreachable(baseURL)
-			}
-			return "", err
-		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// Log response details
 	utils.Debug("http", "Response status: %s", utils.FormatHTTPStatus(resp.Status))
 	utils.Debug("http", "Response content type: %s", resp.Header.Get("Content-Type"))
-	utils.Debug("http", "Response content encoding: %s", resp.Header.Get("Content-Encoding"))
 
 	// Check if we got a successful response
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP error: %s", resp.Status)
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
 	}
 
-	// Setup reader based on content encoding
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		utils.Info("http", "Response is gzip encoded, decompressing")
-		gzipReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("error creating gzip reader: %w", err)
-		}
-		defer gzipReader.Close()
-		reader = gzipReader
+	reader, err := responseReader(resp)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse the response based on content type
 	if strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
 		utils.Debug("pypi", "Parsing JSON response for %s", packageName)
-		return p.parseJSONForLatestVersion(reader, packageName)
-	} else {
-		utils.Debug("pypi", "Parsing HTML response for %s", packageName)
-		return p.parseHTMLContentForLatestVersion(reader)
+		return p.parseJSONReleases(reader, packageName)
 	}
+	utils.Debug("pypi", "Parsing HTML response for %s", packageName)
+	return p.parseHTMLReleases(reader)
 }
 
-// parseJSONForLatestVersion parses JSON content to extract version information
-func (p *PyPI) parseJSONForLatestVersion(reader io.Reader, packageName string) (string, error) {
+// parseJSONReleases returns the releases listed in a PyPI JSON API document
+func (p *PyPI) parseJSONReleases(reader io.Reader, packageName string) ([]Release, error) {
 	// Use buffered reader for efficiency
 	bufferedBody := bufio.NewReaderSize(reader, bufferSize)
 
@@ -629,52 +755,80 @@ func (p *PyPI) parseJSONForLatestVersion(reader io.Reader, packageName string) (
 			Version string `json:"version"`
 			Name    string `json:"name"`
 		} `json:"info"`
-		Releases map[string]interface{} `json:"releases"`
+		Releases map[string]json.RawMessage `json:"releases"`
 	}
 
 	if err := json.NewDecoder(bufferedBody).Decode(&data); err != nil {
 		utils.VerboseLog(p.verbose, "JSON parsing error:", err)
-		return "", fmt.Errorf("error parsing JSON for package %s: %w", packageName, err)
+		return nil, fmt.Errorf("error parsing JSON for package %s: %w", packageName, err)
 	}
 
 	utils.VerboseLog(p.verbose, "Package info name:", data.Info.Name)
 	utils.VerboseLog(p.verbose, "Package info version:", data.Info.Version)
 	utils.VerboseLog(p.verbose, "Number of releases:", len(data.Releases))
 
-	// Extract versions from the releases map
-	var versions []string
-	for version := range data.Releases {
+	// Extract releases from the releases map, with the metadata of their files
+	var releases []Release
+	for version, raw := range data.Releases {
 		utils.VerboseLog(p.verbose, "Found version:", version)
-		versions = append(versions, version)
+		release := Release{Version: version}
+		var files []struct {
+			Filename       string `json:"filename"`
+			RequiresPython string `json:"requires_python"`
+			Yanked         bool   `json:"yanked"`
+			YankedReason   string `json:"yanked_reason"`
+			UploadTime     string `json:"upload_time_iso_8601"`
+		}
+		if json.Unmarshal(raw, &files) == nil {
+			yanked := 0
+			for _, f := range files {
+				if release.RequiresPython == "" {
+					release.RequiresPython = f.RequiresPython
+				}
+				if f.Filename != "" {
+					release.Files = append(release.Files, f.Filename)
+				}
+				if f.Yanked {
+					yanked++
+					if release.YankedReason == "" {
+						release.YankedReason = f.YankedReason
+					}
+				}
+				if uploaded, err := time.Parse(time.RFC3339Nano, f.UploadTime); err == nil && (release.UploadTime.IsZero() || uploaded.Before(release.UploadTime)) {
+					release.UploadTime = uploaded
+				}
+			}
+			// A release is yanked when all of its files are
+			release.Yanked = yanked > 0 && yanked == len(files)
+			if !release.Yanked {
+				release.YankedReason = ""
+			}
+		}
+		releases = append(releases, release)
 	}
 
 	// Test-specific handling: if we're in a test environment, check for empty release arrays
-	if len(versions) == 0 && data.Info.Version != "" {
+	if len(releases) == 0 && data.Info.Version != "" {
 		// If we're in a test environment, the data.Info.Version might be our only clue
 		utils.VerboseLog(p.verbose, "No versions found in releases map, using info version as fallback:", data.Info.Version)
-		return data.Info.Version, nil
+		return []Release{{Version: data.Info.Version}}, nil
 	}
 
-	if len(versions) == 0 {
+	if len(releases) == 0 {
 		// If we really have no versions, this is an error
 		utils.VerboseLog(p.verbose, "No versions found in releases map and no info version available")
-		return "", fmt.Errorf("no versions found for package %s", packageName)
+		return nil, fmt.Errorf("no versions found for package %s", packageName)
 	}
 
-	// Get the latest version
-	latestVersion, err := p.selectLatestStableVersion(versions)
-	if err != nil {
-		return "", fmt.Errorf("error selecting latest version for %s: %w", packageName, err)
-	}
-
-	utils.VerboseLog(p.verbose, "Selected latest version:", latestVersion)
-	return latestVersion, nil
+	return releases, nil
 }
 
-// parseHTMLContentForLatestVersion parses HTML content to extract version information
-func (p *PyPI) parseHTMLContentForLatestVersion(reader io.Reader) (string, error) {
-	var versions []string
-	seenVersions := make(map[string]bool) // To prevent duplicates
+// parseHTMLReleases returns the releases linked from a package's HTML page,
+// with the requires-python of their data-requires-python attribute and the
+// wheels linked for them
+func (p *PyPI) parseHTMLReleases(reader io.Reader) ([]Release, error) {
+	var releases []Release
+	seenVersions := make(map[string]int) // Index of each version in releases, to prevent duplicates
 
 	z := html.NewTokenizer(reader)
 	for {
@@ -682,44 +836,58 @@ func (p *PyPI) parseHTMLContentForLatestVersion(reader io.Reader) (string, error
 		switch {
 		case tt == html.ErrorToken:
 			if z.Err() == io.EOF {
-				utils.VerboseLog(p.verbose, "Found versions:", versions)
-				utils.Debug("legacy", "Found versions: %v", versions)
-				if len(versions) == 0 {
-					return "", fmt.Errorf("no versions found")
+				utils.VerboseLog(p.verbose, "Found releases:", releases)
+				utils.Debug("legacy", "Found releases: %v", releases)
+				if len(releases) == 0 {
+					return nil, fmt.Errorf("no versions found")
 				}
-				version, err := p.selectLatestStableVersion(versions)
-				if err != nil {
-					return "", fmt.Errorf("error selecting latest version: %w", err)
-				}
-				utils.VerboseLog(p.verbose, "Selected version:", version)
-				utils.Debug("legacy", "Selected version: %s", version)
-				return version, nil
+				return releases, nil
 			}
-			return "", z.Err()
+			return nil, z.Err()
 
 		case tt == html.StartTagToken:
 			t := z.Token()
-			if t.Data == "a" {
-				for _, a := range t.Attr {
-					if a.Key == "href" {
-						versionPath := strings.Trim(a.Val, "/")
-						parts := strings.Split(versionPath, "/")
-						if len(parts) > 0 {
-							version := parts[0]
-							// Add validation for version strings
-							if isValidVersionString(version) {
-								// Only add if we haven't seen this version before
-								if !seenVersions[version] {
-									versions = append(versions, version)
-									seenVersions[version] = true
-									utils.VerboseLog(p.verbose, "Found version:", version, "IsPrerelease:", isPrerelease(version))
-									utils.Debug("legacy", "Found version: %s IsPrerelease: %v", version, isPrerelease(version))
-								}
-							}
-						}
+			if t.Data != "a" {
+				continue
+			}
+			var version, file, requiresPython string
+			for _, a := range t.Attr {
+				switch a.Key {
+				case "href":
+					versionPath := strings.Trim(a.Val, "/")
+					// Links to wheels name their version in the file name
+					filename := path.Base(strings.SplitN(versionPath, "#", 2)[0])
+					if wheelVersion, _, ok := parseWheelFilename(filename); ok {
+						version, file = wheelVersion, filename
+					} else if parts := strings.Split(versionPath, "/"); isValidVersionString(parts[0]) {
+						// Add validation for version strings
+						version = parts[0]
 					}
+				case "data-requires-python":
+					requiresPython = a.Val
+				}
+			}
+			if version == "" {
+				continue
+			}
+			// Only add if we haven't seen this version before
+			if i, ok := seenVersions[version]; ok {
+				if releases[i].RequiresPython == "" {
+					releases[i].RequiresPython = requiresPython
 				}
+				if file != "" {
+					releases[i].Files = append(releases[i].Files, file)
+				}
+				continue
 			}
+			seenVersions[version] = len(releases)
+			release := Release{Version: version, RequiresPython: requiresPython}
+			if file != "" {
+				release.Files = []string{file}
+			}
+			releases = append(releases, release)
+			utils.VerboseLog(p.verbose, "Found version:", version, "IsPrerelease:", isPrerelease(version))
+			utils.Debug("legacy", "Found version: %s IsPrerelease: %v", version, isPrerelease(version))
 		}
 	}
 }
@@ -786,101 +954,47 @@ func isValidVersionString(version string) bool {
 	return false
 }
 
-// compareVersions compares two version strings
-func compareVersions(v1, v2 string) int {
-	// Split version strings into parts
-	parts1 := strings.Split(v1, ".")
-	parts2 := strings.Split(v2, ".")
-
-	// Compare each part
-	maxLen := len(parts1)
-	if len(parts2) > maxLen {
-		maxLen = len(parts2)
-	}
-
-	for i := 0; i < maxLen; i++ {
-		var num1, num2 int
-
-		// Get number from first version, defaulting to 0 if part doesn't exist
-		if i < len(parts1) {
-			num1, _ = strconv.Atoi(parts1[i])
-		}
-
-		// Get number from second version, defaulting to 0 if part doesn't exist
-		if i < len(parts2) {
-			num2, _ = strconv.Atoi(parts2[i])
-		}
-
-		if num1 < num2 {
-			return -1
-		}
-		if num1 > num2 {
-			return 1
-		}
-	}
-
-	return 0
-}
-
-// isPrerelease checks if a version string contains pre-release indicators
+// isPrerelease reports whether a version is a pre-release or development
+// release; versions that are not valid PEP 440 count as pre-releases
 func isPrerelease(version string) bool {
-	prereleaseIndicators := []string{
-		"a", "b", "c", "rc", "alpha", "beta", "dev", "preview",
-		".dev", ".a", ".b", ".rc", "-a", "-b", "-rc",
-	}
-
-	versionLower := strings.ToLower(version)
-	for _, indicator := range prereleaseIndicators {
-		if strings.Contains(versionLower, indicator) {
-			return true
-		}
-	}
-	return false
-}
-
-// stripPrereleaseSuffix removes pre-release suffixes for version comparison
-func stripPrereleaseSuffix(version string) string {
-	re := regexp.MustCompile(`(\.post\d+|\.dev\d+|a\d*|b\d*|c\d*|rc\d*|alpha\d*|beta\d*|preview\d*|[-+].*)$`)
-	return re.ReplaceAllString(version, "")
+	v, err := pep440.Parse(version)
+	return err != nil || v.IsPrerelease()
 }
 
-func (p *PyPI) selectLatestStableVersion(versions []string) (string, error) {
+// selectLatestStableVersion returns the highest final release, or the
+// highest pre-release if there are no final releases. Versions that are not
+// valid PEP 440 are ignored. A project on a pre-release (current) follows
+// that channel: it gets the newest pre-release or the newest final release,
+// whichever is higher.
+func (p *PyPI) selectLatestStableVersion(versions []string, current string) (string, error) {
 	utils.Debug("version", "Selecting from versions: %v", versions)
 
-	// Separate stable and pre-release versions
-	var stableVersions, preReleaseVersions []string
-	for _, version := range versions {
-		if isPrerelease(version) {
-			utils.Debug("version", "Pre-release version: %s", version)
-			preReleaseVersions = append(preReleaseVersions, version)
-		} else {
-			utils.Debug("version", "Stable version: %s", version)
-			stableVersions = append(stableVersions, version)
+	// The empty specifier set prefers final releases, as pip does
+	candidates := pep440.MustParseSpecifierSet("").Filter(versions)
+	if cur, err := pep440.Parse(current); err == nil && cur.IsPrerelease() {
+		candidates = nil
+		for _, version := range versions {
+			if _, err := pep440.Parse(version); err == nil {
+				candidates = append(candidates, version)
+			}
 		}
 	}
-
-	// Prefer stable versions
-	var versionsToUse []string
-	if len(stableVersions) > 0 {
-		utils.Info("version", "Using stable versions: %v", stableVersions)
-		versionsToUse = stableVersions
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no versions found")
+	}
+	if isPrerelease(candidates[0]) {
+		utils.Info("version", "No stable versions found, using pre-release versions: %v", candidates)
 	} else {
-		utils.Info("version", "No stable versions found, using pre-release versions: %v", preReleaseVersions)
-		versionsToUse = preReleaseVersions
+		utils.Info("version", "Using stable versions: %v", candidates)
 	}
 
-	// Sort versions and return the latest
-	if len(versionsToUse) == 0 {
-		return "", fmt.Errorf("no versions found")
+	result := candidates[0]
+	latest := pep440.MustParse(result)
+	for _, candidate := range candidates[1:] {
+		if v := pep440.MustParse(candidate); v.Compare(latest) > 0 {
+			result, latest = candidate, v
+		}
 	}
-
-	// Sort versions in ascending order
-	sort.Slice(versionsToUse, func(i, j int) bool {
-		return compareVersions(versionsToUse[i], versionsToUse[j]) < 0
-	})
-
-	// Return the highest version (last in the sorted list)
-	result := versionsToUse[len(versionsToUse)-1]
 	utils.Debug("version", "Selected version: %s", utils.FormatVersion(result))
 	return result, nil
 }
@@ -1021,27 +1135,17 @@ func (p *PyPI) setIndexURL(url string) {
 	if strings.HasSuffix(url, "/simple") && strings.Contains(url, "simple-index.example.com") {
 		// Special case for test: URL with /simple suffix requires no /pypi addition
 		p.pypiURL = url
-	} else if strings.HasSuffix(url, "/simple") {
-		// Replace /simple with /pypi to match test expectations
-		p.pypiURL = strings.TrimSuffix(url, "/simple") + "/pypi"
-	} else if strings.Contains(url, "amazonaws.com") {
-		// For CodeArtifact URLs, don't append /pypi
-		p.pypiURL = url
-		p.isCodeArtifact = true
 	} else {
-		// For other URLs, append /pypi if needed
-		if !strings.HasSuffix(url, "/pypi") {
-			p.pypiURL = url + "/pypi"
-		} else {
-			p.pypiURL = url
-		}
+		p.pypiURL = p.configureIndex(url)
 	}
+	p.isCodeArtifact = strings.Contains(url, "amazonaws.com")
 
 	// Set the custom index URL flag
 	p.isCustomIndexURL = true
+	p.primaryLast = false
 
 	// Log the URL being set
-	utils.VerboseLog(p.verbose, fmt.Sprintf("Using custom PyPI index URL: %s", p.pypiURL))
+	utils.VerboseLog(p.verbose, fmt.Sprintf("Using custom PyPI index URL: %s", redactURL(p.pypiURL)))
 }
 
 // ForceReadPipConf forces reading from pip.conf files, used for testing
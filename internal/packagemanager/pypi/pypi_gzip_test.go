@@ -100,9 +100,13 @@ func TestGetLatestVersionGzipped(t *testing.T) {
 	pypi.verbose = true // Enable verbose logging for debug output
 
 	// Test getting the latest version directly from the test server
-	version, err := pypi.getLatestVersionFromHTML("example-package", server.URL)
+	releases, err := pypi.fetchReleases("example-package", server.URL)
 	if err != nil {
-		t.Fatalf("getLatestVersionFromHTML failed with gzipped response: %v", err)
+		t.Fatalf("fetchReleases failed with gzipped response: %v", err)
+	}
+	version, err := pypi.selectLatestStableVersion(releaseVersions(releases), "")
+	if err != nil {
+		t.Fatalf("selectLatestStableVersion failed: %v", err)
 	}
 
 	// Verify we got the expected version
@@ -141,7 +145,7 @@ func TestGzipDecompressionError(t *testing.T) {
 	pypi.verbose = true // Enable verbose logging for debug output
 
 	// Expect an error when trying to decompress invalid gzip data
-	_, err := pypi.getLatestVersionFromHTML("example-package", server.URL)
+	_, err := pypi.fetchReleases("example-package", server.URL)
 	if err == nil {
 		t.Fatalf("Expected error when decompressing invalid gzip data, got nil")
 	}
@@ -251,7 +255,11 @@ func TestMixedEncodingResponses(t *testing.T) {
 			pypi.verbose = true // Enable verbose logging for debug
 
 			// Get the latest version directly using the method we're testing
-			version, err := pypi.getLatestVersionFromHTML("example-package", server.URL)
+			var version string
+			releases, err := pypi.fetchReleases("example-package", server.URL)
+			if err == nil {
+				version, err = pypi.selectLatestStableVersion(releaseVersions(releases), "")
+			}
 
 			// Check results based on expectations
 			if tc.expectedErrContains != "" {
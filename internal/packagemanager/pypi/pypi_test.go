@@ -10,8 +10,18 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/rvben/ru/internal/packagemanager/requirements"
+	"github.com/rvben/ru/internal/versioning"
 )
 
+// useRequirementsIndexes points p at the indexes declared in requirements
+// file content
+func useRequirementsIndexes(p *PyPI, content string) {
+	p.UseIndexes(IndexSetFromRequirements(&requirements.Document{File: requirements.Parse(content)}))
+}
+
 // MockPyPIResponse structure for the JSON response from PyPI
 type MockPyPIResponse struct {
 	Info struct {
@@ -40,10 +50,14 @@ func TestGetLatestVersion(t *testing.T) {
 	pypi := New(true)
 	pypi.verbose = true
 
-	// Call parseJSONForLatestVersion directly to test the parsing logic
-	version, err := pypi.parseJSONForLatestVersion(reader, "example-package")
+	// Parse the document directly to test the parsing logic
+	releases, err := pypi.parseJSONReleases(reader, "example-package")
 	if err != nil {
-		t.Fatalf("parseJSONForLatestVersion failed: %v", err)
+		t.Fatalf("parseJSONReleases failed: %v", err)
+	}
+	version, err := pypi.selectLatestStableVersion(releaseVersions(releases), "")
+	if err != nil {
+		t.Fatalf("selectLatestStableVersion failed: %v", err)
 	}
 
 	// Verify we got the expected version
@@ -72,6 +86,136 @@ func TestGetLatestVersionError(t *testing.T) {
 	}
 }
 
+func TestGetHighestVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"info": {"name": "example-package", "version": "2.0.0"},
+			"releases": {"1.4.2": [{}], "1.4.9": [{}], "1.4.10rc1": [{}], "1.5.0": [{}], "2.0.0": [{}]}
+		}`)
+	}))
+	defer server.Close()
+
+	pypi := New(false)
+	pypi.SetDirectIndexURL(server.URL + "/pypi")
+
+	tests := []struct {
+		current  string
+		level    versioning.Level
+		expected string
+	}{
+		{"1.4.2", versioning.Patch, "1.4.9"},
+		{"1.4.2", versioning.Minor, "1.5.0"},
+		{"1.4.2", versioning.Major, "2.0.0"},
+		{"2.0.0", versioning.Patch, ""},
+	}
+	for _, tt := range tests {
+		got, err := pypi.GetHighestVersion("example-package", tt.current, tt.level)
+		if err != nil {
+			t.Fatalf("GetHighestVersion failed: %v", err)
+		}
+		if got != tt.expected {
+			t.Errorf("GetHighestVersion(%s, %s) = %q, want %q", tt.current, tt.level, got, tt.expected)
+		}
+	}
+}
+
+func TestParseReleasesRequiresPython(t *testing.T) {
+	pypi := New(false)
+
+	releases, err := pypi.parseJSONReleases(strings.NewReader(`{
+		"info": {"name": "example-package", "version": "2.0.0"},
+		"releases": {
+			"1.0.0": [{"requires_python": null}],
+			"1.5.0": [{"filename": "example_package-1.5.0.tar.gz", "requires_python": ""}, {"filename": "example_package-1.5.0-py3-none-any.whl", "requires_python": ">=3.8"}],
+			"2.0.0": [{"requires_python": ">=3.10"}],
+			"2.1.0": []
+		}
+	}`), "example-package")
+	if err != nil {
+		t.Fatalf("parseJSONReleases failed: %v", err)
+	}
+	got := make(map[string]string)
+	for _, r := range releases {
+		got[r.Version] = r.RequiresPython
+		if r.Version == "1.5.0" && len(r.Files) != 2 {
+			t.Errorf("expected both files of 1.5.0, got %v", r.Files)
+		}
+	}
+	expected := map[string]string{"1.0.0": "", "1.5.0": ">=3.8", "2.0.0": ">=3.10", "2.1.0": ""}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("JSON releases = %v, want %v", got, expected)
+	}
+
+	releases, err = pypi.parseHTMLReleases(strings.NewReader(`<html><body>
+		<a href="/1.0.0/">1.0.0</a>
+		<a href="/2.0.0/" data-requires-python="&gt;=3.10">2.0.0</a>
+		<a href="/2.0.0/">2.0.0</a>
+		<a href="../../packages/ab/cd/example_package-2.0.0-cp310-cp310-manylinux_2_17_x86_64.whl#sha256=00">file</a>
+	</body></html>`))
+	if err != nil {
+		t.Fatalf("parseHTMLReleases failed: %v", err)
+	}
+	expectedReleases := []Release{
+		{Version: "1.0.0"},
+		{Version: "2.0.0", RequiresPython: ">=3.10", Files: []string{"example_package-2.0.0-cp310-cp310-manylinux_2_17_x86_64.whl"}},
+	}
+	if !reflect.DeepEqual(releases, expectedReleases) {
+		t.Errorf("HTML releases = %v, want %v", releases, expectedReleases)
+	}
+}
+
+func TestYankedReleases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"info": {"name": "example-package", "version": "2.0.0"},
+			"releases": {
+				"1.0.0": [{"filename": "example_package-1.0.0.tar.gz", "upload_time_iso_8601": "2023-05-01T10:00:00.000000Z"}],
+				"1.1.0": [
+					{"filename": "example_package-1.1.0.tar.gz", "yanked": true, "yanked_reason": "Broken wheel"},
+					{"filename": "example_package-1.1.0-py3-none-any.whl", "yanked": false}
+				],
+				"2.0.0": [{"filename": "example_package-2.0.0.tar.gz", "yanked": true, "yanked_reason": "Leaked a secret"}]
+			}
+		}`)
+	}))
+	defer server.Close()
+
+	pypi := New(false)
+	pypi.SetDirectIndexURL(server.URL + "/pypi")
+
+	releases, err := pypi.GetReleases("example-package")
+	if err != nil {
+		t.Fatalf("GetReleases failed: %v", err)
+	}
+	for _, r := range releases {
+		switch r.Version {
+		case "1.0.0":
+			if r.Yanked || !r.UploadTime.Equal(time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)) {
+				t.Errorf("unexpected release: %+v", r)
+			}
+		case "1.1.0":
+			// Only one of its files is yanked
+			if r.Yanked || r.YankedReason != "" {
+				t.Errorf("1.1.0 should not be yanked: %+v", r)
+			}
+		case "2.0.0":
+			if !r.Yanked || r.YankedReason != "Leaked a secret" {
+				t.Errorf("2.0.0 should be yanked: %+v", r)
+			}
+		}
+	}
+
+	latest, err := pypi.GetLatestVersion("example-package")
+	if err != nil {
+		t.Fatalf("GetLatestVersion failed: %v", err)
+	}
+	if latest != "1.1.0" {
+		t.Errorf("GetLatestVersion = %s, want 1.1.0", latest)
+	}
+}
+
 func TestParseHTMLForLatestVersion(t *testing.T) {
 	// Sample HTML input
 	htmlContent := `<!DOCTYPE html>
@@ -98,10 +242,14 @@ func TestParseHTMLForLatestVersion(t *testing.T) {
 	// Create a PyPI instance
 	pypi := New(true)
 
-	// Call the function to test
-	latestVersion, err := pypi.parseHTMLContentForLatestVersion(reader)
+	// Call the functions to test
+	releases, err := pypi.parseHTMLReleases(reader)
+	if err != nil {
+		t.Fatalf("parseHTMLReleases failed: %v", err)
+	}
+	latestVersion, err := pypi.selectLatestStableVersion(releaseVersions(releases), "")
 	if err != nil {
-		t.Fatalf("parseHTMLContentForLatestVersion failed: %v", err)
+		t.Fatalf("selectLatestStableVersion failed: %v", err)
 	}
 
 	// Define the expected latest version
@@ -137,10 +285,14 @@ func TestParseHTMLForLatestVersionPreferStable(t *testing.T) {
 	// Create a PyPI instance
 	pypi := New(true)
 
-	// Call the function to test
-	latestVersion, err := pypi.parseHTMLContentForLatestVersion(reader)
+	// Call the functions to test
+	releases, err := pypi.parseHTMLReleases(reader)
+	if err != nil {
+		t.Fatalf("parseHTMLReleases failed: %v", err)
+	}
+	latestVersion, err := pypi.selectLatestStableVersion(releaseVersions(releases), "")
 	if err != nil {
-		t.Fatalf("parseHTMLContentForLatestVersion failed: %v", err)
+		t.Fatalf("selectLatestStableVersion failed: %v", err)
 	}
 
 	// Define the expected latest version
@@ -156,6 +308,7 @@ func TestSelectLatestStableVersion(t *testing.T) {
 	testCases := []struct {
 		name     string
 		versions []string
+		current  string
 		want     string
 		wantErr  bool
 	}{
@@ -174,6 +327,30 @@ func TestSelectLatestStableVersion(t *testing.T) {
 			versions: []string{"1.0.0", "1.1.0", "1.0.1"},
 			want:     "1.1.0",
 		},
+		{
+			name:     "stable current ignores pre-releases",
+			versions: []string{"1.9.3", "2.0.0rc1"},
+			current:  "1.9.3",
+			want:     "1.9.3",
+		},
+		{
+			name:     "pre-release current follows newer pre-releases",
+			versions: []string{"1.9.3", "2.0.0rc1", "2.0.0rc2"},
+			current:  "2.0.0rc1",
+			want:     "2.0.0rc2",
+		},
+		{
+			name:     "pre-release current moves to the final release",
+			versions: []string{"1.9.3", "2.0.0rc1", "2.0.0rc2", "2.0.0"},
+			current:  "2.0.0rc1",
+			want:     "2.0.0",
+		},
+		{
+			name:     "pre-release current takes whichever is higher",
+			versions: []string{"2.0.0", "2.1.0b1", "2.0.1"},
+			current:  "2.0.0rc1",
+			want:     "2.1.0b1",
+		},
 		{
 			name:     "fallback to pre-release if no stable",
 			versions: []string{"1.0.0b1", "1.0.0b2", "1.0.0rc1"},
@@ -194,7 +371,7 @@ func TestSelectLatestStableVersion(t *testing.T) {
 	pypi := New(true)
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := pypi.selectLatestStableVersion(tc.versions)
+			got, err := pypi.selectLatestStableVersion(tc.versions, tc.current)
 			if (err != nil) != tc.wantErr {
 				t.Errorf("selectLatestStableVersion() error = %v, wantErr %v", err, tc.wantErr)
 				return
@@ -477,6 +654,15 @@ flask==2.0.0
 requests==2.25.1`,
 			expectedURL: "https://requirements-shorthand.example.com/pypi",
 		},
+		{
+			name: "Requirements.txt with environment variable in --index-url",
+			envVars: map[string]string{
+				"RU_TEST_INDEX_HOST": "env-index.example.com",
+			},
+			requirementsTxt: `--index-url https://${RU_TEST_INDEX_HOST}/simple
+flask==2.0.0`,
+			expectedURL: "https://env-index.example.com/pypi",
+		},
 		{
 			name: "PyProject.toml with Poetry source",
 			pyprojectToml: `[tool.poetry]
@@ -565,7 +751,7 @@ url = "https://download.pytorch.org/whl/cpu"
 
 			// Test requirements.txt parsing if provided
 			if tt.requirementsTxt != "" {
-				p.SetIndexURLFromRequirements(tt.requirementsTxt)
+				useRequirementsIndexes(p, tt.requirementsTxt)
 			}
 
 			// Test pyproject.toml parsing if provided
@@ -640,11 +826,28 @@ requests==2.26.0`,
 		{
 			name: "Requirements.txt with shorthand notation",
 			requirementsTxt: `-i https://primary.example.com/simple
--e https://extra1.example.com/simple
+--extra-index-url https://extra1.example.com/simple
+flask==2.0.0`,
+			expectedPrimary: "https://primary.example.com/pypi",
+			expectedExtras:  []string{"https://extra1.example.com/pypi"},
+		},
+		{
+			name: "Requirements.txt with = separated options",
+			requirementsTxt: `--index-url=https://primary.example.com/simple
+--extra-index-url=https://extra1.example.com/simple
 flask==2.0.0`,
 			expectedPrimary: "https://primary.example.com/pypi",
 			expectedExtras:  []string{"https://extra1.example.com/pypi"},
 		},
+		{
+			name: "Requirements.txt with editable install",
+			requirementsTxt: `--index-url https://primary.example.com/simple
+-e .
+-e git+https://github.com/example/project.git#egg=project
+flask==2.0.0`,
+			expectedPrimary: "https://primary.example.com/pypi",
+			expectedExtras:  []string{},
+		},
 		{
 			name: "Poetry with primary and supplemental sources",
 			pyprojectToml: `[tool.poetry]
@@ -784,7 +987,7 @@ url = "https://extra2.example.com/simple"
 				// For pip.conf test, set the values directly to match the expected values
 				// This is a workaround for issues with the pip.conf file handling
 				p.setIndexURL("https://primary.example.com/simple")
-				p.ClearExtraIndexURLs() // Clear any existing extra URLs
+				p.extraIndexURLs = nil // Clear any existing extra URLs
 				p.addExtraIndexURL("https://extra1.example.com/simple")
 				p.addExtraIndexURL("https://extra2.example.com/simple")
 			} else if tt.name == "Multiple sources with precedence: ENV > requirements.txt > pyproject.toml > pip.conf" {
@@ -796,20 +999,20 @@ url = "https://extra2.example.com/simple"
 				// Then pyproject.toml
 				if tt.pyprojectToml != "" {
 					// Clear any existing URLs first to ensure clean state
-					p.ClearExtraIndexURLs()
+					p.extraIndexURLs = nil
 					p.SetIndexURLFromPyProjectTOML([]byte(tt.pyprojectToml))
 				}
 
 				// Then requirements.txt
 				if tt.requirementsTxt != "" {
 					// Clear URLs set by pyproject.toml first
-					p.ClearExtraIndexURLs()
-					p.SetIndexURLFromRequirements(tt.requirementsTxt)
+					p.extraIndexURLs = nil
+					useRequirementsIndexes(p, tt.requirementsTxt)
 				}
 
 				// Finally, force environment variable precedence
 				// Clear any previously set URLs
-				p.ClearExtraIndexURLs()
+				p.extraIndexURLs = nil
 				// This will read the environment variables we set up earlier
 				p.SetCustomIndexURL()
 			} else if tt.name == "PyProject.toml with Poetry source" {
@@ -820,7 +1023,7 @@ url = "https://extra2.example.com/simple"
 
 				// Apply settings from requirements.txt if provided
 				if tt.requirementsTxt != "" {
-					p.SetIndexURLFromRequirements(tt.requirementsTxt)
+					useRequirementsIndexes(p, tt.requirementsTxt)
 				}
 
 				// Apply settings from pyproject.toml if provided
@@ -0,0 +1,121 @@
+package pypi
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rvben/ru/internal/pep440"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// pythonVersionFile is the file pyenv and uv read the Python version of a
+// project from
+const pythonVersionFile = ".python-version"
+
+// PythonTarget is the Python version a project runs on. Releases whose
+// requires_python does not accept it are not considered for updates.
+type PythonTarget struct {
+	// Version is the lowest Python version the project supports
+	Version *pep440.Version
+	// Declared is the declared version or range, e.g. ">=3.9" or "3.9.18"
+	Declared string
+	// Source is where it was declared, e.g. "requires-python" or ".python-version"
+	Source string
+}
+
+// PythonTargetFromRequiresPython returns the target declared by the
+// requires-python of a pyproject.toml. A project declaring ">=3.9" must
+// keep working on 3.9, so that is the version releases are checked against.
+func PythonTargetFromRequiresPython(spec string) (*PythonTarget, error) {
+	if _, err := pep440.ParseSpecifierSet(spec); err != nil {
+		return nil, fmt.Errorf("invalid requires-python: %w", err)
+	}
+	floor := versioning.Floor(versioning.PEP440, spec)
+	if floor == "" {
+		return nil, fmt.Errorf("invalid requires-python %q: it has no lower bound", spec)
+	}
+	return &PythonTarget{Version: pep440.MustParse(floor), Declared: spec, Source: "requires-python"}, nil
+}
+
+// ParsePythonVersion returns the target for a Python version such as "3.9"
+// or "3.9.18" declared in source.
+func ParsePythonVersion(version, source string) (*PythonTarget, error) {
+	v, err := pep440.Parse(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Python version %q: %w", version, err)
+	}
+	return &PythonTarget{Version: v, Declared: version, Source: source}, nil
+}
+
+// FindPythonVersionFile looks for a .python-version file in dir and its
+// parents, the way pyenv does, and returns the first version it names. It
+// returns nil if there is none or if it names something other than a
+// CPython version, such as "system" or "pypy3.10-7.3.12".
+func FindPythonVersionFile(dir string) (*PythonTarget, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for current := dir; ; {
+		path := filepath.Join(current, pythonVersionFile)
+		version, err := readPythonVersionFile(path)
+		if err == nil {
+			target, err := ParsePythonVersion(version, pythonVersionFile)
+			if err != nil {
+				utils.Debug("pypi", "Ignoring Python version %q in %s", version, path)
+				return nil, nil
+			}
+			return target, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return nil, nil
+		}
+		current = parent
+	}
+}
+
+// readPythonVersionFile returns the first version listed in a version file
+func readPythonVersionFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return strings.Fields(line)[0], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// Supports reports whether a release with the given requires_python can be
+// installed on the target's Python version. Releases without a valid
+// requires_python are always accepted, as pip does.
+func (t *PythonTarget) Supports(requiresPython string) bool {
+	if t == nil || strings.TrimSpace(requiresPython) == "" {
+		return true
+	}
+	specs, err := pep440.ParseSpecifierSet(requiresPython)
+	if err != nil {
+		return true
+	}
+	// Like pip, pre-releases of Python itself are matched as any version
+	return specs.Matches(t.Version)
+}
+
+// String describes the target for output, e.g. "python >=3.9 (requires-python)"
+func (t *PythonTarget) String() string {
+	return fmt.Sprintf("python %s (%s)", t.Declared, t.Source)
+}
@@ -0,0 +1,91 @@
+package pypi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePythonVersionFile(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, pythonVersionFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPythonVersionFile(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if target, err := FindPythonVersionFile(pkg); err != nil || target != nil {
+		t.Fatalf("expected no target without a version file, got %v, %v", target, err)
+	}
+
+	writePythonVersionFile(t, root, "# pinned for CI\n3.9.18\n")
+	target, err := FindPythonVersionFile(pkg)
+	if err != nil {
+		t.Fatalf("FindPythonVersionFile failed: %v", err)
+	}
+	if target == nil || target.Version.String() != "3.9.18" || target.Source != ".python-version" {
+		t.Fatalf("expected 3.9.18 from the parent .python-version, got %v", target)
+	}
+
+	writePythonVersionFile(t, pkg, "3.12 3.11\n")
+	if target, err = FindPythonVersionFile(pkg); err != nil || target == nil || target.Version.String() != "3.12" {
+		t.Fatalf("expected the closest version file to win, got %v, %v", target, err)
+	}
+
+	writePythonVersionFile(t, pkg, "system\n")
+	if target, err = FindPythonVersionFile(pkg); err != nil || target != nil {
+		t.Errorf("expected non-version names to be ignored, got %v, %v", target, err)
+	}
+}
+
+func TestPythonTargetSupports(t *testing.T) {
+	declared, err := PythonTargetFromRequiresPython(">=3.9, <4")
+	if err != nil {
+		t.Fatalf("PythonTargetFromRequiresPython failed: %v", err)
+	}
+	if declared.String() != "python >=3.9, <4 (requires-python)" {
+		t.Errorf("unexpected description %q", declared.String())
+	}
+	pinned, err := ParsePythonVersion("3.12.1", "-python")
+	if err != nil {
+		t.Fatalf("ParsePythonVersion failed: %v", err)
+	}
+
+	tests := []struct {
+		requiresPython string
+		fromDeclared   bool
+		fromPinned     bool
+	}{
+		{"", true, true},
+		{">=3.8", true, true},
+		{">=3.10", false, true},
+		{">=3.7,<3.12", true, false},
+		{"!=3.9.*,>=3.8", false, true},
+		{"not a specifier", true, true},
+	}
+	for _, tt := range tests {
+		if got := declared.Supports(tt.requiresPython); got != tt.fromDeclared {
+			t.Errorf("requires-python >=3.9 supports %q = %v, want %v", tt.requiresPython, got, tt.fromDeclared)
+		}
+		if got := pinned.Supports(tt.requiresPython); got != tt.fromPinned {
+			t.Errorf("python 3.12.1 supports %q = %v, want %v", tt.requiresPython, got, tt.fromPinned)
+		}
+	}
+
+	var none *PythonTarget
+	if !none.Supports(">=3.13") {
+		t.Error("expected a nil target to support every release")
+	}
+	for _, spec := range []string{"<4", "~~"} {
+		if _, err := PythonTargetFromRequiresPython(spec); err == nil || !strings.Contains(err.Error(), "requires-python") {
+			t.Errorf("expected an error for requires-python %q, got %v", spec, err)
+		}
+	}
+}
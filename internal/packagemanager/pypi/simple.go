@@ -0,0 +1,403 @@
+package pypi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/rvben/ru/internal/pep440"
+	"github.com/rvben/ru/internal/utils"
+)
+
+// simpleAccept asks for the JSON form of the Simple API (PEP 691), falling
+// back to its HTML form and to plain HTML for indexes predating PEP 691
+const simpleAccept = "application/vnd.pypi.simple.v1+json, application/vnd.pypi.simple.v1+html;q=0.2, text/html;q=0.01"
+
+// simpleJSONType is the content type of the JSON form of the Simple API
+const simpleJSONType = "application/vnd.pypi.simple.v1+json"
+
+// File is a distribution file of a project listed by the Simple API
+type File struct {
+	Filename string
+	// URL is where the file is downloaded from, resolved against the page
+	// that lists it
+	URL string
+	// Hashes are the hex digests of the file by hash name, e.g. "sha256"
+	Hashes         map[string]string
+	RequiresPython string
+	// Yanked files (PEP 592) are only installed when pinned exactly;
+	// YankedReason is the index's explanation, if it gives one
+	Yanked       bool
+	YankedReason string
+	// HasMetadata reports whether the index serves the core metadata of
+	// the file on its own (PEP 658)
+	HasMetadata bool
+	// UploadTime is zero if the index does not say (PEP 700)
+	UploadTime time.Time
+}
+
+// SimpleProject is the page of a project on the Simple API
+type SimpleProject struct {
+	Name  string
+	Files []File
+	// Versions are the versions the index lists for the project, including
+	// those without files; only the JSON form has them (PEP 700)
+	Versions []string
+}
+
+// simpleIndexURL returns the Simple API root of an index given the base URL
+// of its JSON API: the URL the index was configured with, or for an index
+// that was not configured, the inverse of indexAPIURL
+func (p *PyPI) simpleIndexURL(baseURL string) string {
+	if url, ok := p.simpleURLs[baseURL]; ok {
+		return url
+	}
+	if base, ok := strings.CutSuffix(baseURL, "/pypi"); ok && !strings.Contains(baseURL, "amazonaws.com") {
+		return base + "/simple"
+	}
+	return baseURL
+}
+
+// fetchSimpleReleases returns the releases of a package from the Simple API
+// of the index at baseURL. Indexes that answer with a document of the JSON
+// API of PyPI instead, or with an HTML page linking versions rather than
+// files, are understood too.
+func (p *PyPI) fetchSimpleReleases(packageName, baseURL string) ([]Release, error) {
+	pageURL := p.simpleIndexURL(baseURL) + "/" + normalizeName(packageName) + "/"
+	utils.Debug("http", "Trying URL (Simple API): %s", utils.FormatURL(redactURL(pageURL)))
+
+	resp, err := p.client.GetWithRetry(pageURL, map[string]string{
+		"Accept":          simpleAccept,
+		"Accept-Encoding": "gzip, deflate",
+	})
+	if err != nil {
+		if isUnreachableError(err) {
+			p.MarkHostUnreachable(baseURL)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	utils.Debug("http", "Response status: %s", utils.FormatHTTPStatus(resp.Status))
+	utils.Debug("http", "Response content type: %s", resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	reader, err := responseReader(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch contentType {
+	case simpleJSONType:
+		project, err := parseSimpleJSON(reader, resp.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the Simple API page of %s: %w", packageName, err)
+		}
+		return project.releases(packageName)
+	case "application/json":
+		utils.Debug("pypi", "Index answered with the JSON API for %s", packageName)
+		return p.parseJSONReleases(reader, packageName)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the Simple API page of %s: %w", packageName, err)
+	}
+	project, err := parseSimpleHTML(bytes.NewReader(body), resp.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the Simple API page of %s: %w", packageName, err)
+	}
+	if len(project.Files) == 0 {
+		// Some indexes link a directory per version instead of files
+		utils.Debug("pypi", "No distribution files linked for %s, looking for version links", packageName)
+		return p.parseHTMLReleases(bytes.NewReader(body))
+	}
+	return project.releases(packageName)
+}
+
+// checkAPIVersion fails for a Simple API version this client does not
+// understand; minor versions only add to the API
+func checkAPIVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	if major, _, _ := strings.Cut(version, "."); major != "1" {
+		return fmt.Errorf("unsupported Simple API version %s", version)
+	}
+	return nil
+}
+
+// parseSimpleJSON parses the JSON form of a project page (PEP 691)
+func parseSimpleJSON(reader io.Reader, pageURL *url.URL) (*SimpleProject, error) {
+	var data struct {
+		Meta struct {
+			APIVersion string `json:"api-version"`
+		} `json:"meta"`
+		Name  string `json:"name"`
+		Files []struct {
+			Filename         string            `json:"filename"`
+			URL              string            `json:"url"`
+			Hashes           map[string]string `json:"hashes"`
+			RequiresPython   string            `json:"requires-python"`
+			Yanked           json.RawMessage   `json:"yanked"`
+			CoreMetadata     json.RawMessage   `json:"core-metadata"`
+			DistInfoMetadata json.RawMessage   `json:"dist-info-metadata"`
+			UploadTime       string            `json:"upload-time"`
+		} `json:"files"`
+		Versions []string `json:"versions"`
+	}
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		return nil, err
+	}
+	if err := checkAPIVersion(data.Meta.APIVersion); err != nil {
+		return nil, err
+	}
+
+	project := &SimpleProject{Name: data.Name, Versions: data.Versions}
+	for _, f := range data.Files {
+		file := File{
+			Filename:       f.Filename,
+			URL:            resolveURL(pageURL, f.URL),
+			Hashes:         f.Hashes,
+			RequiresPython: f.RequiresPython,
+		}
+		// yanked is false, true or the reason
+		var reason string
+		if json.Unmarshal(f.Yanked, &reason) == nil {
+			file.Yanked, file.YankedReason = true, reason
+		} else {
+			_ = json.Unmarshal(f.Yanked, &file.Yanked)
+		}
+		// core-metadata, or its older name, is false, true or the hashes of
+		// the metadata file
+		metadata := f.CoreMetadata
+		if metadata == nil {
+			metadata = f.DistInfoMetadata
+		}
+		file.HasMetadata = len(metadata) > 0 && string(metadata) != "false" && string(metadata) != "null"
+		if f.UploadTime != "" {
+			uploaded, err := time.Parse(time.RFC3339Nano, f.UploadTime)
+			if err != nil {
+				return nil, fmt.Errorf("invalid upload-time of %s: %w", f.Filename, err)
+			}
+			file.UploadTime = uploaded
+		}
+		project.Files = append(project.Files, file)
+	}
+	return project, nil
+}
+
+// parseSimpleHTML parses the HTML form of a project page: an anchor per
+// file (PEP 503) with its hash in the URL fragment and the data-yanked
+// (PEP 592), data-requires-python and data-core-metadata (PEP 658)
+// attributes
+func parseSimpleHTML(reader io.Reader, pageURL *url.URL) (*SimpleProject, error) {
+	project := &SimpleProject{}
+	z := html.NewTokenizer(reader)
+	var file *File
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return project, nil
+			}
+			return nil, z.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "meta":
+				if attr(t, "name") == "pypi:repository-version" {
+					if err := checkAPIVersion(attr(t, "content")); err != nil {
+						return nil, err
+					}
+				}
+			case "a":
+				file = anchorFile(t, pageURL)
+			}
+
+		case html.TextToken:
+			// The text of an anchor is the file name
+			if file != nil {
+				if text := strings.TrimSpace(string(z.Text())); text != "" {
+					file.Filename = text
+				}
+			}
+
+		case html.EndTagToken:
+			if file != nil && z.Token().Data == "a" {
+				if isDistribution(file.Filename) {
+					project.Files = append(project.Files, *file)
+				}
+				file = nil
+			}
+		}
+	}
+}
+
+// anchorFile returns the file an anchor of a project page links to, named
+// after the last segment of its URL until the anchor text is read
+func anchorFile(t html.Token, pageURL *url.URL) *File {
+	href, ok := attrValue(t, "href")
+	if !ok {
+		return nil
+	}
+	link, fragment, _ := strings.Cut(href, "#")
+	file := &File{
+		URL:            resolveURL(pageURL, link),
+		RequiresPython: attr(t, "data-requires-python"),
+	}
+	if unescaped, err := url.PathUnescape(path.Base(link)); err == nil {
+		file.Filename = unescaped
+	}
+	if name, digest, ok := strings.Cut(fragment, "="); ok && digest != "" {
+		file.Hashes = map[string]string{name: digest}
+	}
+	file.YankedReason, file.Yanked = attrValue(t, "data-yanked")
+	for _, key := range []string{"data-core-metadata", "data-dist-info-metadata"} {
+		if value, ok := attrValue(t, key); ok && value != "false" {
+			file.HasMetadata = true
+		}
+	}
+	return file
+}
+
+// attrValue returns the value of an attribute of a tag and whether it is set
+func attrValue(t html.Token, key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// attr returns the value of an attribute of a tag, or ""
+func attr(t html.Token, key string) string {
+	value, _ := attrValue(t, key)
+	return value
+}
+
+// resolveURL resolves a link of a page, returning it unchanged if either
+// is not a valid URL
+func resolveURL(pageURL *url.URL, link string) string {
+	ref, err := url.Parse(link)
+	if err != nil || pageURL == nil {
+		return link
+	}
+	return pageURL.ResolveReference(ref).String()
+}
+
+// sdistExtensions are the archive formats of source distributions
+var sdistExtensions = []string{".tar.gz", ".zip", ".tar.bz2", ".tar.xz", ".tgz", ".tar"}
+
+// isDistribution reports whether a file name is that of a wheel or a
+// source distribution
+func isDistribution(filename string) bool {
+	if _, _, ok := parseWheelFilename(filename); ok {
+		return true
+	}
+	for _, ext := range sdistExtensions {
+		if strings.HasSuffix(filename, ext) {
+			return strings.Contains(filename, "-")
+		}
+	}
+	return false
+}
+
+// distributionVersion returns the version of a wheel or source distribution
+// of a project from its file name. Source distributions are named
+// {name}-{version}.{ext}, where older ones may have dashes in the name.
+func distributionVersion(project, filename string) (string, bool) {
+	if version, _, ok := parseWheelFilename(filename); ok {
+		return version, true
+	}
+	for _, ext := range sdistExtensions {
+		base, ok := strings.CutSuffix(filename, ext)
+		if !ok {
+			continue
+		}
+		for i := range len(base) {
+			if base[i] == '-' && normalizeName(base[:i]) == normalizeName(project) {
+				return base[i+1:], true
+			}
+		}
+		if i := strings.LastIndex(base, "-"); i > 0 {
+			return base[i+1:], true
+		}
+	}
+	return "", false
+}
+
+// versionKey returns the normalized form of a version, so that "1.0" and
+// "1.0.0" are one release, or the version itself if it is not PEP 440
+func versionKey(version string) string {
+	if v, err := pep440.Parse(version); err == nil {
+		return v.String()
+	}
+	return version
+}
+
+// releases groups the files of a project by version. A release is yanked
+// when all of its files are, and was uploaded with its first file.
+func (s *SimpleProject) releases(packageName string) ([]Release, error) {
+	var releases []Release
+	index := make(map[string]int)
+	yankedFiles := make(map[int]int)
+	for _, f := range s.Files {
+		version, ok := distributionVersion(packageName, f.Filename)
+		if !ok {
+			continue
+		}
+		i, ok := index[versionKey(version)]
+		if !ok {
+			i = len(releases)
+			index[versionKey(version)] = i
+			releases = append(releases, Release{Version: version})
+		}
+		r := &releases[i]
+		r.Files = append(r.Files, f.Filename)
+		if r.RequiresPython == "" {
+			r.RequiresPython = f.RequiresPython
+		}
+		if f.Yanked {
+			yankedFiles[i]++
+			if r.YankedReason == "" {
+				r.YankedReason = f.YankedReason
+			}
+		}
+		if !f.UploadTime.IsZero() && (r.UploadTime.IsZero() || f.UploadTime.Before(r.UploadTime)) {
+			r.UploadTime = f.UploadTime
+		}
+	}
+	for i := range releases {
+		r := &releases[i]
+		r.Yanked = yankedFiles[i] == len(r.Files)
+		if !r.Yanked {
+			r.YankedReason = ""
+		}
+	}
+	// Versions without files are still releases
+	for _, version := range s.Versions {
+		if _, ok := index[versionKey(version)]; !ok {
+			index[versionKey(version)] = len(releases)
+			releases = append(releases, Release{Version: version})
+		}
+	}
+
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no versions found for package %s", packageName)
+	}
+	return releases, nil
+}
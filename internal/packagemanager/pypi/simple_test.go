@@ -0,0 +1,236 @@
+package pypi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSimpleJSON(t *testing.T) {
+	page, _ := url.Parse("https://index.example.com/simple/example-package/")
+	project, err := parseSimpleJSON(strings.NewReader(`{
+		"meta": {"api-version": "1.1"},
+		"name": "example-package",
+		"files": [
+			{"filename": "example_package-1.0.0.tar.gz", "url": "../../packages/example_package-1.0.0.tar.gz",
+			 "hashes": {"sha256": "aa"}, "yanked": false, "upload-time": "2024-01-02T03:04:05.123456Z"},
+			{"filename": "example_package-2.0.0-py3-none-any.whl", "url": "https://files.example.com/example_package-2.0.0-py3-none-any.whl",
+			 "hashes": {"sha256": "bb"}, "requires-python": ">=3.9", "yanked": "Broken on Windows", "core-metadata": {"sha256": "cc"}},
+			{"filename": "example_package-2.1.0-py3-none-any.whl", "url": "x.whl", "hashes": {}, "yanked": true, "dist-info-metadata": true}
+		],
+		"versions": ["1.0.0", "2.0.0", "2.1.0", "3.0.0"]
+	}`), page)
+	if err != nil {
+		t.Fatalf("parseSimpleJSON failed: %v", err)
+	}
+
+	expected := []File{
+		{
+			Filename:   "example_package-1.0.0.tar.gz",
+			URL:        "https://index.example.com/packages/example_package-1.0.0.tar.gz",
+			Hashes:     map[string]string{"sha256": "aa"},
+			UploadTime: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		},
+		{
+			Filename:       "example_package-2.0.0-py3-none-any.whl",
+			URL:            "https://files.example.com/example_package-2.0.0-py3-none-any.whl",
+			Hashes:         map[string]string{"sha256": "bb"},
+			RequiresPython: ">=3.9",
+			Yanked:         true,
+			YankedReason:   "Broken on Windows",
+			HasMetadata:    true,
+		},
+		{
+			Filename:    "example_package-2.1.0-py3-none-any.whl",
+			URL:         "https://index.example.com/simple/example-package/x.whl",
+			Hashes:      map[string]string{},
+			Yanked:      true,
+			HasMetadata: true,
+		},
+	}
+	if !reflect.DeepEqual(project.Files, expected) {
+		t.Errorf("files = %+v, want %+v", project.Files, expected)
+	}
+	if !reflect.DeepEqual(project.Versions, []string{"1.0.0", "2.0.0", "2.1.0", "3.0.0"}) {
+		t.Errorf("unexpected versions: %v", project.Versions)
+	}
+
+	if _, err := parseSimpleJSON(strings.NewReader(`{"meta": {"api-version": "2.0"}, "files": []}`), page); err == nil {
+		t.Errorf("expected an error for an unsupported API version")
+	}
+}
+
+func TestParseSimpleHTML(t *testing.T) {
+	page, _ := url.Parse("https://index.example.com/simple/example-package/")
+	project, err := parseSimpleHTML(strings.NewReader(`<!DOCTYPE html>
+<html><head><meta name="pypi:repository-version" content="1.1"></head><body>
+<h1>Links for example-package</h1>
+<a href="../../packages/example-package-1.0.0.tar.gz#sha256=aa">example-package-1.0.0.tar.gz</a><br/>
+<a href="https://files.example.com/example_package-2.0.0-py3-none-any.whl#sha256=bb" data-requires-python="&gt;=3.9" data-dist-info-metadata="sha256=cc" data-yanked="Broken on Windows">example_package-2.0.0-py3-none-any.whl</a><br/>
+<a href="example_package-2.0.0.tar.gz" data-yanked="" data-core-metadata="false">example_package-2.0.0.tar.gz</a><br/>
+<a href="/help/">Help</a>
+</body></html>`), page)
+	if err != nil {
+		t.Fatalf("parseSimpleHTML failed: %v", err)
+	}
+
+	expected := []File{
+		{
+			Filename: "example-package-1.0.0.tar.gz",
+			URL:      "https://index.example.com/packages/example-package-1.0.0.tar.gz",
+			Hashes:   map[string]string{"sha256": "aa"},
+		},
+		{
+			Filename:       "example_package-2.0.0-py3-none-any.whl",
+			URL:            "https://files.example.com/example_package-2.0.0-py3-none-any.whl",
+			Hashes:         map[string]string{"sha256": "bb"},
+			RequiresPython: ">=3.9",
+			Yanked:         true,
+			YankedReason:   "Broken on Windows",
+			HasMetadata:    true,
+		},
+		{
+			Filename: "example_package-2.0.0.tar.gz",
+			URL:      "https://index.example.com/simple/example-package/example_package-2.0.0.tar.gz",
+			Yanked:   true,
+		},
+	}
+	if !reflect.DeepEqual(project.Files, expected) {
+		t.Errorf("files = %+v, want %+v", project.Files, expected)
+	}
+
+	releases, err := project.releases("example-package")
+	if err != nil {
+		t.Fatalf("releases failed: %v", err)
+	}
+	expectedReleases := []Release{
+		{Version: "1.0.0", Files: []string{"example-package-1.0.0.tar.gz"}},
+		{
+			Version:        "2.0.0",
+			RequiresPython: ">=3.9",
+			Files:          []string{"example_package-2.0.0-py3-none-any.whl", "example_package-2.0.0.tar.gz"},
+			Yanked:         true,
+			YankedReason:   "Broken on Windows",
+		},
+	}
+	if !reflect.DeepEqual(releases, expectedReleases) {
+		t.Errorf("releases = %+v, want %+v", releases, expectedReleases)
+	}
+
+	if _, err := parseSimpleHTML(strings.NewReader(`<meta name="pypi:repository-version" content="2.0">`), page); err == nil {
+		t.Errorf("expected an error for an unsupported API version")
+	}
+}
+
+func TestDistributionVersion(t *testing.T) {
+	tests := []struct {
+		project  string
+		filename string
+		expected string
+		ok       bool
+	}{
+		{"example-package", "example_package-1.0.0.tar.gz", "1.0.0", true},
+		{"example-package", "example-package-1.0.0.zip", "1.0.0", true},
+		{"Django", "Django-5.0.1-py3-none-any.whl", "5.0.1", true},
+		{"zope.interface", "zope.interface-6.0-cp311-cp311-manylinux_2_17_x86_64.whl", "6.0", true},
+		{"foo-bar", "Foo-Bar-1.0.tar.gz", "1.0", true},
+		{"renamed", "old-name-1.0.tar.gz", "1.0", true},
+		{"example-package", "example_package-1.0.0.exe", "", false},
+	}
+	for _, tt := range tests {
+		version, ok := distributionVersion(tt.project, tt.filename)
+		if version != tt.expected || ok != tt.ok {
+			t.Errorf("distributionVersion(%q, %q) = %q, %v, want %q, %v", tt.project, tt.filename, version, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestFetchSimpleReleases(t *testing.T) {
+	// A stand-in for indexes that only serve the HTML form of the Simple
+	// API, such as pypiserver
+	htmlIndex := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/example-package/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<a href="/packages/example_package-1.0.0.tar.gz#sha256=aa">example_package-1.0.0.tar.gz</a>
+<a href="/packages/example_package-1.1.0.tar.gz#sha256=bb" data-yanked="">example_package-1.1.0.tar.gz</a>`)
+	}))
+	defer htmlIndex.Close()
+
+	// A stand-in for indexes that negotiate the JSON form
+	jsonIndex := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), simpleJSONType) {
+			t.Errorf("expected the JSON form to be negotiated, got Accept: %s", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", simpleJSONType)
+		io.WriteString(w, `{"meta": {"api-version": "1.0"}, "name": "example-package", "files": [
+			{"filename": "example_package-2.0.0-py3-none-any.whl", "url": "/f.whl", "hashes": {"sha256": "cc"}, "requires-python": ">=3.10"}
+		]}`)
+	}))
+	defer jsonIndex.Close()
+
+	// A stand-in for mirrors that only have the JSON API of PyPI
+	apiIndex := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/pypi/") || !strings.HasSuffix(r.URL.Path, "/json") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"info": {"name": "example-package"}, "releases": {"3.0.0": [{}]}}`)
+	}))
+	defer apiIndex.Close()
+
+	// A stand-in for indexes whose Simple API is not at /simple, such as
+	// devpi and CodeArtifact
+	pathIndex := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/root/pypi/+simple/example-package/" && r.URL.Path != "/pypi/repo/simple/example-package/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<a href="/packages/example_package-4.0.0.tar.gz#sha256=dd">example_package-4.0.0.tar.gz</a>`)
+	}))
+	defer pathIndex.Close()
+
+	tests := []struct {
+		name     string
+		indexURL string
+		expected []Release
+	}{
+		{"html", htmlIndex.URL + "/simple", []Release{
+			{Version: "1.0.0", Files: []string{"example_package-1.0.0.tar.gz"}},
+			{Version: "1.1.0", Files: []string{"example_package-1.1.0.tar.gz"}, Yanked: true},
+		}},
+		{"json", jsonIndex.URL + "/simple/", []Release{
+			{Version: "2.0.0", RequiresPython: ">=3.10", Files: []string{"example_package-2.0.0-py3-none-any.whl"}},
+		}},
+		{"json api", apiIndex.URL + "/simple", []Release{{Version: "3.0.0"}}},
+		{"devpi", pathIndex.URL + "/root/pypi/+simple/", []Release{
+			{Version: "4.0.0", Files: []string{"example_package-4.0.0.tar.gz"}},
+		}},
+		{"codeartifact", pathIndex.URL + "/pypi/repo/simple/", []Release{
+			{Version: "4.0.0", Files: []string{"example_package-4.0.0.tar.gz"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(false)
+			p.SetPyPIFallback(false)
+			p.SetDirectIndexURL(tt.indexURL)
+			releases, err := p.GetReleases("Example.Package")
+			if err != nil {
+				t.Fatalf("GetReleases failed: %v", err)
+			}
+			if !reflect.DeepEqual(releases, tt.expected) {
+				t.Errorf("releases = %+v, want %+v", releases, tt.expected)
+			}
+		})
+	}
+}
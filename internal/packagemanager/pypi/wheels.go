@@ -0,0 +1,197 @@
+package pypi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rvben/ru/internal/pep440"
+)
+
+// wheelTag is one combination of the Python, ABI and platform tags of a
+// wheel, e.g. cp39-abi3-manylinux_2_17_x86_64
+type wheelTag struct {
+	python   string
+	abi      string
+	platform string
+}
+
+// parseWheelFilename returns the version and tags of a wheel from its file
+// name, {name}-{version}(-{build})?-{python}-{abi}-{platform}.whl, where each
+// tag may be a set such as "py2.py3". It reports false for other files.
+func parseWheelFilename(filename string) (string, []wheelTag, bool) {
+	base, ok := strings.CutSuffix(filename, ".whl")
+	if !ok {
+		return "", nil, false
+	}
+	parts := strings.Split(base, "-")
+	if len(parts) != 5 && len(parts) != 6 {
+		return "", nil, false
+	}
+	n := len(parts)
+	var tags []wheelTag
+	for _, python := range strings.Split(parts[n-3], ".") {
+		for _, abi := range strings.Split(parts[n-2], ".") {
+			for _, platform := range strings.Split(parts[n-1], ".") {
+				tags = append(tags, wheelTag{python: python, abi: abi, platform: platform})
+			}
+		}
+	}
+	return parts[1], tags, true
+}
+
+// platformTagRegex matches the platform tags whose compatibility depends
+// on a version: manylinux_2_17_x86_64, musllinux_1_2_aarch64 and
+// macosx_11_0_arm64
+var platformTagRegex = regexp.MustCompile(`^(manylinux|musllinux|macosx)_(\d+)_(\d+)_(.+)$`)
+
+// legacyManylinux maps the manylinux tags from before PEP 600 to the glibc
+// version they stand for
+var legacyManylinux = map[string]string{
+	"manylinux1":    "manylinux_2_5",
+	"manylinux2010": "manylinux_2_12",
+	"manylinux2014": "manylinux_2_17",
+}
+
+// platformTag is a parsed platform tag
+type platformTag struct {
+	family       string
+	major, minor int
+	arch         string
+}
+
+// parsePlatformTag parses a platform tag. Tags without a version, such as
+// win_amd64 or linux_x86_64, only have a family.
+func parsePlatformTag(tag string) platformTag {
+	for legacy, current := range legacyManylinux {
+		if arch, ok := strings.CutPrefix(tag, legacy+"_"); ok {
+			tag = current + "_" + arch
+			break
+		}
+	}
+	m := platformTagRegex.FindStringSubmatch(tag)
+	if m == nil {
+		return platformTag{family: tag}
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	return platformTag{family: m[1], major: major, minor: minor, arch: m[4]}
+}
+
+// macOSArchs lists the architectures of the multi-architecture macOS tags
+var macOSArchs = map[string][]string{
+	"universal2": {"x86_64", "arm64"},
+	"universal":  {"x86_64", "i386", "ppc64", "ppc"},
+	"intel":      {"x86_64", "i386"},
+}
+
+// platformCompatible reports whether a wheel for platform installs on
+// target. A manylinux or musllinux wheel needs at most the libc version of
+// the target, a macOS wheel at most its macOS version; other platforms must
+// match exactly.
+func platformCompatible(platform, target string) bool {
+	if platform == "any" || platform == target {
+		return true
+	}
+	p, t := parsePlatformTag(platform), parsePlatformTag(target)
+	if p.arch == "" || p.family != t.family {
+		return false
+	}
+	if p.major > t.major || (p.major == t.major && p.minor > t.minor) {
+		return false
+	}
+	if p.arch == t.arch {
+		return true
+	}
+	for _, arch := range macOSArchs[p.arch] {
+		if p.family == "macosx" && arch == t.arch {
+			return true
+		}
+	}
+	return false
+}
+
+// pythonTagRegex matches Python and ABI tags such as cp39, py3 or cp37m
+var pythonTagRegex = regexp.MustCompile(`^(cp|py)(\d)(\d*)([a-z]*)$`)
+
+// compatible reports whether a wheel tag installs on CPython python
+// (any CPython 3 if nil) on target, following the order of tags pip
+// accepts: cpXY with its own ABI, abi3 wheels built for older versions,
+// and pure Python wheels for this or older versions.
+func (t wheelTag) compatible(python *pep440.Version, target string) bool {
+	if !platformCompatible(t.platform, target) {
+		return false
+	}
+	m := pythonTagRegex.FindStringSubmatch(t.python)
+	if m == nil || m[2] != "3" || m[4] != "" {
+		return false
+	}
+	// the target's minor version, -1 for any
+	minor := -1
+	if python != nil && len(python.Release) > 1 {
+		minor = int(python.Release[1])
+	}
+	// the tag's minor version, -1 for tags such as py3 that name none
+	tagMinor := -1
+	if m[3] != "" {
+		tagMinor, _ = strconv.Atoi(m[3])
+	}
+	newer := minor >= 0 && tagMinor > minor
+
+	switch t.abi {
+	case "none":
+		// pure Python or built without the C API: py3, py3N and cpXY
+		if m[1] == "cp" {
+			return minor < 0 || tagMinor == minor
+		}
+		return !newer
+	case "abi3":
+		// the stable ABI of CPython 3.N works on every later version
+		return m[1] == "cp" && tagMinor >= 0 && !newer
+	}
+	abi := pythonTagRegex.FindStringSubmatch(t.abi)
+	// the version-specific ABI, e.g. cp39 or cp37m, but not debug or
+	// free-threaded builds
+	if m[1] != "cp" || abi == nil || abi[1] != "cp" || abi[2]+abi[3] != m[2]+m[3] || (abi[4] != "" && abi[4] != "m") {
+		return false
+	}
+	return minor < 0 || tagMinor == minor
+}
+
+// MissingWheels returns the target platforms none of the given files is a
+// compatible wheel for, on CPython python (any CPython 3 if nil).
+func MissingWheels(files []string, python *pep440.Version, platforms []string) []string {
+	var missing []string
+	for _, platform := range platforms {
+		found := false
+		for _, file := range files {
+			_, tags, ok := parseWheelFilename(file)
+			if !ok {
+				continue
+			}
+			for _, tag := range tags {
+				if tag.compatible(python, platform) {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, platform)
+		}
+	}
+	return missing
+}
+
+// ValidatePlatformTag checks that tag looks like a wheel platform tag,
+// e.g. manylinux_2_17_x86_64 or win_amd64.
+func ValidatePlatformTag(tag string) error {
+	if tag == "" || tag == "any" || strings.ContainsAny(tag, "-. ") || strings.ToLower(tag) != tag {
+		return fmt.Errorf("invalid platform tag %q (e.g. manylinux_2_17_x86_64)", tag)
+	}
+	return nil
+}
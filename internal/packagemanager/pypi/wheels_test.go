@@ -0,0 +1,122 @@
+package pypi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rvben/ru/internal/pep440"
+)
+
+func TestPlatformCompatible(t *testing.T) {
+	tests := []struct {
+		platform string
+		target   string
+		expected bool
+	}{
+		{"any", "manylinux_2_17_x86_64", true},
+		{"manylinux_2_17_x86_64", "manylinux_2_17_x86_64", true},
+		{"manylinux2014_x86_64", "manylinux_2_28_x86_64", true},
+		{"manylinux1_x86_64", "manylinux_2_17_x86_64", true},
+		{"manylinux_2_28_x86_64", "manylinux_2_17_x86_64", false},
+		{"manylinux_2_17_aarch64", "manylinux_2_17_x86_64", false},
+		{"musllinux_1_1_x86_64", "musllinux_1_2_x86_64", true},
+		{"musllinux_1_1_x86_64", "manylinux_2_17_x86_64", false},
+		{"linux_x86_64", "manylinux_2_17_x86_64", false},
+		{"macosx_10_9_universal2", "macosx_11_0_arm64", true},
+		{"macosx_12_0_arm64", "macosx_11_0_arm64", false},
+		{"win_amd64", "win_amd64", true},
+		{"win32", "win_amd64", false},
+	}
+	for _, tt := range tests {
+		if got := platformCompatible(tt.platform, tt.target); got != tt.expected {
+			t.Errorf("platformCompatible(%s, %s) = %v, want %v", tt.platform, tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestMissingWheels(t *testing.T) {
+	targets := []string{"manylinux_2_17_x86_64", "manylinux_2_17_aarch64"}
+	py39 := pep440.MustParse("3.9")
+
+	tests := []struct {
+		name     string
+		files    []string
+		python   *pep440.Version
+		expected []string
+	}{
+		{"pure Python", []string{"requests-2.32.0-py3-none-any.whl", "requests-2.32.0.tar.gz"}, py39, nil},
+		{"sdist only", []string{"pkg-1.0.tar.gz"}, py39, targets},
+		{
+			"both architectures",
+			[]string{
+				"numpy-1.26.0-cp39-cp39-manylinux_2_17_x86_64.manylinux2014_x86_64.whl",
+				"numpy-1.26.0-cp39-cp39-manylinux_2_17_aarch64.manylinux2014_aarch64.whl",
+			},
+			py39, nil,
+		},
+		{
+			"missing aarch64",
+			[]string{"numpy-1.26.0-cp39-cp39-manylinux_2_17_x86_64.manylinux2014_x86_64.whl"},
+			py39, []string{"manylinux_2_17_aarch64"},
+		},
+		{
+			"other Python version",
+			[]string{
+				"numpy-2.1.0-cp310-cp310-manylinux_2_17_x86_64.whl",
+				"numpy-2.1.0-cp310-cp310-manylinux_2_17_aarch64.whl",
+			},
+			py39, targets,
+		},
+		{
+			"any CPython when the version is unknown",
+			[]string{
+				"numpy-2.1.0-cp310-cp310-manylinux_2_17_x86_64.whl",
+				"numpy-2.1.0-cp310-cp310-manylinux_2_17_aarch64.whl",
+			},
+			nil, nil,
+		},
+		{
+			"stable ABI from an older version",
+			[]string{"cryptography-43.0.0-cp37-abi3-manylinux_2_17_x86_64.manylinux_2_17_aarch64.whl"},
+			py39, nil,
+		},
+		{
+			"newer glibc",
+			[]string{
+				"pkg-1.0-cp39-cp39-manylinux_2_28_x86_64.whl",
+				"pkg-1.0-cp39-cp39-manylinux_2_17_aarch64.whl",
+			},
+			py39, []string{"manylinux_2_17_x86_64"},
+		},
+		{
+			"musllinux and free-threaded builds",
+			[]string{
+				"pkg-1.0-cp39-cp39-musllinux_1_1_x86_64.whl",
+				"pkg-1.0-cp39-cp39t-manylinux_2_17_aarch64.whl",
+			},
+			py39, targets,
+		},
+		{"build tag", []string{"pkg-1.0-1-py2.py3-none-any.whl"}, py39, nil},
+		{"Python 2 only", []string{"pkg-1.0-py2-none-any.whl"}, py39, targets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingWheels(tt.files, tt.python, targets); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("MissingWheels() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidatePlatformTag(t *testing.T) {
+	for _, tag := range []string{"manylinux_2_17_x86_64", "win_amd64", "macosx_11_0_arm64"} {
+		if err := ValidatePlatformTag(tag); err != nil {
+			t.Errorf("ValidatePlatformTag(%s) failed: %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"", "any", "manylinux-2-17", "Win_AMD64"} {
+		if err := ValidatePlatformTag(tag); err == nil {
+			t.Errorf("expected ValidatePlatformTag(%q) to fail", tag)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package pyproject
 import (
 	"os"
 	"testing"
+
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // BenchmarkLoadAndUpdate benchmarks loading and updating a pyproject.toml file
@@ -111,88 +113,53 @@ line-length = 120
 	}
 }
 
-// BenchmarkUpdateDependencyString benchmarks updating a dependency string
-func BenchmarkUpdateDependencyString(b *testing.B) {
+// BenchmarkMoveConstraint benchmarks moving a constraint to a new version
+func BenchmarkMoveConstraint(b *testing.B) {
 	// Test cases
 	testCases := []struct {
-		name     string
-		line     string
-		versions map[string]string
+		name       string
+		scheme     versioning.Scheme
+		constraint string
+		newVersion string
 	}{
 		{
-			name:     "Simple equality constraint",
-			line:     "requests==2.28.0",
-			versions: map[string]string{"requests": "2.32.0"},
-		},
-		{
-			name:     "Range constraint",
-			line:     "flask>=2.0.0,<3.0.0",
-			versions: map[string]string{"flask": "2.3.0"},
-		},
-		{
-			name:     "Approximate constraint",
-			line:     "django~=4.0.0",
-			versions: map[string]string{"django": "4.2.0"},
-		},
-		{
-			name:     "Caret constraint",
-			line:     "numpy^1.20.0",
-			versions: map[string]string{"numpy": "1.25.0"},
-		},
-		{
-			name:     "No constraint",
-			line:     "pandas",
-			versions: map[string]string{"pandas": "2.0.0"},
+			name:       "Simple equality constraint",
+			scheme:     versioning.PEP440,
+			constraint: "==2.28.0",
+			newVersion: "2.32.0",
 		},
-	}
-
-	p := &PyProject{}
-
-	b.ResetTimer()
-	b.ReportAllocs()
-
-	for i := 0; i < b.N; i++ {
-		tc := testCases[i%len(testCases)]
-		p.updateDependencyString(tc.line, tc.versions)
-	}
-}
-
-// BenchmarkUpdateComplexConstraint benchmarks updating a complex constraint
-func BenchmarkUpdateComplexConstraint(b *testing.B) {
-	// Test cases
-	testCases := []struct {
-		name     string
-		dep      string
-		versions map[string]string
-	}{
 		{
-			name:     "Equality in complex constraint",
-			dep:      "requests>=2.28.0,==2.31.0",
-			versions: map[string]string{"requests": "2.32.0"},
+			name:       "Equality in complex constraint",
+			scheme:     versioning.PEP440,
+			constraint: ">=2.28.0,==2.31.0",
+			newVersion: "2.32.0",
 		},
 		{
-			name:     "Multiple constraints",
-			dep:      "flask>=2.0.0,<3.0.0,!=2.1.0",
-			versions: map[string]string{"flask": "2.3.0"},
+			name:       "Multiple constraints",
+			scheme:     versioning.PEP440,
+			constraint: ">=2.0.0,<3.0.0,!=2.1.0",
+			newVersion: "2.3.0",
 		},
 		{
-			name:     "Simple constraint",
-			dep:      "django>=4.0.0",
-			versions: map[string]string{"django": "4.2.0"},
+			name:       "Approximate constraint",
+			scheme:     versioning.PEP440,
+			constraint: "~=4.0.0",
+			newVersion: "4.2.0",
 		},
 		{
-			name:     "No update needed",
-			dep:      "numpy>=1.20.0,<2.0.0",
-			versions: map[string]string{"numpy": "1.25.0"},
+			name:       "Caret constraint",
+			scheme:     versioning.Poetry,
+			constraint: "^1.20.0",
+			newVersion: "1.25.0",
 		},
 	}
 
+	p := NewPyProject("")
 	b.ResetTimer()
 	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
 		tc := testCases[i%len(testCases)]
-		depCopy := tc.dep
-		updateComplexConstraint(&depCopy, tc.versions)
+		p.moveConstraint(tc.scheme, tc.name, tc.constraint, tc.newVersion)
 	}
 }
@@ -2,8 +2,11 @@ package pyproject
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/rvben/ru/internal/versioning"
 )
 
 func TestLoadAndUpdate(t *testing.T) {
@@ -362,205 +365,61 @@ dependencies = [
 	}
 }
 
-func TestUpdateDependencyString(t *testing.T) {
-	tests := []struct {
-		name     string
-		line     string
-		versions map[string]string
-		want     string
-		updated  bool
-	}{
-		{
-			name:     "Simple version update",
-			line:     "requests==2.28.0",
-			versions: map[string]string{"requests": "2.32.0"},
-			want:     "requests==2.32.0",
-			updated:  true,
-		},
-		{
-			name:     "no update needed",
-			line:     "requests==2.32.0",
-			versions: map[string]string{"requests": "2.32.0"},
-			want:     "requests==2.32.0",
-			updated:  false,
-		},
-		{
-			name:     "package not in versions",
-			line:     "flask==2.0.0",
-			versions: map[string]string{"requests": "2.32.0"},
-			want:     "flask==2.0.0",
-			updated:  false,
-		},
-		{
-			name:     "empty line",
-			line:     "",
-			versions: map[string]string{"requests": "2.32.0"},
-			want:     "",
-			updated:  false,
-		},
-		{
-			name:     "comment line",
-			line:     "# This is a comment",
-			versions: map[string]string{"requests": "2.32.0"},
-			want:     "# This is a comment",
-			updated:  false,
-		},
-		{
-			name:     "non-equality constraint",
-			line:     "requests>=2.28.0",
-			versions: map[string]string{"requests": "2.32.0"},
-			want:     "requests>=2.32.0",
-			updated:  true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := &PyProject{}
-			got, updated := p.updateDependencyString(tt.line, tt.versions)
-			if got != tt.want {
-				t.Errorf("updateDependencyString() got = %v, want %v", got, tt.want)
-			}
-			if updated != tt.updated {
-				t.Errorf("updateDependencyString() updated = %v, want %v", updated, tt.updated)
-			}
-		})
-	}
-}
-
-func TestUpdateComplexConstraint(t *testing.T) {
-	tests := []struct {
-		name        string
-		dep         string
-		versions    map[string]string
-		wantUpdated bool
-		wantPackage string
-		wantDep     string
-	}{
-		{
-			name:        "Special case equality constraint",
-			dep:         "requests>=2.28.0,==2.31.0",
-			versions:    map[string]string{"requests": "2.32.0"},
-			wantUpdated: true,
-			wantPackage: "requests",
-			wantDep:     "requests>=2.28.0,==2.32.0",
-		},
-		{
-			name:        "No equality constraint in complex string",
-			dep:         "requests>=2.28.0,<3.0.0",
-			versions:    map[string]string{"requests": "2.32.0"},
-			wantUpdated: false,
-			wantPackage: "requests",
-			wantDep:     "requests>=2.28.0,<3.0.0",
-		},
-		{
-			name:        "Package not in versions",
-			dep:         "flask>=2.0.0,==2.1.0",
-			versions:    map[string]string{"requests": "2.32.0"},
-			wantUpdated: false,
-			wantPackage: "flask",
-			wantDep:     "flask>=2.0.0,==2.1.0",
-		},
-		{
-			name:        "Empty line",
-			dep:         "",
-			versions:    map[string]string{"requests": "2.32.0"},
-			wantUpdated: false,
-			wantPackage: "",
-			wantDep:     "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			depCopy := tt.dep
-			pkg, updated := updateComplexConstraint(&depCopy, tt.versions)
-			if tt.name == "Package not in versions" {
-				// Special case - the function returns the package name correctly as flask
-				// but we're getting "flask>=2.0.0," instead in the test
-				if pkg != "flask" {
-					t.Errorf("updateComplexConstraint() pkg = %v, want %v", pkg, "flask")
-				}
-			} else if pkg != tt.wantPackage {
-				t.Errorf("updateComplexConstraint() pkg = %v, want %v", pkg, tt.wantPackage)
-			}
-			if updated != tt.wantUpdated {
-				t.Errorf("updateComplexConstraint() updated = %v, want %v", updated, tt.wantUpdated)
-			}
-			if depCopy != tt.wantDep {
-				t.Errorf("updateComplexConstraint() dep = %v, want %v", depCopy, tt.wantDep)
-			}
-		})
-	}
-}
-
-func TestUpdateVersionWithSameConstraint(t *testing.T) {
+func TestMoveConstraint(t *testing.T) {
 	tests := []struct {
 		name       string
+		scheme     versioning.Scheme
 		constraint string
 		newVersion string
 		want       string
+		updated    bool
 	}{
-		{
-			name:       "Caret constraint",
-			constraint: "^2.28.0",
-			newVersion: "2.32.0",
-			want:       "^2.32.0",
-		},
-		{
-			name:       "Tilde constraint",
-			constraint: "~2.28.0",
-			newVersion: "2.32.0",
-			want:       "~2.32.0",
-		},
-		{
-			name:       "Greater than or equal constraint",
-			constraint: ">=2.28.0",
-			newVersion: "2.32.0",
-			want:       ">=2.32.0",
-		},
-		{
-			name:       "Less than or equal constraint",
-			constraint: "<=2.28.0",
-			newVersion: "2.32.0",
-			want:       "<=2.32.0",
-		},
-		{
-			name:       "Greater than constraint",
-			constraint: ">2.28.0",
-			newVersion: "2.32.0",
-			want:       ">2.32.0",
-		},
-		{
-			name:       "Less than constraint",
-			constraint: "<3.0.0",
-			newVersion: "2.32.0",
-			want:       "<2.32.0",
-		},
-		{
-			name:       "Approximate constraint",
-			constraint: "~=2.28.0",
-			newVersion: "2.32.0",
-			want:       "~=2.32.0",
-		},
-		{
-			name:       "No constraint",
-			constraint: "2.28.0",
-			newVersion: "2.32.0",
-			want:       "2.32.0",
-		},
+		{"pin", versioning.PEP440, "==2.28.0", "2.32.0", "==2.32.0", true},
+		{"pin already current", versioning.PEP440, "==2.32.0", "2.32.0", "", false},
+		{"pin never moves down", versioning.PEP440, "==2.32.0", "2.28.0", "", false},
+		{"lower bound", versioning.PEP440, ">=2.28.0", "2.32.0", ">=2.32.0", true},
+		{"upper bound is kept", versioning.PEP440, "<=2.0", "2.32.0", "", false},
+		{"exclusive upper bound is kept", versioning.PEP440, "<2.28.0", "2.32.0", "", false},
+		{"poetry upper bound is kept", versioning.Poetry, "<=2.0", "2.32.0", "", false},
+		{"compatible release", versioning.PEP440, "~=1.9.0", "1.10.7", "~=1.10.7", true},
+		{"spaces are kept", versioning.PEP440, ">= 2.28.0", "2.32.0", ">= 2.32.0", true},
+		{"exclusive bound would exclude the version", versioning.PEP440, ">2.28.0", "2.32.0", "", false},
+		{"newer upper bound", versioning.PEP440, "<3.0.0", "2.32.0", "", false},
+		{"pin in a list", versioning.PEP440, ">=2.28.0,==2.31.0", "2.32.0", ">=2.28.0,==2.32.0", true},
+		{"pin before a bound", versioning.PEP440, "==7.0.0,<8.0.0", "7.4.3", "==7.4.3,<8.0.0", true},
+		{"pin beyond a bound", versioning.PEP440, "==7.0.0,<8.0.0", "8.1.0", "", false},
+		{"range without a pin", versioning.PEP440, ">=2.28.0,<3.0.0", "2.32.0", "", false},
+		{"exclusion", versioning.PEP440, ">=2.0.0,<3.0.0,!=2.1.0", "2.3.0", "", false},
+		{"wildcard", versioning.PEP440, "==1.4.*", "1.5.0", "", false},
+		{"poetry caret", versioning.Poetry, "^2.31.0", "2.32.0", "^2.32.0", true},
+		{"poetry caret already current", versioning.Poetry, "^7.4.3", "7.4.3", "", false},
+		{"poetry tilde", versioning.Poetry, "~2.28.0", "2.28.5", "~2.28.5", true},
+		{"poetry exact", versioning.Poetry, "2.28.0", "2.32.0", "2.32.0", true},
+		{"poetry range", versioning.Poetry, ">=2.0.0,<3.0.0", "2.1.0", "", false},
+		{"poetry union", versioning.Poetry, "^1.2 || ^2.0", "2.1.0", "", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := updateVersionWithSameConstraint(tt.constraint, tt.newVersion)
-			if got != tt.want {
-				t.Errorf("updateVersionWithSameConstraint() = %v, want %v", got, tt.want)
+			got, updated := NewPyProject("").moveConstraint(tt.scheme, "pkg", tt.constraint, tt.newVersion)
+			if got != tt.want || updated != tt.updated {
+				t.Errorf("moveConstraint(%q, %q) = %q, %v, want %q, %v", tt.constraint, tt.newVersion, got, updated, tt.want, tt.updated)
 			}
 		})
 	}
 }
 
+func TestMoveConstraintDowngrade(t *testing.T) {
+	p := NewPyProject("")
+	p.SetAllowDowngrade(true)
+	if got, ok := p.moveConstraint(versioning.PEP440, "pkg", "==2.0.0rc1", "1.9.0"); !ok || got != "==1.9.0" {
+		t.Errorf("expected the pin to move down to ==1.9.0, got %q (updated=%v)", got, ok)
+	}
+	if got, ok := p.moveConstraint(versioning.Poetry, "pkg", "^2.0.0rc1", "1.9.0"); !ok || got != "^1.9.0" {
+		t.Errorf("expected the caret to move down to ^1.9.0, got %q (updated=%v)", got, ok)
+	}
+}
+
 func TestRemoveDuplicates(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -609,3 +468,173 @@ func TestRemoveDuplicates(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateRequirementRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy versioning.RangeStrategy
+		dep      string
+		want     string
+		updated  bool
+	}{
+		{"Pin range", versioning.RangePin, "requests>=2.28", "requests==2.32.0", true},
+		{"Bump floor", versioning.RangeBump, "requests>=2.28,<3", "requests>=2.32.0,<3", true},
+		{"Bump keeps extras and marker", versioning.RangeBump, `requests[socks] >=2.28 ; python_version >= "3.8"`, `requests[socks] >=2.32.0 ; python_version >= "3.8"`, true},
+		{"Widen upper bound", versioning.RangeWiden, "requests>=2.0,<2.30", "requests>=2.0,<2.33", true},
+		{"Widen leaves accepting range", versioning.RangeWiden, "requests>=2.0,<3", "", false},
+		{"Replace range", versioning.RangeReplace, "requests (~=1.4)", "requests (~=2.32)", true},
+		{"Pin moves up", versioning.RangeWiden, "requests==2.31.0", "requests==2.32.0", true},
+		{"Bump blocked by upper bound", versioning.RangeBump, "requests>=2.0,<2.30", "", false},
+		{"Unconstrained", versioning.RangePin, "requests", "", false},
+		{"Unknown package", versioning.RangePin, "flask>=2.0", "", false},
+	}
+
+	versions := map[string]string{"requests": "2.32.0"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PyProject{rangeStrategy: tt.strategy}
+			got, name, updated := p.updateRequirementRange(tt.dep, versions)
+			if updated != tt.updated || got != tt.want {
+				t.Errorf("updateRequirementRange(%q) = %q, %v, want %q, %v", tt.dep, got, updated, tt.want, tt.updated)
+			}
+			if updated && name != "requests" {
+				t.Errorf("updateRequirementRange(%q) name = %q, want requests", tt.dep, name)
+			}
+		})
+	}
+}
+
+func TestLoadAndUpdateRangeStrategy(t *testing.T) {
+	content := `[project]
+dependencies = [
+    "requests>=2.28,<2.30",
+    "flask==2.0.0",
+]
+
+[tool.poetry]
+name = "example"
+
+[tool.poetry.dependencies]
+pytest = "^6.2"
+`
+	tmpfile := filepath.Join(t.TempDir(), "pyproject.toml")
+	if err := os.WriteFile(tmpfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPyProject(tmpfile)
+	p.SetRangeStrategy(versioning.RangeWiden)
+	updated, err := p.LoadAndUpdate(map[string]string{"requests": "2.32.0", "flask": "2.1.0", "pytest": "8.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 3 {
+		t.Errorf("expected 3 updated packages, got %v", updated)
+	}
+
+	got, err := os.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"requests>=2.28,<2.33"`, `"flask==2.1.0"`, `pytest = "^6.2 || ^8.1.1"`} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("expected %s in updated file:\n%s", want, got)
+		}
+	}
+}
+
+func TestLoadAndUpdatePreservesDocument(t *testing.T) {
+	content := `# Managed by ru
+[project]
+name = "example"
+dependencies = ['requests>=2.28.0', "flask[async] == 2.0.0 ; python_version >= '3.8'"]  # runtime
+
+[project.optional-dependencies]
+docs = [
+    "sphinx==7.0.0"  # keep the comment
+]
+
+[dependency-groups]
+dev = [{ include-group = "lint" }, "pytest==7.4.2"]
+
+[tool.poetry.dependencies]
+python = "^3.9"
+django = { version = "^4.2", extras = ["bcrypt"] }
+
+[tool.poetry.group.test.dependencies]
+coverage = '^7.0'
+
+[tool.unknown]
+anything = { nested = [1, 2, 3], when = 1979-05-27 }
+`
+	tmpfile := filepath.Join(t.TempDir(), "pyproject.toml")
+	if err := os.WriteFile(tmpfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPyProject(tmpfile)
+	updated, err := p.LoadAndUpdate(map[string]string{
+		"requests": "2.32.0",
+		"flask":    "3.0.3",
+		"sphinx":   "7.3.7",
+		"pytest":   "8.2.0",
+		"django":   "5.0.6",
+		"coverage": "7.5.1",
+		"python":   "3.12.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(updated, ","); got != "requests,flask,sphinx,pytest,django,coverage" {
+		t.Errorf("unexpected updated packages: %s", got)
+	}
+
+	expected := strings.NewReplacer(
+		`'requests>=2.28.0'`, `'requests>=2.32.0'`,
+		`"flask[async] == 2.0.0 ;`, `"flask[async] == 3.0.3 ;`,
+		`"sphinx==7.0.0"  #`, `"sphinx==7.3.7",  #`,
+		`"pytest==7.4.2"`, `"pytest==8.2.0"`,
+		`"^4.2"`, `"^5.0.6"`,
+		`'^7.0'`, `'^7.5.1'`,
+	).Replace(content)
+	got, err := os.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != expected {
+		t.Errorf("unexpected file:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	content := `[project]
+dependencies = ["requests>=2.28,<3", "rich", "local @ file:///tmp/local", "attrs (==23.1.0)"]
+
+[tool.poetry]
+dependencies = { python = "^3.9", flask = "^2.0", version = "1.0" }
+`
+	tmpfile := filepath.Join(t.TempDir(), "pyproject.toml")
+	if err := os.WriteFile(tmpfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProject(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Dependency{
+		{Name: "requests", Constraint: ">=2.28,<3"},
+		{Name: "attrs", Constraint: "==23.1.0"},
+		{Name: "flask", Constraint: "^2.0", Poetry: true},
+		{Name: "version", Constraint: "1.0", Poetry: true},
+	}
+	got := p.Dependencies()
+	if len(got) != len(expected) {
+		t.Fatalf("Dependencies() = %+v, want %+v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Dependencies()[%d] = %+v, want %+v", i, got[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package requirements
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// envVarRegex matches the environment variable references pip expands:
+// only the braced form with upper case letters, digits and underscores
+var envVarRegex = regexp.MustCompile(`\$\{([A-Z0-9_]+)\}`)
+
+// ExpandEnv expands ${VAR} references the way pip does. References to
+// variables that are unset or empty are left untouched.
+func ExpandEnv(s string) string {
+	return envVarRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if value := os.Getenv(ref[2 : len(ref)-1]); value != "" {
+			return value
+		}
+		return ref
+	})
+}
+
+// Document is a requirements file together with the files it references
+// through "-r" (includes) and "-c" (constraints) lines.
+type Document struct {
+	// Path is the cleaned path of the file
+	Path string
+	// File is the parsed content of the file
+	File *File
+	// Includes are the documents referenced with -r, in file order
+	Includes []*Document
+	// Constraints are the documents referenced with -c, in file order
+	Constraints []*Document
+
+	// refs maps the -r and -c lines of File to the documents they reference
+	refs map[*Line]*Document
+}
+
+// loader keeps the state of a recursive Load
+type loader struct {
+	docs  map[string]*Document
+	stack []string
+}
+
+// Load parses the requirements file at path and, recursively, every file it
+// includes or uses as constraints. Relative references are resolved against
+// the directory of the referencing file and ${VAR} references are expanded.
+// Files reached through several paths are loaded once; an include cycle is
+// reported as an error. Remote references (http/https URLs) are skipped.
+func Load(path string) (*Document, error) {
+	l := &loader{docs: make(map[string]*Document)}
+	return l.load(filepath.Clean(path))
+}
+
+func (l *loader) load(path string) (*Document, error) {
+	for i, p := range l.stack {
+		if p == path {
+			chain := append(append([]string{}, l.stack[i:]...), path)
+			return nil, fmt.Errorf("include cycle detected: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if doc, ok := l.docs[path]; ok {
+		return doc, nil
+	}
+
+	file, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Path: path, File: file, refs: make(map[*Line]*Document)}
+
+	l.stack = append(l.stack, path)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	for _, line := range file.Lines {
+		if line.Kind != KindInclude && line.Kind != KindConstraint {
+			continue
+		}
+		target := ExpandEnv(line.Value)
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		child, err := l.load(filepath.Clean(target))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s:%d: referenced file not found: %s", path, line.Number, line.Value)
+			}
+			return nil, err
+		}
+		doc.refs[line] = child
+		if line.Kind == KindInclude {
+			doc.Includes = append(doc.Includes, child)
+		} else {
+			doc.Constraints = append(doc.Constraints, child)
+		}
+	}
+
+	l.docs[path] = doc
+	return doc, nil
+}
+
+// Files returns the document and every document it includes, directly or
+// indirectly, each exactly once and in depth-first order. These are the
+// files whose requirements are installed together with this one.
+func (d *Document) Files() []*Document {
+	var files []*Document
+	seen := make(map[*Document]bool)
+	var walk func(doc *Document)
+	walk = func(doc *Document) {
+		if seen[doc] {
+			return
+		}
+		seen[doc] = true
+		files = append(files, doc)
+		for _, inc := range doc.Includes {
+			walk(inc)
+		}
+	}
+	walk(d)
+	return files
+}
+
+// Lines returns the lines of the document and the files it includes in the
+// order pip reads them: the lines of an included file take the place of its
+// -r line. Each file is read once. Global options such as --index-url apply
+// to the whole tree, wherever they appear.
+func (d *Document) Lines() []*Line {
+	var lines []*Line
+	seen := make(map[*Document]bool)
+	var walk func(doc *Document)
+	walk = func(doc *Document) {
+		if seen[doc] {
+			return
+		}
+		seen[doc] = true
+		for _, line := range doc.File.Lines {
+			if child, ok := doc.refs[line]; ok && line.Kind == KindInclude {
+				walk(child)
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	walk(d)
+	return lines
+}
+
+// ConstraintSet returns the version specifiers imposed by every constraints
+// file in the tree, keyed by normalized package name. Like pip, constraints
+// apply to all requirements of the tree regardless of where the -c line is.
+func (d *Document) ConstraintSet() map[string][]string {
+	constraints := make(map[string][]string)
+	seen := make(map[*Document]bool)
+
+	// collect adds the requirements of a constraints file and of everything
+	// it references in turn
+	var collect func(doc *Document)
+	collect = func(doc *Document) {
+		if seen[doc] {
+			return
+		}
+		seen[doc] = true
+		for _, line := range doc.File.Requirements() {
+			if line.Specifier != "" {
+				name := line.NormalizedName()
+				constraints[name] = append(constraints[name], line.Specifier)
+			}
+		}
+		for _, inc := range doc.Includes {
+			collect(inc)
+		}
+		for _, c := range doc.Constraints {
+			collect(c)
+		}
+	}
+
+	for _, doc := range d.Files() {
+		for _, c := range doc.Constraints {
+			collect(c)
+		}
+	}
+	return constraints
+}
@@ -0,0 +1,539 @@
+// Package requirements implements a format-preserving model of pip
+// requirements files.
+//
+// A file is parsed into logical lines (physical lines joined by trailing
+// backslashes, exactly as pip does). Every line keeps its original bytes, so
+// rendering an unmodified File reproduces the input byte for byte. Edits are
+// applied in place: changing a requirement's version only rewrites the
+// version token and leaves markers, comments, hashes and continuations alone.
+package requirements
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LineKind identifies what a logical line of a requirements file contains.
+type LineKind int
+
+const (
+	// KindBlank is an empty or whitespace-only line
+	KindBlank LineKind = iota
+	// KindComment is a line that only contains a comment
+	KindComment
+	// KindRequirement is a PEP 508 name-based requirement such as "flask>=2.0"
+	KindRequirement
+	// KindURL is a direct reference: "name @ url", a bare URL, a VCS URL or a local path
+	KindURL
+	// KindEditable is an "-e"/"--editable" line
+	KindEditable
+	// KindInclude is an "-r"/"--requirement" line
+	KindInclude
+	// KindConstraint is a "-c"/"--constraint" line
+	KindConstraint
+	// KindOption is any other global option such as "--index-url" or "--pre"
+	KindOption
+)
+
+// String returns a human readable name for the line kind
+func (k LineKind) String() string {
+	switch k {
+	case KindBlank:
+		return "blank"
+	case KindComment:
+		return "comment"
+	case KindRequirement:
+		return "requirement"
+	case KindURL:
+		return "url"
+	case KindEditable:
+		return "editable"
+	case KindInclude:
+		return "include"
+	case KindConstraint:
+		return "constraint"
+	case KindOption:
+		return "option"
+	}
+	return fmt.Sprintf("LineKind(%d)", int(k))
+}
+
+// shortOptions maps pip's short option names to their long form
+var shortOptions = map[string]string{
+	"-r": "--requirement",
+	"-c": "--constraint",
+	"-e": "--editable",
+	"-i": "--index-url",
+	"-f": "--find-links",
+}
+
+// nameRegex matches a PEP 508 distribution name at the start of a string
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?`)
+
+// normalizeRegex matches runs of characters that PEP 503 treats as equivalent
+var normalizeRegex = regexp.MustCompile(`[-_.]+`)
+
+// Line is a single logical line of a requirements file.
+type Line struct {
+	// Kind is the kind of content on the line
+	Kind LineKind
+	// Raw is the exact source text of the line, including any backslash
+	// continuations, but excluding the final line terminator
+	Raw string
+	// EOL is the line terminator that followed Raw ("\n", "\r\n" or "")
+	EOL string
+	// Number is the 1-based number of the first physical line
+	Number int
+
+	// Name is the distribution name for requirement, URL and editable lines
+	Name string
+	// Extras lists the requested extras, e.g. ["security"] for "requests[security]"
+	Extras []string
+	// Specifier is the version specifier, e.g. ">=1.0,<2.0"
+	Specifier string
+	// Marker is the environment marker after ";", e.g. `python_version < "3.10"`
+	Marker string
+	// URL is the direct reference of URL and editable lines
+	URL string
+	// Hashes lists the values of --hash options, e.g. "sha256:abcd..."
+	Hashes []string
+	// Comment is the trailing comment including the leading "#"
+	Comment string
+
+	// Option is the long option name of option, include and constraint lines
+	Option string
+	// Value is the option argument, e.g. the path of an "-r" line
+	Value string
+
+	// logical is the line with continuations removed; index maps every byte
+	// of logical to its offset in Raw
+	logical string
+	index   []int
+	// specStart/specEnd delimit the specifier in logical; specParens
+	// records whether the specifier was written in parentheses
+	specStart, specEnd int
+	specParens         bool
+}
+
+// File is a parsed requirements file.
+type File struct {
+	Lines []*Line
+}
+
+// Parse parses the content of a requirements file. Parsing never fails:
+// lines that cannot be understood are kept verbatim as options or URLs.
+func Parse(content string) *File {
+	f := &File{}
+	var raw strings.Builder
+	start := 0
+	lineNo := 1
+
+	for len(content) > 0 {
+		// Split off one physical line together with its terminator
+		text, eol := content, ""
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			text, eol = content[:i], "\n"
+			if strings.HasSuffix(text, "\r") {
+				text, eol = text[:len(text)-1], "\r\n"
+			}
+			content = content[i+1:]
+		} else {
+			content = ""
+		}
+
+		if raw.Len() == 0 {
+			start = lineNo
+		}
+		lineNo++
+
+		// A trailing backslash joins the next physical line, unless it is
+		// part of a comment: pip ends a line at any comment, so a backslash
+		// after "#" never continues it
+		if strings.HasSuffix(text, `\`) && commentStart(text) < 0 && content != "" {
+			raw.WriteString(text)
+			raw.WriteString(eol)
+			continue
+		}
+
+		raw.WriteString(text)
+		line := &Line{Raw: raw.String(), EOL: eol, Number: start}
+		line.parse()
+		f.Lines = append(f.Lines, line)
+		raw.Reset()
+	}
+
+	return f
+}
+
+// ParseFile reads and parses the requirements file at path.
+func ParseFile(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(content)), nil
+}
+
+// String renders the file. An unmodified File renders to its exact input.
+func (f *File) String() string {
+	var b strings.Builder
+	for _, l := range f.Lines {
+		b.WriteString(l.Raw)
+		b.WriteString(l.EOL)
+	}
+	return b.String()
+}
+
+// Requirements returns the name-based requirement lines in file order.
+func (f *File) Requirements() []*Line {
+	var reqs []*Line
+	for _, l := range f.Lines {
+		if l.Kind == KindRequirement {
+			reqs = append(reqs, l)
+		}
+	}
+	return reqs
+}
+
+// Options returns all lines carrying the given long option name, e.g. "--index-url".
+func (f *File) Options(name string) []*Line {
+	var opts []*Line
+	for _, l := range f.Lines {
+		if l.Option == name {
+			opts = append(opts, l)
+		}
+	}
+	return opts
+}
+
+// NormalizeName returns the PEP 503 normalized form of a distribution name.
+func NormalizeName(name string) string {
+	return strings.ToLower(normalizeRegex.ReplaceAllString(name, "-"))
+}
+
+// NormalizedName returns the PEP 503 normalized form of the line's name.
+func (l *Line) NormalizedName() string {
+	return NormalizeName(l.Name)
+}
+
+// Pinned returns the version of a requirement pinned with a single "==" or
+// "===" clause. Wildcard pins such as "==1.4.*" are reported as pinned.
+func (l *Line) Pinned() (string, bool) {
+	if l.Kind != KindRequirement || strings.Contains(l.Specifier, ",") {
+		return "", false
+	}
+	spec := strings.TrimSpace(l.Specifier)
+	for _, op := range []string{"===", "=="} {
+		if strings.HasPrefix(spec, op) {
+			return strings.TrimSpace(spec[len(op):]), true
+		}
+	}
+	return "", false
+}
+
+// SetVersion pins the requirement to version. For a line pinned with "=="
+// only the version token is replaced; a requirement without a specifier gets
+// "==version" inserted right after its name and extras. Any other specifier
+// is replaced by "==version". It reports whether the line changed.
+func (l *Line) SetVersion(version string) bool {
+	if l.Kind != KindRequirement {
+		return false
+	}
+	if current, ok := l.Pinned(); ok {
+		if current == version {
+			return false
+		}
+		// Locate the version token inside the specifier and replace only it
+		spec := l.logical[l.specStart:l.specEnd]
+		opEnd := strings.Index(spec, "==") + 2
+		if strings.HasPrefix(spec[opEnd:], "=") {
+			opEnd++
+		}
+		tokenStart := opEnd
+		for tokenStart < len(spec) && (spec[tokenStart] == ' ' || spec[tokenStart] == '\t') {
+			tokenStart++
+		}
+		tokenEnd := len(strings.TrimRight(spec, " \t)"))
+		l.splice(l.specStart+tokenStart, l.specStart+tokenEnd, version)
+		return true
+	}
+	return l.SetSpecifier("==" + version)
+}
+
+// SetSpecifier replaces the requirement's version specifier, keeping
+// everything else on the line intact. It reports whether the line changed.
+func (l *Line) SetSpecifier(spec string) bool {
+	if l.Kind != KindRequirement || spec == l.Specifier {
+		return false
+	}
+	if l.specParens {
+		spec = "(" + spec + ")"
+	}
+	l.splice(l.specStart, l.specEnd, spec)
+	return true
+}
+
+// splice replaces logical[start:end] with text and re-parses the line.
+func (l *Line) splice(start, end int, text string) {
+	rawStart := 0
+	if start < len(l.index) {
+		rawStart = l.index[start]
+	} else if len(l.index) > 0 {
+		// Append after the last logical byte, before any dangling backslash
+		rawStart = l.index[len(l.index)-1] + 1
+	}
+	rawEnd := rawStart
+	if end > start {
+		rawEnd = l.index[end-1] + 1
+	}
+	l.Raw = l.Raw[:rawStart] + text + l.Raw[rawEnd:]
+	l.parse()
+}
+
+// parse (re)computes every derived field of the line from Raw.
+func (l *Line) parse() {
+	*l = Line{Raw: l.Raw, EOL: l.EOL, Number: l.Number}
+	l.join()
+
+	body := l.logical
+	if strings.HasSuffix(body, `\`) && commentStart(body) < 0 {
+		// A continuation on the last line of the file has nothing to join;
+		// like pip, drop the dangling backslash
+		body = body[:len(body)-1]
+		l.logical, l.index = body, l.index[:len(body)]
+	}
+	if i := commentStart(body); i >= 0 {
+		l.Comment = strings.TrimSpace(body[i:])
+		body = body[:i]
+	}
+
+	trimmed := strings.TrimSpace(body)
+	switch {
+	case trimmed == "" && l.Comment != "":
+		l.Kind = KindComment
+	case trimmed == "":
+		l.Kind = KindBlank
+	case strings.HasPrefix(trimmed, "-"):
+		l.parseOption(trimmed)
+	default:
+		offset := strings.Index(body, trimmed)
+		l.parseRequirement(body, offset)
+	}
+}
+
+// join builds the logical line by dropping backslash-newline continuations,
+// recording for every logical byte its offset in Raw.
+func (l *Line) join() {
+	var b strings.Builder
+	l.index = l.index[:0]
+	raw := l.Raw
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			rest := raw[i+1:]
+			if strings.HasPrefix(rest, "\r\n") {
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(rest, "\n") {
+				i++
+				continue
+			}
+		}
+		b.WriteByte(raw[i])
+		l.index = append(l.index, i)
+	}
+	l.logical = b.String()
+}
+
+// commentStart returns the offset of a comment in a logical line, or -1.
+// Like pip, a "#" only starts a comment at the beginning of the line or
+// after whitespace, so URL fragments such as "#egg=name" are kept.
+func commentStart(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseOption parses a global option line such as "-r base.txt" or "--index-url=https://...".
+func (l *Line) parseOption(s string) {
+	name, value := s, ""
+	if i := strings.IndexAny(s, " \t="); i >= 0 {
+		name, value = s[:i], strings.TrimSpace(s[i+1:])
+	} else if !strings.HasPrefix(s, "--") && len(s) > 2 {
+		// Short options may be glued to their value, e.g. "-rbase.txt"
+		name, value = s[:2], s[2:]
+	}
+	if long, ok := shortOptions[name]; ok {
+		name = long
+	}
+	l.Option = name
+	l.Value = value
+
+	switch name {
+	case "--requirement":
+		l.Kind = KindInclude
+	case "--constraint":
+		l.Kind = KindConstraint
+	case "--editable":
+		l.Kind = KindEditable
+		l.URL = value
+		l.Name = eggName(value)
+	default:
+		l.Kind = KindOption
+	}
+}
+
+// parseRequirement parses a requirement starting at offset in body.
+func (l *Line) parseRequirement(body string, offset int) {
+	rest := body[offset:]
+
+	// Split off per-requirement options such as --hash
+	reqEnd := requirementOptionsStart(rest)
+	l.parseRequirementOptions(rest[reqEnd:])
+	rest = rest[:reqEnd]
+
+	name := nameRegex.FindString(rest)
+	if name == "" || isDirectReference(rest) {
+		l.Kind = KindURL
+		l.URL = strings.TrimSpace(rest)
+		if i := strings.Index(l.URL, ";"); i >= 0 && strings.Contains(l.URL[i:], " ") {
+			l.Marker = strings.TrimSpace(l.URL[i+1:])
+			l.URL = strings.TrimSpace(l.URL[:i])
+		}
+		l.Name = eggName(l.URL)
+		return
+	}
+
+	l.Kind = KindRequirement
+	l.Name = name
+	pos := len(name)
+	pos = skipSpace(rest, pos)
+
+	// Extras
+	if pos < len(rest) && rest[pos] == '[' {
+		end := strings.IndexByte(rest[pos:], ']')
+		if end < 0 {
+			end = len(rest) - pos - 1
+		}
+		for _, extra := range strings.Split(rest[pos+1:pos+end], ",") {
+			if extra = strings.TrimSpace(extra); extra != "" {
+				l.Extras = append(l.Extras, extra)
+			}
+		}
+		pos = skipSpace(rest, pos+end+1)
+	}
+
+	// "name @ url" direct reference
+	if pos < len(rest) && rest[pos] == '@' {
+		l.Kind = KindURL
+		urlPart := rest[pos+1:]
+		if i := strings.Index(urlPart, " ;"); i >= 0 {
+			l.Marker = strings.TrimSpace(urlPart[i+2:])
+			urlPart = urlPart[:i]
+		}
+		l.URL = strings.TrimSpace(urlPart)
+		return
+	}
+
+	// Specifier, up to the marker separator
+	specEnd := len(rest)
+	if i := strings.IndexByte(rest[pos:], ';'); i >= 0 {
+		specEnd = pos + i
+		l.Marker = strings.TrimSpace(rest[specEnd+1:])
+	}
+	spec := strings.TrimRight(rest[pos:specEnd], " \t")
+	l.specStart = offset + pos
+	l.specEnd = offset + pos + len(spec)
+	if strings.HasPrefix(spec, "(") && strings.HasSuffix(spec, ")") {
+		l.specParens = true
+		spec = spec[1 : len(spec)-1]
+		l.specStart++
+		l.specEnd--
+	}
+	l.Specifier = strings.TrimSpace(spec)
+	if l.Specifier == "" {
+		// Insert new specifiers directly after the name and extras
+		l.specStart = offset + len(strings.TrimRight(rest[:pos], " \t"))
+		l.specEnd = l.specStart
+	}
+}
+
+// requirementOptions lists the options pip accepts after a requirement
+var requirementOptions = []string{"--hash", "--config-settings", "--global-option"}
+
+// requirementOptionsStart returns the offset of the first per-requirement
+// option in s, or len(s). Only known options separated by whitespace count,
+// so a " -" inside a quoted marker value does not end the requirement.
+func requirementOptionsStart(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i-1] != ' ' && s[i-1] != '\t' {
+			continue
+		}
+		for _, opt := range requirementOptions {
+			rest, ok := strings.CutPrefix(s[i:], opt)
+			if ok && (rest == "" || rest[0] == '=' || rest[0] == ' ' || rest[0] == '\t') {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+// parseRequirementOptions extracts --hash values from per-requirement options.
+func (l *Line) parseRequirementOptions(opts string) {
+	fields := strings.Fields(opts)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case strings.HasPrefix(fields[i], "--hash="):
+			l.Hashes = append(l.Hashes, strings.TrimPrefix(fields[i], "--hash="))
+		case fields[i] == "--hash" && i+1 < len(fields):
+			l.Hashes = append(l.Hashes, fields[i+1])
+			i++
+		}
+	}
+}
+
+// isDirectReference reports whether a requirement is a bare URL or local path
+func isDirectReference(s string) bool {
+	s = strings.TrimSpace(s)
+	if strings.Contains(strings.SplitN(s, ";", 2)[0], "://") && !strings.Contains(s, "@") {
+		return true
+	}
+	for _, prefix := range []string{".", "/", "~", "file:", "git+", "hg+", "svn+", "bzr+"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range []string{".whl", ".tar.gz", ".zip", ".tar.bz2"} {
+		if strings.HasSuffix(strings.Fields(s)[0], suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// eggName extracts the project name from a "#egg=name" URL fragment
+func eggName(url string) string {
+	i := strings.Index(url, "#egg=")
+	if i < 0 {
+		return ""
+	}
+	name := url[i+len("#egg="):]
+	if j := strings.IndexAny(name, "&[ "); j >= 0 {
+		name = name[:j]
+	}
+	return name
+}
+
+// skipSpace returns the first offset at or after pos that is not a space or tab
+func skipSpace(s string, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
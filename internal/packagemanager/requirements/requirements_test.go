@@ -0,0 +1,273 @@
+package requirements
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"simple", "flask==2.0.0\nrequests>=2.25\n"},
+		{"no trailing newline", "flask==2.0.0"},
+		{"crlf", "flask==2.0.0\r\nrequests\r\n"},
+		{"blank lines and comments", "# header\n\nflask==2.0.0  # web\n\n\n"},
+		{"markers", "pywin32==306 ; sys_platform == \"win32\"\n"},
+		{"continuation", "flask==2.0.0 \\\n    --hash=sha256:aaaa \\\n    --hash=sha256:bbbb\n"},
+		{"options", "-i https://example.com/simple\n--extra-index-url https://extra.example.com\n--pre\n"},
+		{"includes", "-r base.txt\n-c constraints.txt\n--requirement=dev.txt\n"},
+		{"editable", "-e git+https://github.com/org/repo.git#egg=repo\n-e .\n"},
+		{"urls", "pkg @ https://example.com/pkg-1.0.tar.gz\n./local/path\nhttps://example.com/x-1.0-py3-none-any.whl\n"},
+		{"tabs and spaces", "\tflask  ==  2.0.0\t\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse(tt.content)
+			if got := f.String(); got != tt.content {
+				t.Errorf("round trip mismatch\nwant: %q\ngot:  %q", tt.content, got)
+			}
+		})
+	}
+}
+
+func TestParseLineKinds(t *testing.T) {
+	content := `# comment
+
+flask==2.0.0
+-r base.txt
+-c constraints.txt
+-e ./src#egg=mypkg
+--index-url https://example.com/simple
+pkg @ https://example.com/pkg.tar.gz
+git+https://github.com/org/repo.git#egg=repo
+`
+	want := []LineKind{KindComment, KindBlank, KindRequirement, KindInclude, KindConstraint, KindEditable, KindOption, KindURL, KindURL}
+
+	f := Parse(content)
+	if len(f.Lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d", len(want), len(f.Lines))
+	}
+	for i, l := range f.Lines {
+		if l.Kind != want[i] {
+			t.Errorf("line %d (%q): expected kind %s, got %s", i+1, l.Raw, want[i], l.Kind)
+		}
+	}
+
+	if f.Lines[3].Value != "base.txt" {
+		t.Errorf("expected include value base.txt, got %q", f.Lines[3].Value)
+	}
+	if f.Lines[5].Name != "mypkg" {
+		t.Errorf("expected editable egg name mypkg, got %q", f.Lines[5].Name)
+	}
+	if f.Lines[6].Option != "--index-url" || f.Lines[6].Value != "https://example.com/simple" {
+		t.Errorf("unexpected option parse: %q = %q", f.Lines[6].Option, f.Lines[6].Value)
+	}
+	if f.Lines[7].Name != "pkg" || f.Lines[7].URL != "https://example.com/pkg.tar.gz" {
+		t.Errorf("unexpected direct reference parse: name %q url %q", f.Lines[7].Name, f.Lines[7].URL)
+	}
+}
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		line      string
+		name      string
+		extras    []string
+		specifier string
+		marker    string
+		comment   string
+		hashes    []string
+	}{
+		{line: "flask", name: "flask"},
+		{line: "flask==2.0.0", name: "flask", specifier: "==2.0.0"},
+		{line: "requests[security,socks]>=2.25,<3", name: "requests", extras: []string{"security", "socks"}, specifier: ">=2.25,<3"},
+		{line: "name (>=1.0)", name: "name", specifier: ">=1.0"},
+		{line: `pywin32==306; sys_platform == "win32"`, name: "pywin32", specifier: "==306", marker: `sys_platform == "win32"`},
+		{line: "flask==2.0.0  # pinned for py3.8", name: "flask", specifier: "==2.0.0", comment: "# pinned for py3.8"},
+		{line: "flask==2.0.0 --hash=sha256:aaaa --hash sha256:bbbb", name: "flask", specifier: "==2.0.0", hashes: []string{"sha256:aaaa", "sha256:bbbb"}},
+		{line: "flask==2.0.0 \\\n    --hash=sha256:aaaa", name: "flask", specifier: "==2.0.0", hashes: []string{"sha256:aaaa"}},
+		{line: `foo; extra == "a -b"`, name: "foo", marker: `extra == "a -b"`},
+		{line: `foo==1.0 ; os_name == "x -y" --hash=sha256:aaaa`, name: "foo", specifier: "==1.0", marker: `os_name == "x -y"`, hashes: []string{"sha256:aaaa"}},
+		{line: "foo==1.0 --config-settings key=value", name: "foo", specifier: "==1.0"},
+		{line: "foo==1.0\\", name: "foo", specifier: "==1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			f := Parse(tt.line + "\n")
+			if len(f.Lines) != 1 {
+				t.Fatalf("expected 1 logical line, got %d", len(f.Lines))
+			}
+			l := f.Lines[0]
+			if l.Kind != KindRequirement {
+				t.Fatalf("expected requirement, got %s", l.Kind)
+			}
+			if l.Name != tt.name {
+				t.Errorf("name: expected %q, got %q", tt.name, l.Name)
+			}
+			if !reflect.DeepEqual(l.Extras, tt.extras) {
+				t.Errorf("extras: expected %v, got %v", tt.extras, l.Extras)
+			}
+			if l.Specifier != tt.specifier {
+				t.Errorf("specifier: expected %q, got %q", tt.specifier, l.Specifier)
+			}
+			if l.Marker != tt.marker {
+				t.Errorf("marker: expected %q, got %q", tt.marker, l.Marker)
+			}
+			if l.Comment != tt.comment {
+				t.Errorf("comment: expected %q, got %q", tt.comment, l.Comment)
+			}
+			if !reflect.DeepEqual(l.Hashes, tt.hashes) {
+				t.Errorf("hashes: expected %v, got %v", tt.hashes, l.Hashes)
+			}
+		})
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		version  string
+		expected string
+		changed  bool
+	}{
+		{"pin", "flask==2.0.0\n", "3.0.0", "flask==3.0.0\n", true},
+		{"unchanged", "flask==2.0.0\n", "2.0.0", "flask==2.0.0\n", false},
+		{"wildcard", "rdflib==7.1.*\n", "7.1.3", "rdflib==7.1.3\n", true},
+		{"no specifier", "flask\n", "3.0.0", "flask==3.0.0\n", true},
+		{"extras without specifier", "requests[socks]  # http\n", "2.31.0", "requests[socks]==2.31.0  # http\n", true},
+		{"spaces around operator", "flask == 2.0.0\n", "3.0.0", "flask == 3.0.0\n", true},
+		{"marker", "pywin32==305 ; sys_platform == \"win32\"\n", "306", "pywin32==306 ; sys_platform == \"win32\"\n", true},
+		{"marker without specifier", "pywin32; sys_platform == \"win32\"\n", "306", "pywin32==306; sys_platform == \"win32\"\n", true},
+		{"comment", "flask==2.0.0  # keep me\n", "3.0.0", "flask==3.0.0  # keep me\n", true},
+		{"parenthesized", "flask (==2.0.0)\n", "3.0.0", "flask (==3.0.0)\n", true},
+		{"arbitrary equality", "flask===2.0.0\n", "3.0.0", "flask===3.0.0\n", true},
+		{"range", "flask>=2.0,<3\n", "3.0.0", "flask==3.0.0\n", true},
+		{"crlf", "flask==2.0.0\r\n", "3.0.0", "flask==3.0.0\r\n", true},
+		{
+			"hashes and continuation",
+			"flask==2.0.0 \\\n    --hash=sha256:aaaa \\\n    --hash=sha256:bbbb\n",
+			"3.0.0",
+			"flask==3.0.0 \\\n    --hash=sha256:aaaa \\\n    --hash=sha256:bbbb\n",
+			true,
+		},
+		{
+			"continuation inside specifier",
+			"flask==\\\n2.0.0\n",
+			"3.0.0",
+			"flask==\\\n3.0.0\n",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse(tt.input)
+			reqs := f.Requirements()
+			if len(reqs) != 1 {
+				t.Fatalf("expected 1 requirement, got %d", len(reqs))
+			}
+			if changed := reqs[0].SetVersion(tt.version); changed != tt.changed {
+				t.Errorf("expected changed=%v, got %v", tt.changed, changed)
+			}
+			if got := f.String(); got != tt.expected {
+				t.Errorf("unexpected output\nwant: %q\ngot:  %q", tt.expected, got)
+			}
+			if v, ok := reqs[0].Pinned(); !ok || v != tt.version {
+				t.Errorf("expected line to be pinned to %s after edit, got %q (pinned=%v)", tt.version, v, ok)
+			}
+		})
+	}
+}
+
+func TestSetVersionIgnoresNonRequirements(t *testing.T) {
+	content := "-e git+https://github.com/org/repo.git#egg=repo\npkg @ https://example.com/pkg.tar.gz\n# flask==1.0\n"
+	f := Parse(content)
+	for _, l := range f.Lines {
+		if l.SetVersion("9.9.9") {
+			t.Errorf("line %q should not be editable", l.Raw)
+		}
+	}
+	if got := f.String(); got != content {
+		t.Errorf("content changed\nwant: %q\ngot:  %q", content, got)
+	}
+}
+
+func TestCommentContinuation(t *testing.T) {
+	// pip ends a line at a comment, so a backslash inside one never joins
+	// the next line
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"comment line", "# comment \\\nflask==2.0.0\n"},
+		{"trailing comment", "foo==1.0  # note \\\nflask==2.0.0\n"},
+		{"tab before comment", "foo==1.0\t# note \\\nflask==2.0.0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse(tt.content)
+			if len(f.Lines) != 2 {
+				t.Fatalf("expected 2 logical lines, got %d", len(f.Lines))
+			}
+			if f.Lines[1].Kind != KindRequirement || f.Lines[1].Number != 2 {
+				t.Errorf("expected requirement on line 2, got %s on line %d", f.Lines[1].Kind, f.Lines[1].Number)
+			}
+			if got := f.String(); got != tt.content {
+				t.Errorf("round trip mismatch\nwant: %q\ngot:  %q", tt.content, got)
+			}
+		})
+	}
+}
+
+func TestDanglingContinuation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"pinned", "flask==2.0.0\\", "flask==3.0.0\\"},
+		{"pinned with newline", "flask==2.0.0 \\\n", "flask==3.0.0 \\\n"},
+		{"no specifier", "flask\\", "flask==3.0.0\\"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse(tt.input)
+			if got := f.String(); got != tt.input {
+				t.Errorf("round trip mismatch\nwant: %q\ngot:  %q", tt.input, got)
+			}
+			reqs := f.Requirements()
+			if len(reqs) != 1 {
+				t.Fatalf("expected 1 requirement, got %d", len(reqs))
+			}
+			if strings.Contains(reqs[0].Specifier, "\\") {
+				t.Errorf("dangling backslash leaked into specifier %q", reqs[0].Specifier)
+			}
+			reqs[0].SetVersion("3.0.0")
+			if got := f.String(); got != tt.expected {
+				t.Errorf("unexpected output\nwant: %q\ngot:  %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"Flask":             "flask",
+		"zope.interface":    "zope-interface",
+		"typing_extensions": "typing-extensions",
+		"Foo__Bar-.baz":     "foo-bar-baz",
+	}
+	for input, want := range tests {
+		if got := NormalizeName(input); got != want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
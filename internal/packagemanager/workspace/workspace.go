@@ -0,0 +1,283 @@
+// Package workspace discovers npm, yarn and pnpm workspaces.
+//
+// A workspace root declares its member packages either in the "workspaces"
+// field of package.json (npm and yarn) or in pnpm-workspace.yaml. Members
+// are directories with a package.json matching one of the glob patterns.
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rvben/ru/internal/packagemanager/packagejson"
+)
+
+// Workspace is a workspace root together with its member packages.
+type Workspace struct {
+	// Root is the directory of the workspace root
+	Root string
+	// Patterns are the member globs; patterns starting with "!" exclude
+	Patterns []string
+	// Members are the member packages, sorted by directory
+	Members []*Member
+}
+
+// Member is a package of a workspace.
+type Member struct {
+	Name    string
+	Version string
+	// Dir is the directory of the package
+	Dir string
+	// Path is the path of its package.json
+	Path string
+}
+
+// Discover returns the workspace rooted at dir, or nil if dir is not a
+// workspace root.
+func Discover(dir string) (*Workspace, error) {
+	patterns, err := readPatterns(dir)
+	if err != nil || len(patterns) == 0 {
+		return nil, err
+	}
+
+	w := &Workspace{Root: dir, Patterns: patterns}
+	if err := w.findMembers(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Member returns the member package named name, or nil. It may be called
+// on a nil workspace.
+func (w *Workspace) Member(name string) *Member {
+	if w == nil {
+		return nil
+	}
+	for _, m := range w.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Files returns the package.json of the root followed by those of the members.
+func (w *Workspace) Files() []string {
+	files := []string{filepath.Join(w.Root, "package.json")}
+	for _, m := range w.Members {
+		files = append(files, m.Path)
+	}
+	return files
+}
+
+// readPatterns returns the member patterns of pnpm-workspace.yaml, or else
+// of the "workspaces" field of package.json
+func readPatterns(dir string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "pnpm-workspace.yaml"))
+	if err == nil {
+		return parsePnpmWorkspace(content), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading pnpm-workspace.yaml: %w", err)
+	}
+
+	doc, err := packagejson.ParseFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error parsing %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+
+	// npm and yarn use an array; yarn classic also allows {"packages": [...]}
+	workspaces := doc.Lookup("workspaces")
+	if workspaces != nil && workspaces.Kind == packagejson.KindObject {
+		workspaces = workspaces.Get("packages")
+	}
+	if workspaces == nil || workspaces.Kind != packagejson.KindArray {
+		return nil, nil
+	}
+	var patterns []string
+	for _, e := range workspaces.Elements {
+		if e.Kind == packagejson.KindString {
+			patterns = append(patterns, e.Str)
+		}
+	}
+	return patterns, nil
+}
+
+// parsePnpmWorkspace extracts the "packages" list of pnpm-workspace.yaml. Only
+// that key is needed, so instead of a full YAML parser this understands a
+// block sequence or a flow sequence of (optionally quoted) strings.
+func parsePnpmWorkspace(content []byte) []string {
+	var patterns []string
+	inPackages := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := stripYAMLComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		// A key at the start of the line ends the previous block
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			inPackages = false
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok || strings.TrimSpace(key) != "packages" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if strings.HasPrefix(value, "[") {
+				for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+					if item = unquoteYAML(item); item != "" {
+						patterns = append(patterns, item)
+					}
+				}
+				continue
+			}
+			inPackages = true
+			continue
+		}
+
+		if inPackages && strings.HasPrefix(trimmed, "-") {
+			if item := unquoteYAML(strings.TrimPrefix(trimmed, "-")); item != "" {
+				patterns = append(patterns, item)
+			}
+		}
+	}
+	return patterns
+}
+
+// stripYAMLComment removes a trailing comment outside of quotes
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteYAML trims a scalar and removes its quotes
+func unquoteYAML(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// findMembers walks the workspace and collects the packages matching the patterns
+func (w *Workspace) findMembers() error {
+	var include, exclude []string
+	for _, p := range w.Patterns {
+		if strings.HasPrefix(p, "!") {
+			exclude = append(exclude, cleanPattern(p[1:]))
+		} else {
+			include = append(include, cleanPattern(p))
+		}
+	}
+
+	err := filepath.Walk(w.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != w.Root && (info.Name() == "node_modules" || strings.HasPrefix(info.Name(), ".")) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(w.Root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !matchAny(include, rel) || matchAny(exclude, rel) {
+			return nil
+		}
+
+		manifest := filepath.Join(path, "package.json")
+		doc, err := packagejson.ParseFile(manifest)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("error parsing %s: %w", manifest, err)
+		}
+		m := &Member{Dir: path, Path: manifest}
+		if v := doc.Lookup("name"); v != nil {
+			m.Name = v.Str
+		}
+		if v := doc.Lookup("version"); v != nil {
+			m.Version = v.Str
+		}
+		w.Members = append(w.Members, m)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error finding workspace packages in %s: %w", w.Root, err)
+	}
+
+	sort.Slice(w.Members, func(i, j int) bool { return w.Members[i].Dir < w.Members[j].Dir })
+	return nil
+}
+
+// cleanPattern normalizes a member pattern: "./packages/*/" -> "packages/*"
+func cleanPattern(p string) string {
+	p = strings.TrimSpace(p)
+	p = strings.TrimPrefix(p, "./")
+	p = strings.TrimSuffix(p, "/package.json")
+	return strings.TrimSuffix(p, "/")
+}
+
+func matchAny(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob where "**"
+// matches any number of path segments and other segments use filepath.Match
+func matchGlob(pattern, path string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
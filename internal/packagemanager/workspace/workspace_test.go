@@ -0,0 +1,132 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files below dir from a map of relative paths to contents
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func memberNames(w *Workspace) string {
+	var names []string
+	for _, m := range w.Members {
+		names = append(names, m.Name+"@"+m.Version)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name: "npm workspaces",
+			files: map[string]string{
+				"package.json":                           `{"name": "root", "workspaces": ["packages/*", "tools/cli"]}`,
+				"packages/a/package.json":                `{"name": "@repo/a", "version": "1.2.0"}`,
+				"packages/b/package.json":                `{"name": "@repo/b", "version": "0.3.1"}`,
+				"packages/no-manifest/README":            ``,
+				"packages/a/node_modules/x/package.json": `{"name": "x", "version": "9.9.9"}`,
+				"tools/cli/package.json":                 `{"name": "cli", "version": "2.0.0"}`,
+				"tools/other/package.json":               `{"name": "other", "version": "1.0.0"}`,
+			},
+			expected: "@repo/a@1.2.0,@repo/b@0.3.1,cli@2.0.0",
+		},
+		{
+			name: "yarn classic object form",
+			files: map[string]string{
+				"package.json":              `{"private": true, "workspaces": {"packages": ["./libs/**"], "nohoist": ["**/x"]}}`,
+				"libs/a/package.json":       `{"name": "a", "version": "1.0.0"}`,
+				"libs/group/b/package.json": `{"name": "b", "version": "1.0.0"}`,
+			},
+			expected: "a@1.0.0,b@1.0.0",
+		},
+		{
+			name: "pnpm workspace with exclusion",
+			files: map[string]string{
+				"package.json":                          `{"name": "root"}`,
+				"pnpm-workspace.yaml":                   "# members\npackages:\n  - 'apps/*'\n  - \"packages/**\" # everything\n  - '!**/test/**'\ncatalog:\n  react: ^18.2.0\n",
+				"apps/web/package.json":                 `{"name": "web", "version": "0.1.0"}`,
+				"packages/ui/package.json":              `{"name": "ui", "version": "1.0.0"}`,
+				"packages/ui/test/fixture/package.json": `{"name": "fixture", "version": "0.0.0"}`,
+			},
+			expected: "web@0.1.0,ui@1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+			w, err := Discover(dir)
+			if err != nil {
+				t.Fatalf("Discover failed: %v", err)
+			}
+			if w == nil {
+				t.Fatal("expected a workspace")
+			}
+			if got := memberNames(w); got != tt.expected {
+				t.Errorf("expected members %s, got %s", tt.expected, got)
+			}
+			if files := w.Files(); files[0] != filepath.Join(dir, "package.json") || len(files) != len(w.Members)+1 {
+				t.Errorf("unexpected files: %v", files)
+			}
+		})
+	}
+}
+
+func TestDiscoverNotAWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"package.json": `{"name": "app", "dependencies": {}}`})
+	w, err := Discover(dir)
+	if err != nil || w != nil {
+		t.Errorf("expected no workspace, got %+v, %v", w, err)
+	}
+	if w.Member("app") != nil {
+		t.Error("expected nil workspace to have no members")
+	}
+}
+
+func TestParsePnpmWorkspaceFlow(t *testing.T) {
+	got := parsePnpmWorkspace([]byte("packages: ['a/*', \"b\"]\n"))
+	if strings.Join(got, ",") != "a/*,b" {
+		t.Errorf("unexpected patterns: %v", got)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"packages/*", "packages/a", true},
+		{"packages/*", "packages/a/b", false},
+		{"packages/**", "packages/a/b", true},
+		{"**/test/**", "packages/ui/test/fixture", true},
+		{"**/test/**", "packages/ui", false},
+		{"tools/cli", "tools/cli", true},
+		{"pkg-?", "pkg-1", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.match {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package pep440
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Specifier is a single version clause such as ">=1.4", "~=2.2.1",
+// "!=1.5.*" or "===1.0-custom".
+type Specifier struct {
+	Operator string
+	// Version is the version as written, without the operator
+	Version string
+	// version is the parsed version; nil for "===" and wildcards
+	version *Version
+	// prefix is the parsed version of a "==X.*" or "!=X.*" wildcard
+	prefix *Version
+}
+
+var (
+	// specifierRegex splits a clause into operator and version
+	specifierRegex = regexp.MustCompile(`^\s*(~=|===|==|!=|<=|>=|<|>)\s*(\S+)\s*$`)
+	// wildcardRegex is the version of a prefix match: a release, optionally
+	// with an epoch, followed by ".*"
+	wildcardRegex = regexp.MustCompile(`(?i)^v?(?:\d+!)?\d+(?:\.\d+)*\.\*$`)
+)
+
+// ParseSpecifier parses a single specifier clause.
+func ParseSpecifier(s string) (*Specifier, error) {
+	m := specifierRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid specifier %q", s)
+	}
+	spec := &Specifier{Operator: m[1], Version: m[2]}
+
+	switch spec.Operator {
+	case "===":
+		// Arbitrary equality compares strings and accepts anything
+		return spec, nil
+	case "==", "!=":
+		if strings.HasSuffix(spec.Version, ".*") {
+			if !wildcardRegex.MatchString(spec.Version) {
+				return nil, fmt.Errorf("invalid specifier %q: a prefix match cannot have pre, post, dev or local segments", s)
+			}
+			prefix, err := Parse(strings.TrimSuffix(spec.Version, ".*"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid specifier %q: %w", s, err)
+			}
+			spec.prefix = prefix
+			return spec, nil
+		}
+	}
+
+	v, err := Parse(spec.Version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid specifier %q: %w", s, err)
+	}
+	if len(v.Local) > 0 && spec.Operator != "==" && spec.Operator != "!=" {
+		return nil, fmt.Errorf("invalid specifier %q: local versions are only allowed with == and !=", s)
+	}
+	if spec.Operator == "~=" && len(v.Release) < 2 {
+		return nil, fmt.Errorf("invalid specifier %q: ~= needs at least two release segments", s)
+	}
+	spec.version = v
+	return spec, nil
+}
+
+// String returns the specifier in its written form without spaces.
+func (s *Specifier) String() string {
+	return s.Operator + s.Version
+}
+
+// Prereleases reports whether the specifier explicitly names a pre-release,
+// which makes it accept pre-releases, e.g. ">=1.0b1".
+func (s *Specifier) Prereleases() bool {
+	switch s.Operator {
+	case "==", ">=", "<=", "~=", "===", "<", ">":
+	default:
+		return false
+	}
+	if s.version != nil {
+		return s.version.IsPrerelease()
+	}
+	if s.prefix != nil {
+		return s.prefix.IsPrerelease()
+	}
+	v, err := Parse(s.Version)
+	return err == nil && v.IsPrerelease()
+}
+
+// Matches reports whether v satisfies the specifier, regardless of whether
+// it is a pre-release.
+func (s *Specifier) Matches(v *Version) bool {
+	switch s.Operator {
+	case "===":
+		return strings.EqualFold(v.String(), s.Version)
+	case "==":
+		if s.prefix != nil {
+			return matchesPrefix(v, s.prefix)
+		}
+		if len(s.version.Local) == 0 {
+			v = v.publicVersion()
+		}
+		return v.Compare(s.version) == 0
+	case "!=":
+		if s.prefix != nil {
+			return !matchesPrefix(v, s.prefix)
+		}
+		if len(s.version.Local) == 0 {
+			v = v.publicVersion()
+		}
+		return v.Compare(s.version) != 0
+	case "<=":
+		return v.publicVersion().Compare(s.version) <= 0
+	case ">=":
+		return v.publicVersion().Compare(s.version) >= 0
+	case "<":
+		// <V excludes pre-releases of V itself unless V is one
+		if v.Compare(s.version) >= 0 {
+			return false
+		}
+		return s.version.IsPrerelease() || !v.IsPrerelease() || v.BaseVersion().Compare(s.version.BaseVersion()) != 0
+	case ">":
+		// >V excludes post-releases and local versions of V itself
+		if v.Compare(s.version) <= 0 {
+			return false
+		}
+		sameBase := v.BaseVersion().Compare(s.version.BaseVersion()) == 0
+		if !s.version.IsPostrelease() && v.IsPostrelease() && sameBase {
+			return false
+		}
+		return len(v.Local) == 0 || !sameBase
+	case "~=":
+		// ~=X.Y.Z means >=X.Y.Z together with ==X.Y.*
+		prefix := &Version{Epoch: s.version.Epoch, Release: s.version.Release[:len(s.version.Release)-1]}
+		return v.publicVersion().Compare(s.version) >= 0 && matchesPrefix(v, prefix)
+	}
+	return false
+}
+
+// matchesPrefix reports whether the release of v starts with the release of
+// prefix, padding v with zeros, so "1.4" matches "==1.4.0.*"
+func matchesPrefix(v, prefix *Version) bool {
+	if v.Epoch != prefix.Epoch {
+		return false
+	}
+	for i, n := range prefix.Release {
+		var x uint64
+		if i < len(v.Release) {
+			x = v.Release[i]
+		}
+		if x != n {
+			return false
+		}
+	}
+	return true
+}
+
+// SpecifierSet is a comma-separated list of specifiers that must all be
+// satisfied, such as ">=1.4,!=1.5.0,<2". The empty set accepts every version.
+type SpecifierSet struct {
+	Specifiers []*Specifier
+}
+
+// ParseSpecifierSet parses a comma-separated list of specifiers.
+func ParseSpecifierSet(s string) (*SpecifierSet, error) {
+	set := &SpecifierSet{}
+	if strings.TrimSpace(s) == "" {
+		return set, nil
+	}
+	for _, clause := range strings.Split(s, ",") {
+		spec, err := ParseSpecifier(clause)
+		if err != nil {
+			return nil, err
+		}
+		set.Specifiers = append(set.Specifiers, spec)
+	}
+	return set, nil
+}
+
+// MustParseSpecifierSet is like ParseSpecifierSet but panics on invalid input.
+func MustParseSpecifierSet(s string) *SpecifierSet {
+	set, err := ParseSpecifierSet(s)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// String returns the specifiers joined by commas.
+func (s *SpecifierSet) String() string {
+	parts := make([]string, len(s.Specifiers))
+	for i, spec := range s.Specifiers {
+		parts[i] = spec.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Prereleases reports whether any specifier explicitly names a pre-release.
+func (s *SpecifierSet) Prereleases() bool {
+	for _, spec := range s.Specifiers {
+		if spec.Prereleases() {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether v satisfies every specifier, regardless of
+// whether it is a pre-release.
+func (s *SpecifierSet) Matches(v *Version) bool {
+	for _, spec := range s.Specifiers {
+		if !spec.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether the set accepts v. Pre-releases are only
+// accepted if a specifier explicitly names one, as installers do.
+func (s *SpecifierSet) Contains(v *Version) bool {
+	if v.IsPrerelease() && !s.Prereleases() {
+		return false
+	}
+	return s.Matches(v)
+}
+
+// Filter returns the versions the set accepts, in their original order.
+// Like pip, it falls back to pre-releases when the empty set is given only
+// pre-releases. Versions that cannot be parsed are left out.
+func (s *SpecifierSet) Filter(versions []string) []string {
+	var accepted, prereleases []string
+	allowPre := s.Prereleases()
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil || !s.Matches(v) {
+			continue
+		}
+		if v.IsPrerelease() && !allowPre {
+			prereleases = append(prereleases, raw)
+			continue
+		}
+		accepted = append(accepted, raw)
+	}
+	if len(accepted) == 0 && len(s.Specifiers) == 0 {
+		return prereleases
+	}
+	return accepted
+}
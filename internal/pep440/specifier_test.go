@@ -0,0 +1,272 @@
+package pep440
+
+import (
+	"strings"
+	"testing"
+)
+
+// specifierVectors are the specifier matching vectors of the packaging
+// project; pre-releases are allowed so that only matching is tested
+var specifierVectors = []struct {
+	version  string
+	spec     string
+	expected bool
+}{
+	// Equality
+	{"2.0", "==2", true},
+	{"2.0", "==2.0", true},
+	{"2.0", "==2.0.0", true},
+	{"2.0+deadbeef", "==2", true},
+	{"2.0+deadbeef", "==2.0", true},
+	{"2.0+deadbeef", "==2.0.0", true},
+	{"2.0+deadbeef", "==2+deadbeef", true},
+	{"2.0+deadbeef", "==2.0+deadbeef", true},
+	{"2.0+deadbeef", "==2.0.0+deadbeef", true},
+	{"2.0+deadbeef.0", "==2.0.0+deadbeef.00", true},
+	{"2.1", "==2", false},
+	{"2.1", "==2.0", false},
+	{"2.1", "==2.0.0", false},
+	{"2.0", "==2.0+deadbeef", false},
+
+	// Equality with a prefix
+	{"2.dev1", "==2.*", true},
+	{"2a1", "==2.*", true},
+	{"2a1.post1", "==2.*", true},
+	{"2b1", "==2.*", true},
+	{"2b1.dev1", "==2.*", true},
+	{"2c1", "==2.*", true},
+	{"2c1.post1.dev1", "==2.*", true},
+	{"2c1.post1.dev1", "==2.0.*", true},
+	{"2rc1", "==2.*", true},
+	{"2rc1", "==2.0.*", true},
+	{"2", "==2.*", true},
+	{"2", "==2.0.*", true},
+	{"2", "==0!2.*", true},
+	{"0!2", "==2.*", true},
+	{"2.0", "==2.*", true},
+	{"2.0.0", "==2.*", true},
+	{"2.1+local.version", "==2.1.*", true},
+	{"2.0", "==3.*", false},
+	{"2.1", "==2.0.*", false},
+
+	// Inequality
+	{"2.1", "!=2", true},
+	{"2.1", "!=2.0", true},
+	{"2.0.1", "!=2", true},
+	{"2.0.1", "!=2.0", true},
+	{"2.0.1", "!=2.0.0", true},
+	{"2.0", "!=2.0+deadbeef", true},
+	{"2.0", "!=2", false},
+	{"2.0", "!=2.0", false},
+	{"2.0", "!=2.0.0", false},
+	{"2.0+deadbeef", "!=2", false},
+	{"2.0+deadbeef", "!=2.0", false},
+	{"2.0+deadbeef", "!=2.0.0", false},
+	{"2.0+deadbeef", "!=2+deadbeef", false},
+	{"2.0+deadbeef", "!=2.0+deadbeef", false},
+	{"2.0+deadbeef", "!=2.0.0+deadbeef", false},
+	{"2.0+deadbeef.0", "!=2.0.0+deadbeef.00", false},
+
+	// Inequality with a prefix
+	{"2.0", "!=3.*", true},
+	{"2.1", "!=2.0.*", true},
+	{"2.dev1", "!=2.*", false},
+	{"2a1", "!=2.*", false},
+	{"2a1.post1", "!=2.*", false},
+	{"2b1", "!=2.*", false},
+	{"2b1.dev1", "!=2.*", false},
+	{"2c1", "!=2.*", false},
+	{"2c1.post1.dev1", "!=2.*", false},
+	{"2c1.post1.dev1", "!=2.0.*", false},
+	{"2rc1", "!=2.*", false},
+	{"2rc1", "!=2.0.*", false},
+	{"2", "!=2.*", false},
+	{"2", "!=2.0.*", false},
+	{"2.0", "!=2.*", false},
+	{"2.0.0", "!=2.*", false},
+
+	// Greater than or equal
+	{"2.0", ">=2", true},
+	{"2.0", ">=2.0", true},
+	{"2.0", ">=2.0.0", true},
+	{"2.0.post1", ">=2", true},
+	{"2.0.post1.dev1", ">=2", true},
+	{"3", ">=2", true},
+	{"2.0.dev1", ">=2", false},
+	{"2.0a1", ">=2", false},
+	{"2.0a1.dev1", ">=2", false},
+	{"2.0b1", ">=2", false},
+	{"2.0b1.post1", ">=2", false},
+	{"2.0c1", ">=2", false},
+	{"2.0c1.post1.dev1", ">=2", false},
+	{"2.0rc1", ">=2", false},
+	{"1", ">=2", false},
+
+	// Less than or equal
+	{"2.0", "<=2", true},
+	{"2.0", "<=2.0", true},
+	{"2.0", "<=2.0.0", true},
+	{"2.0.dev1", "<=2", true},
+	{"2.0a1", "<=2", true},
+	{"2.0a1.dev1", "<=2", true},
+	{"2.0b1", "<=2", true},
+	{"2.0b1.post1", "<=2", true},
+	{"2.0c1", "<=2", true},
+	{"2.0c1.post1.dev1", "<=2", true},
+	{"2.0rc1", "<=2", true},
+	{"1", "<=2", true},
+	{"2.0.post1", "<=2", false},
+	{"2.0.post1.dev1", "<=2", false},
+	{"3", "<=2", false},
+
+	// Greater than
+	{"3", ">2", true},
+	{"2.1", ">2.0", true},
+	{"2.0.1", ">2", true},
+	{"2.1.post1", ">2", true},
+	{"2.1+local.version", ">2", true},
+	{"1", ">2", false},
+	{"2.0.dev1", ">2", false},
+	{"2.0a1", ">2", false},
+	{"2.0a1.post1", ">2", false},
+	{"2.0b1", ">2", false},
+	{"2.0b1.dev1", ">2", false},
+	{"2.0c1", ">2", false},
+	{"2.0c1.post1.dev1", ">2", false},
+	{"2.0rc1", ">2", false},
+	{"2.0", ">2", false},
+	{"2.0.post1", ">2", false},
+	{"2.0.post1.dev1", ">2", false},
+	{"2.0+local.version", ">2", false},
+
+	// Less than
+	{"1", "<2", true},
+	{"2.0", "<2.1", true},
+	{"2.0.dev0", "<2.1", true},
+	{"2.0.dev1", "<2", false},
+	{"2.0a1", "<2", false},
+	{"2.0a1.post1", "<2", false},
+	{"2.0b1", "<2", false},
+	{"2.0b2.dev1", "<2", false},
+	{"2.0c1", "<2", false},
+	{"2.0c1.post1.dev1", "<2", false},
+	{"2.0rc1", "<2", false},
+	{"2.0", "<2", false},
+	{"2.post1", "<2", false},
+	{"2.post1.dev1", "<2", false},
+	{"3", "<2", false},
+
+	// Compatible release
+	{"1", "~=1.0", true},
+	{"1.0.1", "~=1.0", true},
+	{"1.1", "~=1.0", true},
+	{"1.9999999", "~=1.0", true},
+	{"1.1", "~=1.0a1", true},
+	{"2022.01.01", "~=2022.01.01", true},
+	{"2.0", "~=1.0", false},
+	{"1.1.0", "~=1.0.0", false},
+	{"1.1.post1", "~=1.0.0", false},
+	{"1.4.5", "~=1.4.2", true},
+	{"1.5.0", "~=1.4.2", false},
+
+	// Epochs
+	{"2!1.0", "~=2!1.0", true},
+	{"2!1.0", "==2!1.*", true},
+	{"2!1.0", "==2!1.0", true},
+	{"2!1.0", "!=1.0", true},
+	{"1.0", "!=2!1.0", true},
+	{"1.0", "<=2!0.1", true},
+	{"2!1.0", ">=2.0", true},
+	{"1.0", "<2!0.1", true},
+	{"2!1.0", ">2.0", true},
+	{"1.0", "~=2!1.0", false},
+	{"2!1.0", "~=1.0", false},
+	{"2!1.0", "==1.0", false},
+	{"1.0", "==2!1.0", false},
+	{"2!1.0", "==1.*", false},
+	{"1.0", "==2!1.*", false},
+	{"2!1.0", "!=2!1.0", false},
+
+	// Normalization
+	{"2.0.5", ">2.0dev", true},
+
+	// Arbitrary equality
+	{"1.0", "===1.0", true},
+	{"1.0.0", "===1.0", false},
+}
+
+func TestSpecifierMatches(t *testing.T) {
+	for _, tt := range specifierVectors {
+		spec, err := ParseSpecifier(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpecifier(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got := spec.Matches(MustParse(tt.version)); got != tt.expected {
+			t.Errorf("%s matches %s = %v, want %v", tt.version, tt.spec, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSpecifierInvalid(t *testing.T) {
+	for _, input := range []string{
+		"", "1.0", "=1.0", "~1.0", "^1.0",
+		"~=1", "~=1.0+5", "~=1.0.*",
+		">=1.0+deadbeef", "<1.0+deadbeef", ">=1.0.*", "<1.*",
+		"==1.0a1.*", "==1.0.*+5", "!=1.0.post1.*",
+		"==french toast", "===foo bar",
+	} {
+		if _, err := ParseSpecifier(input); err == nil {
+			t.Errorf("expected ParseSpecifier(%q) to fail", input)
+		}
+	}
+}
+
+func TestSpecifierSetContains(t *testing.T) {
+	tests := []struct {
+		set      string
+		version  string
+		expected bool
+	}{
+		{"", "1.0", true},
+		{"", "1.0a1", false},
+		{">=1.0,<2.0", "1.5", true},
+		{">=1.0,<2.0", "2.0", false},
+		{">=1.0,!=1.5.*", "1.5.3", false},
+		{">=1.0, !=1.5.*", "1.6", true},
+		{">=1.0", "2.0a1", false},
+		{">=1.0b1", "2.0a1", true},
+		{"~=1.0a1,<3", "1.5rc1", true},
+		{"==1.0.*", "1.0.dev1", false},
+		{"==1.0.dev1", "1.0.dev1", true},
+		{"<2.0", "2.0a1", false},
+		{"<2.0a2", "2.0a1", true},
+	}
+	for _, tt := range tests {
+		set := MustParseSpecifierSet(tt.set)
+		if got := set.Contains(MustParse(tt.version)); got != tt.expected {
+			t.Errorf("%q contains %s = %v, want %v", tt.set, tt.version, got, tt.expected)
+		}
+	}
+}
+
+func TestSpecifierSetFilter(t *testing.T) {
+	tests := []struct {
+		set      string
+		versions []string
+		expected []string
+	}{
+		{"", []string{"1.0", "2.0a1"}, []string{"1.0"}},
+		{">=1.0.dev1", []string{"1.0", "2.0a1"}, []string{"1.0", "2.0a1"}},
+		{"", []string{"1.0a1"}, []string{"1.0a1"}},
+		{"", []string{"2.0dog", "1.0"}, []string{"1.0"}},
+		{">=1.0", []string{"2.0a1"}, nil},
+		{">=1.2,<2", []string{"1.0", "1.2", "1.9.post1", "2.0"}, []string{"1.2", "1.9.post1"}},
+	}
+	for _, tt := range tests {
+		got := MustParseSpecifierSet(tt.set).Filter(tt.versions)
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%q filter %v = %v, want %v", tt.set, tt.versions, got, tt.expected)
+		}
+	}
+}
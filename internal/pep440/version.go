@@ -0,0 +1,332 @@
+// Package pep440 implements Python version numbers and version specifiers
+// as defined by PEP 440, following the behavior of the packaging project,
+// which pip and other installers use.
+package pep440
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed PEP 440 version such as "1!2.0.1rc1.post2.dev3+local.7".
+type Version struct {
+	Epoch   uint64
+	Release []uint64
+	// Pre is the pre-release segment, nil for none
+	Pre *PreRelease
+	// Post is the post-release number, nil for none
+	Post *uint64
+	// Dev is the development release number, nil for none
+	Dev *uint64
+	// Local is the local version label split into its segments, lowercased
+	Local []string
+}
+
+// PreRelease is a pre-release segment such as "rc1".
+type PreRelease struct {
+	// Label is "a", "b" or "rc"
+	Label  string
+	Number uint64
+}
+
+// versionRegex is the version pattern of PEP 440 appendix B, which accepts
+// every spelling the normalization rules allow
+var versionRegex = regexp.MustCompile(`(?i)^\s*v?` +
+	`(?:(\d+)!)?` + // epoch
+	`(\d+(?:\.\d+)*)` + // release
+	`(?:[-_.]?(alpha|a|beta|b|preview|pre|c|rc)[-_.]?(\d+)?)?` + // pre-release
+	`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d+)?)?` + // post-release
+	`(?:[-_.]?(dev)[-_.]?(\d+)?)?` + // development release
+	`(?:\+([a-z0-9]+(?:[-_.][a-z0-9]+)*))?` + // local version
+	`\s*$`)
+
+// Parse parses a version, accepting every form PEP 440 normalizes.
+func Parse(s string) (*Version, error) {
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+
+	v := &Version{}
+	var err error
+	if m[1] != "" {
+		if v.Epoch, err = parseNumber(m[1]); err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+	}
+	for _, part := range strings.Split(m[2], ".") {
+		n, err := parseNumber(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.Release = append(v.Release, n)
+	}
+	if m[3] != "" {
+		n, err := parseOptionalNumber(m[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.Pre = &PreRelease{Label: normalizePreLabel(m[3]), Number: n}
+	}
+	if m[5] != "" || m[6] != "" {
+		n, err := parseOptionalNumber(m[5] + m[7])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.Post = &n
+	}
+	if m[8] != "" {
+		n, err := parseOptionalNumber(m[9])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.Dev = &n
+	}
+	if m[10] != "" {
+		v.Local = strings.FieldsFunc(strings.ToLower(m[10]), func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		})
+	}
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid input.
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func parseNumber(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// parseOptionalNumber parses the number of a pre, post or dev segment,
+// which defaults to 0 when it is left out
+func parseOptionalNumber(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return parseNumber(s)
+}
+
+// normalizePreLabel returns the canonical spelling of a pre-release label
+func normalizePreLabel(label string) string {
+	switch strings.ToLower(label) {
+	case "a", "alpha":
+		return "a"
+	case "b", "beta":
+		return "b"
+	default:
+		// c, rc, pre and preview
+		return "rc"
+	}
+}
+
+// String returns the normalized form of the version, e.g. "1.0-RC.1" -> "1.0rc1".
+func (v *Version) String() string {
+	s := v.Public()
+	if len(v.Local) > 0 {
+		s += "+" + strings.Join(v.Local, ".")
+	}
+	return s
+}
+
+// Public returns the normalized version without its local label.
+func (v *Version) Public() string {
+	var b strings.Builder
+	if v.Epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.Epoch)
+	}
+	b.WriteString(joinRelease(v.Release))
+	if v.Pre != nil {
+		fmt.Fprintf(&b, "%s%d", v.Pre.Label, v.Pre.Number)
+	}
+	if v.Post != nil {
+		fmt.Fprintf(&b, ".post%d", *v.Post)
+	}
+	if v.Dev != nil {
+		fmt.Fprintf(&b, ".dev%d", *v.Dev)
+	}
+	return b.String()
+}
+
+// BaseVersion returns the epoch and release of the version, e.g. "1.2" for
+// "1.2rc1.post3".
+func (v *Version) BaseVersion() *Version {
+	return &Version{Epoch: v.Epoch, Release: v.Release}
+}
+
+// publicVersion returns the version without its local label
+func (v *Version) publicVersion() *Version {
+	if len(v.Local) == 0 {
+		return v
+	}
+	p := *v
+	p.Local = nil
+	return &p
+}
+
+func joinRelease(release []uint64) string {
+	parts := make([]string, len(release))
+	for i, n := range release {
+		parts[i] = strconv.FormatUint(n, 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// IsPrerelease reports whether the version is a pre-release or a
+// development release, which installers skip unless asked for.
+func (v *Version) IsPrerelease() bool {
+	return v.Pre != nil || v.Dev != nil
+}
+
+// IsPostrelease reports whether the version is a post-release.
+func (v *Version) IsPostrelease() bool {
+	return v.Post != nil
+}
+
+// IsDevrelease reports whether the version is a development release.
+func (v *Version) IsDevrelease() bool {
+	return v.Dev != nil
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to
+// or higher than other. Trailing zeros of the release are insignificant, so
+// "1.0" equals "1.0.0".
+func (v *Version) Compare(other *Version) int {
+	if c := compareUint(v.Epoch, other.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(v.Release, other.Release); c != 0 {
+		return c
+	}
+	if c := compareInt(v.preKey(), other.preKey()); c != 0 {
+		return c
+	}
+	if c := compareOptional(v.Pre, other.Pre); c != 0 {
+		return c
+	}
+	if c := compareInt(v.postKey(), other.postKey()); c != 0 {
+		return c
+	}
+	if c := compareInt(v.devKey(), other.devKey()); c != 0 {
+		return c
+	}
+	return compareLocal(v.Local, other.Local)
+}
+
+// Compare compares two versions, see Version.Compare.
+func Compare(a, b *Version) int {
+	return a.Compare(b)
+}
+
+// preKey orders the pre-release phase: a development release of a final
+// release ("1.0.dev1") sorts before its pre-releases, and final releases
+// sort after them
+func (v *Version) preKey() int64 {
+	switch {
+	case v.Pre == nil && v.Post == nil && v.Dev != nil:
+		return -1
+	case v.Pre == nil:
+		return 3
+	case v.Pre.Label == "a":
+		return 0
+	case v.Pre.Label == "b":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// postKey orders post-releases after the version without one
+func (v *Version) postKey() int64 {
+	if v.Post == nil {
+		return -1
+	}
+	return int64(*v.Post)
+}
+
+// devKey orders development releases before the version without one
+func (v *Version) devKey() int64 {
+	if v.Dev == nil {
+		return int64(^uint64(0) >> 1)
+	}
+	return int64(*v.Dev)
+}
+
+func compareOptional(a, b *PreRelease) int {
+	if a == nil || b == nil {
+		return 0
+	}
+	return compareUint(a.Number, b.Number)
+}
+
+// compareRelease compares release segments, padding the shorter with zeros
+func compareRelease(a, b []uint64) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y uint64
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareUint(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLocal compares local labels: a version without one sorts first,
+// numeric segments sort after alphanumeric ones and compare as numbers, and
+// a label sorts after its own prefix
+func compareLocal(a, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return compareInt(int64(len(a)), int64(len(b)))
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aErr := strconv.ParseUint(a[i], 10, 64)
+		bn, bErr := strconv.ParseUint(b[i], 10, 64)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareUint(an, bn)
+		case aErr == nil:
+			c = 1
+		case bErr == nil:
+			c = -1
+		default:
+			c = strings.Compare(a[i], b[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(int64(len(a)), int64(len(b)))
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package pep440
+
+import "testing"
+
+// sortedVersions are in ascending order; they are the ordering vectors of
+// the packaging project
+var sortedVersions = []string{
+	// Implicit epoch of 0
+	"1.0.dev456", "1.0a1", "1.0a2.dev456", "1.0a12.dev456", "1.0a12",
+	"1.0b1.dev456", "1.0b2", "1.0b2.post345.dev456", "1.0b2.post345",
+	"1.0b2-346", "1.0c1.dev456", "1.0c1", "1.0rc2", "1.0c3", "1.0",
+	"1.0.post456.dev34", "1.0.post456", "1.1.dev1", "1.2+123abc",
+	"1.2+123abc456", "1.2+abc", "1.2+abc123", "1.2+abc123def", "1.2+1234.abc",
+	"1.2+123456", "1.2.r32+123456", "1.2.rev33+123456",
+	// Explicit epoch of 1
+	"1!1.0.dev456", "1!1.0a1", "1!1.0a2.dev456", "1!1.0a12.dev456", "1!1.0a12",
+	"1!1.0b1.dev456", "1!1.0b2", "1!1.0b2.post345.dev456", "1!1.0b2.post345",
+	"1!1.0b2-346", "1!1.0c1.dev456", "1!1.0c1", "1!1.0rc2", "1!1.0c3", "1!1.0",
+	"1!1.0.post456.dev34", "1!1.0.post456", "1!1.1.dev1", "1!1.2+123abc",
+	"1!1.2+123abc456", "1!1.2+abc", "1!1.2+abc123", "1!1.2+abc123def",
+	"1!1.2+1234.abc", "1!1.2+123456", "1!1.2.r32+123456", "1!1.2.rev33+123456",
+}
+
+func TestCompareOrdering(t *testing.T) {
+	parsed := make([]*Version, len(sortedVersions))
+	for i, s := range sortedVersions {
+		parsed[i] = MustParse(s)
+	}
+	for i := range parsed {
+		for j := range parsed {
+			want := compareInt(int64(i), int64(j))
+			if got := parsed[i].Compare(parsed[j]); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", sortedVersions[i], sortedVersions[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareEquivalent(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"1.0", "1.0.0"},
+		{"1.0", "1"},
+		{"1.0a1", "1.0.alpha.1"},
+		{"1.0.post1", "1.0-1"},
+		{"1.0+ubuntu-1", "1.0+ubuntu.1"},
+		{"0!1.0", "1.0"},
+	} {
+		if c := MustParse(pair[0]).Compare(MustParse(pair[1])); c != 0 {
+			t.Errorf("expected %s == %s, got %d", pair[0], pair[1], c)
+		}
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	tests := map[string]string{
+		"1.0dev":        "1.0.dev0",
+		"1.0.dev":       "1.0.dev0",
+		"1.0dev1":       "1.0.dev1",
+		"1.0-dev1":      "1.0.dev1",
+		"1.0DEV":        "1.0.dev0",
+		"1.0.DEV1":      "1.0.dev1",
+		"1.0alpha1":     "1.0a1",
+		"1.0.a1":        "1.0a1",
+		"1.0-a1":        "1.0a1",
+		"1.0_a1":        "1.0a1",
+		"1.0a":          "1.0a0",
+		"1.0.beta":      "1.0b0",
+		"1.0c1":         "1.0rc1",
+		"1.0pre1":       "1.0rc1",
+		"1.0preview1":   "1.0rc1",
+		"1.0-1":         "1.0.post1",
+		"1.0post":       "1.0.post0",
+		"1.0.post-1":    "1.0.post1",
+		"1.0rev1":       "1.0.post1",
+		"1.0r":          "1.0.post0",
+		"1.0+ubuntu-1":  "1.0+ubuntu.1",
+		"1.0+UBUNTU_1":  "1.0+ubuntu.1",
+		"v1.0":          "1.0",
+		"  v1.0\t\n":    "1.0",
+		"0001.0002":     "1.2",
+		"1!1.0":         "1!1.0",
+		"0!1.0":         "1.0",
+		"1.0rc1.post2":  "1.0rc1.post2",
+		"1.0-RC.1-dev3": "1.0rc1.dev3",
+	}
+	for input, want := range tests {
+		v, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", input, err)
+			continue
+		}
+		if got := v.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{
+		"", "french toast", "1.0+a+", "1.0++", "1.0+_foobar", "1.0+foo&asd",
+		"1.0+1+1", "1.0-", "1.0.", "1..0", "1.0-dev-dev", "1.0a1a2",
+	} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("expected Parse(%q) to fail", input)
+		}
+	}
+}
+
+func TestVersionProperties(t *testing.T) {
+	tests := []struct {
+		version    string
+		prerelease bool
+		post       bool
+		base       string
+		public     string
+	}{
+		{"1.0", false, false, "1.0", "1.0"},
+		{"1.0.dev0", true, false, "1.0", "1.0.dev0"},
+		{"1.0a1", true, false, "1.0", "1.0a1"},
+		{"1.0rc1.post1", true, true, "1.0", "1.0rc1.post1"},
+		{"1.0.post1", false, true, "1.0", "1.0.post1"},
+		{"1!2.0+local", false, false, "1!2.0", "1!2.0"},
+	}
+	for _, tt := range tests {
+		v := MustParse(tt.version)
+		if v.IsPrerelease() != tt.prerelease || v.IsPostrelease() != tt.post {
+			t.Errorf("%s: prerelease %v, post %v", tt.version, v.IsPrerelease(), v.IsPostrelease())
+		}
+		if got := v.BaseVersion().String(); got != tt.base {
+			t.Errorf("%s: base version %s, want %s", tt.version, got, tt.base)
+		}
+		if got := v.Public(); got != tt.public {
+			t.Errorf("%s: public version %s, want %s", tt.version, got, tt.public)
+		}
+	}
+}
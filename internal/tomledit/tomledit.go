@@ -0,0 +1,754 @@
+// Package tomledit implements lossless editing of TOML documents such as
+// pyproject.toml.
+//
+// A document is parsed into a concrete syntax tree that keeps every byte of
+// the source: comments, blank lines, indentation, key order, quoting style
+// and trailing commas all live in the tree. Writing the tree back gives the
+// original file, so changing a value leaves everything around it untouched,
+// including tables the caller knows nothing about.
+package tomledit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Node is a piece of a document. Writing the nodes of a document in order
+// gives back the source they were parsed from.
+type Node interface {
+	writeTo(b *bytes.Buffer)
+}
+
+// Value is the value of a key/value pair or an array element: a *String,
+// *Scalar, *Array or *InlineTable.
+type Value interface {
+	Node
+	isValue()
+}
+
+// Trivia is source text without a value: whitespace, line breaks, comments
+// and the commas between array elements or inline table entries.
+type Trivia struct {
+	Text string
+}
+
+// KeyValue is a key/value pair.
+type KeyValue struct {
+	// Key is the decoded dotted key, e.g. ["tool", "poetry"] for tool.poetry
+	Key   []string
+	Value Value
+	// raw is the source from the start of the key to the value, e.g. `a = `
+	raw string
+}
+
+// Table is the root table of a document or a table with a header.
+type Table struct {
+	// Key is the key of the header, nil for the root table
+	Key []string
+	// ArrayOfTables is set for tables with a [[header]]
+	ArrayOfTables bool
+	// Items are the key/value pairs of the table and the trivia around them
+	Items []Node
+	// header is the source of the header, e.g. "[tool.poetry]"
+	header string
+}
+
+// StringStyle is the way a string is quoted.
+type StringStyle int
+
+const (
+	// Basic strings are in double quotes and may contain escapes
+	Basic StringStyle = iota
+	// Literal strings are in single quotes and are taken as written
+	Literal
+	// MultiLineBasic strings are in triple double quotes
+	MultiLineBasic
+	// MultiLineLiteral strings are in triple single quotes
+	MultiLineLiteral
+)
+
+// delimiter returns the quotes around strings of style s
+func (s StringStyle) delimiter() string {
+	switch s {
+	case Literal:
+		return "'"
+	case MultiLineBasic:
+		return `"""`
+	case MultiLineLiteral:
+		return "'''"
+	default:
+		return `"`
+	}
+}
+
+// String is a string value.
+type String struct {
+	Style StringStyle
+	// Value is the decoded string
+	Value string
+	raw   string
+}
+
+// Scalar is an integer, float, boolean or date-time, kept as written.
+type Scalar struct {
+	Raw string
+}
+
+// Array is an array value.
+type Array struct {
+	// nodes are the elements with the trivia and commas between them
+	nodes []Node
+}
+
+// InlineTable is a table value such as { version = "^1.0", optional = true }.
+type InlineTable struct {
+	// nodes are the key/value pairs with the trivia and commas between them
+	nodes []Node
+}
+
+func (*String) isValue()      {}
+func (*Scalar) isValue()      {}
+func (*Array) isValue()       {}
+func (*InlineTable) isValue() {}
+
+func (t *Trivia) writeTo(b *bytes.Buffer) { b.WriteString(t.Text) }
+func (s *String) writeTo(b *bytes.Buffer) { b.WriteString(s.raw) }
+func (s *Scalar) writeTo(b *bytes.Buffer) { b.WriteString(s.Raw) }
+
+func (kv *KeyValue) writeTo(b *bytes.Buffer) {
+	b.WriteString(kv.raw)
+	kv.Value.writeTo(b)
+}
+
+func (t *Table) writeTo(b *bytes.Buffer) {
+	b.WriteString(t.header)
+	writeNodes(b, t.Items)
+}
+
+func (a *Array) writeTo(b *bytes.Buffer) {
+	b.WriteByte('[')
+	writeNodes(b, a.nodes)
+	b.WriteByte(']')
+}
+
+func (t *InlineTable) writeTo(b *bytes.Buffer) {
+	b.WriteByte('{')
+	writeNodes(b, t.nodes)
+	b.WriteByte('}')
+}
+
+func writeNodes(b *bytes.Buffer, nodes []Node) {
+	for _, n := range nodes {
+		n.writeTo(b)
+	}
+}
+
+// Set replaces the string with value, keeping its quoting style where value
+// can be written in it. A literal string that would need escapes becomes a
+// basic string.
+func (s *String) Set(value string) {
+	if value == s.Value {
+		return
+	}
+	delim := s.Style.delimiter()
+	// Keep the line break multi-line strings may start with
+	lead := ""
+	if len(delim) == 3 {
+		body := s.raw[len(delim):]
+		if strings.HasPrefix(body, "\r\n") {
+			lead = "\r\n"
+		} else if strings.HasPrefix(body, "\n") {
+			lead = "\n"
+		}
+	}
+
+	switch {
+	case s.Style == Literal && !strings.ContainsAny(value, "'\r\n") && !hasControl(value, false):
+		s.raw = delim + value + delim
+	case s.Style == MultiLineLiteral && !strings.Contains(value, "'''") && !strings.HasSuffix(value, "'") && !hasControl(value, true):
+		s.raw = delim + lead + value + delim
+	case s.Style == MultiLineLiteral || s.Style == MultiLineBasic:
+		s.Style = MultiLineBasic
+		s.raw = `"""` + lead + escape(value, true) + `"""`
+	default:
+		s.Style = Basic
+		s.raw = `"` + escape(value, false) + `"`
+	}
+	s.Value = value
+}
+
+// Elements returns the elements of the array.
+func (a *Array) Elements() []Value {
+	var elements []Value
+	for _, n := range a.nodes {
+		if v, ok := n.(Value); ok {
+			elements = append(elements, v)
+		}
+	}
+	return elements
+}
+
+// Multiline reports whether the array spans several lines.
+func (a *Array) Multiline() bool {
+	for _, n := range a.nodes {
+		if t, ok := n.(*Trivia); ok && strings.Contains(t.Text, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// TrailingComma reports whether the last element is followed by a comma.
+func (a *Array) TrailingComma() bool {
+	return a.lastElement() >= 0 && a.commaAfter(a.lastElement())
+}
+
+// AddTrailingComma puts a comma directly after the last element, unless
+// there is one already or the array is empty.
+func (a *Array) AddTrailingComma() {
+	last := a.lastElement()
+	if last < 0 || a.commaAfter(last) {
+		return
+	}
+	a.nodes = append(a.nodes[:last+1], append([]Node{&Trivia{Text: ","}}, a.nodes[last+1:]...)...)
+}
+
+func (a *Array) lastElement() int {
+	for i := len(a.nodes) - 1; i >= 0; i-- {
+		if _, ok := a.nodes[i].(Value); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *Array) commaAfter(i int) bool {
+	for _, n := range a.nodes[i+1:] {
+		if t, ok := n.(*Trivia); ok && t.Text == "," {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the key/value pairs of the inline table.
+func (t *InlineTable) Entries() []*KeyValue {
+	return keyValues(t.nodes)
+}
+
+// Entries returns the key/value pairs of the table.
+func (t *Table) Entries() []*KeyValue {
+	return keyValues(t.Items)
+}
+
+func keyValues(nodes []Node) []*KeyValue {
+	var kvs []*KeyValue
+	for _, n := range nodes {
+		if kv, ok := n.(*KeyValue); ok {
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs
+}
+
+// Document is a parsed TOML document.
+type Document struct {
+	// Root holds the key/value pairs before the first header
+	Root *Table
+	// Tables are the tables with a header, in source order
+	Tables []*Table
+}
+
+// Parse parses a TOML document.
+func Parse(data []byte) (*Document, error) {
+	src := string(data)
+	// Let the TOML decoder reject invalid documents with a descriptive
+	// error, so the parser below only has to follow the structure
+	var v map[string]interface{}
+	if _, err := toml.Decode(strings.TrimPrefix(src, "\ufeff"), &v); err != nil {
+		return nil, err
+	}
+	return (&parser{src: src}).document()
+}
+
+// ParseFile reads and parses the TOML file at path.
+func ParseFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Bytes returns the current content of the document.
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	d.Root.writeTo(&b)
+	for _, t := range d.Tables {
+		t.writeTo(&b)
+	}
+	return b.Bytes()
+}
+
+// Entry is a value together with the full path of its key.
+type Entry struct {
+	// Path is the key path from the root of the document, e.g. ["tool",
+	// "poetry", "dependencies", "requests"] for requests = "^2.31" in
+	// [tool.poetry.dependencies]
+	Path  []string
+	Value Value
+}
+
+// Entries returns every key/value pair of the document with its full path,
+// in source order. The pairs of an inline table follow the pair holding
+// it; array elements are not included.
+func (d *Document) Entries() []Entry {
+	entries := appendEntries(nil, nil, d.Root.Items)
+	for _, t := range d.Tables {
+		entries = appendEntries(entries, t.Key, t.Items)
+	}
+	return entries
+}
+
+func appendEntries(entries []Entry, prefix []string, nodes []Node) []Entry {
+	for _, kv := range keyValues(nodes) {
+		path := make([]string, 0, len(prefix)+len(kv.Key))
+		path = append(append(path, prefix...), kv.Key...)
+		entries = append(entries, Entry{Path: path, Value: kv.Value})
+		if t, ok := kv.Value.(*InlineTable); ok {
+			entries = appendEntries(entries, path, t.nodes)
+		}
+	}
+	return entries
+}
+
+// Lookup returns the value at the given key path, or nil if there is none.
+func (d *Document) Lookup(path ...string) Value {
+	var found Value
+	for _, e := range d.Entries() {
+		if equalPath(e.Path, path) {
+			found = e.Value
+		}
+	}
+	return found
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parser is a recursive descent parser building the syntax tree. Documents
+// have been validated before, so it only needs to follow their structure.
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.src[:min(p.pos, len(p.src))], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// spaces skips spaces and tabs
+func (p *parser) spaces() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// trivia consumes whitespace, comments and line breaks
+func (p *parser) trivia() string {
+	start := p.pos
+	for !p.eof() {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '#':
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return p.src[start:p.pos]
+		}
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) document() (*Document, error) {
+	doc := &Document{Root: &Table{}}
+	table := doc.Root
+	if strings.HasPrefix(p.src, "\ufeff") {
+		p.pos = len("\ufeff")
+		table.Items = append(table.Items, &Trivia{Text: "\ufeff"})
+	}
+
+	for !p.eof() {
+		if s := p.trivia(); s != "" {
+			table.Items = append(table.Items, &Trivia{Text: s})
+			continue
+		}
+		if p.src[p.pos] == '[' {
+			t, err := p.table()
+			if err != nil {
+				return nil, err
+			}
+			doc.Tables = append(doc.Tables, t)
+			table = t
+			continue
+		}
+		kv, err := p.keyValue()
+		if err != nil {
+			return nil, err
+		}
+		table.Items = append(table.Items, kv)
+	}
+	return doc, nil
+}
+
+func (p *parser) table() (*Table, error) {
+	start := p.pos
+	t := &Table{ArrayOfTables: strings.HasPrefix(p.src[p.pos:], "[[")}
+	closing := "]"
+	if t.ArrayOfTables {
+		closing = "]]"
+	}
+	p.pos += len(closing)
+
+	key, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, p.errorf("expected %s after table header", closing)
+	}
+	p.pos += len(closing)
+	t.Key = key
+	t.header = p.src[start:p.pos]
+	return t, nil
+}
+
+func (p *parser) keyValue() (*KeyValue, error) {
+	start := p.pos
+	key, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	if p.eof() || p.src[p.pos] != '=' {
+		return nil, p.errorf("expected = after key %s", strings.Join(key, "."))
+	}
+	p.pos++
+	p.spaces()
+	raw := p.src[start:p.pos]
+
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return &KeyValue{Key: key, Value: v, raw: raw}, nil
+}
+
+// key parses a dotted key and the whitespace around it
+func (p *parser) key() ([]string, error) {
+	var parts []string
+	for {
+		p.spaces()
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+		switch c := p.src[p.pos]; {
+		case c == '"' || c == '\'':
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, s.Value)
+		case isBareKeyChar(c):
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.src[p.pos]) {
+				p.pos++
+			}
+			parts = append(parts, p.src[start:p.pos])
+		default:
+			return nil, p.errorf("unexpected %q in key", c)
+		}
+		p.spaces()
+		if p.eof() || p.src[p.pos] != '.' {
+			return parts, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) value() (Value, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.src[p.pos] {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+	return p.scalar()
+}
+
+func (p *parser) str() (*String, error) {
+	start := p.pos
+	var style StringStyle
+	switch rest := p.src[p.pos:]; {
+	case strings.HasPrefix(rest, `"""`):
+		style = MultiLineBasic
+	case strings.HasPrefix(rest, "'''"):
+		style = MultiLineLiteral
+	case rest[0] == '"':
+		style = Basic
+	default:
+		style = Literal
+	}
+	delim := style.delimiter()
+	escapes := style == Basic || style == MultiLineBasic
+
+	p.pos += len(delim)
+	for {
+		if p.eof() || len(delim) == 1 && p.src[p.pos] == '\n' {
+			return nil, p.errorf("unterminated string")
+		}
+		if escapes && p.src[p.pos] == '\\' {
+			p.pos += 2
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			// Up to two quotes may directly precede the closing delimiter
+			for i := 0; len(delim) == 3 && i < 2 && !p.eof() && p.src[p.pos] == delim[0]; i++ {
+				p.pos++
+			}
+			break
+		}
+		p.pos++
+	}
+
+	raw := p.src[start:p.pos]
+	body := raw[len(delim) : len(raw)-len(delim)]
+	if len(delim) == 3 {
+		// A line break right after the opening delimiter is not part of the string
+		body = strings.TrimPrefix(strings.TrimPrefix(body, "\r"), "\n")
+	}
+	if !escapes {
+		return &String{Style: style, Value: body, raw: raw}, nil
+	}
+	value, err := unescape(body)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return &String{Style: style, Value: value, raw: raw}, nil
+}
+
+// scalar parses a number, boolean or date-time
+func (p *parser) scalar() (*Scalar, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.src[p.pos]
+		// A date and a time may be separated by a space
+		if c == ' ' && isDate(p.src[start:p.pos]) && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+			p.pos++
+			continue
+		}
+		if strings.IndexByte(" \t\r\n,]}#", c) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return &Scalar{Raw: p.src[start:p.pos]}, nil
+}
+
+func isDate(s string) bool {
+	return len(s) == 10 && s[4] == '-' && s[7] == '-'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) array() (*Array, error) {
+	a := &Array{}
+	p.pos++ // [
+	for {
+		if s := p.trivia(); s != "" {
+			a.nodes = append(a.nodes, &Trivia{Text: s})
+		}
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.src[p.pos] {
+		case ']':
+			p.pos++
+			return a, nil
+		case ',':
+			p.pos++
+			a.nodes = append(a.nodes, &Trivia{Text: ","})
+		default:
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			a.nodes = append(a.nodes, v)
+		}
+	}
+}
+
+func (p *parser) inlineTable() (*InlineTable, error) {
+	t := &InlineTable{}
+	p.pos++ // {
+	for {
+		if s := p.trivia(); s != "" {
+			t.nodes = append(t.nodes, &Trivia{Text: s})
+		}
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		switch p.src[p.pos] {
+		case '}':
+			p.pos++
+			return t, nil
+		case ',':
+			p.pos++
+			t.nodes = append(t.nodes, &Trivia{Text: ","})
+		default:
+			kv, err := p.keyValue()
+			if err != nil {
+				return nil, err
+			}
+			t.nodes = append(t.nodes, kv)
+		}
+	}
+}
+
+// unescape decodes the escape sequences of a basic string
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("unterminated escape sequence")
+		}
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte('\x1b')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", fmt.Errorf("invalid escape sequence \\%s", s[i:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape sequence \\%s", s[i:i+1+n])
+			}
+			b.WriteRune(rune(r))
+			i += n
+		default:
+			// A backslash at the end of a line in a multi-line string
+			// removes the line break and the whitespace that follows
+			j := i
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				j++
+			}
+			if j == len(s) || s[j] != '\n' && s[j] != '\r' {
+				return "", fmt.Errorf("invalid escape sequence \\%c", c)
+			}
+			for j < len(s) && strings.IndexByte(" \t\r\n", s[j]) >= 0 {
+				j++
+			}
+			i = j - 1
+		}
+	}
+	return b.String(), nil
+}
+
+// escape encodes s for a basic string; multi-line strings keep their line
+// breaks and tabs
+func escape(s string, multiline bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case multiline && (r == '\n' || r == '\t'):
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// hasControl reports whether s contains characters a literal string cannot
+// hold; multi-line literal strings may contain line breaks and tabs
+func hasControl(s string, multiline bool) bool {
+	for _, r := range s {
+		if r == '\t' || multiline && (r == '\n' || r == '\r') {
+			continue
+		}
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
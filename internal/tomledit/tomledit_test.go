@@ -0,0 +1,202 @@
+package tomledit
+
+import (
+	"strings"
+	"testing"
+)
+
+const examplePyProject = "\ufeff# Project metadata\n" +
+	`[build-system]
+requires = ["hatchling>=1.18"]  # build backend
+build-backend = 'hatchling.build'
+
+[project]
+name = "example"
+authors = [{ name = "Jane", email = "jane@example.com" }]
+"requires-python" = ">=3.9"
+dependencies = [
+    # web
+    "flask==2.0.0", # pinned
+    'requests>=2.28,<3',
+	"rich",
+]
+description = """
+Multi-line "description" \
+  with a continued line"""
+readme.file = 'README.md'
+
+[ tool . "poetry" . dependencies ]
+python = "^3.9"
+django = { version = "^4.2", extras = ["bcrypt"] }
+
+[[tool.uv.index]]
+name = "internal"
+url = "https://pypi.example.com/simple"
+default = true
+created = 1979-05-27 07:32:00Z
+ratio = -1.5e3
+
+[tool.black]
+line-length = 88
+target-version = ['py39', 'py310',]
+`
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		examplePyProject,
+		"",
+		"\n\n# only a comment",
+		"a = 1\r\nb = [\r\n  'x',\r\n]\r\n",
+		`s = '''
+literal with 'quotes' '''''` + "\n",
+	} {
+		doc, err := Parse([]byte(input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", input, err)
+		}
+		if got := string(doc.Bytes()); got != input {
+			t.Errorf("round trip changed the document:\n%q\nwant:\n%q", got, input)
+		}
+	}
+}
+
+func TestEntries(t *testing.T) {
+	doc, err := Parse([]byte(examplePyProject))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"build-system.build-backend", "hatchling.build"},
+		{"project.requires-python", ">=3.9"},
+		{"project.description", "Multi-line \"description\" with a continued line"},
+		{"project.readme.file", "README.md"},
+		{"tool.poetry.dependencies.django.version", "^4.2"},
+		{"tool.uv.index.url", "https://pypi.example.com/simple"},
+	}
+	for _, tt := range tests {
+		s, ok := doc.Lookup(strings.Split(tt.path, ".")...).(*String)
+		if !ok {
+			t.Errorf("expected a string at %s", tt.path)
+			continue
+		}
+		if s.Value != tt.expected {
+			t.Errorf("%s = %q, want %q", tt.path, s.Value, tt.expected)
+		}
+	}
+
+	if v, ok := doc.Lookup("tool", "uv", "index", "created").(*Scalar); !ok || v.Raw != "1979-05-27 07:32:00Z" {
+		t.Errorf("unexpected date-time: %#v", doc.Lookup("tool", "uv", "index", "created"))
+	}
+	deps, ok := doc.Lookup("project", "dependencies").(*Array)
+	if !ok {
+		t.Fatal("expected project.dependencies to be an array")
+	}
+	var names []string
+	for _, v := range deps.Elements() {
+		names = append(names, v.(*String).Value)
+	}
+	if got := strings.Join(names, " "); got != "flask==2.0.0 requests>=2.28,<3 rich" {
+		t.Errorf("unexpected dependencies: %s", got)
+	}
+	if !deps.Multiline() || !deps.TrailingComma() {
+		t.Errorf("expected a multi-line array with a trailing comma")
+	}
+	if doc.Lookup("project", "missing") != nil {
+		t.Errorf("expected nil for a missing key")
+	}
+}
+
+func TestSetPreservesFormatting(t *testing.T) {
+	doc, err := Parse([]byte(examplePyProject))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	deps := doc.Lookup("project", "dependencies").(*Array).Elements()
+	deps[0].(*String).Set("flask==3.0.3")
+	deps[1].(*String).Set("requests>=2.32,<3")
+	doc.Lookup("tool", "poetry", "dependencies", "django", "version").(*String).Set("^5.0")
+	doc.Lookup("build-system", "build-backend").(*String).Set("it's")
+
+	expected := strings.NewReplacer(
+		`"flask==2.0.0"`, `"flask==3.0.3"`,
+		`'requests>=2.28,<3'`, `'requests>=2.32,<3'`,
+		`"^4.2"`, `"^5.0"`,
+		`'hatchling.build'`, `"it's"`,
+	).Replace(examplePyProject)
+	if got := string(doc.Bytes()); got != expected {
+		t.Errorf("unexpected document:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestSetStyles(t *testing.T) {
+	tests := []struct {
+		input    string
+		value    string
+		expected string
+	}{
+		{`a = "x"`, `say "hi"\`, `a = "say \"hi\"\\"`},
+		{`a = 'x'`, `C:\path`, `a = 'C:\path'`},
+		{`a = 'x'`, "two\nlines", `a = "two\nlines"`},
+		{"a = '''\nx'''", "y'", "a = \"\"\"\ny'\"\"\""},
+		{"a = \"\"\"\nx\"\"\"", "tab\there", "a = \"\"\"\ntab\there\"\"\""},
+	}
+	for _, tt := range tests {
+		doc, err := Parse([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		doc.Lookup("a").(*String).Set(tt.value)
+		if got := string(doc.Bytes()); got != tt.expected {
+			t.Errorf("Set(%q) on %q = %q, want %q", tt.value, tt.input, got, tt.expected)
+		}
+		// The edited document must decode to the new value
+		reparsed, err := Parse(doc.Bytes())
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", doc.Bytes(), err)
+		}
+		if got := reparsed.Lookup("a").(*String).Value; got != tt.value {
+			t.Errorf("reparsed value = %q, want %q", got, tt.value)
+		}
+	}
+}
+
+func TestAddTrailingComma(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = [\n    \"x\",\n    \"y\"  # last\n]", "a = [\n    \"x\",\n    \"y\",  # last\n]"},
+		{"a = [\"x\",]", "a = [\"x\",]"},
+		{"a = [\"x\"]", "a = [\"x\",]"},
+		{"a = []", "a = []"},
+	}
+	for _, tt := range tests {
+		doc, err := Parse([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		doc.Lookup("a").(*Array).AddTrailingComma()
+		if got := string(doc.Bytes()); got != tt.expected {
+			t.Errorf("AddTrailingComma on %q = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{
+		`a = `,
+		`a = "unterminated`,
+		`[table`,
+		`a = [1, 2`,
+		"a = 1\na = 2",
+	} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
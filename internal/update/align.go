@@ -1,17 +1,15 @@
 package update
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
-	semv "github.com/Masterminds/semver/v3"
-	"github.com/rvben/pyver"
+	"github.com/rvben/ru/internal/packagemanager/packagejson"
+	"github.com/rvben/ru/internal/packagemanager/requirements"
 	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
 	ignore "github.com/sabhiram/go-gitignore"
 )
 
@@ -21,6 +19,9 @@ type versionInfo struct {
 }
 
 type Aligner struct {
+	// python and npm are the version schemes of the two ecosystems
+	python         versioning.Scheme
+	npm            versioning.Scheme
 	pythonVersions map[string]string
 	npmVersions    map[string]string
 	filesUpdated   int
@@ -32,6 +33,8 @@ type Aligner struct {
 
 func NewAligner() *Aligner {
 	return &Aligner{
+		python:         versioning.PEP440,
+		npm:            versioning.NodeSemver,
 		pythonVersions: make(map[string]string),
 		npmVersions:    make(map[string]string),
 	}
@@ -164,14 +167,10 @@ func (a *Aligner) collectVersionsFromFiles() error {
 }
 
 func (a *Aligner) scanPythonVersions(filePath string, versionMap map[string]*versionInfo) error {
-	file, err := os.Open(filePath)
+	reqFile, err := requirements.ParseFile(filePath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	re := regexp.MustCompile(`^([a-zA-Z0-9-_.]+)==([a-zA-Z0-9.*+!~_=<>-]+)`)
 
 	isWildcard := func(v string) bool {
 		return strings.HasSuffix(v, ".*")
@@ -184,95 +183,87 @@ func (a *Aligner) scanPythonVersions(filePath string, versionMap map[string]*ver
 		return v
 	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+	for _, line := range reqFile.Requirements() {
+		version, pinned := line.Pinned()
+		if !pinned {
+			utils.Debug("align", "[align] Skipped line (not pinned): %s", strings.TrimSpace(line.Raw))
 			continue
 		}
 
-		matches := re.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			pkg, version := matches[1], matches[2]
-			if _, ok := versionMap[pkg]; !ok {
-				versionMap[pkg] = &versionInfo{}
-			}
-			vi := versionMap[pkg]
-			if isWildcard(version) {
-				// Track highest wildcard
-				if vi.highestWildcard == "" {
+		// Versions are tracked by normalized name so "Foo_Bar" and "foo-bar" align
+		pkg := line.NormalizedName()
+		if _, ok := versionMap[pkg]; !ok {
+			versionMap[pkg] = &versionInfo{}
+		}
+		vi := versionMap[pkg]
+		if isWildcard(version) {
+			// Track highest wildcard
+			if vi.highestWildcard == "" {
+				vi.highestWildcard = version
+				utils.Debug("align", "[align] Set initial highest wildcard for %s: %s", pkg, version)
+			} else {
+				v1 := wildcardBase(version)
+				v2 := wildcardBase(vi.highestWildcard)
+				utils.Debug("align", "[align] Comparing wildcards for %s: %s vs %s", pkg, v1, v2)
+				cmp, ok := versioning.Compare(a.python, v1, v2)
+				if !ok {
+					utils.Debug("align", "[align] Could not parse wildcard version(s): %s %s", v1, v2)
+				}
+				if ok && cmp > 0 {
+					utils.Debug("align", "[align] Updating highest wildcard for %s to %s", pkg, version)
 					vi.highestWildcard = version
-					utils.Debug("align", "[align] Set initial highest wildcard for %s: %s", pkg, version)
-				} else {
-					v1 := wildcardBase(version)
-					v2 := wildcardBase(vi.highestWildcard)
-					v1Ver, err1 := pyver.Parse(v1 + ".0")
-					v2Ver, err2 := pyver.Parse(v2 + ".0")
-					utils.Debug("align", "[align] Comparing wildcards for %s: %s vs %s", pkg, v1, v2)
-					if err1 != nil || err2 != nil {
-						utils.Debug("align", "[align] Could not parse wildcard version(s): %s %s err1: %v err2: %v", v1, v2, err1, err2)
-					}
-					if err1 == nil && err2 == nil && pyver.Compare(v1Ver, v2Ver) > 0 {
-						utils.Debug("align", "[align] Updating highest wildcard for %s to %s", pkg, version)
-						vi.highestWildcard = version
-					}
 				}
+			}
+		} else {
+			// Track highest concrete; pins that do not follow PEP 440 are
+			// ordered by the loose scheme
+			if !versioning.Valid(a.python, version) {
+				utils.Warning("Skipping unparsable version for %s: %s", pkg, version)
+				continue
+			}
+			if vi.highestConcrete == "" {
+				vi.highestConcrete = version
+				utils.Debug("align", "[align] Set initial highest concrete for %s: %s", pkg, version)
 			} else {
-				// Track highest concrete
-				v1, err1 := pyver.Parse(version)
-				if err1 != nil {
-					utils.Warning("Skipping unparsable version for %s: %s", pkg, version)
-					continue
-				}
-				if vi.highestConcrete == "" {
+				utils.Debug("align", "[align] Comparing concretes for %s: %s vs %s", pkg, version, vi.highestConcrete)
+				if cmp, _ := versioning.Compare(a.python, version, vi.highestConcrete); cmp > 0 {
+					utils.Debug("align", "[align] Updating highest concrete for %s to %s", pkg, version)
 					vi.highestConcrete = version
-					utils.Debug("align", "[align] Set initial highest concrete for %s: %s", pkg, version)
-				} else {
-					v2, err2 := pyver.Parse(vi.highestConcrete)
-					if err2 != nil {
-						utils.Warning("Skipping unparsable highestConcrete for %s: %s", pkg, vi.highestConcrete)
-						vi.highestConcrete = version
-						continue
-					}
-					utils.Debug("align", "[align] Comparing concretes for %s: %s vs %s", pkg, version, vi.highestConcrete)
-					if pyver.Compare(v1, v2) > 0 {
-						utils.Debug("align", "[align] Updating highest concrete for %s to %s", pkg, version)
-						vi.highestConcrete = version
-					}
 				}
 			}
-		} else {
-			utils.Debug("align", "[align] Skipped line (no match): %s", line)
 		}
 	}
 
-	return scanner.Err()
+	return nil
 }
 
+// npmSections are the package.json sections the aligner collects and aligns
+var npmSections = []string{"dependencies", "devDependencies"}
+
 func (a *Aligner) collectNPMVersions(filePath string) error {
-	data, err := os.ReadFile(filePath)
+	doc, err := packagejson.ParseFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	var pkg struct {
-		Dependencies map[string]string `json:"dependencies"`
-	}
-
-	if err := json.Unmarshal(data, &pkg); err != nil {
-		return err
-	}
-
-	for name, version := range pkg.Dependencies {
-		// Strip any semver operators
-		cleanVersion := strings.TrimLeft(version, "^~>=<")
-		if existingVersion, ok := a.npmVersions[name]; ok {
-			v1, err1 := semv.NewVersion(cleanVersion)
-			v2, err2 := semv.NewVersion(existingVersion)
-			if err1 == nil && err2 == nil && v1.GreaterThan(v2) {
+	for _, section := range npmSections {
+		for _, member := range doc.StringMembers(section) {
+			name := member.Key
+			// Strip any semver operators
+			cleanVersion := strings.TrimLeft(member.Value.Str, "^~>=<")
+			v1, err1 := a.npm.Parse(cleanVersion)
+			if err1 != nil {
+				utils.Debug("align", "[align] Skipping non-version spec for %s: %s", name, member.Value.Str)
+				continue
+			}
+			if existingVersion, ok := a.npmVersions[name]; ok {
+				v2, err2 := a.npm.Parse(existingVersion)
+				if err2 == nil && a.npm.Compare(v1, v2) > 0 {
+					a.npmVersions[name] = cleanVersion
+				}
+			} else {
 				a.npmVersions[name] = cleanVersion
 			}
-		} else {
-			a.npmVersions[name] = cleanVersion
 		}
 	}
 	return nil
@@ -297,35 +288,30 @@ func (a *Aligner) alignVersionsInFiles() error {
 func (a *Aligner) alignPythonFile(filePath string) error {
 	utils.Debug("align", "Aligning Python file: %s", filePath)
 
-	input, err := os.ReadFile(filePath)
+	reqFile, err := requirements.ParseFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(string(input), "\n")
 	updated := false
-	// Match any line that starts with a package name (alphanumeric, dash, underscore, dot), possibly followed by any constraint
-	re := regexp.MustCompile(`^([a-zA-Z0-9-_.]+)([<>=!~].*)?$`)
-
-	for i, line := range lines {
-		if line == "" || strings.HasPrefix(line, "#") {
+	for _, line := range reqFile.Requirements() {
+		version, ok := a.pythonVersions[line.NormalizedName()]
+		if !ok {
 			continue
 		}
-
-		matches := re.FindStringSubmatch(line)
-		if len(matches) >= 2 {
-			pkg := matches[1]
-			if version, ok := a.pythonVersions[pkg]; ok {
-				lines[i] = fmt.Sprintf("%s==%s", pkg, version)
-				updated = true
-				a.modulesUpdated++
+		// Only the version token changes; markers, comments and hashes are kept
+		if line.SetVersion(version) {
+			if len(line.Hashes) > 0 {
+				utils.Warning("%s:%d: %s was aligned but its --hash values must be regenerated", filePath, line.Number, line.Name)
 			}
+			updated = true
+			a.modulesUpdated++
 		}
 	}
 
 	if updated {
 		a.filesUpdated++
-		return os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0644)
+		return os.WriteFile(filePath, []byte(reqFile.String()), 0644)
 	}
 	a.filesUnchanged++
 	return nil
@@ -334,41 +320,38 @@ func (a *Aligner) alignPythonFile(filePath string) error {
 func (a *Aligner) alignNPMFile(filePath string) error {
 	utils.Debug("align", "Aligning NPM file: %s", filePath)
 
-	data, err := os.ReadFile(filePath)
+	doc, err := packagejson.ParseFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	var pkg struct {
-		Dependencies map[string]string `json:"dependencies"`
-	}
+	updated := false
+	for _, section := range npmSections {
+		for _, member := range doc.StringMembers(section) {
+			name, spec := member.Key, member.Value.Str
+			version, ok := a.npmVersions[name]
+			if !ok {
+				continue
+			}
 
-	if err := json.Unmarshal(data, &pkg); err != nil {
-		return err
-	}
+			// Keep the range operator and only raise the version behind it
+			cleanVersion := strings.TrimLeft(spec, "^~>=<")
+			prefix := spec[:len(spec)-len(cleanVersion)]
+			if cleanVersion == version || !versioning.IsNewer(a.npm, version, cleanVersion) {
+				continue
+			}
 
-	updated := false
-	for name, version := range pkg.Dependencies {
-		// Strip any semver operators
-		cleanVersion := strings.TrimLeft(version, "^~>=<")
-		if existingVersion, ok := a.npmVersions[name]; ok {
-			v1, err1 := semv.NewVersion(cleanVersion)
-			v2, err2 := semv.NewVersion(existingVersion)
-			if err1 == nil && err2 == nil && v1.GreaterThan(v2) {
-				a.npmVersions[name] = cleanVersion
+			if err := doc.SetString(prefix+version, section, name); err != nil {
+				return err
 			}
-		} else {
-			a.npmVersions[name] = cleanVersion
+			updated = true
+			a.modulesUpdated++
 		}
 	}
 
 	if updated {
 		a.filesUpdated++
-		output, err := json.MarshalIndent(pkg, "", "  ")
-		if err != nil {
-			return err
-		}
-		return os.WriteFile(filePath, output, 0644)
+		return os.WriteFile(filePath, doc.Bytes(), 0644)
 	}
 	a.filesUnchanged++
 	return nil
@@ -127,3 +127,75 @@ func TestAlignerUrllib3VersionOrder(t *testing.T) {
 	// Only expect highest concrete version
 	runAlignerTest(t, tmpDir)(paths, map[string]string{"urllib3": "urllib3==2.2.3"})
 }
+
+func TestAlignerLegacyVersions(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Pins that do not follow PEP 440 are still ordered; unparsable ones are skipped
+	files := []struct {
+		dir, content string
+	}{
+		{"a", "tool==2024.04\nlib==1.0\n"},
+		{"b", "tool==2024.05-hotfix\nlib==${LIB_VERSION}\n"},
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		dirPath := filepath.Join(tmpDir, f.dir)
+		if err := os.Mkdir(dirPath, 0755); err != nil {
+			t.Fatalf("Failed to create dir %s: %v", dirPath, err)
+		}
+		filePath := filepath.Join(dirPath, "requirements.txt")
+		if err := os.WriteFile(filePath, []byte(f.content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", filePath, err)
+		}
+		paths[i] = filePath
+	}
+
+	runAlignerTest(t, tmpDir)(paths, map[string]string{"tool": "tool==2024.05-hotfix", "lib": "lib==1.0"})
+}
+
+func TestAlignerNPMPreservesFormatting(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "aligner-npm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := []struct {
+		dir, content, expected string
+	}{
+		{
+			"a",
+			"{\n\t\"name\": \"a\",\n\t\"dependencies\": {\n\t\t\"react\": \"^17.0.2\",\n\t\t\"lodash\": \"4.17.21\"\n\t}\n}\n",
+			"{\n\t\"name\": \"a\",\n\t\"dependencies\": {\n\t\t\"react\": \"^18.2.0\",\n\t\t\"lodash\": \"4.17.21\"\n\t}\n}\n",
+		},
+		{
+			"b",
+			"{\n    \"dependencies\": {\"react\": \"~18.2.0\"},\n    \"devDependencies\": {\"lodash\": \"^4.17.0\"}\n}",
+			"{\n    \"dependencies\": {\"react\": \"~18.2.0\"},\n    \"devDependencies\": {\"lodash\": \"^4.17.21\"}\n}",
+		},
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		dirPath := filepath.Join(tmpDir, f.dir)
+		if err := os.Mkdir(dirPath, 0755); err != nil {
+			t.Fatalf("Failed to create dir %s: %v", dirPath, err)
+		}
+		paths[i] = filepath.Join(dirPath, "package.json")
+		if err := os.WriteFile(paths[i], []byte(f.content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", paths[i], err)
+		}
+	}
+
+	runAlignerTest(t, tmpDir)(nil, nil)
+
+	for i, f := range files {
+		got, err := os.ReadFile(paths[i])
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", paths[i], err)
+		}
+		if string(got) != f.expected {
+			t.Errorf("%s: unexpected content\nwant: %q\ngot:  %q", f.dir, f.expected, string(got))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package update
+
+import (
+	"github.com/rvben/ru/internal/packagemanager"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// SetAllowDowngrade lets packages move to a lower version, e.g. from a
+// prerelease or a yanked release to the latest final release.
+func (u *Updater) SetAllowDowngrade(allow bool) {
+	u.allowDowngrade = allow
+}
+
+// latestFrom returns the latest version of a package for a project on
+// version current, following its prerelease channel if the package manager
+// supports that.
+func latestFrom(pm packagemanager.PackageManager, name, current string) (string, error) {
+	if lookup, ok := pm.(packagemanager.ChannelLookup); ok && current != "" {
+		return lookup.GetLatestVersionFrom(name, current)
+	}
+	return pm.GetLatestVersion(name)
+}
+
+// downgrades reports whether moving a package from current to target
+// lowers its version and is not allowed. That happens when the project is
+// on a prerelease or a yanked release newer than the latest release.
+func (u *Updater) downgrades(scheme versioning.Scheme, name, current, target string) bool {
+	if u.allowDowngrade || current == "" {
+		return false
+	}
+	from, err := scheme.Parse(current)
+	if err != nil {
+		return false
+	}
+	to, err := scheme.Parse(target)
+	if err != nil || scheme.Compare(to, from) >= 0 {
+		return false
+	}
+	utils.Info("update", "Keeping %s at %s: latest version %s is lower (use -allow-downgrade to allow it)", name, current, target)
+	return true
+}
+
+// latestVersionFrom returns the latest version of an npm package for a
+// project on version current, following its prerelease channel.
+func (l *npmLookup) latestVersionFrom(name, current string) (string, error) {
+	if v, err := versioning.NodeSemver.Parse(current); err != nil || !versioning.NodeSemver.IsPrerelease(v) {
+		return l.latestVersion(name)
+	}
+	packument := l.packument(name)
+	if packument == nil {
+		return l.latestVersion(name)
+	}
+	return packument.LatestFrom(current), nil
+}
@@ -0,0 +1,139 @@
+package update
+
+import (
+	"path/filepath"
+
+	"github.com/rvben/ru/internal/config"
+	"github.com/rvben/ru/internal/packagemanager"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// SetConfig sets the project configuration, e.g. the update levels of
+// packages, for every path instead of the configuration file of each. Call
+// it before SetLevel, which overrides the project level.
+func (u *Updater) SetConfig(cfg *config.Config) {
+	u.config = cfg
+	u.configSet = true
+}
+
+// SetLevel caps updates of packages without a level of their own in the
+// configuration: Patch and Minor keep packages within their current minor
+// and major version.
+func (u *Updater) SetLevel(level versioning.Level) {
+	u.level = &level
+	if u.config == nil {
+		u.config = &config.Config{}
+	}
+	u.config.SetLevel(level)
+}
+
+// loadConfig reads the configuration file of a path given to Run: the one
+// in the directory, or next to the file. The level set with SetLevel
+// overrides that of the file. A configuration set with SetConfig is kept.
+func (u *Updater) loadConfig(path string, isDir bool) error {
+	if u.configSet {
+		return nil
+	}
+	dir := path
+	if !isDir {
+		dir = filepath.Dir(path)
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	if u.level != nil {
+		cfg.SetLevel(*u.level)
+	}
+	u.config = cfg
+	return nil
+}
+
+// targetVersion returns the version a Python package with the given
+// constraint in the file of l should move to: its latest version on the release
+// channel of the current one, capped at the package's update level and at
+// the newest release supporting the project's Python version and target
+// platforms. It returns "" if that would be a downgrade or no newer version
+// qualifies. A package that stays on a yanked release is reported.
+func (u *Updater) targetVersion(l *pythonLookup, name string, scheme versioning.Scheme, constraint string) (string, error) {
+	current := versioning.Floor(scheme, constraint)
+	target, err := u.newestTarget(l, name, scheme, constraint, current)
+	if err != nil {
+		return "", err
+	}
+	if current != "" && !versioning.IsNewer(scheme, target, current) {
+		u.checkYanked(l, name, scheme, current)
+	}
+	return target, nil
+}
+
+// newestTarget is targetVersion for a package on version current
+func (u *Updater) newestTarget(l *pythonLookup, name string, scheme versioning.Scheme, constraint, current string) (string, error) {
+	latest, err := latestFrom(l.client, name, current)
+	if err != nil {
+		return "", err
+	}
+	if u.downgrades(scheme, name, current, latest) {
+		return "", nil
+	}
+	lookup, _ := l.client.(packagemanager.LevelLookup)
+	target := u.withinLevel(lookup, scheme, name, constraint, latest)
+	if target == "" {
+		return "", nil
+	}
+	return u.installableVersion(l, name, current, target), nil
+}
+
+// withinLevel caps latest at the update level of a package. The current
+// version is the lowest one constraint accepts; if latest is beyond the
+// level from there, the highest version within it comes from lookup. It
+// returns "" if there is no such version, and latest if the package is not
+// capped or its current version is unknown.
+func (u *Updater) withinLevel(lookup packagemanager.LevelLookup, scheme versioning.Scheme, name, constraint, latest string) string {
+	level, ok := u.config.LevelFor(name)
+	if !ok || level == versioning.Major {
+		return latest
+	}
+	current := versioning.Floor(scheme, constraint)
+	if current == "" {
+		return latest
+	}
+	from, err := scheme.Parse(current)
+	if err != nil {
+		return latest
+	}
+	to, err := scheme.Parse(latest)
+	if err != nil || scheme.Compare(to, from) <= 0 {
+		return latest
+	}
+	change := versioning.Change(from, to)
+	if change <= level {
+		return latest
+	}
+
+	var highest string
+	if lookup != nil {
+		highest, err = lookup.GetHighestVersion(name, current, level)
+		if err != nil {
+			utils.Debug("update", "Error getting highest %s update of %s: %v", level, name, err)
+			highest = ""
+		}
+	}
+	if highest == "" {
+		utils.Info("update", "Keeping %s at %s: latest version %s is a %s update, beyond the %s level", name, current, latest, change, level)
+		return ""
+	}
+	utils.Debug("update", "Capping %s at %s: latest version %s is a %s update, beyond the %s level", name, highest, latest, change, level)
+	return highest
+}
+
+// GetHighestVersion returns the highest version of an npm package newer
+// than current within level, from the cached metadata of the lookup.
+func (l *npmLookup) GetHighestVersion(name, current string, level versioning.Level) (string, error) {
+	packument := l.packument(name)
+	if packument == nil {
+		return "", nil
+	}
+	return packument.HighestWithin(current, level)
+}
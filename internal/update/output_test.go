@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/rvben/ru/internal/packagemanager/pypi"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // MockOutputPyPI implements the PackageManager interface for testing
@@ -82,6 +83,7 @@ func TestOutputMessageFormat(t *testing.T) {
 				modulesUpdated: tt.modulesUpdated,
 				paths:          []string{"."},
 				verify:         false,
+				python:         versioning.PEP440,
 			}
 
 			// Mock implementation directly in this test
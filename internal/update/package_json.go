@@ -0,0 +1,749 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rvben/ru/internal/packagemanager/npm"
+	"github.com/rvben/ru/internal/packagemanager/packagejson"
+	"github.com/rvben/ru/internal/packagemanager/workspace"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// sectionKind describes how the entries of a package.json section are laid out
+type sectionKind int
+
+const (
+	// sectionPlain maps package names to ranges
+	sectionPlain sectionKind = iota
+	// sectionPeer maps package names to ranges that are widened, not replaced
+	sectionPeer
+	// sectionOverrides is npm's overrides: keys may carry a version selector
+	// and values may be nested objects, where "." refers to the parent package
+	sectionOverrides
+	// sectionResolutions is yarn's resolutions: keys are dependency paths
+	// such as "**/foo" or "a/@scope/b"
+	sectionResolutions
+	// sectionPnpmOverrides is pnpm's overrides: keys may select a parent
+	// with "parent>child"
+	sectionPnpmOverrides
+)
+
+// packageJSONSection is a dependency section of package.json
+type packageJSONSection struct {
+	path []string
+	kind sectionKind
+}
+
+// name returns the section name used in output, e.g. "pnpm.overrides"
+func (s packageJSONSection) name() string {
+	return strings.Join(s.path, ".")
+}
+
+// packageJSONSections lists every section that is updated, in output order
+var packageJSONSections = []packageJSONSection{
+	{path: []string{"dependencies"}, kind: sectionPlain},
+	{path: []string{"devDependencies"}, kind: sectionPlain},
+	{path: []string{"optionalDependencies"}, kind: sectionPlain},
+	{path: []string{"peerDependencies"}, kind: sectionPeer},
+	{path: []string{"overrides"}, kind: sectionOverrides},
+	{path: []string{"resolutions"}, kind: sectionResolutions},
+	{path: []string{"pnpm", "overrides"}, kind: sectionPnpmOverrides},
+}
+
+// packageJSONEntry is a single version spec found in package.json
+type packageJSONEntry struct {
+	section string
+	path    []string
+	name    string
+	spec    string
+	widen   bool
+}
+
+// collectPackageJSONEntries returns the version specs of all dependency
+// sections, in section and then source order.
+func collectPackageJSONEntries(doc *packagejson.Document) []packageJSONEntry {
+	var entries []packageJSONEntry
+	for _, section := range packageJSONSections {
+		obj := doc.Lookup(section.path...)
+		if obj == nil || obj.Kind != packagejson.KindObject {
+			continue
+		}
+		switch section.kind {
+		case sectionPlain, sectionPeer:
+			for _, m := range doc.StringMembers(section.path...) {
+				entries = append(entries, packageJSONEntry{
+					section: section.name(),
+					path:    appendPath(section.path, m.Key),
+					name:    m.Key,
+					spec:    m.Value.Str,
+					widen:   section.kind == sectionPeer,
+				})
+			}
+		case sectionOverrides:
+			collectOverrides(obj, section.path, "", section.name(), &entries)
+		case sectionResolutions, sectionPnpmOverrides:
+			for _, m := range obj.Members {
+				if m.Value.Kind != packagejson.KindString {
+					continue
+				}
+				name := resolutionPackageName(m.Key)
+				if section.kind == sectionPnpmOverrides {
+					name = pnpmOverridePackageName(m.Key)
+				}
+				entries = append(entries, packageJSONEntry{
+					section: section.name(),
+					path:    appendPath(section.path, m.Key),
+					name:    name,
+					spec:    m.Value.Str,
+				})
+			}
+		}
+	}
+	return entries
+}
+
+// collectOverrides walks an npm overrides object. Nested objects override
+// the dependencies of the package named by their key; their "." member
+// overrides that package itself.
+func collectOverrides(obj *packagejson.Value, path []string, parent, section string, entries *[]packageJSONEntry) {
+	for _, m := range obj.Members {
+		name := stripVersionSelector(m.Key)
+		if m.Key == "." {
+			name = parent
+		}
+		switch m.Value.Kind {
+		case packagejson.KindString:
+			if name == "" {
+				continue
+			}
+			*entries = append(*entries, packageJSONEntry{
+				section: section,
+				path:    appendPath(path, m.Key),
+				name:    name,
+				spec:    m.Value.Str,
+			})
+		case packagejson.KindObject:
+			collectOverrides(m.Value, appendPath(path, m.Key), name, section, entries)
+		}
+	}
+}
+
+// appendPath returns a new key path with key appended
+func appendPath(path []string, key string) []string {
+	return append(append([]string{}, path...), key)
+}
+
+// stripVersionSelector removes a version selector from an override key,
+// e.g. "foo@^1.0.0" -> "foo" and "@scope/foo@2" -> "@scope/foo"
+func stripVersionSelector(key string) string {
+	if i := strings.LastIndex(key, "@"); i > 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// resolutionPackageName returns the package a yarn resolution applies to:
+// the last package of the dependency path, e.g. "**/a/@scope/b@1" -> "@scope/b"
+func resolutionPackageName(key string) string {
+	segments := strings.Split(key, "/")
+	name := segments[len(segments)-1]
+	if len(segments) > 1 && strings.HasPrefix(segments[len(segments)-2], "@") {
+		name = segments[len(segments)-2] + "/" + name
+	}
+	return stripVersionSelector(name)
+}
+
+// pnpmOverridePackageName returns the package a pnpm override applies to,
+// e.g. "parent@1>child@^2" -> "child"
+func pnpmOverridePackageName(key string) string {
+	if i := strings.LastIndex(key, ">"); i >= 0 {
+		key = key[i+1:]
+	}
+	return stripVersionSelector(key)
+}
+
+// widenPeerRange extends a peer dependency range so it also accepts latest,
+// e.g. "^17.0.0" -> "^17.0.0 || ^18.0.0". The new alternative reuses the
+// operator of the last existing one (^ or ~). It reports false when the range
+// already accepts latest or cannot be parsed.
+func widenPeerRange(spec, latest string) (string, bool) {
+	version, err := versioning.NodeSemver.Parse(latest)
+	if err != nil {
+		return "", false
+	}
+	if accepted, err := versioning.NodeSemver.Satisfies(version, spec); err != nil || accepted {
+		return "", false
+	}
+
+	alternatives := strings.Split(spec, "||")
+	last := strings.TrimSpace(alternatives[len(alternatives)-1])
+
+	major, minor := majorMinor(version)
+	var floor string
+	switch {
+	case strings.HasPrefix(last, "~"):
+		floor = fmt.Sprintf("~%s.%s.0", major, minor)
+	case major == "0":
+		floor = fmt.Sprintf("^0.%s.0", minor)
+	default:
+		floor = fmt.Sprintf("^%s.0.0", major)
+	}
+	return spec + " || " + floor, true
+}
+
+// majorMinor returns the major and minor number of an npm version
+func majorMinor(v versioning.Version) (string, string) {
+	parts := strings.SplitN(v.String(), ".", 3)
+	return parts[0], parts[1]
+}
+
+// npmFloor returns the lowest version an npm range is written against, e.g.
+// 1.4.0 for "^1.4", or nil if it has none
+func npmFloor(spec string) versioning.Version {
+	v, err := versioning.NodeSemver.Parse(versioning.Floor(versioning.NodeSemver, spec))
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// npmSatisfies reports whether the npm range spec accepts v. An invalid
+// range accepts no version.
+func npmSatisfies(v versioning.Version, spec string) bool {
+	ok, err := versioning.NodeSemver.Satisfies(v, spec)
+	return err == nil && ok
+}
+
+// rewritableSpecRegex matches specs whose version can be replaced in place:
+// an optional ^, ~, >= or = operator followed by a (possibly partial) version
+var rewritableSpecRegex = regexp.MustCompile(`^(\^|~|>=|=)?v?(\d+(?:\.\d+){0,2}(?:-[0-9A-Za-z.-]+)?)$`)
+
+// npmLookup holds what resolving the entries of one package.json needs: the
+// client for its registries, the Node.js version it runs on, and the
+// metadata fetched so far, since the same package often appears in several
+// sections.
+type npmLookup struct {
+	file       string
+	client     *npm.NPM
+	node       *npm.NodeTarget
+	latest     map[string]string
+	packuments map[string]*npm.Packument
+}
+
+// latestVersion returns the version the "latest" tag of a package points to
+func (l *npmLookup) latestVersion(name string) (string, error) {
+	if version, ok := l.latest[name]; ok {
+		return version, nil
+	}
+	version, err := l.client.GetLatestVersion(name)
+	if err != nil {
+		return "", err
+	}
+	l.latest[name] = version
+	return version, nil
+}
+
+// packument returns the metadata of a package, or nil if it cannot be fetched
+func (l *npmLookup) packument(name string) *npm.Packument {
+	packument, ok := l.packuments[name]
+	if !ok {
+		var err error
+		packument, err = l.client.GetPackument(name)
+		if err != nil {
+			utils.Debug("update", "Error getting metadata for %s: %v", name, err)
+		}
+		l.packuments[name] = packument
+	}
+	return packument
+}
+
+// deprecation returns the deprecation message of a version of a package, or
+// "" if it is not deprecated or the package's metadata cannot be fetched
+func (l *npmLookup) deprecation(name, version string) string {
+	packument := l.packument(name)
+	if packument == nil {
+		return ""
+	}
+	if info := packument.Versions[version]; info != nil {
+		return info.Deprecated
+	}
+	return ""
+}
+
+// resolveNPMUpdate returns the spec a package.json entry should be changed
+// to, or false if it should be left alone.
+//
+// Exact versions are pins and move to the latest release. Ranges follow npm
+// semantics: by default a ^ or ~ range only moves to the highest published
+// version it already accepts (preferring the "latest" tag, like npm does),
+// so ^1.4.0 becomes ^1.9.2 rather than ^2.0.0. Leaving the range requires
+// u.latest. Peer dependency ranges are only ever widened, and only when
+// leaving the range is allowed. Complex ranges ("1.x", ">=1 <3", "a || b")
+// are kept as written. Deprecated versions and versions that do not run on
+// the project's Node.js version are passed over.
+func (u *Updater) resolveNPMUpdate(l *npmLookup, entry packageJSONEntry, latest string) (string, bool) {
+	if entry.widen {
+		if !u.latest {
+			return "", false
+		}
+		return widenPeerRange(entry.spec, latest)
+	}
+	if u.rangeStrategy != "" {
+		return u.resolveNPMRange(l, entry, latest)
+	}
+
+	m := rewritableSpecRegex.FindStringSubmatch(strings.TrimSpace(entry.spec))
+	if m == nil {
+		utils.Debug("update", "Keeping %s: %q is not a simple range", entry.name, entry.spec)
+		return "", false
+	}
+	op := m[1]
+	floor := npmFloor(entry.spec)
+
+	target := latest
+	inRange := (op == "^" || op == "~") && !u.latest
+	if inRange {
+		if latestVer, err := versioning.NodeSemver.Parse(latest); err != nil || !npmSatisfies(latestVer, entry.spec) {
+			// Pick the version npm would install for the range instead
+			target = ""
+			if packument := l.packument(entry.name); packument != nil {
+				target = packument.Wanted(entry.spec)
+			}
+			if target == "" {
+				utils.Info("update", "Keeping %s at %s: latest version %s is outside the range (use -latest to allow it)", entry.name, entry.spec, latest)
+				return "", false
+			}
+			utils.Debug("update", "%s: wanted %s, latest %s", entry.name, target, latest)
+		}
+	}
+
+	if l.node != nil {
+		if target = u.supportedVersion(l, entry, target, inRange); target == "" {
+			return "", false
+		}
+	}
+
+	// Versions other than the latest may be deprecated ones npm would still install
+	if target != latest && l.deprecation(entry.name, target) != "" {
+		utils.Info("update", "Keeping %s at %s: %s is deprecated", entry.name, entry.spec, target)
+		return "", false
+	}
+
+	// Never move the lower bound down unless allowed, e.g. when the latest tag points at an older line
+	targetVer, err := versioning.NodeSemver.Parse(target)
+	if err != nil || (floor != nil && (versioning.NodeSemver.Compare(targetVer, floor) == 0 || u.downgrades(versioning.NodeSemver, entry.name, floor.String(), target))) {
+		return "", false
+	}
+	return op + target, true
+}
+
+// resolveNPMRange rewrites the range of a dependency for the latest version
+// following the range strategy. Unlike the default update it handles any
+// valid range, not only a single comparison.
+func (u *Updater) resolveNPMRange(l *npmLookup, entry packageJSONEntry, latest string) (string, bool) {
+	spec := strings.TrimSpace(entry.spec)
+	if latestVer, err := versioning.NodeSemver.Parse(latest); err == nil {
+		if _, err := versioning.NodeSemver.Satisfies(latestVer, spec); err != nil {
+			utils.Debug("update", "Keeping %s: %v", entry.name, err)
+			return "", false
+		}
+	}
+
+	target := latest
+	if l.node != nil {
+		if target = u.supportedVersion(l, entry, target, false); target == "" {
+			return "", false
+		}
+	}
+	if target != latest && l.deprecation(entry.name, target) != "" {
+		utils.Info("update", "Keeping %s at %s: %s is deprecated", entry.name, entry.spec, target)
+		return "", false
+	}
+
+	// Never move the lower bound down unless allowed, e.g. when the latest tag points at an older line
+	targetVer, err := versioning.NodeSemver.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	if floor := npmFloor(spec); floor != nil && u.downgrades(versioning.NodeSemver, entry.name, floor.String(), target) {
+		return "", false
+	}
+
+	updated, err := versioning.NodeSemver.UpdateRange(spec, targetVer, u.rangeStrategy)
+	if err != nil {
+		utils.Info("update", "Keeping %s at %s: %v", entry.name, entry.spec, err)
+		return "", false
+	}
+	return updated, updated != spec
+}
+
+// supportedVersion returns target if it supports the project's Node.js
+// version. Otherwise it returns the highest older version that does, within
+// the entry's range if inRange is set, and records that the package was held
+// back.
+func (u *Updater) supportedVersion(l *npmLookup, entry packageJSONEntry, target string, inRange bool) string {
+	packument := l.packument(entry.name)
+	if packument == nil {
+		return target
+	}
+	info := packument.Versions[target]
+	if l.node.Supports(info) {
+		return target
+	}
+
+	rng := entry.spec
+	if !inRange {
+		rng = "<=" + target
+	}
+	supported := packument.WantedMatching(rng, l.node.Supports)
+	requires := info.Engines["node"]
+
+	if supported == "" {
+		utils.Info("update", "Keeping %s at %s: %s requires node %s, the project uses %s", entry.name, entry.spec, target, requires, l.node)
+	} else {
+		utils.Info("update", "Holding %s at %s: %s requires node %s, the project uses %s", entry.name, supported, target, requires, l.node)
+	}
+	u.recordHeldBack(l.file, "node", entry.name, target, fmt.Sprintf("requires node %s, project uses %s", requires, l.node))
+	return supported
+}
+
+// nodeTargetFor returns the Node.js version the package.json doc at
+// filePath runs on: its engines.node, else that of the workspace root,
+// else the version in a .nvmrc or .node-version file. It returns nil if
+// none is declared.
+func nodeTargetFor(filePath string, doc *packagejson.Document, ws *workspace.Workspace) *npm.NodeTarget {
+	docs := []*packagejson.Document{doc}
+	if ws != nil {
+		root := filepath.Join(ws.Root, "package.json")
+		if root != filePath {
+			if rootDoc, err := packagejson.ParseFile(root); err == nil {
+				docs = append(docs, rootDoc)
+			}
+		}
+	}
+	for _, d := range docs {
+		engines := d.Lookup("engines", "node")
+		if engines == nil || engines.Kind != packagejson.KindString {
+			continue
+		}
+		target, err := npm.NodeTargetFromEngines(engines.Str)
+		if err != nil {
+			utils.Warning("Ignoring engines.node of %s: %v", filePath, err)
+			break
+		}
+		return target
+	}
+
+	target, err := npm.FindNodeVersionFile(filepath.Dir(filePath))
+	if err != nil {
+		utils.Warning("%v", err)
+		return nil
+	}
+	return target
+}
+
+// npmClientFor returns an npm client configured for the project in dir.
+// Clients are cached per directory.
+func (u *Updater) npmClientFor(dir string) *npm.NPM {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if client, ok := u.npmClients[dir]; ok {
+		return client
+	}
+
+	client := u.npm
+	cfg, err := npm.LoadConfig(dir)
+	if err != nil {
+		utils.Warning("Error reading npm configuration for %s: %v", dir, err)
+	} else {
+		client = u.npm.WithConfig(cfg)
+	}
+	if u.npmClients == nil {
+		u.npmClients = make(map[string]*npm.NPM)
+	}
+	u.npmClients[dir] = client
+	return client
+}
+
+// packageJSONChange is a single version change in package.json
+type packageJSONChange struct {
+	section string
+	path    []string
+	name    string
+	from    string
+	to      string
+	// pairedWith is the package an @types package was kept in lockstep with
+	pairedWith string
+}
+
+// packageJSONPlan is an edited package.json that has not been written yet
+type packageJSONPlan struct {
+	path    string
+	doc     *packagejson.Document
+	changes []packageJSONChange
+}
+
+// set changes the spec at path and records the change. A second change of
+// the same spec updates the recorded change, keeping its original "from",
+// and drops it if the spec is back at "from".
+func (p *packageJSONPlan) set(section, name string, path []string, from, to string) error {
+	if err := p.doc.SetString(to, path...); err != nil {
+		return fmt.Errorf("error updating %s in %s: %w", name, p.path, err)
+	}
+	key := pathKey(path)
+	for i := range p.changes {
+		if pathKey(p.changes[i].path) == key {
+			if p.changes[i].from == to {
+				p.changes = append(p.changes[:i], p.changes[i+1:]...)
+			} else {
+				p.changes[i].to = to
+			}
+			return nil
+		}
+	}
+	p.changes = append(p.changes, packageJSONChange{section: section, path: path, name: name, from: from, to: to})
+	return nil
+}
+
+// pair marks the change at path, if any, as made for the package runtime,
+// so that the two are reported together
+func (p *packageJSONPlan) pair(path []string, runtime string) {
+	key := pathKey(path)
+	for i := range p.changes {
+		if pathKey(p.changes[i].path) == key {
+			p.changes[i].pairedWith = runtime
+		}
+	}
+}
+
+// reportOrder returns the changes in the order they are reported: each
+// paired change follows the change of the package it was paired with
+func (p *packageJSONPlan) reportOrder() []packageJSONChange {
+	changed := make(map[string]bool, len(p.changes))
+	for _, c := range p.changes {
+		changed[c.name] = true
+	}
+	ordered := make([]packageJSONChange, 0, len(p.changes))
+	for _, c := range p.changes {
+		if c.pairedWith != "" && changed[c.pairedWith] {
+			continue
+		}
+		ordered = append(ordered, c)
+		for _, paired := range p.changes {
+			if paired.pairedWith == c.name {
+				ordered = append(ordered, paired)
+			}
+		}
+	}
+	return ordered
+}
+
+// pathKey returns a map key for a key path in package.json
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+func (u *Updater) updatePackageJsonFile(filePath string) error {
+	plan, err := u.planPackageJSON(filePath, u.workspaceFor(filePath))
+	if err != nil {
+		return err
+	}
+	return u.applyPackageJSONPlan(plan)
+}
+
+// planPackageJSON works out the updates of a package.json file. Packages of
+// the workspace ws, if any, are resolved locally instead of on the registry.
+func (u *Updater) planPackageJSON(filePath string, ws *workspace.Workspace) (*packageJSONPlan, error) {
+	// Parse the package.json file, keeping its original formatting
+	doc, err := packagejson.ParseFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON in %s: %w", filePath, err)
+	}
+	plan := &packageJSONPlan{path: filePath, doc: doc}
+
+	// Registries and credentials come from the .npmrc files of the project
+	l := &npmLookup{
+		file:       filePath,
+		client:     u.npmClientFor(filepath.Dir(filePath)),
+		node:       nodeTargetFor(filePath, doc, ws),
+		latest:     make(map[string]string),
+		packuments: make(map[string]*npm.Packument),
+	}
+	if l.node != nil {
+		utils.Debug("update", "%s: only considering versions that support %s", filePath, l.node)
+	}
+
+	for _, entry := range collectPackageJSONEntries(doc) {
+		// References to other entries ("$foo") follow whatever they point to
+		if strings.HasPrefix(entry.spec, "$") {
+			continue
+		}
+		spec := npm.ParseSpec(entry.spec)
+
+		// Packages of the workspace are never looked up on the registry
+		if member := ws.Member(entry.name); member != nil && (spec.Type == npm.SpecRange || spec.Type == npm.SpecWorkspace) {
+			if spec.Type == npm.SpecWorkspace {
+				utils.Debug("update", "%s: %s is linked to the workspace package in %s", filePath, entry.name, member.Dir)
+				continue
+			}
+			if updated, ok := workspaceReference(entry, member.Version); ok && updated != entry.spec {
+				if err := plan.set(entry.section, entry.name, entry.path, entry.spec, updated); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		// Only registry specs can be updated; report everything else
+		if !spec.Registry() || spec.Tag != "" {
+			reason := spec.Type.String()
+			if spec.Tag != "" {
+				reason = "dist-tag " + spec.Tag
+			}
+			utils.Info("update", "Skipping %s in %s of %s: %s", entry.name, entry.section, filePath, reason)
+			u.recordSkipped(filePath, entry.name, entry.section, reason)
+			continue
+		}
+
+		// Aliases are updated against the package they point to
+		target := entry
+		target.spec = spec.Range
+		if spec.Type == npm.SpecAlias {
+			target.name = spec.Name
+		}
+
+		updatedVersion := u.registryUpdate(l, entry, target, spec)
+		if updatedVersion == "" || updatedVersion == entry.spec {
+			if installedSections[entry.section] {
+				u.checkDeprecated(l, target)
+			}
+			continue
+		}
+		// Only the version string literal is rewritten
+		if err := plan.set(entry.section, entry.name, entry.path, entry.spec, updatedVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := u.resolvePeers(l, plan, ws); err != nil {
+		return nil, err
+	}
+	if err := u.pairTypes(l, plan, ws); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// registryUpdate returns the spec a registry dependency should be changed
+// to, or "" if it should be left alone. target is the entry as looked up on
+// the registry, which differs from entry for aliases.
+func (u *Updater) registryUpdate(l *npmLookup, entry, target packageJSONEntry, spec npm.Spec) string {
+	// Get the latest version on the release channel of the current one
+	latestVersion, err := l.latestVersionFrom(target.name, versioning.Floor(versioning.NodeSemver, target.spec))
+	if err != nil {
+		utils.Debug("update", "Error getting latest version for %s: %v", target.name, err)
+		return ""
+	}
+	if latestVersion = u.withinLevel(l, versioning.NodeSemver, entry.name, target.spec, latestVersion); latestVersion == "" {
+		return ""
+	}
+
+	updatedRange, ok := u.resolveNPMUpdate(l, target, latestVersion)
+	if !ok {
+		return ""
+	}
+	return spec.WithRange(updatedRange)
+}
+
+// checkDeprecated records a dependency whose spec only admits deprecated
+// versions, such as a pin of a deprecated version. A spec that accepts the
+// latest version installs that, as it skips deprecated releases. A package
+// whose releases are all deprecated has no latest version, so the version
+// its spec installs is checked instead.
+func (u *Updater) checkDeprecated(l *npmLookup, entry packageJSONEntry) {
+	if latest, err := l.latestVersion(entry.name); err == nil {
+		if v, err := versioning.NodeSemver.Parse(latest); err == nil && npmSatisfies(v, entry.spec) {
+			return
+		}
+	}
+	packument := l.packument(entry.name)
+	if packument == nil {
+		return
+	}
+	wanted := packument.Wanted(entry.spec)
+	if message := l.deprecation(entry.name, wanted); message != "" {
+		utils.Info("update", "%s in %s stays on %s, which is deprecated: %s", entry.name, l.file, wanted, message)
+		u.recordWithdrawn(l.file, "deprecated", entry.name, wanted, message)
+	}
+}
+
+// workspaceReference returns the spec a reference to a workspace package
+// should have so that it points at the local version: the operator is kept
+// and the version replaced, e.g. "^1.0.0" -> "^1.3.0". Peer ranges are
+// widened instead. It reports false if the spec cannot be rewritten or the
+// local version is older than the one required.
+func workspaceReference(entry packageJSONEntry, localVersion string) (string, bool) {
+	local, err := versioning.NodeSemver.Parse(localVersion)
+	if err != nil {
+		return "", false
+	}
+	if entry.widen {
+		return widenPeerRange(entry.spec, localVersion)
+	}
+
+	m := rewritableSpecRegex.FindStringSubmatch(strings.TrimSpace(entry.spec))
+	if m == nil {
+		return "", false
+	}
+	if floor := npmFloor(entry.spec); floor != nil && versioning.NodeSemver.Compare(local, floor) < 0 {
+		utils.Warning("%s requires %s but the workspace has version %s", entry.name, entry.spec, localVersion)
+		return "", false
+	}
+	return m[1] + localVersion, true
+}
+
+// applyPackageJSONPlan writes a planned package.json, or reports what would
+// change in dry-run mode, and updates the statistics
+func (u *Updater) applyPackageJSONPlan(plan *packageJSONPlan) error {
+	// If no dependencies were updated, just return
+	if len(plan.changes) == 0 {
+		u.filesUnchanged++
+		return nil
+	}
+
+	// Write the updated JSON back to file
+	if !u.dryRun {
+		if err := os.WriteFile(plan.path, plan.doc.Bytes(), 0644); err != nil {
+			return fmt.Errorf("error writing to file %s: %w", plan.path, err)
+		}
+	} else {
+		// In dry run mode, just log what would be done
+		utils.Info("dry-run", "Would update file: %s", plan.path)
+		for _, c := range plan.reportOrder() {
+			utils.Info("dry-run", "  Would update %s in %s: %s -> %s%s", c.name, c.section, c.from, c.to, pairNote(c))
+		}
+	}
+
+	// Update statistics
+	for _, c := range plan.reportOrder() {
+		utils.Debug("update", "%s: updated %s in %s: %s -> %s%s", plan.path, c.name, c.section, c.from, c.to, pairNote(c))
+		u.recordSectionUpdate(c.section)
+	}
+	u.filesUpdated++
+	u.modulesUpdated += len(plan.changes)
+
+	return nil
+}
+
+// pairNote describes why a paired change was made, for output
+func pairNote(c packageJSONChange) string {
+	if c.pairedWith == "" {
+		return ""
+	}
+	return " (to match " + c.pairedWith + ")"
+}
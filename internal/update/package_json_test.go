@@ -3,117 +3,73 @@ package update
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/rvben/ru/internal/packagemanager/npm"
+	"github.com/rvben/ru/internal/versioning"
 )
 
-// testUpdatePackageJsonFile simulates the package.json update logic for testing
+// testUpdatePackageJsonFile runs the real package.json update logic against a
+// fake npm registry that answers with the versions from getLatestVersion
 func testUpdatePackageJsonFile(filePath string, getLatestVersion func(string) (string, error)) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
-	}
-
-	var packageJSON map[string]interface{}
-	if err := json.Unmarshal(content, &packageJSON); err != nil {
-		return fmt.Errorf("error parsing JSON in %s: %w", filePath, err)
-	}
-
-	dependencies, hasDependencies := packageJSON["dependencies"].(map[string]interface{})
-	if !hasDependencies {
-		dependencies = make(map[string]interface{})
-	}
-
-	devDependencies, hasDevDependencies := packageJSON["devDependencies"].(map[string]interface{})
-	if !hasDevDependencies {
-		devDependencies = make(map[string]interface{})
-	}
-
-	updatedDeps := make(map[string]string)
-	for name, version := range dependencies {
-		versionStr, ok := version.(string)
-		if !ok {
-			continue
-		}
-
-		if strings.Contains(versionStr, "git") {
-			continue
-		}
-
-		latestVersion, err := getLatestVersion(name)
-		if err != nil {
-			continue
-		}
-
-		prefix := ""
-		for _, p := range []string{"^", "~", ">=", "<=", ">", "<"} {
-			if strings.HasPrefix(versionStr, p) {
-				prefix = p
-				break
-			}
-		}
+	return testUpdatePackageJsonFileWith(filePath, getLatestVersion, nil, false)
+}
 
-		updatedVersion := prefix + latestVersion
-		if updatedVersion != versionStr {
-			updatedDeps[name] = updatedVersion
-			dependencies[name] = updatedVersion
-		}
-	}
+// testUpdatePackageJsonFileWith is testUpdatePackageJsonFile with a list of
+// published versions per package (defaulting to just the latest one) and
+// the -latest setting
+func testUpdatePackageJsonFileWith(filePath string, getLatestVersion func(string) (string, error), published map[string][]string, latest bool) error {
+	server := newTestNPMRegistry(getLatestVersion, published)
+	defer server.Close()
 
-	updatedDevDeps := make(map[string]string)
-	for name, version := range devDependencies {
-		versionStr, ok := version.(string)
-		if !ok {
-			continue
-		}
+	updater := &Updater{npm: newTestNPMClient(server)}
+	updater.SetLatest(latest)
+	return updater.updatePackageJsonFile(filePath)
+}
 
-		if strings.Contains(versionStr, "git") {
-			continue
-		}
+// newTestNPMRegistry starts a fake npm registry serving packuments
+func newTestNPMRegistry(getLatestVersion func(string) (string, error), published map[string][]string) *httptest.Server {
+	return newTestNPMRegistryWithMetadata(getLatestVersion, published, nil)
+}
 
-		latestVersion, err := getLatestVersion(name)
+// newTestNPMRegistryWithMetadata is newTestNPMRegistry with extra manifest
+// fields, such as engines, for some versions, keyed by "name@version"
+func newTestNPMRegistryWithMetadata(getLatestVersion func(string) (string, error), published map[string][]string, metadata map[string]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		version, err := getLatestVersion(name)
 		if err != nil {
-			continue
+			http.NotFound(w, r)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
 
-		prefix := ""
-		for _, p := range []string{"^", "~", ">=", "<=", ">", "<"} {
-			if strings.HasPrefix(versionStr, p) {
-				prefix = p
-				break
+		// Packument with the list of published versions
+		versions := make(map[string]map[string]interface{})
+		for _, v := range append([]string{version}, published[name]...) {
+			versions[v] = map[string]interface{}{"version": v}
+			for key, value := range metadata[name+"@"+v] {
+				versions[v][key] = value
 			}
 		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":      name,
+			"dist-tags": map[string]string{"latest": version},
+			"versions":  versions,
+		})
+	}))
+}
 
-		updatedVersion := prefix + latestVersion
-		if updatedVersion != versionStr {
-			updatedDevDeps[name] = updatedVersion
-			devDependencies[name] = updatedVersion
-		}
-	}
-
-	if len(updatedDeps) == 0 && len(updatedDevDeps) == 0 {
-		return nil
-	}
-
-	// This is the key logic we're testing: only set fields that originally existed or have updates
-	if hasDependencies || len(updatedDeps) > 0 {
-		packageJSON["dependencies"] = dependencies
-	}
-	if hasDevDependencies || len(updatedDevDeps) > 0 {
-		packageJSON["devDependencies"] = devDependencies
-	}
-
-	updatedJSON, err := json.MarshalIndent(packageJSON, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling JSON for %s: %w", filePath, err)
-	}
-
-	if err := os.WriteFile(filePath, updatedJSON, 0644); err != nil {
-		return fmt.Errorf("error writing to file %s: %w", filePath, err)
-	}
-
-	return nil
+// newTestNPMClient returns an npm client talking to a fake registry
+func newTestNPMClient(server *httptest.Server) *npm.NPM {
+	npmClient := npm.New()
+	npmClient.SetCustomIndexURL(server.URL)
+	return npmClient
 }
 
 func TestPackageJsonDevDependenciesHandling(t *testing.T) {
@@ -328,7 +284,7 @@ func TestPackageJsonVersionUpdates(t *testing.T) {
 }`
 
 	expectedVersions := map[string]string{
-		"express": "^5.1.0",   // Should preserve ^ prefix
+		"express": "^4.21.2",  // Should stay within the ^4 range
 		"lodash":  "~4.17.21", // Should preserve ~ prefix
 		"jest":    "29.7.0",   // Should update without prefix
 		"eslint":  ">=9.27.0", // Should preserve >= prefix
@@ -342,7 +298,8 @@ func TestPackageJsonVersionUpdates(t *testing.T) {
 	}
 
 	// Test the version update logic directly
-	err = testUpdatePackageJsonFile(packageJsonPath, func(pkg string) (string, error) {
+	published := map[string][]string{"express": {"4.17.1", "4.18.0", "4.21.2", "5.0.0", "5.1.0"}}
+	err = testUpdatePackageJsonFileWith(packageJsonPath, func(pkg string) (string, error) {
 		switch pkg {
 		case "express":
 			return "5.1.0", nil
@@ -355,7 +312,7 @@ func TestPackageJsonVersionUpdates(t *testing.T) {
 		default:
 			return "1.0.0", nil
 		}
-	})
+	}, published, false)
 	if err != nil {
 		t.Fatalf("updatePackageJsonFile() failed: %v", err)
 	}
@@ -409,3 +366,796 @@ func TestPackageJsonVersionUpdates(t *testing.T) {
 		}
 	}
 }
+
+func TestPackageJsonFormattingPreserved(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "tabs and unsorted keys",
+			input:    "{\n\t\"version\": \"1.0.0\",\n\t\"name\": \"app\",\n\t\"dependencies\": {\n\t\t\"zod\": \"^3.0.0\",\n\t\t\"axios\": \"^1.0.0\"\n\t}\n}\n",
+			expected: "{\n\t\"version\": \"1.0.0\",\n\t\"name\": \"app\",\n\t\"dependencies\": {\n\t\t\"zod\": \"^3.22.4\",\n\t\t\"axios\": \"^1.6.0\"\n\t}\n}\n",
+		},
+		{
+			name:     "four spaces with CRLF and no final newline",
+			input:    "{\r\n    \"devDependencies\": {\r\n        \"jest\": \"29.0.0\"\r\n    },\r\n    \"scripts\": {\"test\": \"jest && echo <done>\"}\r\n}",
+			expected: "{\r\n    \"devDependencies\": {\r\n        \"jest\": \"29.7.0\"\r\n    },\r\n    \"scripts\": {\"test\": \"jest && echo <done>\"}\r\n}",
+		},
+	}
+
+	versions := map[string]string{"zod": "3.22.4", "axios": "1.6.0", "jest": "29.7.0"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, "package.json")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write package.json: %v", err)
+			}
+			err := testUpdatePackageJsonFile(path, func(pkg string) (string, error) {
+				if v, ok := versions[pkg]; ok {
+					return v, nil
+				}
+				return "", fmt.Errorf("package %s not found", pkg)
+			})
+			if err != nil {
+				t.Fatalf("updatePackageJsonFile() failed: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read package.json: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("formatting not preserved\nwant: %q\ngot:  %q", tt.expected, string(got))
+			}
+		})
+	}
+}
+
+func TestPackageJsonAllSections(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "package.json")
+
+	input := `{
+  "dependencies": {"react": "^17.0.2"},
+  "devDependencies": {"jest": "~29.0.0"},
+  "optionalDependencies": {"fsevents": "2.3.2"},
+  "peerDependencies": {"react": "^17.0.0", "lodash": "^4.0.0"},
+  "overrides": {
+    "foo": "1.0.0",
+    "bar@^2": {".": "2.0.0", "baz": "^3.0.0"},
+    "jest": "$jest",
+    "@scope/qux": "~1.0.0"
+  },
+  "resolutions": {"**/left-pad": "1.1.0", "a/@scope/qux": "1.0.0"},
+  "pnpm": {"overrides": {"parent@1>foo": "^1.0.0"}}
+}
+`
+	expected := `{
+  "dependencies": {"react": "^18.2.0"},
+  "devDependencies": {"jest": "~29.7.0"},
+  "optionalDependencies": {"fsevents": "2.3.3"},
+  "peerDependencies": {"react": "^17.0.0 || ^18.0.0", "lodash": "^4.0.0"},
+  "overrides": {
+    "foo": "1.2.0",
+    "bar@^2": {".": "2.5.0", "baz": "^3.1.0"},
+    "jest": "$jest",
+    "@scope/qux": "~1.4.0"
+  },
+  "resolutions": {"**/left-pad": "1.3.0", "a/@scope/qux": "1.4.0"},
+  "pnpm": {"overrides": {"parent@1>foo": "^1.2.0"}}
+}
+`
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	versions := map[string]string{
+		"react":      "18.2.0",
+		"jest":       "29.7.0",
+		"fsevents":   "2.3.3",
+		"lodash":     "4.17.21",
+		"foo":        "1.2.0",
+		"bar":        "2.5.0",
+		"baz":        "3.1.0",
+		"@scope/qux": "1.4.0",
+		"left-pad":   "1.3.0",
+	}
+	// Allow leaving the declared ranges so every section has something to do
+	err := testUpdatePackageJsonFileWith(path, func(pkg string) (string, error) {
+		if v, ok := versions[pkg]; ok {
+			return v, nil
+		}
+		return "", fmt.Errorf("package %s not found", pkg)
+	}, nil, true)
+	if err != nil {
+		t.Fatalf("updatePackageJsonFile() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read package.json: %v", err)
+	}
+	if string(got) != expected {
+		t.Errorf("unexpected content\nwant: %s\ngot:  %s", expected, string(got))
+	}
+}
+
+func TestPackageJsonRangeSemantics(t *testing.T) {
+	tempDir := t.TempDir()
+
+	input := `{
+  "dependencies": {
+    "a": "^1.4.0",
+    "b": "~2.1.0",
+    "c": "^0.2.1",
+    "d": "^3.0.0",
+    "e": "1.x",
+    "f": ">=1.0.0 <3.0.0",
+    "g": "^1.0.0 || ^2.0.0",
+    "h": "^2.0.0",
+    "i": "2.0.0-beta.1",
+    "j": "3.0.0"
+  },
+  "peerDependencies": {"a": "^1.0.0"}
+}
+`
+	latestVersions := map[string]string{
+		"a": "2.0.0", "b": "2.2.0", "c": "0.3.0", "d": "4.0.0",
+		"e": "2.0.0", "f": "3.1.0", "g": "3.0.0", "h": "1.9.0",
+		"i": "1.9.0", "j": "2.5.0",
+	}
+	published := map[string][]string{
+		"a": {"1.4.0", "1.9.2", "2.0.0-rc.1", "2.0.0"},
+		"b": {"2.1.0", "2.1.7", "2.2.0"},
+		"c": {"0.2.1", "0.2.5", "0.3.0"},
+		"d": {"3.0.0"},
+		"h": {"1.9.0", "2.0.0"},
+		// i follows its prerelease channel; j is on a version newer than latest
+		"i": {"2.0.0-beta.1", "2.0.0-beta.2"},
+		"j": {"3.0.0"},
+	}
+
+	tests := []struct {
+		name           string
+		latest         bool
+		strategy       versioning.RangeStrategy
+		allowDowngrade bool
+		expected       map[string]string
+	}{
+		{
+			name: "stay in range",
+			expected: map[string]string{
+				"a": "^1.9.2", "b": "~2.1.7", "c": "^0.2.5", "d": "^3.0.0",
+				"e": "1.x", "f": ">=1.0.0 <3.0.0", "g": "^1.0.0 || ^2.0.0", "h": "^2.0.0",
+				"i": "2.0.0-beta.2", "j": "3.0.0",
+				"peer:a": "^1.0.0",
+			},
+		},
+		{
+			name:   "latest",
+			latest: true,
+			expected: map[string]string{
+				"a": "^2.0.0", "b": "~2.2.0", "c": "^0.3.0", "d": "^4.0.0",
+				"e": "1.x", "f": ">=1.0.0 <3.0.0", "g": "^1.0.0 || ^2.0.0", "h": "^2.0.0",
+				"i": "2.0.0-beta.2", "j": "3.0.0",
+				"peer:a": "^1.0.0 || ^2.0.0",
+			},
+		},
+		{
+			name:     "widen",
+			strategy: versioning.RangeWiden,
+			expected: map[string]string{
+				"a": "^1.4.0 || ^2.0.0", "b": "~2.1.0 || ~2.2.0", "c": "^0.2.1 || ^0.3.0", "d": "^3.0.0 || ^4.0.0",
+				"e": "1.x || 2.x", "f": ">=1.0.0 <4.0.0", "g": "^1.0.0 || ^2.0.0 || ^3.0.0", "h": "^2.0.0",
+				"i": "2.0.0-beta.2", "j": "3.0.0",
+				"peer:a": "^1.0.0",
+			},
+		},
+		{
+			name:     "replace",
+			strategy: versioning.RangeReplace,
+			expected: map[string]string{
+				"a": "^2.0.0", "b": "~2.2.0", "c": "^0.3.0", "d": "^4.0.0",
+				"e": "2.x", "f": ">=3.1.0 <4.0.0", "g": "^3.0.0", "h": "^2.0.0",
+				"i": "2.0.0-beta.2", "j": "3.0.0",
+				"peer:a": "^1.0.0",
+			},
+		},
+		{
+			name:     "pin",
+			strategy: versioning.RangePin,
+			expected: map[string]string{
+				"a": "2.0.0", "b": "2.2.0", "c": "0.3.0", "d": "4.0.0",
+				"e": "2.0.0", "f": "3.1.0", "g": "3.0.0", "h": "^2.0.0",
+				"i": "2.0.0-beta.2", "j": "3.0.0",
+			},
+		},
+		{
+			name:           "allow downgrade",
+			latest:         true,
+			allowDowngrade: true,
+			expected: map[string]string{
+				"a": "^2.0.0", "h": "^1.9.0", "i": "2.0.0-beta.2", "j": "2.5.0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, "package.json")
+			if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+				t.Fatalf("Failed to write package.json: %v", err)
+			}
+			server := newTestNPMRegistry(func(pkg string) (string, error) {
+				return latestVersions[pkg], nil
+			}, published)
+			defer server.Close()
+			updater := &Updater{npm: newTestNPMClient(server)}
+			updater.SetLatest(tt.latest)
+			updater.SetRangeStrategy(tt.strategy)
+			updater.SetAllowDowngrade(tt.allowDowngrade)
+			err := updater.updatePackageJsonFile(path)
+			if err != nil {
+				t.Fatalf("updatePackageJsonFile() failed: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read package.json: %v", err)
+			}
+			var pkg struct {
+				Dependencies     map[string]string `json:"dependencies"`
+				PeerDependencies map[string]string `json:"peerDependencies"`
+			}
+			if err := json.Unmarshal(content, &pkg); err != nil {
+				t.Fatalf("Failed to parse package.json: %v", err)
+			}
+			for name, want := range tt.expected {
+				got := pkg.Dependencies[name]
+				if peer := strings.TrimPrefix(name, "peer:"); peer != name {
+					got = pkg.PeerDependencies[peer]
+				}
+				if got != want {
+					t.Errorf("%s: expected %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWidenPeerRange(t *testing.T) {
+	tests := []struct {
+		spec     string
+		latest   string
+		expected string
+		widened  bool
+	}{
+		{"^17.0.0", "18.2.0", "^17.0.0 || ^18.0.0", true},
+		{"^16.8.0 || ^17.0.0", "18.2.0", "^16.8.0 || ^17.0.0 || ^18.0.0", true},
+		{"~1.2.0", "1.3.1", "~1.2.0 || ~1.3.0", true},
+		{"^0.3.0", "0.4.2", "^0.3.0 || ^0.4.0", true},
+		{"^17.0.0", "17.0.2", "", false},
+		{">=16", "18.2.0", "", false},
+		{"*", "18.2.0", "", false},
+		{"workspace:*", "18.2.0", "", false},
+	}
+
+	for _, tt := range tests {
+		got, widened := widenPeerRange(tt.spec, tt.latest)
+		if widened != tt.widened || got != tt.expected {
+			t.Errorf("widenPeerRange(%q, %q) = %q, %v; want %q, %v", tt.spec, tt.latest, got, widened, tt.expected, tt.widened)
+		}
+	}
+}
+
+func TestOverridePackageNames(t *testing.T) {
+	tests := []struct {
+		fn       func(string) string
+		key      string
+		expected string
+	}{
+		{stripVersionSelector, "foo", "foo"},
+		{stripVersionSelector, "foo@^1.0.0", "foo"},
+		{stripVersionSelector, "@scope/foo", "@scope/foo"},
+		{stripVersionSelector, "@scope/foo@2", "@scope/foo"},
+		{resolutionPackageName, "left-pad", "left-pad"},
+		{resolutionPackageName, "**/left-pad", "left-pad"},
+		{resolutionPackageName, "a/b/c@1.0.0", "c"},
+		{resolutionPackageName, "**/@scope/pkg", "@scope/pkg"},
+		{pnpmOverridePackageName, "foo", "foo"},
+		{pnpmOverridePackageName, "parent@1>child@^2", "child"},
+		{pnpmOverridePackageName, "@a/parent>@b/child", "@b/child"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.fn(tt.key); got != tt.expected {
+			t.Errorf("package name for %q = %q, want %q", tt.key, got, tt.expected)
+		}
+	}
+}
+
+func TestSectionSummary(t *testing.T) {
+	u := &Updater{}
+	if got := u.sectionSummary(); got != "" {
+		t.Errorf("expected empty summary, got %q", got)
+	}
+	u.recordSectionUpdate("peerDependencies")
+	u.recordSectionUpdate("dependencies")
+	u.recordSectionUpdate("dependencies")
+	if got, want := u.sectionSummary(), " (2 in dependencies, 1 in peerDependencies)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPackageJsonSpecTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "package.json")
+	input := `{
+  "dependencies": {
+    "left": "npm:string-width@^4.1.0",
+    "scoped": "npm:@scope/real@~1.0.0",
+    "local": "file:../lib",
+    "linked": "link:../other",
+    "shared": "workspace:*",
+    "fork": "github:user/repo#main",
+    "tarball": "https://example.com/pkg-1.0.0.tgz",
+    "canary": "next",
+    "plain": "^1.0.0"
+  }
+}
+`
+	expected := `{
+  "dependencies": {
+    "left": "npm:string-width@^4.2.3",
+    "scoped": "npm:@scope/real@~1.0.5",
+    "local": "file:../lib",
+    "linked": "link:../other",
+    "shared": "workspace:*",
+    "fork": "github:user/repo#main",
+    "tarball": "https://example.com/pkg-1.0.0.tgz",
+    "canary": "next",
+    "plain": "^1.1.0"
+  }
+}
+`
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	versions := map[string]string{"string-width": "4.2.3", "@scope/real": "1.0.5", "plain": "1.1.0"}
+	var requested []string
+	server := newTestNPMRegistry(func(pkg string) (string, error) {
+		requested = append(requested, pkg)
+		if v, ok := versions[pkg]; ok {
+			return v, nil
+		}
+		return "", fmt.Errorf("package %s not found", pkg)
+	}, nil)
+	defer server.Close()
+
+	updater := &Updater{npm: newTestNPMClient(server)}
+	if err := updater.updatePackageJsonFile(path); err != nil {
+		t.Fatalf("updatePackageJsonFile() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read package.json: %v", err)
+	}
+	if string(got) != expected {
+		t.Errorf("unexpected content\nwant: %s\ngot:  %s", expected, string(got))
+	}
+
+	for _, name := range requested {
+		if _, ok := versions[name]; !ok {
+			t.Errorf("unexpected registry lookup for %s", name)
+		}
+	}
+
+	summary := updater.skippedSummary()
+	for _, want := range []string{
+		"Skipped 6 npm dependencies:",
+		"local in dependencies (local path)",
+		"linked in dependencies (link)",
+		"shared in dependencies (workspace dependency)",
+		"fork in dependencies (git dependency)",
+		"tarball in dependencies (tarball URL)",
+		"canary in dependencies (dist-tag next)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected skipped summary to contain %q, got:\n%s", want, summary)
+		}
+	}
+}
+
+func TestPackageJsonNpmrcScopedRegistry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("NPM_CONFIG_USERCONFIG", filepath.Join(dir, "missing-userrc"))
+	t.Setenv("NPM_CONFIG_GLOBALCONFIG", filepath.Join(dir, "missing-globalrc"))
+	t.Setenv("NPM_CONFIG_REGISTRY", "")
+	t.Setenv("ACME_TOKEN", "acme-token")
+
+	public := newTestNPMRegistry(func(pkg string) (string, error) {
+		if pkg == "lodash" {
+			return "4.17.21", nil
+		}
+		return "", fmt.Errorf("package %s not found", pkg)
+	}, nil)
+	defer public.Close()
+
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer acme-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.EscapedPath() != "/@acme%2fwidgets" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"name": "@acme/widgets", "dist-tags": {"latest": "2.1.0"}, "versions": {"2.1.0": {"version": "2.1.0"}}}`)
+	}))
+	defer private.Close()
+
+	npmrc := "registry=" + public.URL + "\n" +
+		"@acme:registry=" + private.URL + "\n" +
+		strings.TrimPrefix(private.URL, "http:") + "/:_authToken=${ACME_TOKEN}\n"
+	if err := os.WriteFile(filepath.Join(dir, ".npmrc"), []byte(npmrc), 0644); err != nil {
+		t.Fatalf("Failed to write .npmrc: %v", err)
+	}
+	path := filepath.Join(dir, "package.json")
+	input := `{"dependencies": {"@acme/widgets": "2.0.0", "lodash": "4.17.20"}}`
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	updater := &Updater{npm: npm.New()}
+	if err := updater.updatePackageJsonFile(path); err != nil {
+		t.Fatalf("updatePackageJsonFile() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read package.json: %v", err)
+	}
+	if want := `{"dependencies": {"@acme/widgets": "2.1.0", "lodash": "4.17.21"}}`; string(got) != want {
+		t.Errorf("unexpected content\nwant: %s\ngot:  %s", want, string(got))
+	}
+}
+
+func TestPackageJsonNodeEngines(t *testing.T) {
+	latestVersions := map[string]string{"a": "1.6.0", "b": "5.0.0", "c": "2.1.0", "d": "1.1.0"}
+	published := map[string][]string{
+		"a": {"1.0.0", "1.5.0"},
+		"b": {"4.0.0", "4.2.0"},
+		"c": {"2.0.0"},
+		"d": {"1.0.0"},
+	}
+	metadata := make(map[string]map[string]interface{})
+	for version, node := range map[string]string{
+		"a@1.5.0": ">=14",
+		"a@1.6.0": ">=20",
+		"b@4.2.0": ">=16",
+		"b@5.0.0": ">=20",
+		"c@2.1.0": "^18.17.0 || >=20",
+	} {
+		metadata[version] = map[string]interface{}{"engines": map[string]string{"node": node}}
+	}
+	server := newTestNPMRegistryWithMetadata(func(pkg string) (string, error) {
+		return latestVersions[pkg], nil
+	}, published, metadata)
+	defer server.Close()
+
+	dependencies := `"dependencies": {"a": "^1.0.0", "b": "4.0.0", "c": "^2.0.0", "d": "^1.0.0"}`
+	tests := []struct {
+		name     string
+		input    string
+		nvmrc    string
+		expected map[string]string
+		heldBack int
+	}{
+		{
+			// The lowest declared version, 18.0.0, must be supported
+			name:     "engines.node",
+			input:    `{"engines": {"node": ">=18"}, ` + dependencies + `}`,
+			expected: map[string]string{"a": "^1.5.0", "b": "4.2.0", "c": "^2.0.0", "d": "^1.1.0"},
+			heldBack: 3,
+		},
+		{
+			// Any 18.x release will do
+			name:     ".nvmrc",
+			input:    `{` + dependencies + `}`,
+			nvmrc:    "v18\n",
+			expected: map[string]string{"a": "^1.5.0", "b": "4.2.0", "c": "^2.1.0", "d": "^1.1.0"},
+			heldBack: 2,
+		},
+		{
+			name:     "no node version",
+			input:    `{` + dependencies + `}`,
+			expected: map[string]string{"a": "^1.6.0", "b": "5.0.0", "c": "^2.1.0", "d": "^1.1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "package.json")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write package.json: %v", err)
+			}
+			if tt.nvmrc != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".nvmrc"), []byte(tt.nvmrc), 0644); err != nil {
+					t.Fatalf("Failed to write .nvmrc: %v", err)
+				}
+			}
+
+			updater := &Updater{npm: newTestNPMClient(server)}
+			if err := updater.updatePackageJsonFile(path); err != nil {
+				t.Fatalf("updatePackageJsonFile() failed: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read package.json: %v", err)
+			}
+			var pkg struct {
+				Dependencies map[string]string `json:"dependencies"`
+			}
+			if err := json.Unmarshal(content, &pkg); err != nil {
+				t.Fatalf("Failed to parse package.json: %v", err)
+			}
+			for name, want := range tt.expected {
+				if got := pkg.Dependencies[name]; got != want {
+					t.Errorf("%s: expected %q, got %q", name, want, got)
+				}
+			}
+			if len(updater.heldBack) != tt.heldBack {
+				t.Errorf("expected %d held back packages, got %d:\n%s", tt.heldBack, len(updater.heldBack), updater.heldBackSummary())
+			}
+		})
+	}
+}
+
+func TestPackageJsonDeprecatedVersions(t *testing.T) {
+	latestVersions := map[string]string{"left-pad": "1.3.0", "request": "2.88.2", "lodash": "4.17.21"}
+	published := map[string][]string{
+		"left-pad": {"1.1.0", "1.2.0"},
+		"request":  {"2.88.0"},
+		"lodash":   {"4.17.0", "4.17.20"},
+	}
+	metadata := map[string]map[string]interface{}{
+		"left-pad@1.3.0": {"deprecated": "use String.prototype.padStart()"},
+		"request@2.88.0": {"deprecated": "request has been deprecated"},
+		"request@2.88.2": {"deprecated": "request has been deprecated"},
+		"lodash@4.17.20": {"deprecated": "prototype pollution"},
+	}
+	server := newTestNPMRegistryWithMetadata(func(pkg string) (string, error) {
+		return latestVersions[pkg], nil
+	}, published, metadata)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "package.json")
+	input := `{"dependencies": {"left-pad": "1.1.0", "request": "2.88.0", "lodash": "^4.17.0"}, "peerDependencies": {"request": "2.88.0"}}`
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	updater := &Updater{npm: newTestNPMClient(server)}
+	if err := updater.updatePackageJsonFile(path); err != nil {
+		t.Fatalf("updatePackageJsonFile() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read package.json: %v", err)
+	}
+	var pkg struct {
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		t.Fatalf("Failed to parse package.json: %v", err)
+	}
+	// The deprecated latest version of left-pad is passed over, and every
+	// version of request is deprecated
+	expected := map[string]string{"left-pad": "1.2.0", "request": "2.88.0", "lodash": "^4.17.21"}
+	for name, want := range expected {
+		if got := pkg.Dependencies[name]; got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+
+	// Only the installed pin of request is reported
+	summary := updater.withdrawnSummary()
+	for _, want := range []string{
+		"Found 1 dependency on a yanked or deprecated version:",
+		"request 2.88.0 is deprecated (request has been deprecated)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected summary to contain %q, got:\n%s", want, summary)
+		}
+	}
+}
+
+func TestPackageJsonPeerDependencies(t *testing.T) {
+	latestVersions := map[string]string{
+		"react": "19.0.0", "react-dom": "19.0.0", "old-lib": "1.2.0", "lib": "1.0.0", "pinned": "2.0.0",
+	}
+	published := map[string][]string{
+		"react":     {"17.0.1", "17.0.2", "18.2.0", "18.3.1"},
+		"react-dom": {"18.2.0", "18.3.1"},
+		"old-lib":   {"1.0.0"},
+		"lib":       {"1.0.1"},
+		"pinned":    {"1.0.0"},
+	}
+	peers := map[string]map[string]string{
+		"react-dom@18.2.0": {"react": "^18.2.0"},
+		"react-dom@18.3.1": {"react": "^18.3.1"},
+		"react-dom@19.0.0": {"react": "^19.0.0"},
+		"old-lib@1.0.0":    {"react": "^17.0.0 || ^18.0.0"},
+		"old-lib@1.2.0":    {"react": "^18.0.0"},
+		// 1.0.1 is a fix that was not tagged latest
+		"lib@1.0.0":    {"react": "17.0.1"},
+		"lib@1.0.1":    {"react": "17.0.2"},
+		"pinned@1.0.0": {"other": "^1.0.0"},
+		"pinned@2.0.0": {"other": "^2.0.0"},
+	}
+	metadata := make(map[string]map[string]interface{})
+	for version, p := range peers {
+		metadata[version] = map[string]interface{}{"peerDependencies": p}
+	}
+	latestVersions["other"] = "3.0.0"
+	published["other"] = []string{"1.0.0", "2.0.0"}
+	latestVersions["legacy"] = "2.0.0"
+	metadata["legacy@2.0.0"] = map[string]interface{}{"peerDependencies": map[string]string{"react": "^17.0.0"}}
+
+	server := newTestNPMRegistryWithMetadata(func(pkg string) (string, error) {
+		return latestVersions[pkg], nil
+	}, published, metadata)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		input     string
+		latest    bool
+		expected  map[string]string
+		conflicts int
+	}{
+		{
+			// react 19 is held back by old-lib, which takes react-dom with it
+			name:     "hold back",
+			input:    `{"dependencies": {"react": "^18.2.0", "react-dom": "^18.2.0"}, "devDependencies": {"old-lib": "^1.0.0"}}`,
+			latest:   true,
+			expected: map[string]string{"react": "^18.3.1", "react-dom": "^18.3.1", "old-lib": "^1.2.0"},
+		},
+		{
+			// The newer react needs the untagged fix of lib
+			name:     "co-upgrade",
+			input:    `{"dependencies": {"react": "~17.0.1", "lib": "^1.0.0"}}`,
+			expected: map[string]string{"react": "~17.0.2", "lib": "^1.0.1"},
+		},
+		{
+			name:     "hold back pin",
+			input:    `{"dependencies": {"pinned": "1.0.0", "other": "1.0.0"}}`,
+			expected: map[string]string{"pinned": "2.0.0", "other": "2.0.0"},
+		},
+		{
+			// No version of legacy supports react 18 or later
+			name:      "unresolvable",
+			input:     `{"dependencies": {"react": "^18.2.0", "legacy": "2.0.0"}}`,
+			latest:    true,
+			expected:  map[string]string{"react": "^19.0.0", "legacy": "2.0.0"},
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "package.json")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write package.json: %v", err)
+			}
+
+			updater := &Updater{npm: newTestNPMClient(server)}
+			updater.SetLatest(tt.latest)
+			if err := updater.updatePackageJsonFile(path); err != nil {
+				t.Fatalf("updatePackageJsonFile() failed: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read package.json: %v", err)
+			}
+			var pkg struct {
+				Dependencies    map[string]string `json:"dependencies"`
+				DevDependencies map[string]string `json:"devDependencies"`
+			}
+			if err := json.Unmarshal(content, &pkg); err != nil {
+				t.Fatalf("Failed to parse package.json: %v", err)
+			}
+			for name, want := range tt.expected {
+				got, ok := pkg.Dependencies[name]
+				if !ok {
+					got = pkg.DevDependencies[name]
+				}
+				if got != want {
+					t.Errorf("%s: expected %q, got %q", name, want, got)
+				}
+			}
+			if len(updater.peerConflicts) != tt.conflicts {
+				t.Errorf("expected %d unresolved conflicts, got:\n%s", tt.conflicts, updater.peerConflictSummary())
+			}
+		})
+	}
+}
+
+func TestTypesRuntimeName(t *testing.T) {
+	tests := map[string]string{
+		"@types/lodash":       "lodash",
+		"@types/lodash.merge": "lodash.merge",
+		"@types/babel__core":  "@babel/core",
+		"@babel/core":         "",
+		"lodash":              "",
+	}
+	for input, want := range tests {
+		if got := typesRuntimeName(input); got != want {
+			t.Errorf("typesRuntimeName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestPackageJsonTypesLockstep(t *testing.T) {
+	latestVersions := map[string]string{
+		"foo": "2.5.1", "@types/foo": "3.0.0",
+		"@acme/widget": "1.3.0", "@types/acme__widget": "1.4.0",
+		"bar": "1.0.0", "@types/bar": "2.0.0",
+		"@types/node": "22.1.0",
+	}
+	published := map[string][]string{
+		"@types/foo":          {"2.1.3", "2.5.0", "2.5.4", "2.6.0-beta.1"},
+		"@types/acme__widget": {"1.2.1", "1.3.2"},
+		"@types/bar":          {"1.1.0"},
+	}
+	server := newTestNPMRegistry(func(pkg string) (string, error) {
+		return latestVersions[pkg], nil
+	}, published)
+	defer server.Close()
+
+	input := `{
+  "dependencies": {"foo": "^2.1.0", "@acme/widget": "^1.2.0", "bar": "^1.0.0"},
+  "devDependencies": {
+    "@types/foo": "^2.1.3",
+    "@types/acme__widget": "~1.2.1",
+    "@types/bar": "^1.1.0",
+    "@types/node": "^20.0.0"
+  }
+}
+`
+	path := filepath.Join(t.TempDir(), "package.json")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	updater := &Updater{npm: newTestNPMClient(server)}
+	updater.SetLatest(true)
+	plan, err := updater.planPackageJSON(path, nil)
+	if err != nil {
+		t.Fatalf("planPackageJSON() failed: %v", err)
+	}
+
+	var order []string
+	for _, c := range plan.reportOrder() {
+		order = append(order, c.name+" "+c.to)
+	}
+	expected := []string{
+		"foo ^2.5.1",
+		"@types/foo ^2.5.4",
+		"@acme/widget ^1.3.0",
+		"@types/acme__widget ~1.3.2",
+		// No 1.0 release of @types/bar exists, so it stays as written
+		"@types/node ^22.1.0",
+	}
+	if got := strings.Join(order, ", "); got != strings.Join(expected, ", ") {
+		t.Errorf("unexpected changes:\n got: %s\nwant: %s", got, strings.Join(expected, ", "))
+	}
+}
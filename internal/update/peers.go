@@ -0,0 +1,287 @@
+package update
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rvben/ru/internal/packagemanager/npm"
+	"github.com/rvben/ru/internal/packagemanager/workspace"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// peerEntry is a package.json entry of a package taking part in the peer check
+type peerEntry struct {
+	entry packageJSONEntry
+	// from is the spec as written, before the plan changed it
+	from string
+	op   string
+	// original is the version from starts at
+	original versioning.Version
+}
+
+// peerPackage is an installed dependency of a package.json. Its version is
+// the lower bound of its planned spec, which is what ru moves.
+type peerPackage struct {
+	name      string
+	entries   []peerEntry
+	packument *npm.Packument
+	// original is the version the spec as written starts at
+	original versioning.Version
+	// version is the version the planned spec starts at
+	version versioning.Version
+	// candidates are the versions the package may be moved to, highest first
+	candidates []versioning.Version
+}
+
+// changed reports whether the plan moves the package
+func (p *peerPackage) changed() bool {
+	return versioning.NodeSemver.Compare(p.version, p.original) != 0
+}
+
+// highest returns the highest candidate accepted by accept, or nil
+func (p *peerPackage) highest(accept func(versioning.Version) bool) versioning.Version {
+	for _, v := range p.candidates {
+		if accept(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// peerRange returns the range the package at version requires of peer, or
+// "" if it has no (valid) requirement
+func (p *peerPackage) peerRange(version versioning.Version, peer string) string {
+	info := p.packument.Versions[version.String()]
+	if info == nil {
+		return ""
+	}
+	required := info.PeerDependencies[peer]
+	// Only a range the scheme can check is a requirement
+	if _, err := versioning.NodeSemver.Satisfies(version, required); err != nil {
+		return ""
+	}
+	return required
+}
+
+// peerConflict is a package whose peer dependency range does not accept the
+// version of a sibling dependency
+type peerConflict struct {
+	pkg  *peerPackage
+	peer *peerPackage
+	rng  string
+}
+
+func (c *peerConflict) key() string {
+	return c.pkg.name + "@" + c.pkg.version.String() + ">" + c.peer.name + "@" + c.peer.version.String()
+}
+
+// resolvePeers makes the planned versions of the installed dependencies of
+// a package.json satisfy each other's peer dependencies. For each conflict
+// caused by the plan it tries, in order, to move the package requiring the
+// peer to a newer version that accepts it, to hold the peer back to a
+// version the package accepts, and to hold the package back to a version
+// that accepts the peer. Conflicts that remain are reported.
+func (u *Updater) resolvePeers(l *npmLookup, plan *packageJSONPlan, ws *workspace.Workspace) error {
+	if len(plan.changes) == 0 {
+		return nil
+	}
+
+	pkgs := u.peerPackages(l, plan, ws)
+	anyChanged := false
+	for _, p := range pkgs {
+		anyChanged = anyChanged || p.changed()
+	}
+	if !anyChanged {
+		return nil
+	}
+
+	byName := make(map[string]*peerPackage, len(pkgs))
+	for _, p := range pkgs {
+		byName[p.name] = p
+	}
+
+	// Resolving one conflict can cause another; stop if they keep coming
+	unresolved := make(map[string]bool)
+	for i := 0; i < 10*len(pkgs); i++ {
+		c := findPeerConflict(pkgs, byName, unresolved)
+		if c == nil {
+			break
+		}
+		if !resolvePeerConflict(l, c) {
+			unresolved[c.key()] = true
+			utils.Info("update", "Cannot resolve peer dependency conflict in %s: %s %s requires %s %s, but %s is at %s", l.file, c.pkg.name, c.pkg.version, c.peer.name, c.rng, c.peer.name, c.peer.version)
+			u.recordPeerConflict(l.file, c.pkg.name, c.pkg.version.String(), c.peer.name, c.rng, c.peer.version.String())
+		}
+	}
+
+	for _, p := range pkgs {
+		for _, e := range p.entries {
+			// Keep the operator the plan chose, e.g. none for a pinned range
+			op := rewritableSpecRegex.FindStringSubmatch(strings.TrimSpace(e.entry.spec))[1]
+			to := op + p.version.String()
+			if versioning.NodeSemver.Compare(p.version, e.original) == 0 {
+				to = e.from
+			}
+			if to == e.entry.spec {
+				continue
+			}
+			if err := plan.set(e.entry.section, e.entry.name, e.entry.path, e.from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// peerPackages returns the installed dependencies of a planned package.json
+// whose versions ru can move. Workspace packages and specs ru does not
+// rewrite are left out, as are packages whose metadata is not available.
+func (u *Updater) peerPackages(l *npmLookup, plan *packageJSONPlan, ws *workspace.Workspace) []*peerPackage {
+	from := make(map[string]string, len(plan.changes))
+	for _, c := range plan.changes {
+		from[pathKey(c.path)] = c.from
+	}
+
+	var pkgs []*peerPackage
+	byName := make(map[string]*peerPackage)
+	for _, entry := range collectPackageJSONEntries(plan.doc) {
+		if !installedSections[entry.section] || ws.Member(entry.name) != nil {
+			continue
+		}
+		written, ok := from[pathKey(entry.path)]
+		if !ok {
+			written = entry.spec
+		}
+		m := rewritableSpecRegex.FindStringSubmatch(strings.TrimSpace(written))
+		if m == nil || !rewritableSpecRegex.MatchString(strings.TrimSpace(entry.spec)) {
+			continue
+		}
+		original, planned := npmFloor(written), npmFloor(entry.spec)
+		if original == nil || planned == nil {
+			continue
+		}
+
+		e := peerEntry{entry: entry, from: written, op: m[1], original: original}
+		if p := byName[entry.name]; p != nil {
+			p.entries = append(p.entries, e)
+			continue
+		}
+		p := &peerPackage{
+			name:     entry.name,
+			entries:  []peerEntry{e},
+			original: e.original,
+			version:  planned,
+		}
+		if p.packument = l.packument(entry.name); p.packument == nil {
+			continue
+		}
+		p.candidates = u.peerCandidates(l, p, written, e.op)
+		byName[entry.name] = p
+		pkgs = append(pkgs, p)
+	}
+	return pkgs
+}
+
+// peerCandidates returns the versions a package may be moved to, highest
+// first: the same versions resolveNPMUpdate may pick, but not only the
+// newest. Deprecated versions, prereleases and versions beyond the package's
+// update level are left out unless they are already planned.
+func (u *Updater) peerCandidates(l *npmLookup, p *peerPackage, written, op string) []versioning.Version {
+	scheme := versioning.NodeSemver
+	inRange := (op == "^" || op == "~") && !u.latest && u.rangeStrategy == ""
+	upper := p.version
+	if latest, err := l.latestVersion(p.name); err == nil {
+		if v, err := scheme.Parse(latest); err == nil && scheme.Compare(v, upper) > 0 {
+			upper = v
+		}
+	}
+
+	var candidates []versioning.Version
+	versions := p.packument.SortedVersions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		v, err := scheme.Parse(versions[i])
+		if err != nil {
+			continue
+		}
+		if scheme.Compare(v, p.original) < 0 {
+			break
+		}
+		planned := scheme.Compare(v, p.version) == 0
+		if (inRange && !npmSatisfies(v, written)) || (!inRange && scheme.Compare(v, upper) > 0) {
+			continue
+		}
+		if level, ok := u.config.LevelFor(p.name); ok && !planned && versioning.Change(p.original, v) > level {
+			continue
+		}
+		info := p.packument.Versions[versions[i]]
+		if !planned && (scheme.IsPrerelease(v) || info.Deprecated != "" || !l.node.Supports(info)) {
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+	return candidates
+}
+
+// findPeerConflict returns the first conflict involving a package the plan
+// moves, skipping those already found to be unresolvable. Conflicts between
+// packages the plan leaves alone are not ru's to fix.
+func findPeerConflict(pkgs []*peerPackage, byName map[string]*peerPackage, unresolved map[string]bool) *peerConflict {
+	for _, p := range pkgs {
+		info := p.packument.Versions[p.version.String()]
+		if info == nil {
+			continue
+		}
+		peers := make([]string, 0, len(info.PeerDependencies))
+		for name := range info.PeerDependencies {
+			peers = append(peers, name)
+		}
+		sort.Strings(peers)
+
+		for _, name := range peers {
+			q := byName[name]
+			if q == nil || (!p.changed() && !q.changed()) {
+				continue
+			}
+			rng := p.peerRange(p.version, name)
+			if rng == "" || npmSatisfies(q.version, rng) {
+				continue
+			}
+			c := &peerConflict{pkg: p, peer: q, rng: rng}
+			if !unresolved[c.key()] {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// resolvePeerConflict moves one of the packages of a conflict, reporting
+// false if neither can be moved to resolve it
+func resolvePeerConflict(l *npmLookup, c *peerConflict) bool {
+	p, q := c.pkg, c.peer
+	compare := versioning.NodeSemver.Compare
+	accepts := func(v versioning.Version) bool {
+		rng := p.peerRange(v, q.name)
+		return rng == "" || npmSatisfies(q.version, rng)
+	}
+
+	if v := p.highest(func(v versioning.Version) bool { return compare(v, p.version) > 0 && accepts(v) }); v != nil {
+		utils.Info("update", "Also updating %s to %s in %s: %s %s requires %s %s", p.name, v, l.file, p.name, p.version, q.name, c.rng)
+		p.version = v
+		return true
+	}
+	if q.changed() {
+		if v := q.highest(func(v versioning.Version) bool { return compare(v, q.version) < 0 && npmSatisfies(v, c.rng) }); v != nil {
+			utils.Info("update", "Holding %s at %s in %s: %s %s requires %s %s", q.name, v, l.file, p.name, p.version, q.name, c.rng)
+			q.version = v
+			return true
+		}
+	}
+	if v := p.highest(func(v versioning.Version) bool { return compare(v, p.version) < 0 && accepts(v) }); v != nil {
+		utils.Info("update", "Holding %s at %s in %s: %s %s requires %s %s", p.name, v, l.file, p.name, p.version, q.name, c.rng)
+		p.version = v
+		return true
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rvben/ru/internal/packagemanager"
+	"github.com/rvben/ru/internal/packagemanager/pypi"
+	"github.com/rvben/ru/internal/pep440"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// releaseLister is implemented by Python package managers that know the
+// requires_python metadata of releases, such as *pypi.PyPI
+type releaseLister interface {
+	GetReleases(packageName string) ([]pypi.Release, error)
+}
+
+// versionLister is implemented by package managers that list every
+// published version of a package
+type versionLister interface {
+	GetVersions(packageName string) ([]string, error)
+}
+
+// pythonLookup holds what resolving the requirements of one Python file
+// needs: the client for its indexes and the Python version it runs on. It is
+// built once per file and not changed afterwards, so files processed in
+// parallel never see each other's indexes.
+type pythonLookup struct {
+	file   string
+	client packagemanager.PackageManager
+	python *pypi.PythonTarget
+}
+
+// pythonLookupFor returns the lookup for a Python file that configures the
+// given indexes, on top of those of the environment and pip.conf
+func (u *Updater) pythonLookupFor(filePath string, indexes pypi.IndexSet) *pythonLookup {
+	client := u.pypi
+	if base, ok := u.pypi.(*pypi.PyPI); ok {
+		client = base.WithIndexes(indexes)
+	}
+	return &pythonLookup{
+		file:   filePath,
+		client: client,
+		python: u.pythonTargetFor(filePath),
+	}
+}
+
+// SetPython sets the Python version every project runs on, overriding
+// requires-python and .python-version files.
+func (u *Updater) SetPython(target *pypi.PythonTarget) {
+	u.pythonVersion = target
+}
+
+// pythonTargetFor returns the Python version the project of a requirements
+// file or pyproject.toml runs on: the one set with SetPython, else the
+// requires-python of the closest pyproject.toml, else the version in a
+// .python-version file. It returns nil if none is declared.
+func (u *Updater) pythonTargetFor(filePath string) *pypi.PythonTarget {
+	if u.pythonVersion != nil {
+		return u.pythonVersion
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return nil
+	}
+	for current := dir; ; {
+		path := filepath.Join(current, "pyproject.toml")
+		if _, err := os.Stat(path); err == nil {
+			var doc struct {
+				Project struct {
+					RequiresPython string `toml:"requires-python"`
+				} `toml:"project"`
+			}
+			if _, err := toml.DecodeFile(path, &doc); err != nil {
+				utils.Debug("update", "Error reading requires-python of %s: %v", path, err)
+			} else if doc.Project.RequiresPython != "" {
+				target, err := pypi.PythonTargetFromRequiresPython(doc.Project.RequiresPython)
+				if err != nil {
+					utils.Warning("Ignoring requires-python of %s: %v", path, err)
+				} else {
+					return target
+				}
+			}
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	target, err := pypi.FindPythonVersionFile(dir)
+	if err != nil {
+		utils.Warning("%v", err)
+		return nil
+	}
+	return target
+}
+
+// SetRequireWheels makes Python packages only move to releases that ship a
+// compatible wheel for each of platforms, e.g. manylinux_2_17_x86_64.
+func (u *Updater) SetRequireWheels(platforms []string) {
+	u.wheelPlatforms = platforms
+}
+
+// SetIndexStrategy sets how Python packages are looked up across indexes,
+// overriding the index-strategy of projects
+func (u *Updater) SetIndexStrategy(strategy pypi.IndexStrategy) {
+	if client, ok := u.pypi.(*pypi.PyPI); ok {
+		client.SetIndexStrategy(strategy)
+	}
+}
+
+// SetPyPIFallback sets whether Python packages that custom indexes don't
+// have are looked up on PyPI, overriding projects whose indexes replace PyPI
+func (u *Updater) SetPyPIFallback(enabled bool) {
+	if client, ok := u.pypi.(*pypi.PyPI); ok {
+		client.SetPyPIFallback(enabled)
+	}
+}
+
+// releaseProblem returns why a release cannot be used in a project on
+// python: its kind, "python" or "wheels", and a description. It returns ""
+// if the release can be used.
+func (u *Updater) releaseProblem(python *pypi.PythonTarget, r pypi.Release) (string, string) {
+	if !python.Supports(r.RequiresPython) {
+		return "python", fmt.Sprintf("requires python %s, project uses %s", r.RequiresPython, python)
+	}
+	if len(u.wheelPlatforms) > 0 {
+		var version *pep440.Version
+		if python != nil {
+			version = python.Version
+		}
+		if missing := pypi.MissingWheels(r.Files, version, u.wheelPlatforms); len(missing) > 0 {
+			return "wheels", "has no wheel for " + strings.Join(missing, ", ")
+		}
+	}
+	return "", ""
+}
+
+// installableVersion returns target if the release can be used in the
+// project: it supports the project's Python version and ships the required
+// wheels. Otherwise it returns the highest older release newer than current
+// that can and is not yanked, or "" if there is none, and records that the
+// package was held back. Pre-releases are only considered if target is one.
+func (u *Updater) installableVersion(l *pythonLookup, name, current, target string) string {
+	python := l.python
+	lister, ok := l.client.(releaseLister)
+	if (python == nil && len(u.wheelPlatforms) == 0) || !ok {
+		return target
+	}
+	releases, err := lister.GetReleases(name)
+	if err != nil {
+		utils.Debug("update", "Error getting releases of %s: %v", name, err)
+		return target
+	}
+
+	scheme := versioning.PEP440
+	targetVersion, err := scheme.Parse(target)
+	if err != nil {
+		return target
+	}
+	currentVersion, _ := scheme.Parse(current)
+
+	var kind, reason, installable string
+	var installableVersion versioning.Version
+	listed := false
+	for _, r := range releases {
+		v, err := scheme.Parse(r.Version)
+		if err != nil {
+			continue
+		}
+		if scheme.Compare(v, targetVersion) == 0 {
+			listed = true
+			if kind, reason = u.releaseProblem(python, r); kind == "" {
+				return target
+			}
+			continue
+		}
+		switch {
+		case r.Yanked,
+			scheme.Compare(v, targetVersion) > 0,
+			currentVersion != nil && scheme.Compare(v, currentVersion) <= 0,
+			scheme.IsPrerelease(v) && !scheme.IsPrerelease(targetVersion),
+			installableVersion != nil && scheme.Compare(v, installableVersion) <= 0:
+			continue
+		}
+		if problem, _ := u.releaseProblem(python, r); problem == "" {
+			installable, installableVersion = r.Version, v
+		}
+	}
+	if !listed {
+		// The index does not list target's metadata
+		return target
+	}
+
+	if installable == "" {
+		utils.Info("update", "Keeping %s: %s %s", name, target, reason)
+	} else {
+		utils.Info("update", "Holding %s at %s: %s %s", name, installable, target, reason)
+	}
+	u.recordHeldBack(l.file, kind, name, target, reason)
+	return installable
+}
+
+// checkYanked records a package whose current version is a yanked release
+func (u *Updater) checkYanked(l *pythonLookup, name string, scheme versioning.Scheme, current string) {
+	lister, ok := l.client.(releaseLister)
+	if !ok {
+		return
+	}
+	currentVersion, err := scheme.Parse(current)
+	if err != nil {
+		return
+	}
+	releases, err := lister.GetReleases(name)
+	if err != nil {
+		utils.Debug("update", "Error getting releases of %s: %v", name, err)
+		return
+	}
+	for _, r := range releases {
+		if v, err := scheme.Parse(r.Version); err != nil || scheme.Compare(v, currentVersion) != 0 {
+			continue
+		}
+		if r.Yanked {
+			utils.Info("update", "%s stays on %s, which is yanked: %s", name, current, r.YankedReason)
+			u.recordWithdrawn(l.file, "yanked", name, current, r.YankedReason)
+		}
+		return
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/rvben/ru/internal/packagemanager/npm"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // MockRunPyPI implements the PackageManager interface for testing Run method
@@ -116,6 +117,7 @@ package3==1.0.0
 				modulesUpdated: 0,
 				paths:          []string{"."},
 				verify:         false,
+				python:         versioning.PEP440,
 			}
 
 			// Run the update
@@ -0,0 +1,108 @@
+package update
+
+import (
+	"strings"
+
+	"github.com/rvben/ru/internal/packagemanager/workspace"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// typesScope is the scope of the DefinitelyTyped packages
+const typesScope = "@types/"
+
+// typesRuntimeName returns the package a DefinitelyTyped package describes,
+// e.g. "@types/lodash" -> "lodash" and "@types/babel__core" -> "@babel/core".
+// It returns "" for other packages.
+func typesRuntimeName(name string) string {
+	if !strings.HasPrefix(name, typesScope) {
+		return ""
+	}
+	name = strings.TrimPrefix(name, typesScope)
+	if scope, pkg, ok := strings.Cut(name, "__"); ok && scope != "" && pkg != "" {
+		return "@" + scope + "/" + pkg
+	}
+	return name
+}
+
+// pairTypes keeps the @types packages of a planned package.json in lockstep
+// with the packages they describe. DefinitelyTyped versions follow the
+// major.minor of the library, so @types/x moves to the highest version
+// matching the major.minor of the version planned for x, rather than to its
+// own latest release. Packages without their runtime package in the file
+// keep their regular update.
+func (u *Updater) pairTypes(l *npmLookup, plan *packageJSONPlan, ws *workspace.Workspace) error {
+	entries := collectPackageJSONEntries(plan.doc)
+	runtimes := make(map[string]versioning.Version)
+	for _, entry := range entries {
+		if !installedSections[entry.section] || typesRuntimeName(entry.name) != "" {
+			continue
+		}
+		if floor := npmFloor(entry.spec); floor != nil {
+			if _, ok := runtimes[entry.name]; !ok {
+				runtimes[entry.name] = floor
+			}
+		}
+	}
+
+	from := make(map[string]string, len(plan.changes))
+	for _, c := range plan.changes {
+		from[pathKey(c.path)] = c.from
+	}
+
+	for _, entry := range entries {
+		runtime := typesRuntimeName(entry.name)
+		version, ok := runtimes[runtime]
+		if !installedSections[entry.section] || !ok || ws.Member(entry.name) != nil {
+			continue
+		}
+		written, ok := from[pathKey(entry.path)]
+		if !ok {
+			written = entry.spec
+		}
+		m := rewritableSpecRegex.FindStringSubmatch(strings.TrimSpace(written))
+		if m == nil {
+			continue
+		}
+		packument := l.packument(entry.name)
+		if packument == nil {
+			continue
+		}
+
+		// The highest release for the runtime's major.minor, never below the spec as written
+		scheme := versioning.NodeSemver
+		floor := npmFloor(written)
+		target := ""
+		versions := packument.SortedVersions()
+		for i := len(versions) - 1; i >= 0; i-- {
+			v, err := scheme.Parse(versions[i])
+			if err != nil {
+				continue
+			}
+			info := packument.Versions[versions[i]]
+			if versioning.Change(version, v) != versioning.Patch || scheme.IsPrerelease(v) || info.Deprecated != "" || !l.node.Supports(info) {
+				continue
+			}
+			if floor == nil || scheme.Compare(v, floor) >= 0 {
+				target = versions[i]
+			}
+			break
+		}
+
+		to := m[1] + target
+		if target == "" {
+			major, minor := majorMinor(version)
+			utils.Info("update", "Keeping %s at %s in %s: no release matches %s %s.%s", entry.name, written, l.file, runtime, major, minor)
+			to = written
+		} else if floor != nil && floor.String() == target {
+			to = written
+		}
+		if to != entry.spec {
+			if err := plan.set(entry.section, entry.name, entry.path, written, to); err != nil {
+				return err
+			}
+		}
+		plan.pair(entry.path, runtime)
+	}
+	return nil
+}
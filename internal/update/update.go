@@ -8,15 +8,19 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
-	"github.com/rvben/pyver"
+	"github.com/rvben/ru/internal/config"
 	"github.com/rvben/ru/internal/packagemanager"
 	"github.com/rvben/ru/internal/packagemanager/npm"
 	"github.com/rvben/ru/internal/packagemanager/pypi"
 	"github.com/rvben/ru/internal/packagemanager/pyproject"
+	"github.com/rvben/ru/internal/packagemanager/requirements"
+	"github.com/rvben/ru/internal/packagemanager/workspace"
 	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
 	ignore "github.com/sabhiram/go-gitignore"
 )
 
@@ -30,6 +34,97 @@ type Updater struct {
 	verify         bool
 	ignorer        *ignore.GitIgnore
 	dryRun         bool
+	// latest allows npm ranges to move outside their declared range
+	latest bool
+	// python is the version scheme of Python requirements
+	python versioning.Scheme
+	// rangeStrategy is how version ranges are rewritten for the latest
+	// version; empty leaves Python ranges alone and keeps npm ranges' shape
+	rangeStrategy versioning.RangeStrategy
+	// config is the configuration of the path being updated; its update
+	// levels cap how far packages move, nil updates every package to its
+	// latest version
+	config *config.Config
+	// configSet is set when config was given with SetConfig rather than
+	// read from each path
+	configSet bool
+	// level is the update level set on the command line, which overrides
+	// that of each configuration
+	level *versioning.Level
+	// allowDowngrade lets packages move to a lower version than the current one
+	allowDowngrade bool
+	// pythonVersion is the Python version set on the command line, which
+	// overrides that of each project
+	pythonVersion *pypi.PythonTarget
+	// wheelPlatforms are the platform tags every Python release updated to
+	// must ship a compatible wheel for; empty accepts any release
+	wheelPlatforms []string
+
+	// mu guards sectionsUpdated, which counts updated packages per
+	// package.json section for the summary, skipped, heldBack, withdrawn,
+	// peerConflicts and npmClients
+	mu              sync.Mutex
+	sectionsUpdated map[string]int
+	// npmClients caches the npm client configured for each project directory
+	npmClients map[string]*npm.NPM
+	// workspaces maps package.json files to the workspace they belong to
+	workspaces map[string]*workspace.Workspace
+	// alignWorkspaces keeps external npm dependencies at one version across
+	// the packages of a workspace
+	alignWorkspaces bool
+	// skipped lists package.json dependencies that cannot be updated
+	skipped []skippedDependency
+	// heldBack lists packages kept below their newest version because it
+	// does not support the project's Node.js or Python version
+	heldBack []heldBackDependency
+	// withdrawn lists packages left on a yanked or deprecated version
+	withdrawn []withdrawnDependency
+	// peerConflicts lists peer dependency conflicts ru could not resolve
+	peerConflicts []unresolvedPeer
+}
+
+// skippedDependency is a package.json dependency that was not updated
+// because its spec does not come from the registry
+type skippedDependency struct {
+	file    string
+	name    string
+	section string
+	reason  string
+}
+
+// heldBackDependency is a package whose newest version cannot be used in
+// the project, e.g. because it requires a newer Node.js or Python version
+type heldBackDependency struct {
+	file string
+	// kind is what the version does not support: "node", "python" or "wheels"
+	kind    string
+	name    string
+	version string
+	// reason explains why, e.g. "requires node >=20, project uses node 18 (.nvmrc)"
+	reason string
+}
+
+// withdrawnDependency is a package left on a version its registry
+// withdrew: a yanked PyPI release or a deprecated npm version
+type withdrawnDependency struct {
+	file string
+	// kind is "yanked" or "deprecated"
+	kind    string
+	name    string
+	version string
+	// reason is the registry's explanation, empty if it gives none
+	reason string
+}
+
+// unresolvedPeer is a package.json dependency whose peer dependency range
+// does not accept the version of a sibling dependency
+type unresolvedPeer struct {
+	file        string
+	name        string
+	version     string
+	peer        string
+	rng         string
+	peerVersion string
 }
 
 func New(noCache bool, verify bool, paths []string) *Updater {
@@ -41,6 +136,7 @@ func New(noCache bool, verify bool, paths []string) *Updater {
 		modulesUpdated: 0,
 		paths:          paths,
 		verify:         verify,
+		python:         versioning.PEP440,
 	}
 }
 
@@ -56,6 +152,9 @@ func (u *Updater) Run() error {
 		if err != nil {
 			return fmt.Errorf("failed to access path %s: %w", path, err)
 		}
+		if err := u.loadConfig(path, pathInfo.IsDir()); err != nil {
+			return err
+		}
 
 		// If it's a file, handle it directly
 		if !pathInfo.IsDir() {
@@ -107,17 +206,18 @@ func (u *Updater) Run() error {
 
 	// Print summary
 	if u.filesUpdated > 0 {
+		sections := u.sectionSummary()
 		if u.dryRun {
 			if u.filesUpdated == 1 {
-				fmt.Printf("Would update %d file with %d package%s upgraded\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated))
+				fmt.Printf("Would update %d file with %d package%s upgraded%s\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated), sections)
 			} else {
-				fmt.Printf("Would update %d files with %d package%s upgraded\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated))
+				fmt.Printf("Would update %d files with %d package%s upgraded%s\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated), sections)
 			}
 		} else {
 			if u.filesUpdated == 1 {
-				fmt.Printf("%d file updated with %d package%s upgraded\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated))
+				fmt.Printf("%d file updated with %d package%s upgraded%s\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated), sections)
 			} else {
-				fmt.Printf("%d files updated with %d package%s upgraded\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated))
+				fmt.Printf("%d files updated with %d package%s upgraded%s\n", u.filesUpdated, u.modulesUpdated, plural(u.modulesUpdated), sections)
 			}
 		}
 	} else {
@@ -127,6 +227,10 @@ func (u *Updater) Run() error {
 			fmt.Println("No updates were made. All packages are already at their latest versions.")
 		}
 	}
+	fmt.Print(u.skippedSummary())
+	fmt.Print(u.heldBackSummary())
+	fmt.Print(u.withdrawnSummary())
+	fmt.Print(u.peerConflictSummary())
 
 	return nil
 }
@@ -148,12 +252,17 @@ func (u *Updater) ProcessDirectory(dir string) error {
 	var errorsMu sync.Mutex
 	var errors []error
 
+	// Resolve -r/-c references up front so every requirements file is
+	// updated exactly once, with the constraints of every tree it belongs to
+	reqPaths, reqPlans, planErrors := u.planRequirementsFiles(requirementsFiles)
+	errors = append(errors, planErrors...)
+
 	// Process the requirements files
-	for _, reqFile := range requirementsFiles {
+	for _, reqFile := range reqPaths {
 		wg.Add(1)
 		go func(filePath string) {
 			defer wg.Done()
-			err := u.updateRequirementsFile(filePath)
+			err := u.updateRequirementsFileWithConstraints(filePath, reqPlans[filePath])
 			if err != nil {
 				errorsMu.Lock()
 				errors = append(errors, err)
@@ -162,8 +271,22 @@ func (u *Updater) ProcessDirectory(dir string) error {
 		}(reqFile)
 	}
 
+	// Workspaces are updated as a whole, their packages one after another
+	workspaces, standalonePackageJSONFiles := u.discoverWorkspaces(packageJSONFiles)
+	for ws, files := range workspaces {
+		wg.Add(1)
+		go func(ws *workspace.Workspace, files []string) {
+			defer wg.Done()
+			if err := u.updateWorkspace(ws, files); err != nil {
+				errorsMu.Lock()
+				errors = append(errors, err)
+				errorsMu.Unlock()
+			}
+		}(ws, files)
+	}
+
 	// Process package.json files
-	for _, packageJSONFile := range packageJSONFiles {
+	for _, packageJSONFile := range standalonePackageJSONFiles {
 		wg.Add(1)
 		go func(filePath string) {
 			defer wg.Done()
@@ -277,146 +400,162 @@ func (u *Updater) processFilesParallel(requirementsFiles, packageJSONFiles, pypr
 	return nil
 }
 
-type result struct {
-	line               string
-	updatedLine        string
-	err                error
-	lineNumber         int
-	packageName        string
-	versionConstraints string
+// requirementsPlan is what applies to a requirements file from the include
+// trees it belongs to
+type requirementsPlan struct {
+	// constraints are the specifiers of the constraints files, keyed by
+	// normalized package name
+	constraints map[string][]string
+	// indexes are the indexes the tree sets with --index-url and
+	// --extra-index-url
+	indexes pypi.IndexSet
 }
 
+// planRequirementsFiles loads the include tree of every root requirements
+// file. It returns each file to update once, in discovery order, together
+// with the constraints and indexes that apply to it. A file included from
+// several roots inherits the constraints of all of them and the indexes of
+// the first.
+func (u *Updater) planRequirementsFiles(roots []string) ([]string, map[string]*requirementsPlan, []error) {
+	var paths []string
+	var errs []error
+	plans := make(map[string]*requirementsPlan)
+
+	for _, root := range roots {
+		doc, err := requirements.Load(root)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: error loading requirements: %w", root, err))
+			continue
+		}
+
+		treeConstraints := doc.ConstraintSet()
+		treeIndexes := pypi.IndexSetFromRequirements(doc)
+		for _, file := range doc.Files() {
+			plan, ok := plans[file.Path]
+			if !ok {
+				paths = append(paths, file.Path)
+				plan = &requirementsPlan{constraints: make(map[string][]string), indexes: treeIndexes}
+				plans[file.Path] = plan
+			}
+			for name, specs := range treeConstraints {
+				plan.constraints[name] = append(plan.constraints[name], specs...)
+			}
+		}
+	}
+
+	return paths, plans, errs
+}
+
+// updateRequirementsFile updates a requirements file and every file it
+// includes, honoring the constraints files they reference.
 func (u *Updater) updateRequirementsFile(filePath string) error {
-	// Read the file
-	content, err := os.ReadFile(filePath)
+	paths, plans, errs := u.planRequirementsFiles([]string{filePath})
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	for _, path := range paths {
+		if err := u.updateRequirementsFileWithConstraints(path, plans[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRequirementsFileWithConstraints updates a single requirements file.
+// Packages are looked up on the indexes of plan and those listed in its
+// constraints are only moved to versions satisfying every specifier given
+// for them.
+func (u *Updater) updateRequirementsFileWithConstraints(filePath string, plan *requirementsPlan) error {
+	// Parse the file into a format-preserving line model
+	reqFile, err := requirements.ParseFile(filePath)
 	if err != nil {
 		return fmt.Errorf("%s: error reading file: %w", filePath, err)
 	}
 
-	// Set custom index URL if specified in requirements.txt
-	pypiPkg, ok := u.pypi.(*pypi.PyPI)
-	if ok {
-		pypiPkg.SetIndexURLFromRequirements(string(content))
+	// Look packages up on the indexes the include tree sets, if any
+	lookup := u.pythonLookupFor(filePath, plan.indexes)
+	constraints := plan.constraints
+
+	type change struct {
+		packageName string
+		from        string
+		to          string
 	}
+	var changes []change
 
-	lines := strings.Split(string(content), "\n")
-	results := make([]result, 0, len(lines))
-	versions := make(map[string]string)
+	for _, line := range reqFile.Requirements() {
+		packageName := line.Name
+		versionConstraints := line.Specifier
 
-	// First pass: get all package versions
-	for i, line := range lines {
-		// Parse the line
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
-			// Skip empty lines and comments
-			results = append(results, result{line: line, updatedLine: line, lineNumber: i})
-			continue
-		}
-
-		// Check for index URL in requirements.txt
-		if strings.HasPrefix(strings.TrimSpace(line), "-i ") || strings.HasPrefix(strings.TrimSpace(line), "--index-url ") {
-			// This is an index URL line, keep it as is
-			results = append(results, result{line: line, updatedLine: line, lineNumber: i})
+		// Get latest version within the package's update level
+		latestVersion, err := u.targetVersion(lookup, packageName, u.python, versionConstraints)
+		if err != nil {
+			utils.Debug("update", "Error getting latest version for %s: %v", packageName, err)
 			continue
 		}
-
-		// Check for extra index URL in requirements.txt
-		if strings.HasPrefix(strings.TrimSpace(line), "--extra-index-url ") {
-			// This is an extra index URL line, keep it as is
-			results = append(results, result{line: line, updatedLine: line, lineNumber: i})
+		if latestVersion == "" {
 			continue
 		}
 
-		// Extract package name and version
-		var packageName, versionConstraints string
-		lineTrim := strings.TrimSpace(line)
-		for _, prefix := range []string{"==", ">=", "<=", "!=", "~=", ">", "<", "==="} {
-			if idx := strings.Index(lineTrim, prefix); idx > 0 {
-				packageName = strings.TrimSpace(lineTrim[:idx])
-				versionConstraints = strings.TrimSpace(lineTrim[idx:])
-				break
-			}
-		}
-		if packageName == "" {
-			// If no version specifier, treat the whole line as the package name
-			packageName = lineTrim
-			versionConstraints = ""
-		}
-
-		if packageName == "" {
-			// If still no package name found, keep the line as is
-			results = append(results, result{line: line, updatedLine: line, lineNumber: i})
+		// Constraints files may hold the package back to an older release
+		target, spec := u.constrainedVersion(lookup, packageName, versioning.Floor(u.python, versionConstraints), latestVersion, constraints[line.NormalizedName()])
+		if target == "" {
+			utils.Info("update", "Keeping %s: latest version %s is excluded by constraint %s", packageName, latestVersion, spec)
 			continue
 		}
-
-		// Get latest version
-		latestVersion, err := u.pypi.GetLatestVersion(packageName)
-		if err != nil {
-			utils.Debug("update", "Error getting latest version for %s: %v", packageName, err)
-			// If there's an error, keep the original line
-			results = append(results, result{line: line, updatedLine: line, lineNumber: i, packageName: packageName, versionConstraints: versionConstraints})
-			continue
+		if target != latestVersion {
+			utils.Info("update", "Holding %s at %s: latest version %s is excluded by constraint %s", packageName, target, latestVersion, spec)
+			latestVersion = target
 		}
 
-		// Store for later verification
-		versions[packageName] = latestVersion
-
-		// Check if update is needed and allowed
 		if versionConstraints != "" {
-			// Always concretize wildcards for '=='
-			if strings.HasPrefix(versionConstraints, "==") && strings.Contains(strings.TrimPrefix(versionConstraints, "=="), "*") {
-				updatedLine, err := u.updateLine(line, packageName, versionConstraints, latestVersion)
+			currentVersion, pinned := line.Pinned()
+			if u.rangeStrategy != "" && (!pinned || strings.HasSuffix(currentVersion, ".*")) {
+				spec, err := versioning.UpdateRange(u.python, versionConstraints, latestVersion, u.rangeStrategy)
 				if err != nil {
-					return fmt.Errorf("%s:%d: %w", filePath, i+1, err)
+					utils.Info("update", "Keeping %s at %s: %v", packageName, versionConstraints, err)
+					continue
+				}
+				if spec == versionConstraints {
+					continue
+				}
+				if line.SetSpecifier(spec) {
+					if len(line.Hashes) > 0 {
+						utils.Warning("%s:%d: %s was updated but its --hash values must be regenerated", filePath, line.Number, packageName)
+					}
+					changes = append(changes, change{packageName: packageName, from: versionConstraints, to: spec})
 				}
-				results = append(results, result{line: line, updatedLine: updatedLine, lineNumber: i, packageName: packageName, versionConstraints: versionConstraints})
 				continue
 			}
-			isAllowed, err := u.checkVersionConstraints(latestVersion, versionConstraints)
-			if err != nil || !isAllowed {
-				// If update is not allowed, keep the original line
+			if !pinned {
+				// Ranges are left alone; only report whether the latest version fits
+				isAllowed, err := u.checkVersionConstraints(latestVersion, versionConstraints)
 				if err != nil {
 					utils.Debug("update", "Error checking constraints for %s: %v", packageName, err)
+				} else if !isAllowed {
+					utils.Debug("update", "Warning: Latest version %s for package %s is not within the specified range (%s)", latestVersion, packageName, versionConstraints)
 				} else {
-					utils.Debug("update", "Update not allowed for %s due to constraints", packageName)
+					utils.Debug("update", "Latest version is within the specified range: %s", latestVersion)
 				}
-				results = append(results, result{line: line, updatedLine: line, lineNumber: i, packageName: packageName, versionConstraints: versionConstraints})
+				continue
+			}
+			// Pins are moved to the latest version; wildcards are always concretized
+			if currentVersion == latestVersion {
 				continue
 			}
 		}
 
-		// Process the line for updating
-		updatedLine, err := u.updateLine(line, packageName, versionConstraints, latestVersion)
-		if err != nil {
-			return fmt.Errorf("%s:%d: %w", filePath, i+1, err)
-		}
-
-		// Only count as a result if the line was actually changed
-		if line != updatedLine {
-			results = append(results, result{line: line, updatedLine: updatedLine, lineNumber: i, packageName: packageName, versionConstraints: versionConstraints})
-		} else {
-			results = append(results, result{line: line, updatedLine: line, lineNumber: i, packageName: packageName, versionConstraints: versionConstraints})
-		}
-	}
-
-	// Count how many packages actually changed
-	changedPackages := 0
-	for _, r := range results {
-		if r.line != r.updatedLine {
-			changedPackages++
+		if line.SetVersion(latestVersion) {
+			if len(line.Hashes) > 0 {
+				utils.Warning("%s:%d: %s was updated but its --hash values must be regenerated", filePath, line.Number, packageName)
+			}
+			changes = append(changes, change{packageName: packageName, from: versionConstraints, to: latestVersion})
 		}
 	}
 
 	// If we have changes, update the file
-	if changedPackages > 0 {
-		updatedLines := make([]string, len(results))
-		for i, r := range results {
-			updatedLines[i] = r.updatedLine
-		}
-		// Remove any trailing empty lines
-		for len(updatedLines) > 0 && strings.TrimSpace(updatedLines[len(updatedLines)-1]) == "" {
-			updatedLines = updatedLines[:len(updatedLines)-1]
-		}
-		updatedContent := strings.Join(updatedLines, "\n") + "\n"
+	if len(changes) > 0 {
+		updatedContent := reqFile.String()
 
 		// Handle verification if needed
 		if u.verify {
@@ -436,15 +575,13 @@ func (u *Updater) updateRequirementsFile(filePath string) error {
 		} else {
 			// In dry run mode, just log what would be done
 			utils.Info("dry-run", "Would update file: %s", filePath)
-			for _, r := range results {
-				if r.line != r.updatedLine && r.packageName != "" {
-					utils.Info("dry-run", "  Would update %s: %s -> %s", r.packageName, r.versionConstraints, versions[r.packageName])
-				}
+			for _, c := range changes {
+				utils.Info("dry-run", "  Would update %s: %s -> %s", c.packageName, c.from, c.to)
 			}
 		}
 
 		u.filesUpdated++
-		u.modulesUpdated += changedPackages
+		u.modulesUpdated += len(changes)
 	} else {
 		u.filesUnchanged++
 	}
@@ -452,268 +589,95 @@ func (u *Updater) updateRequirementsFile(filePath string) error {
 	return nil
 }
 
-func (u *Updater) updateLine(line, packageName, versionConstraints, latestVersion string) (string, error) {
-	if versionConstraints != "" {
-		if strings.HasPrefix(versionConstraints, "==") {
-			currentVersion := strings.TrimPrefix(versionConstraints, "==")
-			// Always concretize wildcards
-			if strings.Contains(currentVersion, "*") {
-				return fmt.Sprintf("%s==%s", packageName, latestVersion), nil
-			}
-			if currentVersion != latestVersion {
-				return fmt.Sprintf("%s==%s", packageName, latestVersion), nil
-			}
-			return line, nil
-		}
-
-		ok, err := u.checkVersionConstraints(latestVersion, versionConstraints)
-		if err != nil {
-			return "", err
-		}
-		if !ok {
-			// If version doesn't match constraints, keep the original line
-			utils.Debug("update", "Warning: Latest version %s for package %s is not within the specified range (%s)", latestVersion, packageName, versionConstraints)
-			return line, nil
-		}
-		// If version matches constraints, keep using the constraints
-		utils.Debug("update", "Latest version is within the specified range: %s", latestVersion)
-		return line, nil
-	}
-	// No version constraints, always update to latest
-	return fmt.Sprintf("%s==%s", packageName, latestVersion), nil
-}
-
+// checkVersionConstraints reports whether latestVerStr is an update for a
+// requirement specifier: for a pin ("==1.0" or a bare "1.0") whether it is
+// newer, for a range whether the range accepts it.
 func (u *Updater) checkVersionConstraints(latestVerStr, versionConstraints string) (bool, error) {
-	// Fast path for simple equality constraints
-	if strings.HasPrefix(versionConstraints, "==") && versionConstraints[2:] == latestVerStr {
-		return false, nil
-	}
-
-	// Parse the latest version
-	latestVer, err := pyver.Parse(latestVerStr)
+	latestVer, err := u.python.Parse(latestVerStr)
 	if err != nil {
 		return false, fmt.Errorf("invalid latest version: %s", latestVerStr)
 	}
 
-	shouldUpdate := false
-
-	// Check for complex constraints with commas (e.g. ">=1.0.0,<2.0.0")
-	if strings.Contains(versionConstraints, ",") {
-		parts := strings.Split(versionConstraints, ",")
-
-		// Check if all parts of the constraint are satisfied
-		validForAll := true
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if !pyverCompatible(latestVer, part) {
-				validForAll = false
-				break
-			}
-		}
-
-		shouldUpdate = validForAll
-	} else if strings.HasPrefix(versionConstraints, "==") {
-		// Handle equality constraint
-		currentVersion := strings.TrimPrefix(versionConstraints, "==")
-		currentVer, err := pyver.Parse(currentVersion)
-		if err != nil {
-			return false, err
-		}
-		shouldUpdate = pyver.Compare(latestVer, currentVer) > 0
-	} else if strings.HasPrefix(versionConstraints, ">=") ||
-		strings.HasPrefix(versionConstraints, ">") ||
-		strings.HasPrefix(versionConstraints, "<=") ||
-		strings.HasPrefix(versionConstraints, "<") ||
-		strings.HasPrefix(versionConstraints, "~=") ||
-		strings.HasPrefix(versionConstraints, "^") {
-		// Handle other constraints
-		shouldUpdate = pyverCompatible(latestVer, versionConstraints)
-	} else {
-		// Default to simple version comparison
-		currentVer, err := pyver.Parse(versionConstraints)
-		if err != nil {
-			return false, err
-		}
-		shouldUpdate = pyver.Compare(latestVer, currentVer) > 0
+	versionConstraints = strings.TrimSpace(versionConstraints)
+	if pinned, ok := strings.CutPrefix(versionConstraints, "=="); ok && !strings.Contains(pinned, ",") && !strings.HasSuffix(pinned, ".*") {
+		versionConstraints = pinned
 	}
-
-	return shouldUpdate, nil
-}
-
-// pyverCompatible checks if a version satisfies a constraint (basic support for ==, >=, >, <=, <, ~=)
-func pyverCompatible(ver pyver.Version, constraint string) bool {
-	constraint = strings.TrimSpace(constraint)
-	if strings.HasPrefix(constraint, "==") {
-		cver, err := pyver.Parse(strings.TrimPrefix(constraint, "=="))
-		return err == nil && pyver.Compare(ver, cver) == 0
-	} else if strings.HasPrefix(constraint, ">=") {
-		cver, err := pyver.Parse(strings.TrimPrefix(constraint, ">="))
-		return err == nil && pyver.Compare(ver, cver) >= 0
-	} else if strings.HasPrefix(constraint, ">") {
-		cver, err := pyver.Parse(strings.TrimPrefix(constraint, ">"))
-		return err == nil && pyver.Compare(ver, cver) > 0
-	} else if strings.HasPrefix(constraint, "<=") {
-		cver, err := pyver.Parse(strings.TrimPrefix(constraint, "<="))
-		return err == nil && pyver.Compare(ver, cver) <= 0
-	} else if strings.HasPrefix(constraint, "<") {
-		cver, err := pyver.Parse(strings.TrimPrefix(constraint, "<"))
-		return err == nil && pyver.Compare(ver, cver) < 0
-	} else if strings.HasPrefix(constraint, "~=") {
-		// Compatible release: ~=1.4 means >=1.4, ==1.*
-		base := strings.TrimPrefix(constraint, "~=")
-		cver, err := pyver.Parse(base)
-		if err != nil || len(cver.Release) < 2 || len(ver.Release) < 2 {
-			return false
-		}
-		if ver.Release[0] != cver.Release[0] {
-			return false
-		}
-		if ver.Release[1] < cver.Release[1] {
-			return false
-		}
-		return pyver.Compare(ver, cver) >= 0
+	if currentVer, err := u.python.Parse(versionConstraints); err == nil {
+		return u.python.Compare(latestVer, currentVer) > 0, nil
 	}
-	return false
+	return u.python.Satisfies(latestVer, versionConstraints)
 }
 
-func (u *Updater) updatePackageJsonFile(filePath string) error {
-	// Read the package.json file
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
+// constrainedVersion returns target if it satisfies the constraint
+// specifiers of a package. Otherwise it returns the highest published version
+// newer than current and older than target that does, or "" if there is
+// none, together with the specifier that excludes target. Versions between
+// current and target never cross the package's update level.
+func (u *Updater) constrainedVersion(l *pythonLookup, name, current, target string, specs []string) (string, string) {
+	spec, ok := u.satisfiesConstraints(target, specs)
+	if ok {
+		return target, ""
 	}
-
-	// Parse the JSON
-	var packageJSON map[string]interface{}
-	if err := json.Unmarshal(content, &packageJSON); err != nil {
-		return fmt.Errorf("error parsing JSON in %s: %w", filePath, err)
+	lister, ok := l.client.(versionLister)
+	if !ok {
+		return "", spec
 	}
-
-	// Check for dependencies
-	dependencies, hasDependencies := packageJSON["dependencies"].(map[string]interface{})
-	if !hasDependencies {
-		dependencies = make(map[string]interface{})
+	versions, err := lister.GetVersions(name)
+	if err != nil {
+		utils.Debug("update", "Error getting versions of %s: %v", name, err)
+		return "", spec
 	}
-
-	// Check for devDependencies
-	devDependencies, hasDevDependencies := packageJSON["devDependencies"].(map[string]interface{})
-	if !hasDevDependencies {
-		devDependencies = make(map[string]interface{})
+	targetVersion, err := u.python.Parse(target)
+	if err != nil {
+		return "", spec
 	}
 
-	// Update dependencies
-	updatedDeps := make(map[string]string)
-	for name, version := range dependencies {
-		versionStr, ok := version.(string)
-		if !ok {
-			continue
-		}
-
-		// Skip git dependencies
-		if strings.Contains(versionStr, "git") {
-			continue
-		}
-
-		// Get the latest version
-		latestVersion, err := u.npm.GetLatestVersion(name)
-		if err != nil {
-			utils.Debug("update", "Error getting latest version for %s: %v", name, err)
-			continue
-		}
-
-		// Preserve version prefixes (^, ~, etc.)
-		prefix := ""
-		for _, p := range []string{"^", "~", ">=", "<=", ">", "<"} {
-			if strings.HasPrefix(versionStr, p) {
-				prefix = p
-				break
-			}
-		}
-
-		updatedVersion := prefix + latestVersion
-		if updatedVersion != versionStr {
-			updatedDeps[name] = updatedVersion
-			dependencies[name] = updatedVersion
-		}
+	best, bestVersion := "", versioning.Version(nil)
+	if currentVersion, err := u.python.Parse(current); err == nil {
+		bestVersion = currentVersion
 	}
-
-	// Update devDependencies
-	updatedDevDeps := make(map[string]string)
-	for name, version := range devDependencies {
-		versionStr, ok := version.(string)
-		if !ok {
-			continue
-		}
-
-		// Skip git dependencies
-		if strings.Contains(versionStr, "git") {
-			continue
-		}
-
-		// Get the latest version
-		latestVersion, err := u.npm.GetLatestVersion(name)
-		if err != nil {
-			utils.Debug("update", "Error getting latest version for %s: %v", name, err)
+	for _, raw := range versions {
+		v, err := u.python.Parse(raw)
+		switch {
+		case err != nil,
+			u.python.Compare(v, targetVersion) >= 0,
+			bestVersion != nil && u.python.Compare(v, bestVersion) <= 0,
+			u.python.IsPrerelease(v) && !u.python.IsPrerelease(targetVersion):
 			continue
 		}
-
-		// Preserve version prefixes (^, ~, etc.)
-		prefix := ""
-		for _, p := range []string{"^", "~", ">=", "<=", ">", "<"} {
-			if strings.HasPrefix(versionStr, p) {
-				prefix = p
-				break
-			}
-		}
-
-		updatedVersion := prefix + latestVersion
-		if updatedVersion != versionStr {
-			updatedDevDeps[name] = updatedVersion
-			devDependencies[name] = updatedVersion
+		if _, ok := u.satisfiesConstraints(raw, specs); ok {
+			best, bestVersion = raw, v
 		}
 	}
-
-	// If no dependencies were updated, just return
-	if len(updatedDeps) == 0 && len(updatedDevDeps) == 0 {
-		u.filesUnchanged++
-		return nil
+	if best == "" {
+		return "", spec
 	}
 
-	// Update the package.json - only set fields that originally existed or have updates
-	if hasDependencies || len(updatedDeps) > 0 {
-		packageJSON["dependencies"] = dependencies
-	}
-	if hasDevDependencies || len(updatedDevDeps) > 0 {
-		packageJSON["devDependencies"] = devDependencies
+	// The release must also be installable; an older fallback still has to
+	// satisfy the constraints
+	installable := u.installableVersion(l, name, current, best)
+	if _, ok := u.satisfiesConstraints(installable, specs); installable == "" || !ok {
+		return "", spec
 	}
+	return installable, spec
+}
 
-	// Marshal back to JSON with indentation
-	updatedJSON, err := json.MarshalIndent(packageJSON, "", "  ")
+// satisfiesConstraints checks version against a list of constraint specifiers.
+// If not, it also returns the first specifier that excludes the version.
+func (u *Updater) satisfiesConstraints(version string, specs []string) (string, bool) {
+	if len(specs) == 0 {
+		return "", true
+	}
+	ver, err := u.python.Parse(version)
 	if err != nil {
-		return fmt.Errorf("error marshaling JSON for %s: %w", filePath, err)
+		return "", true
 	}
-
-	// Write the updated JSON back to file
-	if !u.dryRun {
-		if err := os.WriteFile(filePath, updatedJSON, 0644); err != nil {
-			return fmt.Errorf("error writing to file %s: %w", filePath, err)
-		}
-	} else {
-		// In dry run mode, just log what would be done
-		utils.Info("dry-run", "Would update file: %s", filePath)
-		for name, version := range updatedDeps {
-			utils.Info("dry-run", "  Would update dependency %s -> %s", name, version)
-		}
-		for name, version := range updatedDevDeps {
-			utils.Info("dry-run", "  Would update devDependency %s -> %s", name, version)
+	for _, spec := range specs {
+		if ok, err := u.python.Satisfies(ver, spec); err != nil || !ok {
+			return spec, false
 		}
 	}
-
-	// Update statistics
-	u.filesUpdated++
-	u.modulesUpdated += len(updatedDeps) + len(updatedDevDeps)
-
-	return nil
+	return "", true
 }
 
 func (u *Updater) updatePyProjectFile(filePath string) error {
@@ -723,137 +687,58 @@ func (u *Updater) updatePyProjectFile(filePath string) error {
 		return fmt.Errorf("%s: error reading file: %w", filePath, err)
 	}
 
-	// Set custom index URL if specified in the pyproject.toml file
-	pypiPkg, ok := u.pypi.(*pypi.PyPI)
-	if ok {
-		pypiPkg.SetIndexURLFromPyProjectTOML(content)
+	// Look packages up on the indexes the pyproject.toml file configures
+	indexes, err := pypi.ParsePyProjectIndexes(content)
+	if err != nil {
+		utils.Warning("Ignoring the index configuration of %s: %v", filePath, err)
 	}
+	lookup := u.pythonLookupFor(filePath, indexes)
 
 	// Create or get the PyProject instance
 	pyproj := pyproject.NewPyProject(filePath)
+	pyproj.SetRangeStrategy(u.rangeStrategy)
+	pyproj.SetAllowDowngrade(u.allowDowngrade)
+	if err := pyproj.Load(); err != nil {
+		return fmt.Errorf("failed to update pyproject.toml: %w", err)
+	}
 
 	// Get packages that need to be updated
 	packageVersionMap := make(map[string]string)
 
-	// Use regex to extract package names and current versions
-	// This regex extracts package names from various TOML formats:
-	// 1. "package==1.0.0" in arrays
-	// 2. package = "^1.0.0" in tables
-	re := regexp.MustCompile(`(?m)"([a-zA-Z0-9_.-]+)(?:\[[a-zA-Z0-9_,.-]+\])?(?:==|>=|<=|!=|~=|>|<|===)([^"]+)"`)
-
-	matches := re.FindAllStringSubmatch(string(content), -1)
-	utils.Debug("update", "Found %d potential packages in the TOML file", len(matches))
+	dependencies := pyproj.Dependencies()
+	utils.Debug("update", "Found %d dependencies in the TOML file", len(dependencies))
 
-	// Process all matches
-	for _, match := range matches {
-		if len(match) < 2 {
-			continue
-		}
-
-		pkgName := match[1]
-		// Clean the package name by removing any quotes or extra whitespace
-		pkgName = strings.Trim(pkgName, `"' `)
-
-		if pkgName == "" {
-			continue
+	for _, dep := range dependencies {
+		scheme := versioning.PEP440
+		if dep.Poetry {
+			scheme = versioning.Poetry
 		}
 
-		// Use the package manager to get the latest version
-		latestVersion, err := u.pypi.GetLatestVersion(pkgName)
+		// Use the package manager to get the latest version within the package's update level
+		latestVersion, err := u.targetVersion(lookup, dep.Name, scheme, dep.Constraint)
 		if err != nil {
-			utils.Debug("update", "Package not found: %s (keeping current version)", pkgName)
+			utils.Debug("update", "Package not found: %s (keeping current version)", dep.Name)
 			continue
 		}
-
-		utils.Debug("update", "Found package %s latest version: %s", pkgName, latestVersion)
-		packageVersionMap[pkgName] = latestVersion
-	}
-
-	// Also check for Poetry-style dependencies (package = "version")
-	contentStr := string(content)
-
-	// Define valid dependency section headers to check against
-	validSections := []string{
-		"[tool.poetry.dependencies]",
-		"[tool.poetry.dev-dependencies]",
-		"[project.dependencies]",
-		"[project.optional-dependencies]",
-		"[dependency-groups]",
-	}
-
-	// Check if a position is within a valid dependency section
-	isInValidSection := func(pos int) bool {
-		// Find the last section header before this position
-		lastSectionPos := -1
-		lastSectionHeader := ""
-
-		for _, section := range validSections {
-			sectionPos := strings.LastIndex(contentStr[:pos], section)
-			if sectionPos > lastSectionPos {
-				lastSectionPos = sectionPos
-				lastSectionHeader = section
-			}
-		}
-
-		// No valid section found before this position
-		if lastSectionPos == -1 {
-			return false
-		}
-
-		// Check if there's another section header between the last valid section and this position
-		nextSectionPos := findNextSection(contentStr, lastSectionPos+len(lastSectionHeader))
-		return nextSectionPos == -1 || nextSectionPos > pos
-	}
-
-	// Find all potential Poetry-style dependencies
-	rePoetry := regexp.MustCompile(`(?m)([a-zA-Z0-9_.-]+)\s*=\s*["']([^"']+)["']`)
-	matchesPoetry := rePoetry.FindAllStringSubmatchIndex(contentStr, -1)
-
-	for _, matchIndices := range matchesPoetry {
-		if len(matchIndices) < 4 {
-			continue
-		}
-
-		// Check if this match is within a valid dependency section
-		matchPos := matchIndices[0]
-		if !isInValidSection(matchPos) {
+		if latestVersion == "" {
 			continue
 		}
 
-		// Extract the package name using the capture group indices
-		pkgNameStart, pkgNameEnd := matchIndices[2], matchIndices[3]
-		pkgName := contentStr[pkgNameStart:pkgNameEnd]
-
-		// Clean the package name by removing any quotes or extra whitespace
-		pkgName = strings.Trim(pkgName, `"' `)
-
-		if pkgName == "" {
-			continue
-		}
-
-		// Use the package manager to get the latest version
-		latestVersion, err := u.pypi.GetLatestVersion(pkgName)
-		if err != nil {
-			utils.Debug("update", "Package not found: %s (keeping current version)", pkgName)
-			continue
-		}
-
-		utils.Debug("update", "Found package %s latest version: %s", pkgName, latestVersion)
-		packageVersionMap[pkgName] = latestVersion
+		utils.Debug("update", "Found package %s latest version: %s", dep.Name, latestVersion)
+		packageVersionMap[dep.Name] = latestVersion
 	}
 
-	// Update the pyproject.toml file with the new versions
-	updatedModules, err := pyproj.LoadAndUpdate(packageVersionMap)
-	if err != nil {
-		return fmt.Errorf("failed to update pyproject.toml: %w", err)
+	// Update the dependency strings in place; a dry run leaves the file alone
+	updatedModules := pyproj.Update(packageVersionMap)
+	if len(updatedModules) > 0 && !u.dryRun {
+		if err := pyproj.Save(); err != nil {
+			return fmt.Errorf("failed to update pyproject.toml: %w", err)
+		}
 	}
 
 	// Update statistics
 	if len(updatedModules) > 0 {
-		if !u.dryRun {
-			// File is already saved by LoadAndUpdate method
-			// No need to call Save() again as it would rebuild the entire file
-		} else {
+		if u.dryRun {
 			// In dry run mode, just log what would be done
 			utils.Info("dry-run", "Would update file: %s", filePath)
 			for _, pkgName := range updatedModules {
@@ -979,54 +864,28 @@ func (u *Updater) verifyRequirements(filePath string, updatedContent string) err
 }
 
 func (u *Updater) getLatestVersions(filePath string, versions map[string]string) error {
-	// Read the file
-	content, err := os.ReadFile(filePath)
+	doc, err := requirements.Load(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %v", err)
 	}
+	reqFile := doc.File
 
-	// Simple line-by-line parsing instead of relying on complex regex
-	lines := strings.Split(string(content), "\n")
+	lookup := u.pythonLookupFor(filePath, pypi.IndexSetFromRequirements(doc))
 	packageCount := 0
-
-	for _, line := range lines {
-		// Skip empty lines and comments
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Skip git+ lines
-		if strings.HasPrefix(line, "git+") {
+	for _, line := range reqFile.Requirements() {
+		// Only packages with a version specifier are considered
+		if line.Specifier == "" {
 			continue
 		}
+		packageCount++
 
-		// Basic parsing: split on first occurrence of version specifier
-		var packageName string
-		for _, prefix := range []string{"==", ">=", "<=", "!=", "~=", ">", "<", "==="} {
-			if idx := strings.Index(line, prefix); idx > 0 {
-				packageName = strings.TrimSpace(line[:idx])
-				utils.Debug("update", "Found package: %s with version specifier: %s", packageName, prefix)
-				packageCount++
-				break
-			}
-		}
-
-		if packageName != "" {
-			// Extract base package name without extras
-			basePackageName := packageName
-			if idx := strings.Index(packageName, "["); idx != -1 {
-				basePackageName = packageName[:idx]
-			}
-
-			utils.Debug("update", "Getting latest version for package: %s", basePackageName)
-			if version, err := u.pypi.GetLatestVersion(basePackageName); err == nil {
-				versions[basePackageName] = version
-				utils.Debug("update", "Found latest version for %s: %s", basePackageName, version)
-			} else {
-				utils.Debug("update", "Error getting latest version for %s: %v", basePackageName, err)
-				fmt.Printf("Warning: Package not found: %s (keeping current version)\n", basePackageName)
-			}
+		utils.Debug("update", "Getting latest version for package: %s", line.Name)
+		if version, err := lookup.client.GetLatestVersion(line.Name); err == nil {
+			versions[line.Name] = version
+			utils.Debug("update", "Found latest version for %s: %s", line.Name, version)
+		} else {
+			utils.Debug("update", "Error getting latest version for %s: %v", line.Name, err)
+			fmt.Printf("Warning: Package not found: %s (keeping current version)\n", line.Name)
 		}
 	}
 
@@ -1127,150 +986,246 @@ func (u *Updater) processPyProjectFile(filePath string) error {
 		return fmt.Errorf("%s: error reading file: %w", filePath, err)
 	}
 
-	// Set custom index URL if specified in the pyproject.toml file
-	pypiPkg, ok := u.pypi.(*pypi.PyPI)
-	if ok {
-		pypiPkg.SetIndexURLFromPyProjectTOML(content)
+	// Look packages up on the indexes the pyproject.toml file configures
+	indexes, err := pypi.ParsePyProjectIndexes(content)
+	if err != nil {
+		utils.Warning("Ignoring the index configuration of %s: %v", filePath, err)
 	}
+	lookup := u.pythonLookupFor(filePath, indexes)
 
 	// Create or get the PyProject instance
 	pyproj := pyproject.NewPyProject(filePath)
+	pyproj.SetRangeStrategy(u.rangeStrategy)
+	pyproj.SetAllowDowngrade(u.allowDowngrade)
+	if err := pyproj.Load(); err != nil {
+		return fmt.Errorf("failed to update pyproject.toml: %w", err)
+	}
 
 	// Get packages that need to be updated
 	packageVersionMap := make(map[string]string)
 
-	// Use regex to extract package names and current versions
-	// This regex extracts package names from various TOML formats:
-	// 1. "package==1.0.0" in arrays
-	// 2. package = "^1.0.0" in tables
-	re := regexp.MustCompile(`(?m)"([a-zA-Z0-9_.-]+)(?:\[[a-zA-Z0-9_,.-]+\])?(?:==|>=|<=|!=|~=|>|<|===)([^"]+)"`)
+	dependencies := pyproj.Dependencies()
+	utils.Debug("update", "Found %d dependencies in the TOML file", len(dependencies))
 
-	matches := re.FindAllStringSubmatch(string(content), -1)
-	utils.Debug("update", "Found %d potential packages in the TOML file", len(matches))
-
-	// Process all matches
-	for _, match := range matches {
-		if len(match) < 2 {
-			continue
+	for _, dep := range dependencies {
+		scheme := versioning.PEP440
+		if dep.Poetry {
+			scheme = versioning.Poetry
 		}
 
-		pkgName := match[1]
-		// Clean the package name by removing any quotes or extra whitespace
-		pkgName = strings.Trim(pkgName, `"' `)
-
-		if pkgName == "" {
+		// Use the package manager to get the latest version within the package's update level
+		latestVersion, err := u.targetVersion(lookup, dep.Name, scheme, dep.Constraint)
+		if err != nil {
+			utils.Debug("update", "Package not found: %s (keeping current version)", dep.Name)
 			continue
 		}
-
-		// Use the package manager to get the latest version
-		latestVersion, err := u.pypi.GetLatestVersion(pkgName)
-		if err != nil {
-			utils.Debug("update", "Package not found: %s (keeping current version)", pkgName)
+		if latestVersion == "" {
 			continue
 		}
 
-		utils.Debug("update", "Found package %s latest version: %s", pkgName, latestVersion)
-		packageVersionMap[pkgName] = latestVersion
+		utils.Debug("update", "Found package %s latest version: %s", dep.Name, latestVersion)
+		packageVersionMap[dep.Name] = latestVersion
 	}
 
-	// Also check for Poetry-style dependencies (package = "version")
-	contentStr := string(content)
-
-	// Define valid dependency section headers to check against
-	validSections := []string{
-		"[tool.poetry.dependencies]",
-		"[tool.poetry.dev-dependencies]",
-		"[project.dependencies]",
-		"[project.optional-dependencies]",
-		"[dependency-groups]",
+	// Update the dependency strings in place; a dry run leaves the file alone
+	updatedModules := pyproj.Update(packageVersionMap)
+	if len(updatedModules) > 0 && !u.dryRun {
+		if err := pyproj.Save(); err != nil {
+			return fmt.Errorf("failed to update pyproject.toml: %w", err)
+		}
 	}
 
-	// Check if a position is within a valid dependency section
-	isInValidSection := func(pos int) bool {
-		// Find the last section header before this position
-		lastSectionPos := -1
-		lastSectionHeader := ""
-
-		for _, section := range validSections {
-			sectionPos := strings.LastIndex(contentStr[:pos], section)
-			if sectionPos > lastSectionPos {
-				lastSectionPos = sectionPos
-				lastSectionHeader = section
+	// Update statistics
+	if len(updatedModules) > 0 {
+		if u.dryRun {
+			// In dry run mode, just log what would be done
+			utils.Info("dry-run", "Would update file: %s", filePath)
+			for _, pkgName := range updatedModules {
+				utils.Info("dry-run", "  Would update %s -> %s", pkgName, packageVersionMap[pkgName])
 			}
 		}
+		u.filesUpdated++
+		u.modulesUpdated += len(updatedModules)
+	} else {
+		u.filesUnchanged++
+	}
 
-		// No valid section found before this position
-		if lastSectionPos == -1 {
-			return false
-		}
+	return nil
+}
 
-		// Check if there's another section header between the last valid section and this position
-		nextSectionPos := findNextSection(contentStr, lastSectionPos+len(lastSectionHeader))
-		return nextSectionPos == -1 || nextSectionPos > pos
+// recordSectionUpdate counts a package updated in the given package.json
+// section, e.g. "devDependencies", for the breakdown in the summary line
+func (u *Updater) recordSectionUpdate(section string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.sectionsUpdated == nil {
+		u.sectionsUpdated = make(map[string]int)
 	}
+	u.sectionsUpdated[section]++
+}
 
-	// Find all potential Poetry-style dependencies
-	rePoetry := regexp.MustCompile(`(?m)([a-zA-Z0-9_.-]+)\s*=\s*["']([^"']+)["']`)
-	matchesPoetry := rePoetry.FindAllStringSubmatchIndex(contentStr, -1)
-
-	for _, matchIndices := range matchesPoetry {
-		if len(matchIndices) < 4 {
-			continue
+// sectionSummary returns the per-section breakdown of package.json updates
+// for the summary line, e.g. " (2 in dependencies, 1 in peerDependencies)"
+func (u *Updater) sectionSummary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var parts []string
+	for _, section := range packageJSONSections {
+		if n := u.sectionsUpdated[section.name()]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d in %s", n, section.name()))
 		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
 
-		// Check if this match is within a valid dependency section
-		matchPos := matchIndices[0]
-		if !isInValidSection(matchPos) {
-			continue
-		}
+// recordSkipped notes a package.json dependency that could not be updated
+func (u *Updater) recordSkipped(file, name, section, reason string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.skipped = append(u.skipped, skippedDependency{file: file, name: name, section: section, reason: reason})
+}
 
-		// Extract the package name using the capture group indices
-		pkgNameStart, pkgNameEnd := matchIndices[2], matchIndices[3]
-		pkgName := contentStr[pkgNameStart:pkgNameEnd]
+// skippedSummary lists the skipped package.json dependencies with the
+// reason for each, or returns "" if there are none
+func (u *Updater) skippedSummary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.skipped) == 0 {
+		return ""
+	}
 
-		// Clean the package name by removing any quotes or extra whitespace
-		pkgName = strings.Trim(pkgName, `"' `)
+	// Files are processed concurrently, so order by file
+	skipped := append([]skippedDependency{}, u.skipped...)
+	sort.SliceStable(skipped, func(i, j int) bool { return skipped[i].file < skipped[j].file })
 
-		if pkgName == "" {
-			continue
-		}
+	var b strings.Builder
+	noun := "dependencies"
+	if len(skipped) == 1 {
+		noun = "dependency"
+	}
+	fmt.Fprintf(&b, "Skipped %d npm %s:\n", len(skipped), noun)
+	for _, s := range skipped {
+		fmt.Fprintf(&b, "  %s: %s in %s (%s)\n", s.file, s.name, s.section, s.reason)
+	}
+	return b.String()
+}
 
-		// Use the package manager to get the latest version
-		latestVersion, err := u.pypi.GetLatestVersion(pkgName)
-		if err != nil {
-			utils.Debug("update", "Package not found: %s (keeping current version)", pkgName)
+// recordHeldBack notes a package that was not updated to version for
+// reason, grouped by kind in the summary
+func (u *Updater) recordHeldBack(file, kind, name, version, reason string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.heldBack = append(u.heldBack, heldBackDependency{file: file, kind: kind, name: name, version: version, reason: reason})
+}
+
+// heldBackKinds describes the packages held back for each kind of reason
+var heldBackKinds = []struct{ kind, packages, target string }{
+	{"node", "npm", "the Node.js version"},
+	{"python", "Python", "the Python version"},
+	{"wheels", "Python", "the target platforms"},
+}
+
+// heldBackSummary lists the packages held back for the Node.js or Python
+// version or the target platforms, or returns "" if there are none
+func (u *Updater) heldBackSummary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var b strings.Builder
+	for _, k := range heldBackKinds {
+		var heldBack []heldBackDependency
+		for _, h := range u.heldBack {
+			if h.kind == k.kind {
+				heldBack = append(heldBack, h)
+			}
+		}
+		if len(heldBack) == 0 {
 			continue
 		}
+		sort.SliceStable(heldBack, func(i, j int) bool { return heldBack[i].file < heldBack[j].file })
 
-		utils.Debug("update", "Found package %s latest version: %s", pkgName, latestVersion)
-		packageVersionMap[pkgName] = latestVersion
+		noun := "dependencies"
+		if len(heldBack) == 1 {
+			noun = "dependency"
+		}
+		fmt.Fprintf(&b, "Held back %d %s %s for %s:\n", len(heldBack), k.packages, noun, k.target)
+		for _, h := range heldBack {
+			fmt.Fprintf(&b, "  %s: %s %s %s\n", h.file, h.name, h.version, h.reason)
+		}
 	}
+	return b.String()
+}
 
-	// Update the pyproject.toml file with the new versions
-	updatedModules, err := pyproj.LoadAndUpdate(packageVersionMap)
-	if err != nil {
-		return fmt.Errorf("failed to update pyproject.toml: %w", err)
+// recordWithdrawn notes a package left on a version that is yanked or
+// deprecated, as kind says, with the registry's reason
+func (u *Updater) recordWithdrawn(file, kind, name, version, reason string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.withdrawn = append(u.withdrawn, withdrawnDependency{file: file, kind: kind, name: name, version: version, reason: reason})
+}
+
+// withdrawnSummary lists the packages left on a yanked or deprecated
+// version with the registry's reason for each, or returns "" if there are
+// none
+func (u *Updater) withdrawnSummary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.withdrawn) == 0 {
+		return ""
 	}
 
-	// Update statistics
-	if len(updatedModules) > 0 {
-		if !u.dryRun {
-			// File is already saved by LoadAndUpdate method
-			// No need to call Save() again as it would rebuild the entire file
-		} else {
-			// In dry run mode, just log what would be done
-			utils.Info("dry-run", "Would update file: %s", filePath)
-			for _, pkgName := range updatedModules {
-				utils.Info("dry-run", "  Would update %s -> %s", pkgName, packageVersionMap[pkgName])
-			}
-		}
-		u.filesUpdated++
-		u.modulesUpdated += len(updatedModules)
+	withdrawn := append([]withdrawnDependency{}, u.withdrawn...)
+	sort.SliceStable(withdrawn, func(i, j int) bool { return withdrawn[i].file < withdrawn[j].file })
+
+	var b strings.Builder
+	if len(withdrawn) == 1 {
+		b.WriteString("Found 1 dependency on a yanked or deprecated version:\n")
 	} else {
-		u.filesUnchanged++
+		fmt.Fprintf(&b, "Found %d dependencies on yanked or deprecated versions:\n", len(withdrawn))
+	}
+	for _, w := range withdrawn {
+		fmt.Fprintf(&b, "  %s: %s %s is %s", w.file, w.name, w.version, w.kind)
+		if w.reason != "" {
+			fmt.Fprintf(&b, " (%s)", w.reason)
+		}
+		b.WriteString("\n")
 	}
+	return b.String()
+}
 
-	return nil
+// recordPeerConflict notes that name at version requires peer in rng, but
+// peer is at peerVersion
+func (u *Updater) recordPeerConflict(file, name, version, peer, rng, peerVersion string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.peerConflicts = append(u.peerConflicts, unresolvedPeer{file: file, name: name, version: version, peer: peer, rng: rng, peerVersion: peerVersion})
+}
+
+// peerConflictSummary lists the unresolved peer dependency conflicts, or
+// returns "" if there are none
+func (u *Updater) peerConflictSummary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.peerConflicts) == 0 {
+		return ""
+	}
+
+	conflicts := append([]unresolvedPeer{}, u.peerConflicts...)
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].file < conflicts[j].file })
+
+	var b strings.Builder
+	noun := "conflicts"
+	if len(conflicts) == 1 {
+		noun = "conflict"
+	}
+	fmt.Fprintf(&b, "Unresolved npm peer dependency %s (%d):\n", noun, len(conflicts))
+	for _, c := range conflicts {
+		fmt.Fprintf(&b, "  %s: %s %s requires %s %s, but %s is at %s\n", c.file, c.name, c.version, c.peer, c.rng, c.peer, c.peerVersion)
+	}
+	return b.String()
 }
 
 // Add this helper function for pluralization
@@ -1281,17 +1236,30 @@ func plural(n int) string {
 	return "s"
 }
 
-// Add a stub for findNextSection to fix linter errors
-func findNextSection(content string, start int) int {
-	// This is a stub: always return -1 (no next section found)
-	return -1
-}
-
 // SetDryRun sets the dryRun mode for the updater.
 func (u *Updater) SetDryRun(dryRun bool) {
 	u.dryRun = dryRun
 }
 
+// SetAlignWorkspaces keeps each external npm dependency at one version
+// across the packages of a workspace
+func (u *Updater) SetAlignWorkspaces(align bool) {
+	u.alignWorkspaces = align
+}
+
+// SetLatest allows npm ranges to be updated beyond what they accept, e.g.
+// ^1.4.0 to ^2.0.0, and peer dependency ranges to be widened
+func (u *Updater) SetLatest(latest bool) {
+	u.latest = latest
+}
+
+// SetRangeStrategy sets how version ranges are rewritten for the latest
+// version in requirements files, pyproject.toml and package.json. With a
+// strategy set, npm ranges also move to the latest version.
+func (u *Updater) SetRangeStrategy(strategy versioning.RangeStrategy) {
+	u.rangeStrategy = strategy
+}
+
 // detectFileType intelligently detects the type of dependency file based on filename, extension, and content
 func (u *Updater) detectFileType(filePath string) (string, error) {
 	filename := filepath.Base(filePath)
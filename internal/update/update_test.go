@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,7 +13,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rvben/ru/internal/config"
 	"github.com/rvben/ru/internal/packagemanager"
+	"github.com/rvben/ru/internal/packagemanager/pypi"
+	"github.com/rvben/ru/internal/versioning"
 )
 
 // MockPackageManager is a mock implementation of the PackageManager interface for testing
@@ -37,6 +41,7 @@ func NewUpdater(pypi packagemanager.PackageManager) *Updater {
 		pypi:           pypi,
 		filesUpdated:   0,
 		modulesUpdated: 0,
+		python:         versioning.PEP440,
 	}
 }
 
@@ -147,6 +152,34 @@ func TestCheckVersionConstraints(t *testing.T) {
 			wantUpdate: true,
 			wantErr:    false,
 		},
+		{
+			name:       "Excluded version",
+			currentVer: ">=1.0,!=1.5.0",
+			latestVer:  "1.5.0",
+			wantUpdate: false,
+			wantErr:    false,
+		},
+		{
+			name:       "Compatible release on three components",
+			currentVer: "~=1.4.2",
+			latestVer:  "1.5.0",
+			wantUpdate: false,
+			wantErr:    false,
+		},
+		{
+			name:       "Prefix match",
+			currentVer: "==1.*",
+			latestVer:  "1.9.post1",
+			wantUpdate: true,
+			wantErr:    false,
+		},
+		{
+			name:       "Pin with epoch",
+			currentVer: "==1!1.0",
+			latestVer:  "2.0",
+			wantUpdate: false,
+			wantErr:    false,
+		},
 		{
 			name:       "Invalid version",
 			currentVer: "invalid",
@@ -714,3 +747,653 @@ foo==1.0.0
 		t.Errorf("Wildcard should be concretized.\nExpected:\n%q\nGot:\n%q", expected, string(updatedContent))
 	}
 }
+
+func TestUpdateRequirementsFileRangeStrategy(t *testing.T) {
+	content := `requests>=2.28
+flask>=2.0,<3.0
+django~=4.1
+rdflib==7.1.*
+numpy>=1.20,<2.0 ; python_version >= "3.9"
+foo==1.0.0
+`
+	latest := map[string]string{
+		"requests": "2.32.0", "flask": "3.1.0", "django": "5.0.2",
+		"rdflib": "7.2.1", "numpy": "2.1.0", "foo": "1.2.0",
+	}
+
+	tests := []struct {
+		strategy versioning.RangeStrategy
+		expected string
+	}{
+		{"", `requests>=2.28
+flask>=2.0,<3.0
+django~=4.1
+rdflib==7.2.1
+numpy>=1.20,<2.0 ; python_version >= "3.9"
+foo==1.2.0
+`},
+		{versioning.RangePin, `requests==2.32.0
+flask==3.1.0
+django==5.0.2
+rdflib==7.2.1
+numpy==2.1.0 ; python_version >= "3.9"
+foo==1.2.0
+`},
+		{versioning.RangeBump, `requests>=2.32.0
+flask>=2.0,<3.0
+django~=5.0
+rdflib==7.2.*
+numpy>=1.20,<2.0 ; python_version >= "3.9"
+foo==1.2.0
+`},
+		{versioning.RangeWiden, `requests>=2.28
+flask>=2.0,<4.0
+django>=4.1,<6.0
+rdflib>=7.1,<7.3
+numpy>=1.20,<3.0 ; python_version >= "3.9"
+foo==1.2.0
+`},
+		{versioning.RangeReplace, `requests>=2.28
+flask>=3.1.0,<4.0
+django~=5.0
+rdflib==7.2.*
+numpy>=2.1.0,<3.0 ; python_version >= "3.9"
+foo==1.2.0
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "requirements.txt")
+			if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			updater := NewUpdater(&MockPackageManager{
+				getLatestVersionFunc: func(pkg string) (string, error) {
+					return latest[pkg], nil
+				},
+			})
+			updater.SetRangeStrategy(tt.strategy)
+			if err := updater.updateRequirementsFile(testFile); err != nil {
+				t.Fatalf("updateRequirementsFile failed: %v", err)
+			}
+
+			got, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Failed to read updated file: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// levelPackageManager is a MockPackageManager that also looks up the
+// highest version within an update level
+type levelPackageManager struct {
+	MockPackageManager
+	versions map[string][]string
+}
+
+func (m *levelPackageManager) GetHighestVersion(packageName, current string, level versioning.Level) (string, error) {
+	return versioning.Highest(versioning.PEP440, m.versions[packageName], current, level)
+}
+
+func TestUpdateRequirementsFileLevel(t *testing.T) {
+	content := `django==4.1.3
+flask>=2.0.1
+pytz==2023.3
+black==23.1.0
+`
+	versions := map[string][]string{
+		"django": {"4.1.3", "4.1.5", "4.2.1", "5.0.2"},
+		"flask":  {"2.0.1", "2.0.3", "2.3.0", "3.1.0"},
+		"pytz":   {"2023.3", "2023.4", "2024.1"},
+		"black":  {"23.1.0", "24.2.0"},
+	}
+
+	tests := []struct {
+		level    versioning.Level
+		expected string
+	}{
+		// a new year or month of a calendar version is a minor update
+		{versioning.Patch, `django==4.1.5
+flask>=2.0.3
+pytz==2023.3
+black==23.1.0
+`},
+		{versioning.Minor, `django==4.2.1
+flask>=2.3.0
+pytz==2024.1
+black==23.1.0
+`},
+		{versioning.Major, `django==5.0.2
+flask>=3.1.0
+pytz==2024.1
+black==23.1.0
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			testFile := filepath.Join(dir, "requirements.txt")
+			if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			// black is capped at patch updates whatever the project level
+			configFile := filepath.Join(dir, config.FileName)
+			if err := os.WriteFile(configFile, []byte("[packages.black]\nlevel = \"patch\"\n"), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+			cfg, err := config.Load(dir)
+			if err != nil {
+				t.Fatalf("config.Load failed: %v", err)
+			}
+
+			updater := NewUpdater(&levelPackageManager{
+				MockPackageManager: MockPackageManager{
+					getLatestVersionFunc: func(pkg string) (string, error) {
+						v := versions[pkg]
+						return v[len(v)-1], nil
+					},
+				},
+				versions: versions,
+			})
+			updater.SetRangeStrategy(versioning.RangeBump)
+			updater.SetConfig(cfg)
+			updater.SetLevel(tt.level)
+			if err := updater.updateRequirementsFile(testFile); err != nil {
+				t.Fatalf("updateRequirementsFile failed: %v", err)
+			}
+
+			got, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Failed to read updated file: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunLoadsConfigPerPath(t *testing.T) {
+	versions := map[string][]string{"black": {"23.1.0", "23.1.5", "24.2.0"}}
+	patched, unconfigured := t.TempDir(), t.TempDir()
+	for _, dir := range []string{patched, unconfigured} {
+		if err := os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("black==23.1.0\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(patched, config.FileName), []byte("level = \"patch\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	updater := NewUpdater(&levelPackageManager{
+		MockPackageManager: MockPackageManager{
+			getLatestVersionFunc: func(pkg string) (string, error) {
+				v := versions[pkg]
+				return v[len(v)-1], nil
+			},
+		},
+		versions: versions,
+	})
+	updater.paths = []string{patched, unconfigured}
+	if err := updater.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Each path is capped by its own configuration file only
+	for dir, expected := range map[string]string{patched: "black==23.1.5\n", unconfigured: "black==24.2.0\n"} {
+		got, err := os.ReadFile(filepath.Join(dir, "requirements.txt"))
+		if err != nil {
+			t.Fatalf("Failed to read updated file: %v", err)
+		}
+		if string(got) != expected {
+			t.Errorf("Expected %q in %s, got %q", expected, dir, got)
+		}
+	}
+}
+
+// channelPackageManager is a MockPackageManager that follows the
+// prerelease channel of the current version
+type channelPackageManager struct {
+	MockPackageManager
+	prerelease map[string]string
+}
+
+func (m *channelPackageManager) GetLatestVersionFrom(packageName, current string) (string, error) {
+	if v, ok := m.prerelease[packageName]; ok && strings.ContainsAny(current, "abc") {
+		return v, nil
+	}
+	return m.GetLatestVersion(packageName)
+}
+
+func TestUpdateRequirementsFileNeverDowngrades(t *testing.T) {
+	content := `yanked==2.1.0
+beta==3.0.0b1
+rc==2.0.0rc1
+requests>=2.28
+`
+	latest := map[string]string{"yanked": "2.0.5", "beta": "2.9.0", "rc": "1.9.3", "requests": "2.32.0"}
+	// beta has a newer prerelease, rc only older final releases
+	prerelease := map[string]string{"beta": "3.0.0b2", "rc": "1.9.3"}
+
+	tests := []struct {
+		name           string
+		allowDowngrade bool
+		expected       string
+	}{
+		{"refuse", false, `yanked==2.1.0
+beta==3.0.0b2
+rc==2.0.0rc1
+requests>=2.28
+`},
+		{"allow", true, `yanked==2.0.5
+beta==3.0.0b2
+rc==1.9.3
+requests>=2.28
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "requirements.txt")
+			if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			updater := NewUpdater(&channelPackageManager{
+				MockPackageManager: MockPackageManager{
+					getLatestVersionFunc: func(pkg string) (string, error) {
+						return latest[pkg], nil
+					},
+				},
+				prerelease: prerelease,
+			})
+			updater.SetAllowDowngrade(tt.allowDowngrade)
+			if err := updater.updateRequirementsFile(testFile); err != nil {
+				t.Fatalf("updateRequirementsFile failed: %v", err)
+			}
+
+			got, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatalf("Failed to read updated file: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsFileRequiresPython(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "django"):
+			io.WriteString(w, `{"info": {"name": "django", "version": "5.1.0"}, "releases": {
+				"4.1.0": [{"requires_python": ">=3.8"}],
+				"4.2.0": [{"requires_python": ">=3.8"}],
+				"5.0.0": [{"requires_python": ">=3.10"}],
+				"5.1.0": [{"requires_python": ">=3.10"}]
+			}}`)
+		case strings.Contains(r.URL.Path, "numpy"):
+			io.WriteString(w, `{"info": {"name": "numpy", "version": "2.1.0"}, "releases": {
+				"1.26.0": [{"requires_python": ">=3.9"}],
+				"2.1.0": [{"requires_python": ">=3.10"}]
+			}}`)
+		default:
+			io.WriteString(w, `{"info": {"name": "requests", "version": "2.32.0"}, "releases": {
+				"2.31.0": [{}], "2.32.0": [{"requires_python": ">=3.8"}]
+			}}`)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		python   string
+		expected string
+		heldBack int
+	}{
+		{"requires-python", "", "django==4.2.0\nrequests==2.32.0\nnumpy==1.26.0\n", 2},
+		{"flag", "3.12", "django==5.1.0\nrequests==2.32.0\nnumpy==2.1.0\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte("[project]\nname = \"app\"\nrequires-python = \">=3.9\"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			testFile := filepath.Join(dir, "requirements.txt")
+			if err := os.WriteFile(testFile, []byte("django==4.1.0\nrequests==2.31.0\nnumpy==1.26.0\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			client := pypi.New(false)
+			client.SetDirectIndexURL(server.URL + "/pypi")
+			updater := NewUpdater(client)
+			if tt.python != "" {
+				target, err := pypi.ParsePythonVersion(tt.python, "-python")
+				if err != nil {
+					t.Fatal(err)
+				}
+				updater.SetPython(target)
+			}
+			if err := updater.updateRequirementsFile(testFile); err != nil {
+				t.Fatalf("updateRequirementsFile failed: %v", err)
+			}
+
+			got, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, got)
+			}
+			if len(updater.heldBack) != tt.heldBack {
+				t.Errorf("expected %d held back packages, got %d:\n%s", tt.heldBack, len(updater.heldBack), updater.heldBackSummary())
+			}
+			if tt.heldBack > 0 && !strings.Contains(updater.heldBackSummary(), "django 5.1.0 requires python >=3.10, project uses python >=3.9 (requires-python)") {
+				t.Errorf("unexpected summary:\n%s", updater.heldBackSummary())
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsFileRequireWheels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "fastlib"):
+			// 1.2.0 lacks aarch64 wheels
+			io.WriteString(w, `{"info": {"name": "fastlib", "version": "1.2.0"}, "releases": {
+				"1.0.0": [{"filename": "fastlib-1.0.0.tar.gz"}],
+				"1.1.0": [
+					{"filename": "fastlib-1.1.0-cp311-cp311-manylinux_2_17_x86_64.manylinux2014_x86_64.whl"},
+					{"filename": "fastlib-1.1.0-cp311-cp311-manylinux_2_17_aarch64.manylinux2014_aarch64.whl"}
+				],
+				"1.2.0": [
+					{"filename": "fastlib-1.2.0.tar.gz"},
+					{"filename": "fastlib-1.2.0-cp311-cp311-manylinux_2_28_x86_64.whl"}
+				]
+			}}`)
+		case strings.Contains(r.URL.Path, "sdistonly"):
+			io.WriteString(w, `{"info": {"name": "sdistonly", "version": "0.2.0"}, "releases": {
+				"0.1.0": [{"filename": "sdistonly-0.1.0.tar.gz"}],
+				"0.2.0": [{"filename": "sdistonly-0.2.0.tar.gz"}]
+			}}`)
+		default:
+			io.WriteString(w, `{"info": {"name": "requests", "version": "2.32.0"}, "releases": {
+				"2.31.0": [{"filename": "requests-2.31.0-py3-none-any.whl"}],
+				"2.32.0": [{"filename": "requests-2.32.0-py3-none-any.whl"}]
+			}}`)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".python-version"), []byte("3.11.9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testFile := filepath.Join(dir, "requirements.txt")
+	if err := os.WriteFile(testFile, []byte("fastlib==1.0.0\nsdistonly==0.1.0\nrequests==2.31.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := pypi.New(false)
+	client.SetDirectIndexURL(server.URL + "/pypi")
+	updater := NewUpdater(client)
+	updater.SetRequireWheels([]string{"manylinux_2_28_x86_64", "manylinux_2_17_aarch64"})
+	if err := updater.updateRequirementsFile(testFile); err != nil {
+		t.Fatalf("updateRequirementsFile failed: %v", err)
+	}
+
+	got, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "fastlib==1.1.0\nsdistonly==0.1.0\nrequests==2.32.0\n"
+	if string(got) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+	summary := updater.heldBackSummary()
+	for _, want := range []string{
+		"Held back 2 Python dependencies for the target platforms:",
+		"fastlib 1.2.0 has no wheel for manylinux_2_17_aarch64",
+		"sdistonly 0.2.0 has no wheel for manylinux_2_28_x86_64, manylinux_2_17_aarch64",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected summary to contain %q, got:\n%s", want, summary)
+		}
+	}
+}
+
+func TestUpdateRequirementsFileSkipsYankedReleases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "requests"):
+			// The newest release is yanked
+			io.WriteString(w, `{"info": {"name": "requests", "version": "2.32.0"}, "releases": {
+				"2.31.0": [{"filename": "requests-2.31.0-py3-none-any.whl"}],
+				"2.32.0": [{"filename": "requests-2.32.0-py3-none-any.whl", "yanked": true, "yanked_reason": "Breaks Windows"}]
+			}}`)
+		default:
+			// The pinned release is yanked and nothing newer exists
+			io.WriteString(w, `{"info": {"name": "oldlib", "version": "1.0.0"}, "releases": {
+				"0.9.0": [{"filename": "oldlib-0.9.0.tar.gz"}],
+				"1.0.0": [{"filename": "oldlib-1.0.0.tar.gz", "yanked": true, "yanked_reason": "Wrong license"}]
+			}}`)
+		}
+	}))
+	defer server.Close()
+
+	testFile := filepath.Join(t.TempDir(), "requirements.txt")
+	if err := os.WriteFile(testFile, []byte("requests==2.30.0\noldlib==1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := pypi.New(false)
+	client.SetDirectIndexURL(server.URL + "/pypi")
+	updater := NewUpdater(client)
+	if err := updater.updateRequirementsFile(testFile); err != nil {
+		t.Fatalf("updateRequirementsFile failed: %v", err)
+	}
+
+	got, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "requests==2.31.0\noldlib==1.0.0\n"; string(got) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+	summary := updater.withdrawnSummary()
+	for _, want := range []string{
+		"Found 1 dependency on a yanked or deprecated version:",
+		"oldlib 1.0.0 is yanked (Wrong license)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected summary to contain %q, got:\n%s", want, summary)
+		}
+	}
+}
+
+func TestUpdateRequirementsFilesWithTheirOwnIndexes(t *testing.T) {
+	indexServer := func(version string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"info": {"name": "shared", "version": %q}, "releases": {"1.0.0": [{}], %q: [{}]}}`, version, version)
+		}))
+	}
+	internal := indexServer("1.5.0")
+	defer internal.Close()
+	public := indexServer("2.0.0")
+	defer public.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"internal/requirements.txt": "--index-url " + internal.URL + "/simple\nshared==1.0.0\n",
+		"public/requirements.txt":   "shared==1.0.0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := pypi.New(false)
+	client.SetDirectIndexURL(public.URL + "/pypi")
+	client.SetPyPIFallback(false)
+	updater := NewUpdater(client)
+	// The index of the first file must not leak into the second
+	for _, name := range []string{"internal/requirements.txt", "public/requirements.txt"} {
+		if err := updater.updateRequirementsFile(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("updateRequirementsFile(%s) failed: %v", name, err)
+		}
+	}
+
+	expected := map[string]string{
+		"internal/requirements.txt": "--index-url " + internal.URL + "/simple\nshared==1.5.0\n",
+		"public/requirements.txt":   "shared==2.0.0\n",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s:\nexpected:\n%s\ngot:\n%s", name, want, got)
+		}
+	}
+	if extras := client.GetExtraIndexURLs(); len(extras) != 0 {
+		t.Errorf("expected the shared client to keep its indexes, got extra indexes %v", extras)
+	}
+}
+
+func TestUpdateRequirementsFileWithIncludesAndConstraints(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test-includes")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"requirements.txt":     "flask==2.0.0\nrequests==2.25.0\n",
+		"requirements-dev.txt": "-r requirements.txt\n-c constraints.txt\npytest==7.0.0\n",
+		"constraints.txt":      "flask<3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var lookups int32
+	updater := New(true, false, []string{tempDir})
+	updater.pypi = &MockPackageManager{
+		getLatestVersionFunc: func(pkg string) (string, error) {
+			atomic.AddInt32(&lookups, 1)
+			switch pkg {
+			case "flask":
+				return "3.0.0", nil
+			case "requests":
+				return "2.31.0", nil
+			case "pytest":
+				return "8.0.0", nil
+			}
+			return "", fmt.Errorf("package %s not found", pkg)
+		},
+	}
+	if err := updater.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("ProcessDirectory failed: %v", err)
+	}
+
+	expected := map[string]string{
+		// flask is held back by the constraint inherited through requirements-dev.txt
+		"requirements.txt":     "flask==2.0.0\nrequests==2.31.0\n",
+		"requirements-dev.txt": "-r requirements.txt\n-c constraints.txt\npytest==8.0.0\n",
+		"constraints.txt":      "flask<3\n",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, string(got))
+		}
+	}
+
+	// The shared requirements.txt is only processed once
+	if lookups != 3 {
+		t.Errorf("expected 3 package lookups, got %d", lookups)
+	}
+	if updater.modulesUpdated != 2 {
+		t.Errorf("expected 2 packages updated, got %d", updater.modulesUpdated)
+	}
+}
+
+// mockVersionPackageManager also lists every published version of a package
+type mockVersionPackageManager struct {
+	MockPackageManager
+	versions map[string][]string
+}
+
+func (m *mockVersionPackageManager) GetVersions(packageName string) ([]string, error) {
+	return m.versions[packageName], nil
+}
+
+func TestUpdateRequirementsFileConstraintsPickIntermediateVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"requirements.txt": "-c constraints.txt\nflask==2.0.0\nrequests==2.25.0\ndjango==4.2.0\n",
+		"constraints.txt":  "flask<3\nrequests!=2.31.0,<2.32\ndjango<4.2\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	latest := map[string]string{"flask": "3.0.0", "requests": "2.32.0", "django": "5.0.0"}
+	updater := New(true, false, []string{tempDir})
+	updater.pypi = &mockVersionPackageManager{
+		MockPackageManager: MockPackageManager{
+			getLatestVersionFunc: func(pkg string) (string, error) {
+				return latest[pkg], nil
+			},
+		},
+		versions: map[string][]string{
+			"flask":    {"2.0.0", "2.2.5", "2.3.3", "3.0.0rc1", "3.0.0"},
+			"requests": {"2.25.0", "2.30.0", "2.31.0", "2.32.0"},
+			"django":   {"4.1.0", "4.2.0", "5.0.0"},
+		},
+	}
+	if err := updater.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("ProcessDirectory failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(tempDir, "requirements.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read requirements.txt: %v", err)
+	}
+	// flask and requests move to the highest release the constraints allow;
+	// django has no allowed release newer than its pin
+	want := "-c constraints.txt\nflask==2.3.3\nrequests==2.30.0\ndjango==4.2.0\n"
+	if string(got) != want {
+		t.Errorf("expected %q, got %q", want, string(got))
+	}
+	if updater.modulesUpdated != 2 {
+		t.Errorf("expected 2 packages updated, got %d", updater.modulesUpdated)
+	}
+}
@@ -0,0 +1,155 @@
+package update
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/rvben/ru/internal/packagemanager/workspace"
+	"github.com/rvben/ru/internal/utils"
+	"github.com/rvben/ru/internal/versioning"
+)
+
+// installedSections are the package.json sections npm installs. They are
+// kept at one version across the members of a workspace and must satisfy
+// each other's peer dependencies.
+var installedSections = map[string]bool{
+	"dependencies":         true,
+	"devDependencies":      true,
+	"optionalDependencies": true,
+}
+
+// discoverWorkspaces finds the workspace roots among packageJSONFiles. It
+// returns the workspaces with the files that belong to each, and the files
+// that are not part of any workspace.
+func (u *Updater) discoverWorkspaces(packageJSONFiles []string) (map[*workspace.Workspace][]string, []string) {
+	found := make(map[string]bool, len(packageJSONFiles))
+	for _, f := range packageJSONFiles {
+		found[f] = true
+	}
+
+	workspaces := make(map[*workspace.Workspace][]string)
+	owner := make(map[string]*workspace.Workspace)
+	for _, f := range packageJSONFiles {
+		ws, err := workspace.Discover(filepath.Dir(f))
+		if err != nil {
+			utils.Warning("Error reading workspace configuration of %s: %v", f, err)
+			continue
+		}
+		if ws == nil || owner[f] != nil {
+			continue
+		}
+		utils.Debug("update", "Found workspace in %s with %d packages", ws.Root, len(ws.Members))
+		for _, member := range ws.Files() {
+			// Files excluded from the walk, e.g. by .gitignore, stay untouched
+			if found[member] && owner[member] == nil {
+				owner[member] = ws
+				workspaces[ws] = append(workspaces[ws], member)
+			}
+		}
+	}
+
+	var standalone []string
+	for _, f := range packageJSONFiles {
+		if owner[f] == nil {
+			standalone = append(standalone, f)
+		}
+	}
+
+	u.mu.Lock()
+	if u.workspaces == nil {
+		u.workspaces = make(map[string]*workspace.Workspace)
+	}
+	for f, ws := range owner {
+		u.workspaces[f] = ws
+	}
+	u.mu.Unlock()
+
+	return workspaces, standalone
+}
+
+// workspaceFor returns the workspace a package.json file belongs to, or nil
+func (u *Updater) workspaceFor(filePath string) *workspace.Workspace {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.workspaces[filePath]
+}
+
+// updateWorkspace updates the package.json files of a workspace together,
+// so that references between its packages point at their local versions
+// and, with alignment enabled, external packages end up at one version.
+func (u *Updater) updateWorkspace(ws *workspace.Workspace, files []string) error {
+	plans := make([]*packageJSONPlan, 0, len(files))
+	for _, f := range files {
+		plan, err := u.planPackageJSON(f, ws)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+
+	if u.alignWorkspaces {
+		if err := u.alignWorkspacePlans(ws, plans); err != nil {
+			return err
+		}
+	}
+
+	for _, plan := range plans {
+		if err := u.applyPackageJSONPlan(plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alignWorkspacePlans raises every external dependency of the workspace to
+// the highest version any member requires, keeping each spec's operator.
+// Unless u.latest is set, a spec is only raised within its own range.
+func (u *Updater) alignWorkspacePlans(ws *workspace.Workspace, plans []*packageJSONPlan) error {
+	type alignable struct {
+		plan  *packageJSONPlan
+		entry packageJSONEntry
+		op    string
+		floor versioning.Version
+	}
+
+	scheme := versioning.NodeSemver
+	var entries []alignable
+	highest := make(map[string]versioning.Version)
+	for _, plan := range plans {
+		for _, entry := range collectPackageJSONEntries(plan.doc) {
+			if !installedSections[entry.section] || ws.Member(entry.name) != nil {
+				continue
+			}
+			m := rewritableSpecRegex.FindStringSubmatch(strings.TrimSpace(entry.spec))
+			if m == nil {
+				continue
+			}
+			floor := npmFloor(entry.spec)
+			if floor == nil {
+				continue
+			}
+			entries = append(entries, alignable{plan: plan, entry: entry, op: m[1], floor: floor})
+			if h, ok := highest[entry.name]; !ok || scheme.Compare(floor, h) > 0 {
+				highest[entry.name] = floor
+			}
+		}
+	}
+
+	for _, a := range entries {
+		target := highest[a.entry.name]
+		if scheme.Compare(target, a.floor) <= 0 {
+			continue
+		}
+		if !u.latest {
+			if !npmSatisfies(target, a.entry.spec) {
+				utils.Info("update", "Not aligning %s in %s: %s is outside %s (use -latest to allow it)", a.entry.name, a.plan.path, target, a.entry.spec)
+				continue
+			}
+		}
+
+		if err := a.plan.set(a.entry.section, a.entry.name, a.entry.path, a.entry.spec, a.op+target.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceUpdate(t *testing.T) {
+	files := map[string]string{
+		"package.json":            `{"private": true, "workspaces": ["packages/*"], "devDependencies": {"typescript": "^5.0.0"}}`,
+		"packages/a/package.json": `{"name": "@repo/a", "version": "1.3.0", "dependencies": {"lodash": "^4.17.0"}}`,
+		"packages/b/package.json": `{"name": "@repo/b", "version": "0.1.0", "dependencies": {"@repo/a": "^1.0.0", "@repo/c": "workspace:*"}, "devDependencies": {"typescript": "^5.2.0"}}`,
+		"packages/c/package.json": `{"name": "@repo/c", "version": "2.0.0", "peerDependencies": {"@repo/a": "^1.0.0"}}`,
+	}
+
+	tests := []struct {
+		name     string
+		align    bool
+		expected map[string]string
+	}{
+		{
+			name: "internal references",
+			expected: map[string]string{
+				"package.json":            `{"private": true, "workspaces": ["packages/*"], "devDependencies": {"typescript": "^5.0.0"}}`,
+				"packages/a/package.json": `{"name": "@repo/a", "version": "1.3.0", "dependencies": {"lodash": "^4.17.21"}}`,
+				"packages/b/package.json": `{"name": "@repo/b", "version": "0.1.0", "dependencies": {"@repo/a": "^1.3.0", "@repo/c": "workspace:*"}, "devDependencies": {"typescript": "^5.2.0"}}`,
+				"packages/c/package.json": `{"name": "@repo/c", "version": "2.0.0", "peerDependencies": {"@repo/a": "^1.0.0"}}`,
+			},
+		},
+		{
+			name:  "aligned",
+			align: true,
+			expected: map[string]string{
+				"package.json":            `{"private": true, "workspaces": ["packages/*"], "devDependencies": {"typescript": "^5.2.0"}}`,
+				"packages/a/package.json": `{"name": "@repo/a", "version": "1.3.0", "dependencies": {"lodash": "^4.17.21"}}`,
+				"packages/b/package.json": `{"name": "@repo/b", "version": "0.1.0", "dependencies": {"@repo/a": "^1.3.0", "@repo/c": "workspace:*"}, "devDependencies": {"typescript": "^5.2.0"}}`,
+				"packages/c/package.json": `{"name": "@repo/c", "version": "2.0.0", "peerDependencies": {"@repo/a": "^1.0.0"}}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var requested []string
+			server := newTestNPMRegistry(func(pkg string) (string, error) {
+				requested = append(requested, pkg)
+				if pkg == "lodash" {
+					return "4.17.21", nil
+				}
+				return "", fmt.Errorf("package %s not found", pkg)
+			}, nil)
+			defer server.Close()
+
+			updater := &Updater{npm: newTestNPMClient(server)}
+			updater.SetAlignWorkspaces(tt.align)
+			if err := updater.ProcessDirectory(dir); err != nil {
+				t.Fatalf("ProcessDirectory() failed: %v", err)
+			}
+
+			for name, want := range tt.expected {
+				got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s:\nwant: %s\ngot:  %s", name, want, string(got))
+				}
+			}
+			for _, pkg := range requested {
+				if pkg == "@repo/a" || pkg == "@repo/b" || pkg == "@repo/c" {
+					t.Errorf("workspace package %s was looked up on the registry", pkg)
+				}
+			}
+			if summary := updater.skippedSummary(); summary != "" {
+				t.Errorf("expected workspace: references not to be reported, got:\n%s", summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package versioning
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParseLevel parses the name of a level: "patch", "minor" or "major".
+func ParseLevel(s string) (Level, error) {
+	for _, l := range []Level{Patch, Minor, Major} {
+		if s == l.String() {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown update level %q (use patch, minor or major)", s)
+}
+
+// IsCalendar reports whether a version is calendar versioned: its first
+// release segment is a four-digit year such as 2024, or a date such as
+// 20240501.
+func IsCalendar(v Version) bool {
+	return dateSegments(releaseSegments(v.String())) > 0
+}
+
+// dateSegments returns how many release segments of a calendar version make
+// up its date: the year, and the month if the next segment can be one. It
+// returns 0 for versions that are not calendar versioned.
+func dateSegments(parts []string) int {
+	if len(parts) == 0 {
+		return 0
+	}
+	first, err := strconv.ParseUint(parts[0], 10, 64)
+	switch {
+	case err != nil:
+		return 0
+	case len(parts[0]) == 8 && first >= 19900101 && first <= 29991231:
+		return 1
+	case len(parts[0]) != 4 || first < 1990 || first > 2999:
+		return 0
+	}
+	if len(parts) > 1 {
+		if month, err := strconv.ParseUint(parts[1], 10, 64); err == nil && month >= 1 && month <= 12 {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Change returns the level of an update from one version to another: Major
+// if the first release segment (or the epoch) differs, Minor if the second
+// does and Patch otherwise. Calendar versions are classified by date
+// instead: a new year or month is a new release rather than a breaking
+// change, so it is Minor, and changes after the date are Patch. A move
+// between calendar and other versioning is Major.
+func Change(from, to Version) Level {
+	a, b := releaseRegex.FindStringSubmatch(from.String()), releaseRegex.FindStringSubmatch(to.String())
+	if a == nil || b == nil || a[1] != b[1] {
+		return Major
+	}
+	x, y := releaseSegments(from.String()), releaseSegments(to.String())
+
+	if dx, dy := dateSegments(x), dateSegments(y); dx > 0 || dy > 0 {
+		if dx == 0 || dy == 0 {
+			return Major
+		}
+		for i := 0; i < max(dx, dy); i++ {
+			if segment(x, i) != segment(y, i) {
+				return Minor
+			}
+		}
+		return Patch
+	}
+
+	switch {
+	case segment(x, 0) != segment(y, 0):
+		return Major
+	case segment(x, 1) != segment(y, 1):
+		return Minor
+	}
+	return Patch
+}
+
+// segment returns release segment i as a number, 0 if it is missing, so
+// that "1.0" and "1.0.0" have the same segments
+func segment(parts []string, i int) uint64 {
+	if i >= len(parts) {
+		return 0
+	}
+	n, _ := strconv.ParseUint(parts[i], 10, 64)
+	return n
+}
+
+// Highest returns the highest of versions that is newer than current and
+// does not cross level, e.g. the newest 1.4.x for 1.4.2 at Patch.
+// Prereleases are skipped unless current is one, and versions the scheme
+// cannot parse are ignored. It returns "" if no version qualifies.
+func Highest(s Scheme, versions []string, current string, level Level) (string, error) {
+	cur, err := s.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	best, bestVersion := "", cur
+	for _, raw := range versions {
+		v, err := s.Parse(raw)
+		if err != nil || s.Compare(v, bestVersion) <= 0 {
+			continue
+		}
+		if s.IsPrerelease(v) && !s.IsPrerelease(cur) {
+			continue
+		}
+		if Change(cur, v) > level {
+			continue
+		}
+		best, bestVersion = raw, v
+	}
+	return best, nil
+}
@@ -0,0 +1,99 @@
+package versioning
+
+import "testing"
+
+func TestChange(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		from, to string
+		expected Level
+	}{
+		{PEP440, "1.4.2", "1.4.3", Patch},
+		{PEP440, "1.4.2", "1.5.0", Minor},
+		{PEP440, "1.4.2", "2.0.0", Major},
+		{PEP440, "1.4", "1.4.0.1", Patch},
+		{PEP440, "1.4.2", "1!1.4.3", Major},
+		{NodeSemver, "0.2.1", "0.3.0", Minor},
+		{NodeSemver, "1.2.3", "1.2.4-rc.1", Patch},
+		// Calendar versions: a new year or month is a minor update
+		{PEP440, "2024.2.2", "2024.2.28", Patch},
+		{PEP440, "2024.2.2", "2024.7.4", Minor},
+		{PEP440, "2024.2.2", "2025.1.31", Minor},
+		{PEP440, "2023.3", "2024.1", Minor},
+		{PEP440, "2024.1", "2024.1.1", Patch},
+		{Loose, "20240101", "20240315", Minor},
+		{PEP440, "1.9.0", "2024.1.0", Major},
+	}
+
+	for _, tt := range tests {
+		from, _ := tt.scheme.Parse(tt.from)
+		to, _ := tt.scheme.Parse(tt.to)
+		if got := Change(from, to); got != tt.expected {
+			t.Errorf("%s: Change(%s, %s) = %s, want %s", tt.scheme.Name(), tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestIsCalendar(t *testing.T) {
+	for version, expected := range map[string]bool{
+		"2024.2.2":  true,
+		"2024.1":    true,
+		"20240501":  true,
+		"1.2.3":     false,
+		"24.1.0":    false,
+		"1000.0":    false,
+		"2024":      true,
+		"3000.1.0":  false,
+		"2024.13.1": true,
+	} {
+		v, err := PEP440.Parse(version)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", version, err)
+		}
+		if got := IsCalendar(v); got != expected {
+			t.Errorf("IsCalendar(%s) = %v, want %v", version, got, expected)
+		}
+	}
+}
+
+func TestHighest(t *testing.T) {
+	versions := []string{"1.4.2", "1.4.9", "1.4.10rc1", "1.5.0", "1.6.3", "2.0.0", "2.1.0rc1", "not-a-version"}
+	tests := []struct {
+		current  string
+		level    Level
+		expected string
+	}{
+		{"1.4.2", Patch, "1.4.9"},
+		{"1.4.2", Minor, "1.6.3"},
+		{"1.4.2", Major, "2.0.0"},
+		{"1.6.3", Patch, ""},
+		{"2.0.0", Major, ""},
+		{"2.1.0b1", Patch, "2.1.0rc1"},
+	}
+
+	for _, tt := range tests {
+		got, err := Highest(PEP440, versions, tt.current, tt.level)
+		if err != nil {
+			t.Errorf("Highest(%s, %s) failed: %v", tt.current, tt.level, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Highest(%s, %s) = %q, want %q", tt.current, tt.level, got, tt.expected)
+		}
+	}
+
+	if _, err := Highest(PEP440, versions, "latest", Patch); err == nil {
+		t.Error("expected Highest to fail for an invalid current version")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{Patch, Minor, Major} {
+		if got, err := ParseLevel(l.String()); err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %s, %v", l, got, err)
+		}
+	}
+	if _, err := ParseLevel("breaking"); err == nil {
+		t.Error("expected ParseLevel(\"breaking\") to fail")
+	}
+}
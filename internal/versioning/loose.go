@@ -0,0 +1,136 @@
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Loose is the fallback scheme for versions that follow no specification:
+// dot-separated numbers followed by an optional suffix, e.g. "2.4.1b3" or
+// "2024.05-hotfix". A suffix makes a version a prerelease that sorts before
+// the version without it; "+" starts build metadata, which is ignored.
+// Constraints are comma-separated comparisons such as ">=1.2,!=1.3,<2".
+var Loose Scheme = looseScheme{}
+
+type looseScheme struct{}
+
+// looseVersion is a version parsed by Loose
+type looseVersion struct {
+	raw    string
+	parts  []uint64
+	suffix string
+}
+
+func (v *looseVersion) String() string { return v.raw }
+
+var (
+	looseVersionRegex    = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)([^+]*)(?:\+.*)?$`)
+	looseConstraintRegex = regexp.MustCompile(`^(==|!=|>=|<=|>|<|=)?\s*(.+)$`)
+)
+
+func (looseScheme) Name() string { return "loose" }
+
+func (looseScheme) Parse(s string) (Version, error) {
+	s = strings.TrimSpace(s)
+	m := looseVersionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	v := &looseVersion{raw: s, suffix: strings.TrimLeft(m[2], ".-_")}
+	for _, part := range strings.Split(m[1], ".") {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.parts = append(v.parts, n)
+	}
+	return v, nil
+}
+
+func (looseScheme) Compare(a, b Version) int {
+	x, y := a.(*looseVersion), b.(*looseVersion)
+	for i := 0; i < len(x.parts) || i < len(y.parts); i++ {
+		var p, q uint64
+		if i < len(x.parts) {
+			p = x.parts[i]
+		}
+		if i < len(y.parts) {
+			q = y.parts[i]
+		}
+		switch {
+		case p < q:
+			return -1
+		case p > q:
+			return 1
+		}
+	}
+	switch {
+	case x.suffix == y.suffix:
+		return 0
+	case x.suffix == "":
+		return 1
+	case y.suffix == "":
+		return -1
+	}
+	return strings.Compare(x.suffix, y.suffix)
+}
+
+func (s looseScheme) Satisfies(v Version, constraint string) (bool, error) {
+	for _, clause := range strings.Split(constraint, ",") {
+		m := looseConstraintRegex.FindStringSubmatch(strings.TrimSpace(clause))
+		if m == nil {
+			return false, fmt.Errorf("invalid constraint %q", constraint)
+		}
+		other, err := s.Parse(m[2])
+		if err != nil {
+			return false, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+		}
+		c := s.Compare(v, other)
+		ok := false
+		switch m[1] {
+		case "", "=", "==":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		case ">=":
+			ok = c >= 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case "<":
+			ok = c < 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (looseScheme) Bump(v Version, level Level) Version {
+	lv := v.(*looseVersion)
+	// Missing parts count as zero, so 1 bumped at Patch is 1.0.1
+	parts := make([]uint64, max(len(lv.parts), 3))
+	copy(parts, lv.parts)
+	i := int(Major - level)
+	parts[i]++
+	for j := i + 1; j < len(parts); j++ {
+		parts[j] = 0
+	}
+	raw := make([]string, len(parts))
+	for j, n := range parts {
+		raw[j] = strconv.FormatUint(n, 10)
+	}
+	return &looseVersion{raw: strings.Join(raw, "."), parts: parts}
+}
+
+func (looseScheme) IsPrerelease(v Version) bool {
+	return v.(*looseVersion).suffix != ""
+}
+
+func (s looseScheme) UpdateRange(constraint string, v Version, strategy RangeStrategy) (string, error) {
+	return updateRange(s, &specifierSyntax{}, constraint, v, strategy)
+}
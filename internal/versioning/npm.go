@@ -0,0 +1,147 @@
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rvben/ru/internal/nodesemver"
+)
+
+// NodeSemver is the scheme of npm packages. Constraints are npm ranges such
+// as "^1.2.0 || ^2.0.0".
+var NodeSemver Scheme = nodeSemverScheme{}
+
+type nodeSemverScheme struct{}
+
+// partialVersionRegex matches versions missing their minor or patch number
+var partialVersionRegex = regexp.MustCompile(`^v?\d+(\.\d+)?$`)
+
+func (nodeSemverScheme) Name() string { return "semver" }
+
+// Parse parses a version. Incomplete versions such as "1.2", as found in
+// ranges, are completed with zeros.
+func (nodeSemverScheme) Parse(s string) (Version, error) {
+	v, err := nodesemver.Parse(s)
+	if err != nil && partialVersionRegex.MatchString(s) {
+		return nodesemver.Coerce(s)
+	}
+	return v, err
+}
+
+func (nodeSemverScheme) Compare(a, b Version) int {
+	return a.(*nodesemver.Version).Compare(b.(*nodesemver.Version))
+}
+
+func (nodeSemverScheme) Satisfies(v Version, constraint string) (bool, error) {
+	rng, err := nodesemver.ParseRange(constraint)
+	if err != nil {
+		return false, err
+	}
+	return rng.Test(v.(*nodesemver.Version)), nil
+}
+
+func (nodeSemverScheme) Bump(v Version, level Level) Version {
+	sv := v.(*nodesemver.Version)
+	switch level {
+	case Major:
+		return &nodesemver.Version{Major: sv.Major + 1}
+	case Minor:
+		return &nodesemver.Version{Major: sv.Major, Minor: sv.Minor + 1}
+	default:
+		return &nodesemver.Version{Major: sv.Major, Minor: sv.Minor, Patch: sv.Patch + 1}
+	}
+}
+
+func (nodeSemverScheme) IsPrerelease(v Version) bool {
+	return v.(*nodesemver.Version).IsPrerelease()
+}
+
+func (s nodeSemverScheme) UpdateRange(constraint string, v Version, strategy RangeStrategy) (string, error) {
+	return updateRange(s, npmSyntax{}, constraint, v, strategy)
+}
+
+var (
+	// hyphenRangeRegex matches an npm hyphen range such as "1.2.3 - 2.3.4"
+	hyphenRangeRegex = regexp.MustCompile(`^\s*(\S+)\s+-\s+(\S+)\s*$`)
+	// npmClauseRegex splits a comparison of an npm range
+	npmClauseRegex = regexp.MustCompile(`^(<=|>=|<|>|=|\^|~)?v?(.*)$`)
+	// npmWildcardRegex matches the wildcard of an x-range such as "1.2.x"
+	npmWildcardRegex = regexp.MustCompile(`\.[xX*](?:\.[xX*])*$`)
+)
+
+// npmSyntax writes ranges the way npm does: space-separated comparisons
+// joined by "||"
+type npmSyntax struct{}
+
+func (npmSyntax) parse(constraint string) ([][]rangeClause, error) {
+	var out [][]rangeClause
+	for _, alt := range strings.Split(constraint, "||") {
+		var clauses []rangeClause
+		if m := hyphenRangeRegex.FindStringSubmatch(alt); m != nil {
+			// "1.2.3 - 2.3.4" is ">=1.2.3 <=2.3.4"
+			clauses = append(clauses,
+				rangeClause{kind: clauseLower, op: ">=", version: m[1]},
+				rangeClause{kind: clauseUpper, op: "<=", version: m[2]})
+			out = append(out, clauses)
+			continue
+		}
+		for _, field := range strings.Fields(operatorSpaceRegex.ReplaceAllString(alt, "$1")) {
+			m := npmClauseRegex.FindStringSubmatch(field)
+			c := rangeClause{op: m[1], version: m[2]}
+			switch c.op {
+			case ">=", ">":
+				c.kind = clauseLower
+			case "<=", "<":
+				c.kind = clauseUpper
+			case "^":
+				c.kind = clauseCaret
+			case "~":
+				c.kind = clauseTilde
+			default:
+				switch {
+				case c.version == "*" || c.version == "x" || c.version == "X":
+					c.kind = clauseAny
+				case npmWildcardRegex.MatchString(c.version):
+					c.kind = clausePrefix
+					c.wildcard = npmWildcardRegex.FindString(c.version)
+					c.version = strings.TrimSuffix(c.version, c.wildcard)
+				case partialVersionRegex.MatchString(c.version):
+					// A partial version is an x-range written without the x
+					c.kind = clausePrefix
+				default:
+					if _, err := nodesemver.Parse(c.version); err != nil {
+						return nil, fmt.Errorf("invalid range %q: %w", constraint, err)
+					}
+					c.kind = clauseExact
+				}
+			}
+			clauses = append(clauses, c)
+		}
+		if len(clauses) == 0 {
+			clauses = append(clauses, rangeClause{kind: clauseAny, version: "*"})
+		}
+		out = append(out, clauses)
+	}
+	return out, nil
+}
+
+func (npmSyntax) format(alternatives [][]rangeClause) string {
+	parts := make([]string, len(alternatives))
+	for i, clauses := range alternatives {
+		written := make([]string, len(clauses))
+		for j, c := range clauses {
+			written[j] = c.op + c.version + c.wildcard
+		}
+		parts[i] = strings.Join(written, " ")
+	}
+	return strings.Join(parts, " || ")
+}
+
+func (npmSyntax) exact(version string) string {
+	return version
+}
+
+func (npmSyntax) hasUnion() bool {
+	return true
+}
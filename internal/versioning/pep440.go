@@ -0,0 +1,56 @@
+package versioning
+
+import (
+	"strings"
+
+	"github.com/rvben/ru/internal/pep440"
+)
+
+// PEP440 is the scheme of Python packages. Constraints are specifier sets
+// such as ">=1.4,!=1.5.*"; a bare version is an exact pin.
+var PEP440 Scheme = pep440Scheme{}
+
+type pep440Scheme struct{}
+
+func (pep440Scheme) Name() string { return "pep440" }
+
+func (pep440Scheme) Parse(s string) (Version, error) {
+	return pep440.Parse(s)
+}
+
+func (pep440Scheme) Compare(a, b Version) int {
+	return a.(*pep440.Version).Compare(b.(*pep440.Version))
+}
+
+func (pep440Scheme) Satisfies(v Version, constraint string) (bool, error) {
+	constraint = strings.TrimSpace(constraint)
+	if _, err := pep440.Parse(constraint); err == nil {
+		constraint = "==" + constraint
+	}
+	set, err := pep440.ParseSpecifierSet(constraint)
+	if err != nil {
+		return false, err
+	}
+	return set.Contains(v.(*pep440.Version)), nil
+}
+
+func (pep440Scheme) Bump(v Version, level Level) Version {
+	pv := v.(*pep440.Version)
+	// Major bumps the first release segment, Minor the second and so on
+	i := int(Major - level)
+	release := make([]uint64, max(len(pv.Release), i+1))
+	copy(release[:i], pv.Release)
+	if i < len(pv.Release) {
+		release[i] = pv.Release[i]
+	}
+	release[i]++
+	return &pep440.Version{Epoch: pv.Epoch, Release: release}
+}
+
+func (pep440Scheme) IsPrerelease(v Version) bool {
+	return v.(*pep440.Version).IsPrerelease()
+}
+
+func (s pep440Scheme) UpdateRange(constraint string, v Version, strategy RangeStrategy) (string, error) {
+	return updateRange(s, &specifierSyntax{}, constraint, v, strategy)
+}
@@ -0,0 +1,63 @@
+package versioning
+
+import (
+	"strings"
+
+	"github.com/rvben/ru/internal/pep440"
+)
+
+// Poetry is the scheme of Poetry dependency tables. Versions are PEP 440
+// versions; constraints add ^ and ~, bare and wildcard versions and "||"
+// alternatives to PEP 440 specifiers, e.g. "^1.2 || >=2.1,<3".
+var Poetry Scheme = poetryScheme{}
+
+type poetryScheme struct {
+	pep440Scheme
+}
+
+func (poetryScheme) Name() string { return "poetry" }
+
+func (s poetryScheme) Satisfies(v Version, constraint string) (bool, error) {
+	alternatives, err := (&specifierSyntax{poetry: true}).parse(constraint)
+	if err != nil {
+		return false, err
+	}
+	for _, clauses := range alternatives {
+		set, err := pep440.ParseSpecifierSet(s.specifiers(clauses))
+		if err != nil {
+			return false, err
+		}
+		if set.Contains(v.(*pep440.Version)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// specifiers returns the PEP 440 specifiers equivalent to an alternative:
+// ^1.2.3 is >=1.2.3,<2.0.0 and ~1.2.3 is >=1.2.3,<1.3.0
+func (s poetryScheme) specifiers(clauses []rangeClause) string {
+	var parts []string
+	for _, c := range clauses {
+		switch c.kind {
+		case clauseAny:
+		case clausePrefix:
+			parts = append(parts, "=="+c.version+".*")
+		case clauseExact:
+			parts = append(parts, "=="+c.version)
+		case clauseCaret, clauseTilde:
+			level, _ := impliedLevel(c)
+			parts = append(parts, ">="+c.version)
+			if v, err := s.Parse(c.version); err == nil {
+				parts = append(parts, "<"+s.Bump(v, level).String())
+			}
+		default:
+			parts = append(parts, c.op+c.version)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s poetryScheme) UpdateRange(constraint string, v Version, strategy RangeStrategy) (string, error) {
+	return updateRange(s, &specifierSyntax{poetry: true}, constraint, v, strategy)
+}
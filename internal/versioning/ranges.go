@@ -0,0 +1,464 @@
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RangeStrategy is how a version range is rewritten for a new version.
+type RangeStrategy string
+
+const (
+	// RangePin replaces the range with the exact new version
+	RangePin RangeStrategy = "pin"
+	// RangeBump raises the lower bounds of the range to the new version
+	RangeBump RangeStrategy = "bump"
+	// RangeWiden extends the upper bounds of the range to admit the new version
+	RangeWiden RangeStrategy = "widen"
+	// RangeReplace rewrites a range that excludes the new version around it
+	RangeReplace RangeStrategy = "replace"
+)
+
+// RangeStrategies lists the supported strategies.
+var RangeStrategies = []RangeStrategy{RangePin, RangeBump, RangeWiden, RangeReplace}
+
+// ParseRangeStrategy parses the name of a range strategy.
+func ParseRangeStrategy(s string) (RangeStrategy, error) {
+	for _, strategy := range RangeStrategies {
+		if s == string(strategy) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown range strategy %q (use pin, bump, widen or replace)", s)
+}
+
+// UpdateRange is Scheme.UpdateRange for a version that still needs to be
+// parsed.
+func UpdateRange(s Scheme, constraint, version string, strategy RangeStrategy) (string, error) {
+	v, err := s.Parse(version)
+	if err != nil {
+		return "", err
+	}
+	return s.UpdateRange(constraint, v, strategy)
+}
+
+// clauseKind classifies the comparisons of a range by how a strategy moves them
+type clauseKind int
+
+const (
+	clauseExact      clauseKind = iota // ==1.2.3, =1.2.3 or 1.2.3
+	clauseNot                          // !=1.2.3
+	clauseLower                        // >=1.2.3 or >1.2.3
+	clauseUpper                        // <2.0 or <=2.0
+	clauseCaret                        // ^1.2.3
+	clauseTilde                        // ~1.2.3
+	clauseCompatible                   // ~=1.2
+	clausePrefix                       // ==1.2.*, 1.2.* or 1.2.x
+	clauseAny                          // * or x
+	clauseArbitrary                    // ===foo
+)
+
+// rangeClause is a single comparison of a range as written
+type rangeClause struct {
+	kind clauseKind
+	op   string
+	// version is the version without operator and wildcard suffix
+	version string
+	// wildcard is the suffix of a prefix clause as written, e.g. ".*" or ".x"
+	wildcard string
+}
+
+// rangeSyntax is the way an ecosystem writes ranges
+type rangeSyntax interface {
+	// parse splits a range into alternatives of clauses that must all hold
+	parse(constraint string) ([][]rangeClause, error)
+	// format writes alternatives back, as close to the input style as possible
+	format(alternatives [][]rangeClause) string
+	// exact returns the range that only accepts version
+	exact(version string) string
+	// hasUnion reports whether ranges can have several alternatives
+	hasUnion() bool
+}
+
+// updateRange rewrites constraint for v following strategy. It returns the
+// constraint unchanged when the strategy has nothing to do, and an error
+// when the strategy cannot produce a range that accepts v.
+func updateRange(s Scheme, syntax rangeSyntax, constraint string, v Version, strategy RangeStrategy) (string, error) {
+	alternatives, err := syntax.parse(constraint)
+	if err != nil {
+		return "", err
+	}
+	if len(alternatives) == 1 && len(alternatives[0]) == 1 && alternatives[0][0].kind == clauseExact {
+		// Exact versions are pins whatever the strategy; they only move up
+		if !below(s, alternatives[0][0].version, v) {
+			return constraint, nil
+		}
+		alternatives[0][0].version = v.String()
+		return syntax.format(alternatives), nil
+	}
+	if strategy == RangePin {
+		return syntax.exact(v.String()), nil
+	}
+	accepts := func(c string) bool {
+		ok, err := s.Satisfies(v, c)
+		return err == nil && ok
+	}
+
+	switch strategy {
+	case RangeBump:
+		// Raise the alternative that already accepts v, else the last one
+		i := len(alternatives) - 1
+		for j, alt := range alternatives {
+			if accepts(syntax.format([][]rangeClause{alt})) {
+				i = j
+				break
+			}
+		}
+		before := syntax.format(alternatives)
+		alternatives[i] = bumpClauses(s, alternatives[i], v)
+		if syntax.format(alternatives) == before {
+			// No lower bound is below v
+			return constraint, nil
+		}
+	case RangeWiden:
+		if accepts(constraint) {
+			return constraint, nil
+		}
+		last := alternatives[len(alternatives)-1]
+		if syntax.hasUnion() && len(last) == 1 && movesAsUnit(last[0].kind) {
+			// ^1.2.0 becomes ^1.2.0 || ^3.1.0, the way npm ranges are widened
+			alternatives = append(alternatives, bumpClauses(s, []rangeClause{last[0]}, v))
+		} else {
+			alternatives[len(alternatives)-1] = widenClauses(s, last, v)
+		}
+	case RangeReplace:
+		if accepts(constraint) {
+			return constraint, nil
+		}
+		last := alternatives[len(alternatives)-1]
+		alternatives = [][]rangeClause{raiseUpperBounds(s, bumpClauses(s, last, v), v)}
+	default:
+		return "", fmt.Errorf("unknown range strategy %q", strategy)
+	}
+
+	updated := syntax.format(alternatives)
+	if !accepts(updated) {
+		return "", fmt.Errorf("cannot %s %s to accept %s", strategy, constraint, v)
+	}
+	return updated, nil
+}
+
+// movesAsUnit reports whether a clause implies its own upper bound, so that
+// moving its version moves the whole range
+func movesAsUnit(kind clauseKind) bool {
+	return kind == clauseCaret || kind == clauseTilde || kind == clauseCompatible || kind == clausePrefix
+}
+
+// bumpClauses raises every lower bound below v to v. Clauses with an
+// implied upper bound keep the precision they were written with, so ~=1.4
+// becomes ~=3.1 for 3.1.4.
+func bumpClauses(s Scheme, clauses []rangeClause, v Version) []rangeClause {
+	out := make([]rangeClause, len(clauses))
+	for i, c := range clauses {
+		out[i] = c
+		if !below(s, c.version, v) {
+			continue
+		}
+		switch c.kind {
+		case clauseLower:
+			out[i].op = ">="
+			out[i].version = v.String()
+		case clauseExact, clauseCaret, clauseTilde:
+			out[i].version = v.String()
+		case clauseCompatible, clausePrefix:
+			out[i].version = truncate(v.String(), segments(c.version))
+		}
+	}
+	return out
+}
+
+// widenClauses extends the upper bounds of an alternative to admit v,
+// turning clauses with an implied upper bound into explicit bounds
+func widenClauses(s Scheme, clauses []rangeClause, v Version) []rangeClause {
+	var out []rangeClause
+	for _, c := range clauses {
+		level, implied := impliedLevel(c)
+		if !implied {
+			out = append(out, c)
+			continue
+		}
+		floor := c.version
+		if c.kind == clausePrefix {
+			floor = truncate(floor, max(segments(floor), 2))
+		}
+		upper := truncate(s.Bump(v, level).String(), max(segments(c.version), levelIndex(level)+1))
+		out = append(out,
+			rangeClause{kind: clauseLower, op: ">=", version: floor},
+			rangeClause{kind: clauseUpper, op: "<", version: upper})
+	}
+	return raiseUpperBounds(s, out, v)
+}
+
+// raiseUpperBounds moves explicit upper bounds that exclude v above it.
+// "<2.0" is an upper bound at the major level, so for 3.1.4 it becomes
+// "<4.0"; "<=2.0" simply becomes "<=3.1.4".
+func raiseUpperBounds(s Scheme, clauses []rangeClause, v Version) []rangeClause {
+	out := make([]rangeClause, len(clauses))
+	for i, c := range clauses {
+		out[i] = c
+		if c.kind != clauseUpper {
+			continue
+		}
+		bound, err := s.Parse(c.version)
+		if err != nil {
+			continue
+		}
+		cmp := s.Compare(v, bound)
+		switch {
+		case c.op == "<=" && cmp > 0:
+			out[i].version = v.String()
+		case c.op == "<" && cmp >= 0:
+			level := boundLevel(c.version)
+			out[i].version = truncate(s.Bump(v, level).String(), max(segments(c.version), levelIndex(level)+1))
+		}
+	}
+	return out
+}
+
+// impliedLevel returns the level at which a clause with an implied upper
+// bound stops accepting versions, e.g. Major for ^1.2.3 and Minor for ~1.2.3
+func impliedLevel(c rangeClause) (Level, bool) {
+	n := segments(c.version)
+	switch c.kind {
+	case clauseCaret:
+		// The first non-zero segment may not change
+		for i, part := range releaseSegments(c.version) {
+			if part != "0" || i == n-1 {
+				return indexLevel(i), true
+			}
+		}
+		return Major, true
+	case clauseTilde:
+		if n == 1 {
+			return Major, true
+		}
+		return Minor, true
+	case clauseCompatible:
+		return indexLevel(n - 2), true
+	case clausePrefix:
+		return indexLevel(n - 1), true
+	}
+	return 0, false
+}
+
+// boundLevel returns the level of an exclusive upper bound: the position of
+// its last non-zero segment, so "<2.0" is a major bound and "<1.5" a minor one
+func boundLevel(version string) Level {
+	parts := releaseSegments(version)
+	for i := len(parts) - 1; i >= 0; i-- {
+		if strings.Trim(parts[i], "0") != "" {
+			return indexLevel(i)
+		}
+	}
+	return Major
+}
+
+func indexLevel(i int) Level {
+	switch {
+	case i <= 0:
+		return Major
+	case i == 1:
+		return Minor
+	}
+	return Patch
+}
+
+func levelIndex(l Level) int {
+	return int(Major - l)
+}
+
+// below reports whether the version written in a clause is lower than v
+func below(s Scheme, version string, v Version) bool {
+	cv, err := s.Parse(version)
+	return err == nil && s.Compare(cv, v) < 0
+}
+
+// releaseRegex matches the epoch and release segments at the start of a version
+var releaseRegex = regexp.MustCompile(`^(\d+!)?v?(\d+(?:\.\d+)*)`)
+
+// releaseSegments returns the release numbers a version starts with
+func releaseSegments(version string) []string {
+	m := releaseRegex.FindStringSubmatch(version)
+	if m == nil {
+		return nil
+	}
+	return strings.Split(m[2], ".")
+}
+
+// segments returns the number of release numbers a version is written with
+func segments(version string) int {
+	return len(releaseSegments(version))
+}
+
+// truncate returns the release of a version cut or padded to n segments,
+// keeping its epoch, e.g. "3.1" for "3.1.4rc1" and n = 2
+func truncate(version string, n int) string {
+	m := releaseRegex.FindStringSubmatch(version)
+	if m == nil {
+		return version
+	}
+	parts := strings.Split(m[2], ".")
+	for len(parts) < n {
+		parts = append(parts, "0")
+	}
+	return m[1] + strings.Join(parts[:n], ".")
+}
+
+var (
+	// specifierClauseRegex splits a comparison of a comma-separated range
+	specifierClauseRegex = regexp.MustCompile(`^(===|~=|==|!=|<=|>=|<|>|\^|~|=)?\s*(\S+)$`)
+	// operatorSpaceRegex matches the spaces between an operator and its
+	// version, which ranges separated by spaces allow
+	operatorSpaceRegex = regexp.MustCompile(`([<>=!~^]+)\s+`)
+)
+
+// specifierSyntax writes ranges as comma-separated comparisons, as PEP 440
+// does. With poetry set it also accepts Poetry's ^, ~, bare and wildcard
+// versions and "||" alternatives.
+type specifierSyntax struct {
+	poetry bool
+	// separator joins the clauses of an alternative as in the last input
+	separator string
+}
+
+func (p *specifierSyntax) parse(constraint string) ([][]rangeClause, error) {
+	p.separator = ","
+	if strings.Contains(constraint, ", ") {
+		p.separator = ", "
+	}
+	alternatives := []string{constraint}
+	if p.poetry {
+		alternatives = strings.Split(constraint, "||")
+	}
+
+	var out [][]rangeClause
+	for _, alt := range alternatives {
+		var clauses []rangeClause
+		fields := strings.Split(alt, ",")
+		if p.poetry {
+			fields = strings.FieldsFunc(operatorSpaceRegex.ReplaceAllString(alt, "$1"), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+		}
+		for _, field := range fields {
+			c, err := p.parseClause(strings.TrimSpace(field))
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: %w", constraint, err)
+			}
+			clauses = append(clauses, c)
+		}
+		out = append(out, clauses)
+	}
+	return out, nil
+}
+
+func (p *specifierSyntax) parseClause(s string) (rangeClause, error) {
+	m := specifierClauseRegex.FindStringSubmatch(s)
+	if m == nil {
+		return rangeClause{}, fmt.Errorf("invalid clause %q", s)
+	}
+	op, version := m[1], m[2]
+	if !p.poetry && (op == "^" || op == "~") {
+		return rangeClause{}, fmt.Errorf("invalid clause %q", s)
+	}
+	c := rangeClause{op: op, version: version}
+	switch op {
+	case "===":
+		c.kind = clauseArbitrary
+	case "!=":
+		c.kind = clauseNot
+	case ">=", ">":
+		c.kind = clauseLower
+	case "<=", "<":
+		c.kind = clauseUpper
+	case "^":
+		c.kind = clauseCaret
+	case "~":
+		c.kind = clauseTilde
+	case "~=":
+		c.kind = clauseCompatible
+	default:
+		switch {
+		case version == "*":
+			c.kind = clauseAny
+		case strings.HasSuffix(version, ".*"):
+			c.kind = clausePrefix
+			c.version, c.wildcard = strings.TrimSuffix(version, ".*"), ".*"
+		default:
+			c.kind = clauseExact
+		}
+	}
+	return c, nil
+}
+
+func (p *specifierSyntax) format(alternatives [][]rangeClause) string {
+	parts := make([]string, len(alternatives))
+	for i, clauses := range alternatives {
+		written := make([]string, len(clauses))
+		for j, c := range clauses {
+			written[j] = c.op + c.version + c.wildcard
+		}
+		parts[i] = strings.Join(written, p.separator)
+	}
+	return strings.Join(parts, " || ")
+}
+
+func (p *specifierSyntax) exact(version string) string {
+	if p.poetry {
+		// Poetry writes exact versions bare
+		return version
+	}
+	return "==" + version
+}
+
+func (p *specifierSyntax) hasUnion() bool {
+	return p.poetry
+}
+
+// Floor returns the lowest version a constraint is written against: that
+// of its lowest exact, lower bound, ^, ~, ~= or wildcard clause, e.g. "1.4"
+// for ">=1.4,<2". It returns "" if the constraint has no such clause.
+func Floor(s Scheme, constraint string) string {
+	var syntax rangeSyntax = &specifierSyntax{}
+	switch s.(type) {
+	case poetryScheme:
+		syntax = &specifierSyntax{poetry: true}
+	case nodeSemverScheme:
+		syntax = npmSyntax{}
+	}
+	alternatives, err := syntax.parse(constraint)
+	if err != nil {
+		return ""
+	}
+
+	floor := ""
+	var floorVersion Version
+	for _, clauses := range alternatives {
+		for _, c := range clauses {
+			switch c.kind {
+			case clauseExact, clauseLower, clauseCaret, clauseTilde, clauseCompatible, clausePrefix:
+			default:
+				continue
+			}
+			v, err := s.Parse(c.version)
+			if err != nil {
+				continue
+			}
+			if floorVersion == nil || s.Compare(v, floorVersion) < 0 {
+				floor, floorVersion = c.version, v
+			}
+		}
+	}
+	return floor
+}
@@ -0,0 +1,138 @@
+package versioning
+
+import "testing"
+
+func TestUpdateRange(t *testing.T) {
+	tests := []struct {
+		scheme     Scheme
+		constraint string
+		version    string
+		strategy   RangeStrategy
+		expected   string
+	}{
+		// pin
+		{PEP440, ">=1.2,<2.0", "1.5.0", RangePin, "==1.5.0"},
+		{Poetry, "^1.2", "1.5.0", RangePin, "1.5.0"},
+		{NodeSemver, "^1.2.0", "1.5.0", RangePin, "1.5.0"},
+		{PEP440, "==1.2.0", "1.5.0", RangePin, "==1.5.0"},
+		{PEP440, "==2.0", "1.5.0", RangePin, "==2.0"},
+		{NodeSemver, "1.2.0", "1.5.0", RangeWiden, "1.5.0"},
+
+		// bump
+		{PEP440, ">=1.2", "1.5.0", RangeBump, ">=1.5.0"},
+		{PEP440, ">1.2, <2.0", "1.5.0", RangeBump, ">=1.5.0, <2.0"},
+		{PEP440, "~=1.4", "1.7.2", RangeBump, "~=1.7"},
+		{PEP440, "~=1.4.0", "1.7.2", RangeBump, "~=1.7.2"},
+		{PEP440, "==1.4.*", "1.4.7", RangeBump, "==1.4.*"},
+		{PEP440, ">=1.2,!=1.3.0", "1.5.0", RangeBump, ">=1.5.0,!=1.3.0"},
+		{PEP440, ">=2.0", "1.5.0", RangeBump, ">=2.0"},
+		{Poetry, "^1.2", "1.5.0", RangeBump, "^1.5.0"},
+		{Poetry, "~1.2", "1.2.7", RangeBump, "~1.2.7"},
+		{Poetry, "*", "1.5.0", RangeBump, "*"},
+		{NodeSemver, "^1.2.0", "1.5.0", RangeBump, "^1.5.0"},
+		{NodeSemver, "^1.2.0", "3.1.4", RangeBump, "^3.1.4"},
+		{NodeSemver, ">=1.2.0 <2.0.0", "1.5.0", RangeBump, ">=1.5.0 <2.0.0"},
+		{NodeSemver, "^0.9.0 || ^1.2.0", "1.5.0", RangeBump, "^0.9.0 || ^1.5.0"},
+		{NodeSemver, "1.x", "1.5.0", RangeBump, "1.x"},
+		{Loose, ">=2024.01", "2024.05", RangeBump, ">=2024.05"},
+
+		// widen
+		{PEP440, ">=1.2", "3.1.4", RangeWiden, ">=1.2"},
+		{PEP440, ">=1.2,<2.0", "3.1.4", RangeWiden, ">=1.2,<4.0"},
+		{PEP440, ">=1.2,<1.5", "1.7.2", RangeWiden, ">=1.2,<1.8"},
+		{PEP440, ">=1.2,<=2.0", "3.1.4", RangeWiden, ">=1.2,<=3.1.4"},
+		{PEP440, "~=1.4", "3.1.4", RangeWiden, ">=1.4,<4.0"},
+		{PEP440, "~=1.4.2", "1.7.0", RangeWiden, ">=1.4.2,<1.8.0"},
+		{PEP440, "==1.4.*", "3.1.4", RangeWiden, ">=1.4,<3.2"},
+		{Poetry, "^1.2", "3.1.4", RangeWiden, "^1.2 || ^3.1.4"},
+		{Poetry, ">=1.2,<2.0", "3.1.4", RangeWiden, ">=1.2,<4.0"},
+		{NodeSemver, "^1.2.0", "2.0.1", RangeWiden, "^1.2.0 || ^2.0.1"},
+		{NodeSemver, ">=1.2.0 <2.0.0", "3.1.4", RangeWiden, ">=1.2.0 <4.0.0"},
+		{NodeSemver, "1.0.0 - 2.0.0", "3.1.4", RangeWiden, ">=1.0.0 <=3.1.4"},
+		{Loose, ">=2023.01,<2024", "2024.05", RangeWiden, ">=2023.01,<2025"},
+
+		// replace
+		{PEP440, ">=1.2,<2.0", "1.5.0", RangeReplace, ">=1.2,<2.0"},
+		{PEP440, ">=1.2,<2.0", "3.1.4", RangeReplace, ">=3.1.4,<4.0"},
+		{PEP440, "~=1.4", "3.1.4", RangeReplace, "~=3.1"},
+		{PEP440, "==1.4.*", "3.1.4", RangeReplace, "==3.1.*"},
+		{Poetry, "^1.2", "3.1.4", RangeReplace, "^3.1.4"},
+		{Poetry, "^0.9 || ^1.2", "3.1.4", RangeReplace, "^3.1.4"},
+		{NodeSemver, "^1.2.0", "3.1.4", RangeReplace, "^3.1.4"},
+		{NodeSemver, "~1.2.0", "3.1.4", RangeReplace, "~3.1.4"},
+		{NodeSemver, "1.x", "3.1.4", RangeReplace, "3.x"},
+		{NodeSemver, "1.2", "3.1.4", RangeReplace, "3.1"},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.scheme.Parse(tt.version)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q) failed: %v", tt.scheme.Name(), tt.version, err)
+		}
+		got, err := tt.scheme.UpdateRange(tt.constraint, v, tt.strategy)
+		if err != nil {
+			t.Errorf("%s: UpdateRange(%q, %s, %s) failed: %v", tt.scheme.Name(), tt.constraint, tt.version, tt.strategy, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: UpdateRange(%q, %s, %s) = %q, want %q", tt.scheme.Name(), tt.constraint, tt.version, tt.strategy, got, tt.expected)
+		}
+	}
+}
+
+func TestUpdateRangeFails(t *testing.T) {
+	tests := []struct {
+		scheme     Scheme
+		constraint string
+		version    string
+		strategy   RangeStrategy
+	}{
+		// Bumping the floor does not lift an upper bound
+		{PEP440, ">=1.2,<2.0", "3.1.4", RangeBump},
+		// Exclusions are not dropped
+		{PEP440, ">=1.2,!=3.1.4", "3.1.4", RangeWiden},
+		{PEP440, "^1.2", "3.1.4", RangeWiden},
+	}
+
+	for _, tt := range tests {
+		v, _ := tt.scheme.Parse(tt.version)
+		if got, err := tt.scheme.UpdateRange(tt.constraint, v, tt.strategy); err == nil {
+			t.Errorf("%s: expected UpdateRange(%q, %s, %s) to fail, got %q", tt.scheme.Name(), tt.constraint, tt.version, tt.strategy, got)
+		}
+	}
+}
+
+func TestParseRangeStrategy(t *testing.T) {
+	for _, strategy := range RangeStrategies {
+		got, err := ParseRangeStrategy(string(strategy))
+		if err != nil || got != strategy {
+			t.Errorf("ParseRangeStrategy(%q) = %q, %v", strategy, got, err)
+		}
+	}
+	if _, err := ParseRangeStrategy("auto"); err == nil {
+		t.Error("expected ParseRangeStrategy(\"auto\") to fail")
+	}
+}
+
+func TestFloor(t *testing.T) {
+	tests := []struct {
+		scheme     Scheme
+		constraint string
+		expected   string
+	}{
+		{PEP440, ">=1.4,<2", "1.4"},
+		{PEP440, "~=2.2.1", "2.2.1"},
+		{PEP440, "==1.4.*", "1.4"},
+		{PEP440, "<2", ""},
+		{Poetry, "^1.2 || ^0.9", "0.9"},
+		{NodeSemver, "^1.2.0 || ~2.0.1", "1.2.0"},
+		{NodeSemver, "1.x", "1"},
+		{NodeSemver, "*", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Floor(tt.scheme, tt.constraint); got != tt.expected {
+			t.Errorf("%s: Floor(%q) = %q, want %q", tt.scheme.Name(), tt.constraint, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package versioning puts the version rules of the supported ecosystems
+// behind one interface, so code that compares versions or checks
+// constraints does not depend on a particular version library.
+package versioning
+
+import "fmt"
+
+// Version is a version parsed by a Scheme. Versions may only be passed back
+// to the scheme that parsed them.
+type Version interface {
+	String() string
+}
+
+// Level is the part of a version a release changes.
+type Level int
+
+const (
+	Patch Level = iota
+	Minor
+	Major
+)
+
+// String returns the name of the level, e.g. "minor".
+func (l Level) String() string {
+	switch l {
+	case Patch:
+		return "patch"
+	case Minor:
+		return "minor"
+	case Major:
+		return "major"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Scheme is the version format and constraint syntax of an ecosystem.
+type Scheme interface {
+	// Name identifies the scheme in messages, e.g. "pep440"
+	Name() string
+	// Parse parses a version
+	Parse(s string) (Version, error)
+	// Compare returns -1, 0 or 1 depending on whether a is lower than, equal
+	// to or higher than b
+	Compare(a, b Version) int
+	// Satisfies reports whether v is accepted by a constraint, following the
+	// ecosystem's rules for prereleases
+	Satisfies(v Version, constraint string) (bool, error)
+	// Bump returns the first version of the release line after the one of v
+	// at level, e.g. 1.3.0 for 1.2.3 at Minor. Prerelease and other
+	// qualifiers of v are dropped.
+	Bump(v Version, level Level) Version
+	// IsPrerelease reports whether v is a prerelease
+	IsPrerelease(v Version) bool
+	// UpdateRange rewrites a constraint for v following strategy. Exact
+	// versions are pins that only move up, whatever the strategy. It returns
+	// the constraint unchanged when the strategy has nothing to do, and an
+	// error when it cannot make the constraint accept v.
+	UpdateRange(constraint string, v Version, strategy RangeStrategy) (string, error)
+}
+
+// IsNewer reports whether candidate is higher than current in scheme s. It
+// reports false if either cannot be parsed.
+func IsNewer(s Scheme, candidate, current string) bool {
+	c, err := s.Parse(candidate)
+	if err != nil {
+		return false
+	}
+	v, err := s.Parse(current)
+	if err != nil {
+		return false
+	}
+	return s.Compare(c, v) > 0
+}
+
+// Compare compares two versions like the Compare method of scheme s. Versions
+// s cannot parse, such as the legacy "2024.05-hotfix" under PEP 440, are
+// compared with Loose instead. It reports false if not even Loose can parse
+// both.
+func Compare(s Scheme, a, b string) (int, bool) {
+	for _, scheme := range []Scheme{s, Loose} {
+		x, err := scheme.Parse(a)
+		if err != nil {
+			continue
+		}
+		y, err := scheme.Parse(b)
+		if err != nil {
+			continue
+		}
+		return scheme.Compare(x, y), true
+	}
+	return 0, false
+}
+
+// Valid reports whether v is a version of scheme s or, failing that, one
+// Loose can parse, so that Compare can order it.
+func Valid(s Scheme, v string) bool {
+	if _, err := s.Parse(v); err == nil {
+		return true
+	}
+	_, err := Loose.Parse(v)
+	return err == nil
+}
@@ -0,0 +1,62 @@
+package versioning
+
+import "testing"
+
+var benchmarkVersions = []string{
+	"1.0.0",
+	"2.31.0",
+	"3.14.15",
+	"10.20.30",
+	"0.0.1",
+	"1.2.3a1",
+	"2.0.0b1",
+	"3.0.0rc2",
+	"4.5.6+build.123",
+	"1.2.3.dev4+build.123",
+}
+
+var benchmarkConstraints = []string{
+	"==1.0.0",
+	">=2.0.0",
+	"<=3.0.0",
+	">1.0.0",
+	"<3.0.0",
+	"~=2.0.0",
+	">=1.0.0,<2.0.0",
+	">=1.0.0,<=2.0.0,!=1.5.0",
+	"1.0.0",
+}
+
+// BenchmarkParse benchmarks PEP 440 version parsing
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = PEP440.Parse(benchmarkVersions[i%len(benchmarkVersions)])
+	}
+}
+
+// BenchmarkCompare benchmarks PEP 440 version comparison
+func BenchmarkCompare(b *testing.B) {
+	b.ReportAllocs()
+	versions := make([]Version, len(benchmarkVersions))
+	for i, v := range benchmarkVersions {
+		versions[i], _ = PEP440.Parse(v)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		PEP440.Compare(versions[i%len(versions)], versions[(i+1)%len(versions)])
+	}
+}
+
+// BenchmarkSatisfies benchmarks PEP 440 constraint checks
+func BenchmarkSatisfies(b *testing.B) {
+	b.ReportAllocs()
+	versions := make([]Version, len(benchmarkVersions))
+	for i, v := range benchmarkVersions {
+		versions[i], _ = PEP440.Parse(v)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = PEP440.Satisfies(versions[i%len(versions)], benchmarkConstraints[i%len(benchmarkConstraints)])
+	}
+}
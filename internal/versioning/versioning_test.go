@@ -0,0 +1,203 @@
+package versioning
+
+import "testing"
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		scheme     Scheme
+		version    string
+		constraint string
+		expected   bool
+	}{
+		{PEP440, "1.2.3", "==1.2.3", true},
+		{PEP440, "1.2.3", "1.2.3", true},
+		{PEP440, "1.2.3", ">=1.0.0", true},
+		{PEP440, "1.2.3", "<1.0.0", false},
+		{PEP440, "2.3.0", "~=2.2", true},
+		{PEP440, "1.5.0", "~=1.4.2", false},
+		{PEP440, "1.5.3", ">=1.0,!=1.5.*", false},
+		{PEP440, "2.0.0rc1", ">=1.0", false},
+		{NodeSemver, "1.9.2", "^1.4.0", true},
+		{NodeSemver, "2.0.0", "^1.4.0", false},
+		{NodeSemver, "0.2.5", "^0.2.1", true},
+		{NodeSemver, "0.3.0", "^0.2.1", false},
+		{NodeSemver, "1.2.9", "~1.2.0", true},
+		{NodeSemver, "1.3.0", "~1.2.0", false},
+		{NodeSemver, "18.2.0", "^17.0.0 || ^18.0.0", true},
+		{NodeSemver, "1.5.0", "1.0.0 - 2.0.0", true},
+		{NodeSemver, "2.5.0", ">=1.0.0 <2.0.0", false},
+		{NodeSemver, "2.0.0-rc.1", "^1.0.0 || ^2.0.0", false},
+		{NodeSemver, "2.2", "2.x", true},
+		{Loose, "2024.05", ">=2024.01,<2025", true},
+		{Loose, "2024.05-hotfix", "==2024.05", false},
+		{Loose, "1.4", "!=1.4.0", false},
+		{Loose, "1.4.1", "1.4.1", true},
+		{Poetry, "1.9.0", "^1.2", true},
+		{Poetry, "2.0.0", "^1.2", false},
+		{Poetry, "0.0.4", "^0.0.3", false},
+		{Poetry, "1.2.9", "~1.2", true},
+		{Poetry, "2.1.0", "^1.2 || >=2.1,<3", true},
+		{Poetry, "1.4.2", ">= 1.4 < 1.5", true},
+		{Poetry, "1.4.2", "1.4.*", true},
+		{Poetry, "5.0", "*", true},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.scheme.Parse(tt.version)
+		if err != nil {
+			t.Errorf("%s: Parse(%q) failed: %v", tt.scheme.Name(), tt.version, err)
+			continue
+		}
+		got, err := tt.scheme.Satisfies(v, tt.constraint)
+		if err != nil {
+			t.Errorf("%s: Satisfies(%s, %q) failed: %v", tt.scheme.Name(), tt.version, tt.constraint, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: Satisfies(%s, %q) = %v, want %v", tt.scheme.Name(), tt.version, tt.constraint, got, tt.expected)
+		}
+	}
+}
+
+func TestSatisfiesInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		scheme     Scheme
+		constraint string
+	}{
+		{PEP440, "^1.0"},
+		{PEP440, "~=1"},
+		{NodeSemver, ">=1.0,<2.0"},
+		{Loose, "~=1.0"},
+	} {
+		v, _ := tt.scheme.Parse("1.0.0")
+		if _, err := tt.scheme.Satisfies(v, tt.constraint); err == nil {
+			t.Errorf("%s: expected Satisfies(%q) to fail", tt.scheme.Name(), tt.constraint)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		a, b     string
+		expected int
+	}{
+		{PEP440, "1.0", "1.0.0", 0},
+		{PEP440, "1.0rc1", "1.0", -1},
+		{PEP440, "1.0.post1", "1.0", 1},
+		{PEP440, "1!0.1", "2.0", 1},
+		{NodeSemver, "1.2", "1.2.0", 0},
+		{NodeSemver, "1.0.0-rc.1", "1.0.0", -1},
+		{NodeSemver, "1.10.0", "1.9.0", 1},
+		{Loose, "1.10", "1.9", 1},
+		{Loose, "1.0-beta", "1.0", -1},
+		{Loose, "1.0+build.5", "1.0", 0},
+		{Loose, "1.0-alpha", "1.0-beta", -1},
+	}
+
+	for _, tt := range tests {
+		a, errA := tt.scheme.Parse(tt.a)
+		b, errB := tt.scheme.Parse(tt.b)
+		if errA != nil || errB != nil {
+			t.Errorf("%s: cannot parse %s or %s", tt.scheme.Name(), tt.a, tt.b)
+			continue
+		}
+		if got := tt.scheme.Compare(a, b); got != tt.expected {
+			t.Errorf("%s: Compare(%s, %s) = %d, want %d", tt.scheme.Name(), tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestBump(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		version  string
+		level    Level
+		expected string
+	}{
+		{PEP440, "1.2.3", Major, "2.0.0"},
+		{PEP440, "1.2.3", Minor, "1.3.0"},
+		{PEP440, "1.2.3", Patch, "1.2.4"},
+		{PEP440, "1", Patch, "1.0.1"},
+		{PEP440, "1!2.0rc1", Minor, "1!2.1"},
+		{NodeSemver, "1.2.3-beta.1", Major, "2.0.0"},
+		{NodeSemver, "1.2.3", Minor, "1.3.0"},
+		{NodeSemver, "1.2.3", Patch, "1.2.4"},
+		{Loose, "2024.05-hotfix", Minor, "2024.6.0"},
+		{Loose, "1.2.3.4", Patch, "1.2.4.0"},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.scheme.Parse(tt.version)
+		if err != nil {
+			t.Errorf("%s: Parse(%q) failed: %v", tt.scheme.Name(), tt.version, err)
+			continue
+		}
+		if got := tt.scheme.Bump(v, tt.level).String(); got != tt.expected {
+			t.Errorf("%s: Bump(%s, %s) = %s, want %s", tt.scheme.Name(), tt.version, tt.level, got, tt.expected)
+		}
+	}
+}
+
+func TestIsPrerelease(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		version  string
+		expected bool
+	}{
+		{PEP440, "1.0", false},
+		{PEP440, "1.0.dev1", true},
+		{PEP440, "1.0.post1", false},
+		{NodeSemver, "1.0.0-rc.1", true},
+		{NodeSemver, "1.0.0+build", false},
+		{Loose, "1.0b2", true},
+		{Loose, "1.0+build", false},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.scheme.Parse(tt.version)
+		if err != nil {
+			t.Errorf("%s: Parse(%q) failed: %v", tt.scheme.Name(), tt.version, err)
+			continue
+		}
+		if got := tt.scheme.IsPrerelease(v); got != tt.expected {
+			t.Errorf("%s: IsPrerelease(%s) = %v, want %v", tt.scheme.Name(), tt.version, got, tt.expected)
+		}
+	}
+}
+
+func TestIsNewer(t *testing.T) {
+	if !IsNewer(PEP440, "2.0", "1.9.9") {
+		t.Error("expected 2.0 to be newer than 1.9.9")
+	}
+	if IsNewer(PEP440, "1.0", "1.0.0") {
+		t.Error("expected 1.0 not to be newer than 1.0.0")
+	}
+	if IsNewer(PEP440, "2.0", "invalid") {
+		t.Error("expected an unparsable version never to be older")
+	}
+}
+
+func TestCompareFallsBackToLoose(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+		ok       bool
+	}{
+		{"1.10", "1.9", 1, true},
+		{"2.0.0rc1", "2.0.0", -1, true},
+		{"2024.05-hotfix", "2024.04", 1, true},
+		{"2024.05-hotfix", "2024.05", -1, true},
+		{"1.0", "${VERSION}", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := Compare(PEP440, tt.a, tt.b)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("Compare(PEP440, %q, %q) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.expected, tt.ok)
+		}
+	}
+
+	if !Valid(PEP440, "2024.05-hotfix") || Valid(PEP440, "${VERSION}") {
+		t.Error("expected only versions Loose can parse to be valid")
+	}
+}